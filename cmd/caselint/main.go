@@ -0,0 +1,122 @@
+// Command caselint checks case bundles for problems before they're imported.
+//
+// Usage:
+//
+//	caselint [-format text|json] [-static dir] [-remote] [-strict] [bundle root directory]...
+//
+// Each argument is a directory containing bundle directories like ui/cases, which is also the default. The
+// diagnostics are printed one per line as "file:line: severity: path: message [code]" or, with -format json, as a
+// JSON array for tooling. The command exits with status 1 if there are errors, or warnings with -strict, so that it
+// can gate content changes in CI.
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"flag"
+	"fmt"
+	"github.com/myrjola/sheerluck/internal/casebundle"
+	"github.com/myrjola/sheerluck/internal/errors"
+	"github.com/myrjola/sheerluck/internal/logging"
+	"io"
+	"log/slog"
+	"net/http"
+	"os"
+	"path/filepath"
+	"time"
+)
+
+// errFailed is returned when the bundles have problems that should fail the check.
+var errFailed = errors.NewSentinel("case bundles have problems")
+
+func main() {
+	ctx := context.Background()
+	logger := slog.New(logging.NewContextHandler(slog.NewTextHandler(os.Stderr, &slog.HandlerOptions{
+		AddSource:   false,
+		Level:       slog.LevelWarn,
+		ReplaceAttr: nil,
+	})))
+	if err := run(ctx, os.Args[1:], os.Stdout); err != nil {
+		if !errors.Is(err, errFailed) {
+			logger.LogAttrs(ctx, slog.LevelError, "failure linting cases", errors.SlogError(err))
+		}
+		os.Exit(1)
+	}
+}
+
+func run(ctx context.Context, args []string, out io.Writer) error {
+	var (
+		format    string
+		staticDir string
+		remote    bool
+		strict    bool
+		err       error
+		flags     = flag.NewFlagSet("caselint", flag.ContinueOnError)
+	)
+	flags.StringVar(&format, "format", "text", "output format: text or json")
+	flags.StringVar(&staticDir, "static", "ui/static", "directory of the static files served by the web server")
+	flags.BoolVar(&remote, "remote", false, "check that the images referenced by URL exist")
+	flags.BoolVar(&strict, "strict", false, "fail on warnings too")
+	if err = flags.Parse(args); err != nil {
+		return errors.Wrap(err, "parse flags")
+	}
+	if format != "text" && format != "json" {
+		return errors.New("unknown format", slog.String("format", format))
+	}
+	roots := flags.Args()
+	if len(roots) == 0 {
+		roots = []string{"ui/cases"}
+	}
+
+	var bundles []*casebundle.Bundle
+	for _, root := range roots {
+		var loaded []*casebundle.Bundle
+		if loaded, err = casebundle.LoadAll(os.DirFS(root)); err != nil {
+			return errors.Wrap(err, "load bundles", slog.String("root", root))
+		}
+		for _, bundle := range loaded {
+			// Report the manifest paths relative to the working directory.
+			bundle.Dir = filepath.Join(root, bundle.Dir)
+		}
+		bundles = append(bundles, loaded...)
+	}
+
+	options := casebundle.LintOptions{
+		Static:     os.DirFS(staticDir),
+		HTTPClient: nil,
+	}
+	if remote {
+		options.HTTPClient = &http.Client{Timeout: 10 * time.Second} //nolint:exhaustruct,mnd // defaults are fine.
+	}
+	diagnostics := casebundle.Lint(ctx, bundles, options)
+
+	if err = printDiagnostics(out, format, diagnostics); err != nil {
+		return errors.Wrap(err, "print diagnostics")
+	}
+	for _, diagnostic := range diagnostics {
+		if diagnostic.Severity == casebundle.SeverityError || strict {
+			return errors.Wrap(errFailed, "lint", slog.Int("diagnostics", len(diagnostics)))
+		}
+	}
+	return nil
+}
+
+func printDiagnostics(out io.Writer, format string, diagnostics []casebundle.Diagnostic) error {
+	if format == "json" {
+		if diagnostics == nil {
+			diagnostics = []casebundle.Diagnostic{}
+		}
+		encoder := json.NewEncoder(out)
+		encoder.SetIndent("", "  ")
+		if err := encoder.Encode(diagnostics); err != nil {
+			return errors.Wrap(err, "encode json")
+		}
+		return nil
+	}
+	for _, diagnostic := range diagnostics {
+		if _, err := fmt.Fprintln(out, diagnostic); err != nil {
+			return errors.Wrap(err, "write diagnostic")
+		}
+	}
+	return nil
+}
@@ -0,0 +1,85 @@
+// Command importcase imports case bundles into the database and prints the changes.
+//
+// Usage:
+//
+//	importcase [-prune] [-dry-run] <bundle directory>...
+//
+// The database is read from the SHEERLUCK_SQLITE_URL environment variable and defaults to ./sheerluck.sqlite3 like
+// the web server. Use -dry-run to review the changes before applying them and -prune to remove the content deleted
+// from the bundles together with the players' progress related to it.
+package main
+
+import (
+	"context"
+	"flag"
+	"fmt"
+	"github.com/myrjola/sheerluck/internal/casebundle"
+	"github.com/myrjola/sheerluck/internal/errors"
+	"github.com/myrjola/sheerluck/internal/logging"
+	"github.com/myrjola/sheerluck/internal/sqlite"
+	"io"
+	"log/slog"
+	"os"
+	"path/filepath"
+)
+
+func main() {
+	ctx := context.Background()
+	logger := slog.New(logging.NewContextHandler(slog.NewTextHandler(os.Stderr, &slog.HandlerOptions{
+		AddSource:   false,
+		Level:       slog.LevelWarn,
+		ReplaceAttr: nil,
+	})))
+	if err := run(ctx, logger, os.Args[1:], os.Stdout); err != nil {
+		logger.LogAttrs(ctx, slog.LevelError, "failure importing cases", errors.SlogError(err))
+		os.Exit(1)
+	}
+}
+
+func run(ctx context.Context, logger *slog.Logger, args []string, out io.Writer) error {
+	var (
+		options casebundle.ImportOptions
+		err     error
+		flags   = flag.NewFlagSet("importcase", flag.ContinueOnError)
+	)
+	flags.BoolVar(&options.Prune, "prune", false, "remove content deleted from the bundles")
+	flags.BoolVar(&options.DryRun, "dry-run", false, "print the changes without applying them")
+	if err = flags.Parse(args); err != nil {
+		return errors.Wrap(err, "parse flags")
+	}
+	if flags.NArg() == 0 {
+		return errors.New("usage: importcase [-prune] [-dry-run] <bundle directory>...")
+	}
+
+	sqliteURL, ok := os.LookupEnv("SHEERLUCK_SQLITE_URL")
+	if !ok {
+		sqliteURL = "./sheerluck.sqlite3"
+	}
+	var db *sqlite.Database
+	if db, err = sqlite.NewDatabase(ctx, sqliteURL, logger); err != nil {
+		return errors.Wrap(err, "open db", slog.String("url", sqliteURL))
+	}
+	importer := casebundle.NewImporter(db, logger)
+
+	for _, dir := range flags.Args() {
+		var (
+			bundle *casebundle.Bundle
+			diff   *casebundle.Diff
+		)
+		if bundle, err = casebundle.Load(os.DirFS(filepath.Dir(dir)), filepath.Base(dir)); err != nil {
+			return errors.Wrap(err, "load bundle", slog.String("dir", dir))
+		}
+		if diff, err = importer.Import(ctx, bundle, options); err != nil {
+			return errors.Wrap(err, "import bundle", slog.String("dir", dir))
+		}
+		if _, err = fmt.Fprintf(out, "%s: %d unchanged\n", diff.CaseID, diff.Unchanged); err != nil {
+			return errors.Wrap(err, "print diff")
+		}
+		for _, difference := range diff.Differences {
+			if _, err = fmt.Fprintf(out, "  %s\n", difference); err != nil {
+				return errors.Wrap(err, "print diff")
+			}
+		}
+	}
+	return nil
+}
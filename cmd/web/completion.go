@@ -0,0 +1,309 @@
+package main
+
+import (
+	"context"
+	"database/sql"
+	"fmt"
+	"github.com/myrjola/sheerluck/internal/ai"
+	"github.com/myrjola/sheerluck/internal/errors"
+	"github.com/myrjola/sheerluck/internal/guard"
+	"github.com/myrjola/sheerluck/internal/models"
+	"io"
+	"log/slog"
+	"strings"
+	"time"
+	"unicode/utf8"
+)
+
+const (
+	// maxAnswerLength mirrors the length check of the completions table.
+	maxAnswerLength = 2055
+	// maxSummaryLength mirrors the length check of the completions table.
+	maxSummaryLength = 4095
+	// completionTimeout bounds how long we wait for the model to finish its answer.
+	completionTimeout = 2 * time.Minute
+	// classificationTimeout bounds how long the question guard's model classifies the question. The question is
+	// answered if the classification takes longer.
+	classificationTimeout = 10 * time.Second
+	// consumerTimeout is how long the producer waits for the SSE consumer to read a chunk before it stops streaming
+	// and only persists the answer.
+	consumerTimeout = 10 * time.Second
+)
+
+// completionJob holds what is needed to answer a question in the background.
+type completionJob struct {
+	completionID int64
+	userID       []byte
+	inquiry      models.Inquiry
+	// investigation is the investigation the question is asked in. The question guard classifies the question in it.
+	investigation *models.Investigation
+	systemPrompt  string
+	// summaryInstructions is the system prompt for summarising the history when it doesn't fit the context window.
+	summaryInstructions string
+	// deflection is the in-character answer to a question the question guard flags.
+	deflection string
+	// offTopic is set when the question guard's heuristics flagged the question as off-topic. The question is
+	// deflected without asking the model.
+	offTopic bool
+	// history is the done completions preceding the question.
+	history []models.Completion
+	// candidateClues are the clues of the investigation target the player hasn't discovered yet and can discover with
+	// the inquiry.
+	candidateClues []models.Clue
+}
+
+// startCompletion publishes the completion's answer stream in the completion broker and produces the answer in the
+// background. The first subscriber receives the answer chunk by chunk. The rest wait for the answer to be persisted.
+func (app *application) startCompletion(ctx context.Context, job completionJob) {
+	completionID := job.completionID
+	// Unbuffered so that the producer notices when nobody is listening.
+	channel := make(chan string)
+	app.completionBroker.Publish(completionID, channel)
+
+	// Detach the completion from the request so that the answer is persisted after the response is sent.
+	ctx, cancel := context.WithTimeout(context.WithoutCancel(ctx), completionTimeout)
+	go func() {
+		defer func() {
+			if excp := recover(); excp != nil {
+				err := errors.DecoratePanic(excp)
+				app.logger.LogAttrs(ctx, slog.LevelError, "completion producer panicked", errors.SlogError(err))
+			}
+		}()
+		defer cancel()
+		defer app.completionBroker.Unpublish(completionID)
+		defer close(channel)
+
+		consumerGone := false
+		onChunk := func(chunk string) {
+			if consumerGone {
+				return
+			}
+			select {
+			case channel <- chunk:
+			case <-time.After(consumerTimeout):
+				// Consumers arriving later will read the persisted answer.
+				app.logger.LogAttrs(ctx, slog.LevelDebug, "completion consumer gone")
+				consumerGone = true
+			}
+		}
+		if err := app.runCompletion(ctx, job, onChunk); err != nil {
+			err = errors.Wrap(err, "run completion", slog.Int64("completion_id", completionID))
+			app.logger.LogAttrs(ctx, slog.LevelError, "completion failed", errors.SlogError(err))
+		}
+	}()
+}
+
+// runCompletion drives a created completion through its lifecycle described in spec/completion.tla.
+//
+// It classifies the question with the question guard's model, fits the job's conversation into the context window or
+// deflects the flagged question, streams the answer from the AI provider, passes every chunk
+// to onChunk, persists the full answer, records the clues the answer reveals, and unlocks the chapters whose
+// prerequisites the player has discovered. Finally, it notifies the player's party about the answer. The completion is
+// marked as failed if the answer can't be completed.
+func (app *application) runCompletion(ctx context.Context, job completionJob, onChunk func(chunk string)) error {
+	var err error
+	if err = app.investigations.StartStreaming(ctx, job.completionID, job.userID); err != nil {
+		return errors.Wrap(err, "start streaming")
+	}
+
+	var (
+		messages []ai.Message
+		answer   string
+	)
+	deflected := job.offTopic || app.classify(ctx, job) != guard.VerdictInGame
+	if deflected {
+		answer = job.deflection
+		onChunk(answer)
+	} else if messages, err = app.conversationMessages(ctx, job); err == nil {
+		answer, err = app.streamAnswer(ctx, messages, onChunk)
+	}
+	if err != nil {
+		err = errors.Wrap(err, "answer")
+		if failErr := app.investigations.FailCompletion(ctx, job.completionID, job.userID); failErr != nil {
+			err = errors.Join(err, errors.Wrap(failErr, "fail completion"))
+		}
+		return err
+	}
+
+	if err = app.investigations.FinishCompletion(ctx, job.completionID, job.userID, answer); err != nil {
+		return errors.Wrap(err, "finish completion")
+	}
+
+	// Deflections reveal nothing. The answer stands even if the clue detection fails. The clues can be discovered again
+	// with another question.
+	if !deflected {
+		if err = app.discoverClues(ctx, job, answer); err != nil {
+			err = errors.Wrap(err, "discover clues", slog.Int64("completion_id", job.completionID))
+			app.logger.LogAttrs(ctx, slog.LevelError, "clue discovery failed", errors.SlogError(err))
+		}
+	}
+
+	// The unlock rules are evaluated after every completion, since switching branches can bring back discoveries too.
+	// A failed evaluation is retried after the next completion.
+	var chapterIDs []string
+	if chapterIDs, err = app.investigations.UnlockChapters(ctx, job.completionID, job.userID); err != nil {
+		err = errors.Wrap(err, "unlock chapters", slog.Int64("completion_id", job.completionID))
+		app.logger.LogAttrs(ctx, slog.LevelError, "chapter unlocking failed", errors.SlogError(err))
+	} else if len(chapterIDs) > 0 {
+		app.logger.LogAttrs(ctx, slog.LevelInfo, "chapters unlocked", slog.Any("chapter_ids", chapterIDs))
+	}
+
+	app.notifyParty(ctx, job.completionID)
+	return nil
+}
+
+// classify classifies the question the heuristics let through with the question guard's model. It runs in the
+// background, since the model doesn't answer within the request's deadline. A question flagged by the model has
+// already been stored, so it's deflected in character whether it's off-topic or an injection attempt.
+func (app *application) classify(ctx context.Context, job completionJob) guard.Verdict {
+	if job.inquiry.Examined != nil {
+		return guard.VerdictInGame
+	}
+	ctx, cancel := context.WithTimeout(ctx, classificationTimeout)
+	defer cancel()
+	classification, err := app.questionGuard.Classify(ctx, job.investigation, job.inquiry.Question)
+	if err != nil {
+		// The heuristics passed and the character prompts guard against injections as well, so answer the question.
+		err = errors.Wrap(err, "classify question", slog.Int64("completion_id", job.completionID))
+		app.logger.LogAttrs(ctx, slog.LevelWarn, "could not classify question", errors.SlogError(err))
+		return guard.VerdictInGame
+	}
+	if classification.Verdict != guard.VerdictInGame {
+		app.logFlaggedQuestion(ctx, job.investigation, job.inquiry, classification)
+	}
+	return classification.Verdict
+}
+
+// notifyParty tells the members of the party the completion was asked in that there's a new answer. Completions asked
+// outside a party notify nobody.
+func (app *application) notifyParty(ctx context.Context, completionID int64) {
+	partyID, err := app.parties.GetCompletionPartyID(ctx, completionID)
+	if errors.Is(err, sql.ErrNoRows) {
+		return
+	}
+	if err != nil {
+		// The members see the answer on the next page load anyway.
+		app.logger.LogAttrs(ctx, slog.LevelWarn, "could not notify party", errors.SlogError(err))
+		return
+	}
+	app.partyHub.Publish(partyID, partyEventCompletion)
+}
+
+// conversationMessages fits the job's conversation into the context window of the model. Older parts of the history
+// are summarised and the new summary is persisted, so that it doesn't have to be recomputed for the next question.
+func (app *application) conversationMessages(ctx context.Context, job completionJob) ([]ai.Message, error) {
+	turns := make([]ai.Turn, 0, len(job.history))
+	for _, completion := range job.history {
+		turns = append(turns, ai.Turn{
+			Question: withEvidence(completion.Question, completion.Evidence),
+			Answer:   completion.Answer,
+			Summary:  completion.Summary,
+		})
+	}
+	fitted, err := app.historyWindow.Fit(ctx, ai.Conversation{
+		System:              job.systemPrompt,
+		SummaryInstructions: job.summaryInstructions,
+		Turns:               turns,
+		Question:            withEvidence(job.inquiry.Question, job.inquiry.Evidence),
+	})
+	if err != nil {
+		return nil, errors.Wrap(err, "fit conversation")
+	}
+	if fitted.NewSummary {
+		summarized := job.history[fitted.SummarizedTurns-1]
+		summary := truncate(fitted.Summary, maxSummaryLength)
+		if err = app.investigations.SaveSummary(ctx, summarized.ID, job.userID, summary); err != nil {
+			// Not fatal, the summary is recomputed for the next question.
+			err = errors.Wrap(err, "save summary", slog.Int64("summarized_completion_id", summarized.ID))
+			app.logger.LogAttrs(ctx, slog.LevelWarn, "could not save summary", errors.SlogError(err))
+		}
+	}
+	return fitted.Messages, nil
+}
+
+// withEvidence prefixes the question with the evidence presented with it in square brackets, which the person prompt
+// tells the model to react to.
+func withEvidence(question string, evidence *models.Evidence) string {
+	switch {
+	case evidence == nil:
+		return question
+	case evidence.Clue != nil:
+		return fmt.Sprintf("[The detective confronts you with evidence: %s]\n%s", evidence.Clue.Description, question)
+	case evidence.Statement != nil:
+		statement := evidence.Statement
+		return fmt.Sprintf("[The detective confronts you with the statement of %s. Asked %q, %s answered: %q]\n%s",
+			statement.Target.Name, statement.Question, statement.Target.ShortName, statement.Answer, question)
+	default:
+		return question
+	}
+}
+
+// truncate cuts s to at most maxBytes bytes without splitting a multibyte character.
+func truncate(s string, maxBytes int) string {
+	if len(s) <= maxBytes {
+		return s
+	}
+	for maxBytes > 0 && !utf8.RuneStart(s[maxBytes]) {
+		maxBytes--
+	}
+	return s[:maxBytes]
+}
+
+// discoverClues records the candidate clues revealed by the answer.
+func (app *application) discoverClues(ctx context.Context, job completionJob, answer string) error {
+	if len(job.candidateClues) == 0 {
+		return nil
+	}
+	clues, err := app.clueDetector.Detect(ctx, job.candidateClues, job.inquiry.Question, answer)
+	if err != nil {
+		return errors.Wrap(err, "detect clues")
+	}
+	if len(clues) == 0 {
+		return nil
+	}
+	clueIDs := make([]string, 0, len(clues))
+	for _, clue := range clues {
+		clueIDs = append(clueIDs, clue.ID)
+	}
+	if err = app.investigations.DiscoverClues(ctx, job.completionID, job.userID, clueIDs); err != nil {
+		return errors.Wrap(err, "store discovered clues")
+	}
+	app.logger.LogAttrs(ctx, slog.LevelInfo, "clues discovered", slog.Any("clue_ids", clueIDs))
+	return nil
+}
+
+// streamAnswer streams the answer to messages chunk by chunk to onChunk and returns the full answer.
+func (app *application) streamAnswer(
+	ctx context.Context,
+	messages []ai.Message,
+	onChunk func(chunk string),
+) (string, error) {
+	stream, err := app.aiProvider.Stream(ctx, messages)
+	if err != nil {
+		return "", errors.Wrap(err, "start completion stream")
+	}
+	defer func() {
+		if err = stream.Close(); err != nil {
+			err = errors.Wrap(err, "close completion stream")
+			app.logger.LogAttrs(ctx, slog.LevelWarn, "could not close completion stream", errors.SlogError(err))
+		}
+	}()
+
+	var answer strings.Builder
+	for {
+		var chunk string
+		if chunk, err = stream.Recv(); errors.Is(err, io.EOF) {
+			break
+		}
+		if err != nil {
+			return "", errors.Wrap(err, "receive completion chunk")
+		}
+		if answer.Len()+len(chunk) > maxAnswerLength {
+			app.logger.LogAttrs(ctx, slog.LevelWarn, "truncating too long answer")
+			break
+		}
+		answer.WriteString(chunk)
+		onChunk(chunk)
+	}
+	return answer.String(), nil
+}
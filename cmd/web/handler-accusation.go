@@ -0,0 +1,224 @@
+package main
+
+import (
+	"database/sql"
+	"fmt"
+	"github.com/myrjola/sheerluck/internal/contexthelpers"
+	"github.com/myrjola/sheerluck/internal/errors"
+	"github.com/myrjola/sheerluck/internal/models"
+	"github.com/myrjola/sheerluck/internal/repositories"
+	"github.com/myrjola/sheerluck/internal/scoring"
+	"log/slog"
+	"net/http"
+	"net/url"
+	"strings"
+	"time"
+)
+
+// maxMotiveLength mirrors the length check of the accusations table.
+const maxMotiveLength = 1023
+
+type accuseTemplateData struct {
+	BaseTemplateData
+
+	Case     models.Case
+	Suspects []models.Suspect
+	// DiscoveredClues are the clues the player can present to support the accusation.
+	DiscoveredClues []models.DiscoveredClue
+	// Questions is the number of questions asked so far.
+	Questions int
+	// Hints is the number of hints taken so far.
+	Hints int
+}
+
+type accusationResultTemplateData struct {
+	BaseTemplateData
+
+	Case       models.Case
+	Accusation models.Accusation
+	Solution   models.CaseSolution
+	// MissedClues are the clues supporting the solution the player didn't present.
+	MissedClues []models.Clue
+	// Shares are the links sharing a replay of the solved case.
+	Shares []shareLink
+}
+
+// accusationPath returns the path of the accusation page of the request's case.
+func accusationPath(r *http.Request) string {
+	return fmt.Sprintf("/cases/%s/accusation", url.PathEscape(r.PathValue("caseID")))
+}
+
+// totalQuestions sums the questions asked from all the investigation targets of the case.
+func totalQuestions(overview *models.CaseOverview) int {
+	questions := 0
+	for _, target := range overview.Targets {
+		questions += target.Questions
+	}
+	return questions
+}
+
+// renderAccusationResult shows the graded accusation next to the solution.
+func (app *application) renderAccusationResult(
+	w http.ResponseWriter,
+	r *http.Request,
+	overview *models.CaseOverview,
+	solution *models.CaseSolution,
+	accusation *models.Accusation,
+) {
+	userID := contexthelpers.AuthenticatedUserID(r.Context())
+	presented := make(map[string]bool, len(accusation.Clues))
+	for _, clue := range accusation.Clues {
+		presented[clue.Clue.ID] = true
+	}
+	var missed []models.Clue
+	for _, clue := range solution.Clues {
+		if !presented[clue.ID] {
+			missed = append(missed, clue)
+		}
+	}
+	shares, err := app.listShareLinks(r, overview.Case.ID, userID)
+	if err != nil {
+		app.serverError(w, r, errors.Wrap(err, "list share links", slog.String("case_id", overview.Case.ID)))
+		return
+	}
+	app.render(w, r, http.StatusOK, "accusationresult", accusationResultTemplateData{
+		BaseTemplateData: newBaseTemplateData(r),
+		Case:             overview.Case,
+		Accusation:       *accusation,
+		Solution:         *solution,
+		MissedClues:      missed,
+		Shares:           shares,
+	})
+}
+
+// accusationGET shows the accusation form or, if the player has already accused, the result of the accusation.
+func (app *application) accusationGET(w http.ResponseWriter, r *http.Request) {
+	ctx := r.Context()
+	userID := contexthelpers.AuthenticatedUserID(ctx)
+	caseID := r.PathValue("caseID")
+	overview, err := app.cases.Get(ctx, caseID, userID)
+	if errors.Is(err, sql.ErrNoRows) {
+		http.NotFound(w, r)
+		return
+	}
+	if err != nil {
+		app.serverError(w, r, errors.Wrap(err, "get case overview", slog.String("case_id", caseID)))
+		return
+	}
+	solution, err := app.accusations.GetSolution(ctx, caseID)
+	if errors.Is(err, sql.ErrNoRows) {
+		// Cases without a solution can't be solved.
+		http.NotFound(w, r)
+		return
+	}
+	if err != nil {
+		app.serverError(w, r, errors.Wrap(err, "get solution", slog.String("case_id", caseID)))
+		return
+	}
+
+	accusation, err := app.accusations.Get(ctx, caseID, userID)
+	if err == nil {
+		app.renderAccusationResult(w, r, overview, solution, accusation)
+		return
+	}
+	if !errors.Is(err, sql.ErrNoRows) {
+		app.serverError(w, r, errors.Wrap(err, "get accusation", slog.String("case_id", caseID)))
+		return
+	}
+
+	suspects, err := app.accusations.ListSuspects(ctx, caseID)
+	if err != nil {
+		app.serverError(w, r, errors.Wrap(err, "list suspects", slog.String("case_id", caseID)))
+		return
+	}
+	app.render(w, r, http.StatusOK, "accuse", accuseTemplateData{
+		BaseTemplateData: newBaseTemplateData(r),
+		Case:             overview.Case,
+		Suspects:         suspects,
+		DiscoveredClues:  overview.DiscoveredClues,
+		Questions:        totalQuestions(overview),
+		Hints:            len(overview.Hints),
+	})
+}
+
+// accusationPOST grades and stores the player's accusation, which solves the case.
+func (app *application) accusationPOST(w http.ResponseWriter, r *http.Request) {
+	ctx := r.Context()
+	userID := contexthelpers.AuthenticatedUserID(ctx)
+	caseID := r.PathValue("caseID")
+	culpritID := r.PostFormValue("culprit")
+	motive := strings.TrimSpace(r.PostFormValue("motive"))
+	if motive == "" || len(motive) > maxMotiveLength {
+		http.Error(w, http.StatusText(http.StatusBadRequest), http.StatusBadRequest)
+		return
+	}
+	overview, err := app.cases.Get(ctx, caseID, userID)
+	if errors.Is(err, sql.ErrNoRows) {
+		http.NotFound(w, r)
+		return
+	}
+	if err != nil {
+		app.serverError(w, r, errors.Wrap(err, "get case overview", slog.String("case_id", caseID)))
+		return
+	}
+	solution, err := app.accusations.GetSolution(ctx, caseID)
+	if errors.Is(err, sql.ErrNoRows) {
+		http.NotFound(w, r)
+		return
+	}
+	if err != nil {
+		app.serverError(w, r, errors.Wrap(err, "get solution", slog.String("case_id", caseID)))
+		return
+	}
+	suspects, err := app.accusations.ListSuspects(ctx, caseID)
+	if err != nil {
+		app.serverError(w, r, errors.Wrap(err, "list suspects", slog.String("case_id", caseID)))
+		return
+	}
+
+	accusation := models.Accusation{
+		ID:             0,
+		CaseID:         caseID,
+		Culprit:        models.Suspect{ID: "", Name: "", Description: ""},
+		Motive:         motive,
+		Clues:          nil,
+		Questions:      totalQuestions(overview),
+		Hints:          len(overview.Hints),
+		CulpritCorrect: false,
+		MotiveCorrect:  false,
+		Score:          0,
+		Created:        time.Time{},
+	}
+	for _, suspect := range suspects {
+		if suspect.ID == culpritID {
+			accusation.Culprit = suspect
+		}
+	}
+	if accusation.Culprit.ID == "" {
+		http.Error(w, http.StatusText(http.StatusBadRequest), http.StatusBadRequest)
+		return
+	}
+	// Only discovered clues can be presented.
+	discovered := make(map[string]models.Clue, len(overview.DiscoveredClues))
+	for _, discoveredClue := range overview.DiscoveredClues {
+		discovered[discoveredClue.Clue.ID] = discoveredClue.Clue
+	}
+	for _, clueID := range r.PostForm["clue"] {
+		clue, ok := discovered[clueID]
+		if !ok {
+			http.Error(w, http.StatusText(http.StatusBadRequest), http.StatusBadRequest)
+			return
+		}
+		delete(discovered, clueID)
+		accusation.Clues = append(accusation.Clues, models.AccusedClue{Clue: clue, Correct: false})
+	}
+
+	scoring.Grade(solution, &accusation)
+	if _, err = app.accusations.Create(ctx, userID, &accusation); err != nil &&
+		!errors.Is(err, repositories.ErrAlreadyAccused) {
+		app.serverError(w, r, errors.Wrap(err, "create accusation", slog.String("case_id", caseID)))
+		return
+	}
+	// A repeated accusation, e.g., from another tab, shows the result of the first one.
+	http.Redirect(w, r, accusationPath(r), http.StatusSeeOther)
+}
@@ -0,0 +1,68 @@
+package main
+
+import (
+	"context"
+	"github.com/myrjola/sheerluck/internal/e2etest"
+	"github.com/stretchr/testify/require"
+	"net/http"
+	"net/url"
+	"os"
+	"testing"
+)
+
+func Test_application_accusation(t *testing.T) {
+	ctx := context.Background()
+	server, err := e2etest.StartServer(ctx, os.Stdout, testLookupEnv, run)
+	require.NoError(t, err)
+	client := server.Client()
+	_, err = client.Register(ctx)
+	require.NoError(t, err)
+
+	// Discover a clue supporting the solution and another one that doesn't.
+	investigationPath := "/cases/rue-morgue/investigation-targets/le-bon"
+	askQuestion(ctx, t, client, investigationPath, "Did you loan the victims money?")
+	askQuestion(ctx, t, client, investigationPath, "Where did you get the gold watch?")
+
+	accusationPath := "/cases/rue-morgue/accusation"
+	doc, err := client.GetDoc(ctx, accusationPath)
+	require.NoError(t, err)
+	require.Equal(t, 4, doc.Find("#suspects input[name='culprit']").Length())
+	require.Equal(t, 2, doc.Find("#evidence input[name='clue']").Length())
+
+	// Undiscovered clues can't be presented.
+	resp, err := client.PostFormValues(ctx, accusationPath, accusationPath, url.Values{
+		"culprit": {"rue-morgue-ourang-outang"},
+		"motive":  {"It panicked."},
+		"clue":    {"rue-morgue-tufts-of-hair"},
+	})
+	require.NoError(t, err)
+	require.NoError(t, resp.Body.Close())
+	require.Equal(t, http.StatusBadRequest, resp.StatusCode)
+
+	doc, err = client.SubmitFormValues(ctx, accusationPath, accusationPath, url.Values{
+		"culprit": {"rue-morgue-ourang-outang"},
+		"motive":  {"It panicked when the ladies screamed."},
+		"clue":    {"le-bon-last-meeting-with-the-victim", "le-bon-victim-belongings"},
+	})
+	require.NoError(t, err)
+	require.Equal(t, "The verdict", doc.Find("h1").Text())
+	// 50 for the culprit, 20 for the motive, 10 for one of three supporting clues, and -5 for an irrelevant clue.
+	require.Contains(t, doc.Find("#score").Text(), "Your score is 75 after 2 questions.")
+	require.Contains(t, doc.Find("#culprit").Text(), "You were right")
+	require.Contains(t, doc.Find("#motive").Text(), "Correct.")
+	require.Equal(t, 1, doc.Find("#evidence [data-correct='true']").Length())
+	require.Equal(t, 1, doc.Find("#evidence [data-correct='false']").Length())
+	require.Equal(t, 2, doc.Find("#missed-clues li").Length())
+
+	doc, err = client.GetDoc(ctx, "/cases/rue-morgue")
+	require.NoError(t, err)
+	require.Contains(t, doc.Find("#accusation").Text(), "You have solved the case with a score of 75.")
+	doc, err = client.GetDoc(ctx, "/")
+	require.NoError(t, err)
+	require.Equal(t, "Solved", doc.Find("#cases [data-status]").Text())
+
+	resp, err = client.Get(ctx, "/cases/nonexistent/accusation")
+	require.NoError(t, err)
+	require.NoError(t, resp.Body.Close())
+	require.Equal(t, http.StatusNotFound, resp.StatusCode)
+}
@@ -0,0 +1,30 @@
+package main
+
+import (
+	"github.com/myrjola/sheerluck/internal/errors"
+	"github.com/myrjola/sheerluck/internal/models"
+	"github.com/myrjola/sheerluck/internal/repositories"
+	"net/http"
+)
+
+type adminUsageTemplateData struct {
+	BaseTemplateData
+
+	Limits repositories.QuotaLimits
+	Report models.AIUsageReport
+}
+
+// adminUsageGET shows the AI usage counters and quotas to admins.
+func (app *application) adminUsageGET(w http.ResponseWriter, r *http.Request) {
+	report, err := app.quotas.Report(r.Context())
+	if err != nil {
+		app.serverError(w, r, errors.Wrap(err, "report usage"))
+		return
+	}
+	data := adminUsageTemplateData{
+		BaseTemplateData: newBaseTemplateData(r),
+		Limits:           app.quotaLimits,
+		Report:           *report,
+	}
+	app.render(w, r, http.StatusOK, "adminusage", data)
+}
@@ -0,0 +1,64 @@
+package main
+
+import (
+	"context"
+	"github.com/PuerkitoBio/goquery"
+	"github.com/myrjola/sheerluck/internal/e2etest"
+	"github.com/stretchr/testify/require"
+	"net/http"
+	"net/url"
+	"os"
+	"testing"
+)
+
+func Test_application_enforceAIQuota(t *testing.T) {
+	ctx := context.Background()
+	lookupEnv := func(key string) (string, bool) {
+		if key == "SHEERLUCK_QUOTA_USER_QUESTIONS_PER_MINUTE" {
+			return "1", true
+		}
+		return testLookupEnv(key)
+	}
+	server, err := e2etest.StartServer(ctx, os.Stdout, lookupEnv, run)
+	require.NoError(t, err)
+	client := server.Client()
+	_, err = client.Register(ctx)
+	require.NoError(t, err)
+
+	investigationPath := "/cases/rue-morgue/investigation-targets/le-bon"
+	askQuestion(ctx, t, client, investigationPath, "Who are you?")
+
+	resp, err := client.PostFormValues(ctx, investigationPath, investigationPath,
+		url.Values{"question": {"Where were you?"}})
+	require.NoError(t, err)
+	defer resp.Body.Close()
+	require.Equal(t, http.StatusTooManyRequests, resp.StatusCode)
+	require.Equal(t, "60", resp.Header.Get("Retry-After"))
+	doc, err := goquery.NewDocumentFromReader(resp.Body)
+	require.NoError(t, err)
+	require.Equal(t, "The witness needs a rest", doc.Find("h1").Text())
+
+	doc, err = client.GetDoc(ctx, investigationPath)
+	require.NoError(t, err)
+	require.Equal(t, 2, doc.Find("#completions article").Length(), "the rejected question is not persisted")
+}
+
+func Test_application_adminUsage(t *testing.T) {
+	ctx := context.Background()
+	server, err := e2etest.StartServer(ctx, os.Stdout, testLookupEnv, run)
+	require.NoError(t, err)
+	client := server.Client()
+
+	resp, err := client.Get(ctx, "/admin/usage")
+	require.NoError(t, err)
+	resp.Body.Close()
+	require.Equal(t, http.StatusOK, resp.StatusCode, "unauthenticated users are redirected to the front page")
+	require.Equal(t, "/", resp.Request.URL.Path)
+
+	_, err = client.Register(ctx)
+	require.NoError(t, err)
+	resp, err = client.Get(ctx, "/admin/usage")
+	require.NoError(t, err)
+	resp.Body.Close()
+	require.Equal(t, http.StatusNotFound, resp.StatusCode, "the page is hidden from players")
+}
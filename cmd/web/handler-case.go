@@ -0,0 +1,99 @@
+package main
+
+import (
+	"database/sql"
+	"fmt"
+	"github.com/myrjola/sheerluck/internal/contexthelpers"
+	"github.com/myrjola/sheerluck/internal/errors"
+	"github.com/myrjola/sheerluck/internal/hints"
+	"github.com/myrjola/sheerluck/internal/models"
+	"github.com/myrjola/sheerluck/internal/repositories"
+	"github.com/myrjola/sheerluck/internal/scoring"
+	"log/slog"
+	"net/http"
+	"net/url"
+	"slices"
+)
+
+type caseTemplateData struct {
+	BaseTemplateData
+
+	Overview models.CaseOverview
+	// Accusation is the player's accusation or nil if the case is still open.
+	Accusation *models.Accusation
+	// HintAvailable reports whether there are undiscovered clues within the player's reach Dupin hasn't given every hint
+	// about yet.
+	HintAvailable bool
+	// HintPenalty is the number of points a hint costs.
+	HintPenalty int
+	// GameState measures the player's progress against the rules of the game mode.
+	GameState models.GameState
+	// GameModes are the game modes the player can choose from before starting the case.
+	GameModes []models.GameMode
+}
+
+// caseGET shows the investigation targets of the case with the player's progress.
+func (app *application) caseGET(w http.ResponseWriter, r *http.Request) {
+	ctx := r.Context()
+	caseID := r.PathValue("caseID")
+	userID := contexthelpers.AuthenticatedUserID(ctx)
+	overview, err := app.cases.Get(ctx, caseID, userID)
+	if errors.Is(err, sql.ErrNoRows) {
+		http.NotFound(w, r)
+		return
+	}
+	if err != nil {
+		app.serverError(w, r, errors.Wrap(err, "get case overview", slog.String("case_id", caseID)))
+		return
+	}
+	accusation, err := app.accusations.Get(ctx, caseID, userID)
+	if err != nil && !errors.Is(err, sql.ErrNoRows) {
+		app.serverError(w, r, errors.Wrap(err, "get accusation", slog.String("case_id", caseID)))
+		return
+	}
+	candidates, err := app.hints.ListCandidates(ctx, caseID, userID)
+	if err != nil {
+		app.serverError(w, r, errors.Wrap(err, "list hint candidates", slog.String("case_id", caseID)))
+		return
+	}
+	_, _, hintAvailable := hints.Next(candidates, overview.Hints)
+	gameState, err := app.gameModes.Get(ctx, caseID, userID)
+	if err != nil {
+		app.serverError(w, r, errors.Wrap(err, "get game state", slog.String("case_id", caseID)))
+		return
+	}
+	data := caseTemplateData{
+		BaseTemplateData: newBaseTemplateData(r),
+		Overview:         *overview,
+		Accusation:       accusation,
+		HintAvailable:    hintAvailable,
+		HintPenalty:      scoring.HintPenalty,
+		GameState:        *gameState,
+		GameModes:        models.GameModes,
+	}
+	app.render(w, r, http.StatusOK, "case", data)
+}
+
+// gameModePOST starts the case in the game mode the player chose.
+func (app *application) gameModePOST(w http.ResponseWriter, r *http.Request) {
+	ctx := r.Context()
+	caseID := r.PathValue("caseID")
+	userID := contexthelpers.AuthenticatedUserID(ctx)
+	mode := models.GameMode(r.PostFormValue("mode"))
+	if !slices.Contains(models.GameModes, mode) {
+		http.Error(w, http.StatusText(http.StatusBadRequest), http.StatusBadRequest)
+		return
+	}
+	if err := app.gameModes.Choose(ctx, caseID, userID, mode); err != nil {
+		switch {
+		case errors.Is(err, sql.ErrNoRows):
+			http.NotFound(w, r)
+		case errors.Is(err, repositories.ErrCaseStarted):
+			http.Error(w, http.StatusText(http.StatusConflict), http.StatusConflict)
+		default:
+			app.serverError(w, r, errors.Wrap(err, "choose game mode", slog.String("case_id", caseID)))
+		}
+		return
+	}
+	http.Redirect(w, r, fmt.Sprintf("/cases/%s", url.PathEscape(caseID)), http.StatusSeeOther)
+}
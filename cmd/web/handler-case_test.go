@@ -0,0 +1,145 @@
+package main
+
+import (
+	"context"
+	"github.com/myrjola/sheerluck/internal/e2etest"
+	"github.com/myrjola/sheerluck/internal/models"
+	"github.com/stretchr/testify/require"
+	"io"
+	"net/http"
+	"net/url"
+	"os"
+	"strconv"
+	"testing"
+)
+
+func Test_application_caseGET(t *testing.T) {
+	ctx := context.Background()
+	server, err := e2etest.StartServer(ctx, os.Stdout, testLookupEnv, run)
+	require.NoError(t, err)
+	client := server.Client()
+	_, err = client.Register(ctx)
+	require.NoError(t, err)
+
+	doc, err := client.GetDoc(ctx, "/cases/rue-morgue")
+	require.NoError(t, err)
+	require.Equal(t, "The Murders in the Rue Morgue", doc.Find("h1").Text())
+	targets := doc.Find("#targets li")
+	require.Equal(t, 4, targets.Length())
+	leBon := targets.First()
+	require.Equal(t, "Adolphe Le Bon", leBon.Find("h3").Text())
+	href, _ := leBon.Find("a").Attr("href")
+	require.Equal(t, "/cases/rue-morgue/investigation-targets/le-bon", href)
+	require.Contains(t, leBon.Text(), "0 questions")
+	require.Contains(t, leBon.Text(), "0 / 3 clues")
+	require.Equal(t, 0, doc.Find("#clues li").Length())
+	require.Contains(t, doc.Find("#clues").Text(), "No clues discovered yet.")
+
+	// The gold watch is a silhouette until Adolphe reveals the belongings unlocking its chapter.
+	locked := doc.Find("#targets [data-locked]")
+	require.Equal(t, 1, locked.Length())
+	require.Contains(t, locked.Text(), "Revealed in The Seized Belongings")
+	require.NotContains(t, doc.Find("#targets").Text(), "Gold Watch")
+	watchPath := "/cases/rue-morgue/investigation-targets/gold-watch"
+	resp, err := client.Get(ctx, watchPath)
+	require.NoError(t, err)
+	require.NoError(t, resp.Body.Close())
+	require.Equal(t, http.StatusNotFound, resp.StatusCode)
+
+	askQuestion(ctx, t, client, href, "Where did you get the gold watch?")
+	doc, err = client.GetDoc(ctx, "/cases/rue-morgue")
+	require.NoError(t, err)
+	leBon = doc.Find("#targets li").First()
+	require.Contains(t, leBon.Text(), "1 questions")
+	require.Contains(t, leBon.Text(), "1 / 3 clues")
+	require.Contains(t, doc.Find("#clues li").Text(), "The victims' belongings in Adolphe's posession")
+	require.NotContains(t, doc.Find("#clues").Text(), "No clues discovered yet.")
+	require.Equal(t, 0, doc.Find("#targets [data-locked]").Length())
+	require.Equal(t, 1, doc.Find("#targets a[href='"+watchPath+"']").Length())
+	resp, err = client.Get(ctx, watchPath)
+	require.NoError(t, err)
+	require.NoError(t, resp.Body.Close())
+	require.Equal(t, http.StatusOK, resp.StatusCode)
+
+	doc, err = client.GetDoc(ctx, "/")
+	require.NoError(t, err)
+	require.Equal(t, "In progress", doc.Find("#cases [data-status]").Text())
+
+	resp, err = client.Get(ctx, "/cases/nonexistent")
+	require.NoError(t, err)
+	defer resp.Body.Close()
+	require.Equal(t, http.StatusNotFound, resp.StatusCode)
+}
+
+func Test_application_caseGETUnauthenticated(t *testing.T) {
+	ctx := context.Background()
+	server, err := e2etest.StartServer(ctx, os.Stdout, testLookupEnv, run)
+	require.NoError(t, err)
+
+	// The anonymous visitor is redirected to the home page without rendering the case.
+	client := &http.Client{CheckRedirect: func(*http.Request, []*http.Request) error {
+		return http.ErrUseLastResponse
+	}}
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, server.URL()+"/cases/rue-morgue", nil)
+	require.NoError(t, err)
+	resp, err := client.Do(req)
+	require.NoError(t, err)
+	defer resp.Body.Close()
+	require.Equal(t, http.StatusSeeOther, resp.StatusCode)
+	require.Equal(t, "/", resp.Header.Get("Location"))
+	body, err := io.ReadAll(resp.Body)
+	require.NoError(t, err)
+	require.NotContains(t, string(body), "The Murders in the Rue Morgue")
+}
+
+func Test_application_gameMode(t *testing.T) {
+	ctx := context.Background()
+	lookupEnv := func(key string) (string, bool) {
+		if key == "SHEERLUCK_QUOTA_USER_QUESTIONS_PER_MINUTE" {
+			// The budget runs out before the quota.
+			return "0", true
+		}
+		return testLookupEnv(key)
+	}
+	server, err := e2etest.StartServer(ctx, os.Stdout, lookupEnv, run)
+	require.NoError(t, err)
+	client := server.Client()
+	_, err = client.Register(ctx)
+	require.NoError(t, err)
+
+	casePath := "/cases/rue-morgue"
+	doc, err := client.GetDoc(ctx, casePath)
+	require.NoError(t, err)
+	require.Equal(t, 4, doc.Find("#game-mode input[name='mode']").Length())
+	doc, err = client.SubmitFormValues(ctx, casePath, casePath+"/game-mode", url.Values{
+		"mode": {string(models.GameModeTargetBudget)},
+	})
+	require.NoError(t, err)
+	require.Contains(t, doc.Find("#game-mode h2").Text(), "Limited questions per witness")
+	require.Equal(t, 0, doc.Find("#game-mode form").Length(), "the mode can't be changed")
+
+	gazettePath := casePath + "/investigation-targets/gazette-des-tribunaux"
+	for i := range models.TargetQuestionBudget {
+		_, doc = askQuestion(ctx, t, client, gazettePath, "What is on page "+strconv.Itoa(i+1)+"?")
+	}
+	require.Contains(t, doc.Find("#game-mode").Text(), "0 questions left here.")
+	require.Contains(t, doc.Find("#refusal").Text(), "You have asked all your questions here.")
+	require.Equal(t, 0, doc.Find("form[action='"+gazettePath+"']").Length(), "no more questions can be asked")
+	doc, err = client.GetDoc(ctx, casePath+"/investigation-targets/le-bon")
+	require.NoError(t, err)
+	require.Contains(t, doc.Find("#game-mode").Text(), "8 questions left here.")
+
+	// The clock advances with every question.
+	detective, err := e2etest.NewClient(server.URL(), "localhost", "http://localhost:0")
+	require.NoError(t, err)
+	_, err = detective.Register(ctx)
+	require.NoError(t, err)
+	doc, err = detective.SubmitFormValues(ctx, casePath, casePath+"/game-mode", url.Values{
+		"mode": {string(models.GameModeClock)},
+	})
+	require.NoError(t, err)
+	require.Contains(t, doc.Find("#game-mode [data-clock]").Text(), "It's 08:00.")
+	require.Contains(t, doc.Find("#targets").Text(), "Available until 12:00")
+	_, doc = askQuestion(ctx, t, detective, casePath+"/investigation-targets/le-bon", "Where were you?")
+	require.Contains(t, doc.Find("#game-mode").Text(), "It's 08:15.")
+}
@@ -0,0 +1,153 @@
+package main
+
+import (
+	"context"
+	"database/sql"
+	"fmt"
+	"github.com/myrjola/sheerluck/internal/contexthelpers"
+	"github.com/myrjola/sheerluck/internal/errors"
+	"github.com/myrjola/sheerluck/internal/models"
+	"io"
+	"log/slog"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// sseEventWriter writes Server-Sent Events. See https://html.spec.whatwg.org/multipage/server-sent-events.html.
+type sseEventWriter struct {
+	w  io.Writer
+	rc *http.ResponseController
+	id int
+}
+
+// write sends an event with a sequential ID. Multi-line data is split into multiple data fields, which the browser
+// joins back together with newlines.
+func (s *sseEventWriter) write(event, data string) error {
+	s.id++
+	var b strings.Builder
+	_, _ = fmt.Fprintf(&b, "id: %d\nevent: %s\n", s.id, event)
+	for _, line := range strings.Split(data, "\n") {
+		_, _ = fmt.Fprintf(&b, "data: %s\n", line)
+	}
+	b.WriteString("\n")
+	if _, err := io.WriteString(s.w, b.String()); err != nil {
+		return errors.Wrap(err, "write event", slog.String("event", event))
+	}
+	if err := s.rc.Flush(); err != nil {
+		return errors.Wrap(err, "flush event", slog.String("event", event))
+	}
+	return nil
+}
+
+// investigateTargetCompletionStreamGET streams the answer of a completion as Server-Sent Events.
+//
+// The first consumer receives "token" events as the answer is being produced. Consumers reconnecting, e.g., after a
+// page reload, wait for the producer to finish. Finally, every consumer receives either a "done" event with the full
+// persisted answer or a "failed" event. The "done" event is preceded by a "clue" event with the description of every
+// clue the answer revealed.
+func (app *application) investigateTargetCompletionStreamGET(w http.ResponseWriter, r *http.Request) {
+	ctx := r.Context()
+	userID := contexthelpers.AuthenticatedUserID(ctx)
+	completionID, err := strconv.ParseInt(r.PathValue("completionID"), 10, 64)
+	if err != nil {
+		http.NotFound(w, r)
+		return
+	}
+	var completion *models.Completion
+	if completion, err = app.investigations.GetCompletion(ctx, completionID, userID); err != nil {
+		if errors.Is(err, sql.ErrNoRows) {
+			http.NotFound(w, r)
+			return
+		}
+		app.serverError(w, r, errors.Wrap(err, "get completion"))
+		return
+	}
+
+	rc := http.NewResponseController(w)
+	// The answer may take longer than the server's write timeout allows.
+	if err = rc.SetWriteDeadline(time.Time{}); err != nil {
+		app.logger.LogAttrs(ctx, slog.LevelWarn, "could not clear write deadline", errors.SlogError(err))
+	}
+	w.Header().Set("Content-Type", "text/event-stream")
+	w.Header().Set("Cache-Control", "no-cache")
+	events := &sseEventWriter{w: w, rc: rc, id: 0}
+
+	if completion.Status == models.CompletionStatusCreated || completion.Status == models.CompletionStatusStreaming {
+		if err = app.streamCompletionTokens(ctx, events, completionID); err != nil {
+			app.logger.LogAttrs(ctx, slog.LevelDebug, "completion stream interrupted", errors.SlogError(err))
+			return
+		}
+		// The producer has finished, so the persisted completion is final.
+		if completion, err = app.investigations.GetCompletion(ctx, completionID, userID); err != nil {
+			app.logger.LogAttrs(ctx, slog.LevelError, "could not reload completion", errors.SlogError(err))
+			_ = events.write("failed", "")
+			return
+		}
+	}
+
+	if completion.Status == models.CompletionStatusDone {
+		if err = app.writeCompletionClues(ctx, events, completionID, userID); err != nil {
+			// The clues are shown on the next page load anyway.
+			app.logger.LogAttrs(ctx, slog.LevelWarn, "could not send completion clues", errors.SlogError(err))
+		}
+		err = events.write("done", completion.Answer)
+	} else {
+		// Either failed or there's no producer anymore, e.g., because the server restarted mid-stream.
+		err = events.write("failed", "")
+	}
+	if err != nil {
+		app.logger.LogAttrs(ctx, slog.LevelDebug, "could not send final event", errors.SlogError(err))
+	}
+}
+
+// writeCompletionClues sends a "clue" event for every clue the completion revealed.
+func (app *application) writeCompletionClues(
+	ctx context.Context,
+	events *sseEventWriter,
+	completionID int64,
+	userID []byte,
+) error {
+	clues, err := app.investigations.ListCompletionClues(ctx, completionID, userID)
+	if err != nil {
+		return errors.Wrap(err, "list completion clues")
+	}
+	for _, clue := range clues {
+		if err = events.write("clue", clue.Description); err != nil {
+			return errors.Wrap(err, "write clue", slog.String("clue_id", clue.ID))
+		}
+	}
+	return nil
+}
+
+// streamCompletionTokens subscribes to the completion in the completion broker and sends the answer chunks as
+// "token" events until the producer finishes.
+func (app *application) streamCompletionTokens(ctx context.Context, events *sseEventWriter, completionID int64) error {
+	var (
+		channel chan string
+		ok      bool
+	)
+	select {
+	case <-ctx.Done():
+		return errors.Wrap(ctx.Err(), "wait for subscription")
+	case channel, ok = <-app.completionBroker.Subscribe(completionID):
+		if !ok {
+			// Not published or another consumer has the stream and the producer has finished.
+			return nil
+		}
+	}
+	for {
+		select {
+		case <-ctx.Done():
+			return errors.Wrap(ctx.Err(), "wait for token")
+		case chunk, more := <-channel:
+			if !more {
+				return nil
+			}
+			if err := events.write("token", chunk); err != nil {
+				return errors.Wrap(err, "write token")
+			}
+		}
+	}
+}
@@ -0,0 +1,73 @@
+package main
+
+import (
+	"context"
+	"database/sql"
+	"fmt"
+	"github.com/myrjola/sheerluck/internal/contexthelpers"
+	"github.com/myrjola/sheerluck/internal/errors"
+	"github.com/myrjola/sheerluck/internal/hints"
+	"github.com/myrjola/sheerluck/internal/models"
+	"log/slog"
+	"net/http"
+	"net/url"
+	"time"
+)
+
+const (
+	// maxHintLength mirrors the length check of the hints table.
+	maxHintLength = 1023
+	// hintTimeout bounds how long Dupin writes the hint. It's shorter than the server's write timeout so that the
+	// player gets an error page instead of a dropped connection.
+	hintTimeout = 4 * time.Second
+)
+
+// hintPOST gives the player the next hint about the clues they haven't discovered yet and records it against their
+// score. The hint is shown on the case page.
+func (app *application) hintPOST(w http.ResponseWriter, r *http.Request) {
+	ctx := r.Context()
+	userID := contexthelpers.AuthenticatedUserID(ctx)
+	caseID := r.PathValue("caseID")
+	casePath := fmt.Sprintf("/cases/%s", url.PathEscape(caseID))
+	overview, err := app.cases.Get(ctx, caseID, userID)
+	if errors.Is(err, sql.ErrNoRows) {
+		http.NotFound(w, r)
+		return
+	}
+	if err != nil {
+		app.serverError(w, r, errors.Wrap(err, "get case overview", slog.String("case_id", caseID)))
+		return
+	}
+	candidates, err := app.hints.ListCandidates(ctx, caseID, userID)
+	if err != nil {
+		app.serverError(w, r, errors.Wrap(err, "list hint candidates", slog.String("case_id", caseID)))
+		return
+	}
+	candidate, level, ok := hints.Next(candidates, overview.Hints)
+	if !ok {
+		// Everything within reach has been discovered or hinted at, e.g., in another tab, so there's nothing to hint at.
+		http.Redirect(w, r, casePath+"#hints", http.StatusSeeOther)
+		return
+	}
+
+	// The route isn't behind the timeout handler, because the model may write the hint.
+	hintCtx, cancel := context.WithTimeout(ctx, hintTimeout)
+	defer cancel()
+	text, err := app.dupin.Hint(hintCtx, overview.Case, candidate, level)
+	if err != nil {
+		app.serverError(w, r, errors.Wrap(err, "write hint", slog.String("clue_id", candidate.Clue.ID)))
+		return
+	}
+	hint := models.Hint{
+		ID:      0,
+		ClueID:  candidate.Clue.ID,
+		Level:   level,
+		Text:    truncate(text, maxHintLength),
+		Created: time.Time{},
+	}
+	if _, err = app.hints.Create(ctx, caseID, userID, hint); err != nil {
+		app.serverError(w, r, errors.Wrap(err, "create hint", slog.String("case_id", caseID)))
+		return
+	}
+	http.Redirect(w, r, casePath+"#hints", http.StatusSeeOther)
+}
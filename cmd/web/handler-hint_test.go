@@ -0,0 +1,98 @@
+package main
+
+import (
+	"context"
+	"github.com/myrjola/sheerluck/internal/e2etest"
+	"github.com/stretchr/testify/require"
+	"net/url"
+	"os"
+	"testing"
+)
+
+func Test_application_hint(t *testing.T) {
+	ctx := context.Background()
+	server, err := e2etest.StartServer(ctx, os.Stdout, testLookupEnv, run)
+	require.NoError(t, err)
+	client := server.Client()
+	_, err = client.Register(ctx)
+	require.NoError(t, err)
+
+	casePath := "/cases/rue-morgue"
+	hintPath := casePath + "/hints"
+	doc, err := client.GetDoc(ctx, casePath)
+	require.NoError(t, err)
+	require.Equal(t, 0, doc.Find("#hints li").Length())
+	require.Contains(t, doc.Find("#hints form").Text(), "at the cost of 5 points")
+
+	// The authored hints about Adolphe's last meeting get more specific with every hint.
+	for level, want := range []string{
+		"Every crime has a last visitor.",
+		"The clerk in his cell knows more",
+		"Ask Adolphe when he last saw the ladies",
+	} {
+		doc, err = client.SubmitForm(ctx, casePath, hintPath)
+		require.NoError(t, err)
+		hints := doc.Find("#hints li")
+		require.Equal(t, level+1, hints.Length())
+		require.Contains(t, hints.Last().Text(), want)
+	}
+
+	// Dupin moves on to the next clue and writes the hints that haven't been authored.
+	doc, err = client.SubmitForm(ctx, casePath, hintPath)
+	require.NoError(t, err)
+	require.Contains(t, doc.Find("#hints li").Last().Text(), "Not every trace left in that chamber")
+	doc, err = client.SubmitForm(ctx, casePath, hintPath)
+	require.NoError(t, err)
+	written := doc.Find("#hints li").Last()
+	require.Contains(t, written.Text(), "C. Auguste Dupin", "the scripted AI echoes the prompt")
+	level, _ := written.Attr("data-level")
+	require.Equal(t, "2", level)
+
+	// The hints count against the score.
+	accusationPath := casePath + "/accusation"
+	doc, err = client.GetDoc(ctx, accusationPath)
+	require.NoError(t, err)
+	require.Contains(t, doc.Text(), "taken 5 hints")
+	doc, err = client.SubmitFormValues(ctx, accusationPath, accusationPath, url.Values{
+		"culprit": {"rue-morgue-ourang-outang"},
+		"motive":  {"It panicked when the ladies screamed."},
+	})
+	require.NoError(t, err)
+	// 50 for the culprit, 20 for the motive, and -5 for each hint.
+	require.Contains(t, doc.Find("#score").Text(), "Your score is 45 after 0 questions.")
+	require.Contains(t, doc.Find("#hints").Text(), "Dupin gave you 5 hints")
+	doc, err = client.GetDoc(ctx, casePath)
+	require.NoError(t, err)
+	require.Equal(t, 0, doc.Find("#hints form").Length(), "no hints after solving the case")
+
+}
+
+func Test_application_hintExhausted(t *testing.T) {
+	ctx := context.Background()
+	lookupEnv := func(key string) (string, bool) {
+		if key == "SHEERLUCK_QUOTA_USER_QUESTIONS_PER_MINUTE" {
+			// The hints run out before the quota.
+			return "0", true
+		}
+		return testLookupEnv(key)
+	}
+	server, err := e2etest.StartServer(ctx, os.Stdout, lookupEnv, run)
+	require.NoError(t, err)
+	client := server.Client()
+	_, err = client.Register(ctx)
+	require.NoError(t, err)
+
+	// The hint button disappears once every clue within reach has been hinted at the topic level.
+	casePath := "/cases/rue-morgue"
+	doc, err := client.GetDoc(ctx, casePath)
+	require.NoError(t, err)
+	taken := 0
+	for doc.Find("#hints form").Length() > 0 {
+		require.Less(t, taken, 30, "the hints never run out")
+		doc, err = client.SubmitForm(ctx, casePath, casePath+"/hints")
+		require.NoError(t, err)
+		taken++
+		require.Equal(t, taken, doc.Find("#hints li").Length())
+	}
+	require.Contains(t, doc.Find("#hints").Text(), "Dupin has nothing more to suggest.")
+}
@@ -1,16 +1,35 @@
 package main
 
 import (
+	"github.com/myrjola/sheerluck/internal/contexthelpers"
+	"github.com/myrjola/sheerluck/internal/errors"
+	"github.com/myrjola/sheerluck/internal/models"
 	"net/http"
 )
 
 type homeTemplateData struct {
 	BaseTemplateData
+
+	// Cases is the case catalogue shown to authenticated players.
+	Cases []models.CaseSummary
 }
 
 func (app *application) home(w http.ResponseWriter, r *http.Request) {
+	var (
+		ctx   = r.Context()
+		cases []models.CaseSummary
+		err   error
+	)
+	if contexthelpers.IsAuthenticated(ctx) {
+		if cases, err = app.cases.List(ctx, contexthelpers.AuthenticatedUserID(ctx)); err != nil {
+			app.serverError(w, r, errors.Wrap(err, "list cases"))
+			return
+		}
+	}
+
 	data := homeTemplateData{
 		BaseTemplateData: newBaseTemplateData(r),
+		Cases:            cases,
 	}
 
 	app.render(w, r, http.StatusOK, "home", data)
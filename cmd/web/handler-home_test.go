@@ -14,6 +14,8 @@ func testLookupEnv(key string) (string, bool) {
 		return ":memory:", true
 	case "SHEERLUCK_ADDR":
 		return "localhost:0", true
+	case "SHEERLUCK_AI_PROVIDER":
+		return "scripted", true
 	default:
 		return "", false
 	}
@@ -35,6 +37,12 @@ func Test_application_home(t *testing.T) {
 	require.Equal(t, 0, doc.Find("button:contains('Sign in')").Length())
 	require.Equal(t, 0, doc.Find("button:contains('Register')").Length())
 	require.Equal(t, 1, doc.Find("button:contains('Log out')").Length())
+	cases := doc.Find("#cases a")
+	require.Equal(t, 1, cases.Length())
+	href, _ := cases.Attr("href")
+	require.Equal(t, "/cases/rue-morgue", href)
+	require.Equal(t, "The Murders in the Rue Morgue", cases.Find("h2").Text())
+	require.Equal(t, "Not started", cases.Find("[data-status]").Text())
 
 	// Log out and log back in
 	doc, err = client.Logout(ctx)
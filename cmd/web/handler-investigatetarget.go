@@ -1,12 +1,20 @@
 package main
 
 import (
+	"context"
+	"database/sql"
 	"fmt"
 	"github.com/myrjola/sheerluck/internal/contexthelpers"
 	"github.com/myrjola/sheerluck/internal/errors"
+	"github.com/myrjola/sheerluck/internal/guard"
 	"github.com/myrjola/sheerluck/internal/models"
+	"github.com/myrjola/sheerluck/internal/prompts"
+	"github.com/myrjola/sheerluck/internal/repositories"
 	"log/slog"
 	"net/http"
+	"net/url"
+	"strconv"
+	"strings"
 	"time"
 )
 
@@ -14,66 +22,564 @@ type investigateTargetTemplateData struct {
 	BaseTemplateData
 
 	Investigation models.Investigation
+	// InvestigationPath is the path of the investigation page the forms post to.
+	InvestigationPath string
+	// Alternatives navigates between the alternatives of the completions having any, keyed by completion ID.
+	Alternatives map[int64]*alternativesNavigation
+	// Refusal explains why the player's question was refused.
+	Refusal string
+	// GameState measures the player's progress against the rules of the game mode.
+	GameState models.GameState
+	// Notebook is shown in a side panel.
+	Notebook notebookTemplateData
+	// Statements are the pinned answers of the other persons the player can confront a person with.
+	Statements []models.PinnedCompletion
+	// Party is the player's party for the case with only the other members' completions of the investigation target,
+	// or nil if the player isn't a member of a party.
+	Party *models.Party
 }
 
-func (app *application) investigateTargetGET(w http.ResponseWriter, r *http.Request) {
+// alternativesNavigation switches between the alternatives of a completion on the active branch.
+type alternativesNavigation struct {
+	// Position is the 1-based position of the completion among its alternatives.
+	Position int
+	Count    int
+	// PreviousID and NextID are the IDs of the neighbouring alternatives or 0 if there's none.
+	PreviousID int64
+	NextID     int64
+}
+
+func newInvestigateTargetTemplateData(
+	r *http.Request,
+	investigation *models.Investigation,
+	notebook *models.Notebook,
+	gameState *models.GameState,
+	refusal string,
+) investigateTargetTemplateData {
+	alternatives := make(map[int64]*alternativesNavigation)
+	for _, completion := range investigation.Completions {
+		if len(completion.Alternatives) < 2 { //nolint:mnd // nothing to navigate with a single alternative
+			continue
+		}
+		navigation := &alternativesNavigation{Position: 0, Count: len(completion.Alternatives), PreviousID: 0, NextID: 0}
+		for i, id := range completion.Alternatives {
+			if id != completion.ID {
+				continue
+			}
+			navigation.Position = i + 1
+			if i > 0 {
+				navigation.PreviousID = completion.Alternatives[i-1]
+			}
+			if i < len(completion.Alternatives)-1 {
+				navigation.NextID = completion.Alternatives[i+1]
+			}
+		}
+		alternatives[completion.ID] = navigation
+	}
+	var statements []models.PinnedCompletion
+	for _, pinned := range notebook.PinnedCompletions {
+		if investigation.Target.Type == models.InvestigationTargetTypePerson &&
+			pinned.Target.Type == models.InvestigationTargetTypePerson && pinned.Target.ID != investigation.Target.ID {
+			statements = append(statements, pinned)
+		}
+	}
+	return investigateTargetTemplateData{
+		BaseTemplateData:  newBaseTemplateData(r),
+		Investigation:     *investigation,
+		InvestigationPath: investigationPath(r),
+		Alternatives:      alternatives,
+		Refusal:           refusal,
+		GameState:         *gameState,
+		Notebook: notebookTemplateData{
+			Notebook:     *notebook,
+			NotebookPath: notebookPath(r),
+			ReturnPath:   investigationPath(r),
+			Tags:         []models.InvestigationTarget{investigation.Target},
+			DefaultTag:   investigation.Target.ID,
+			Editable:     false,
+		},
+		Statements: statements,
+		Party:      nil,
+	}
+}
+
+// investigationPath returns the path of the investigation page of the request's investigation target.
+func investigationPath(r *http.Request) string {
+	return fmt.Sprintf("/cases/%s/investigation-targets/%s",
+		url.PathEscape(r.PathValue("caseID")), url.PathEscape(r.PathValue("investigationTargetID")))
+}
+
+// getInvestigation reads the player's investigation of the request's investigation target. It responds with an error
+// and returns nil if the investigation can't be read, the target isn't in the request's case, or the player hasn't
+// unlocked the target's chapter yet.
+func (app *application) getInvestigation(w http.ResponseWriter, r *http.Request) *models.Investigation {
 	ctx := r.Context()
 	userID := contexthelpers.AuthenticatedUserID(ctx)
 	investigationTargetID := r.PathValue("investigationTargetID")
 	investigation, err := app.investigations.Get(ctx, investigationTargetID, userID)
+	if errors.Is(err, sql.ErrNoRows) {
+		http.NotFound(w, r)
+		return nil
+	}
 	if err != nil {
 		app.serverError(w, r, errors.Wrap(
 			err,
 			"get investigation",
 			slog.String("investigation_target_id", investigationTargetID),
 		))
+		return nil
+	}
+	if investigation.Locked || investigation.Case.ID != r.PathValue("caseID") {
+		http.NotFound(w, r)
+		return nil
+	}
+	return investigation
+}
+
+func (app *application) investigateTargetGET(w http.ResponseWriter, r *http.Request) {
+	ctx := r.Context()
+	userID := contexthelpers.AuthenticatedUserID(ctx)
+	investigation := app.getInvestigation(w, r)
+	if investigation == nil {
+		return
+	}
+	notebook, err := app.notebooks.Get(ctx, investigation.Case.ID, userID)
+	if err != nil {
+		app.serverError(w, r, errors.Wrap(err, "get notebook", slog.String("case_id", investigation.Case.ID)))
+		return
+	}
+	party, err := app.parties.Get(ctx, investigation.Case.ID, userID)
+	if err != nil && !errors.Is(err, sql.ErrNoRows) {
+		app.serverError(w, r, errors.Wrap(err, "get party", slog.String("case_id", investigation.Case.ID)))
+		return
+	}
+	gameState, err := app.gameModes.Get(ctx, investigation.Case.ID, userID)
+	if err != nil {
+		app.serverError(w, r, errors.Wrap(err, "get game state", slog.String("case_id", investigation.Case.ID)))
 		return
 	}
-	data := investigateTargetTemplateData{
-		BaseTemplateData: newBaseTemplateData(r),
-		Investigation:    *investigation,
+	data := newInvestigateTargetTemplateData(r, investigation, notebook, gameState, "")
+	if party != nil {
+		// The player sees their own completions in the history.
+		var completions []models.PartyCompletion
+		for _, completion := range party.Completions {
+			if completion.Target.ID == investigation.Target.ID && !completion.Member.Self {
+				completions = append(completions, completion)
+			}
+		}
+		party.Completions = completions
+		data.Party = party
 	}
 	app.render(w, r, http.StatusOK, "investigatetarget", data)
 }
 
+const (
+	// maxQuestionLength mirrors the length check of the completions table.
+	maxQuestionLength = 1023
+	// deflectionTemplate is the prompt template rendering the in-character answer to off-topic questions.
+	deflectionTemplate = "deflection"
+	// injectionRefusal is shown when the question guard refuses a question.
+	injectionRefusal = "Dupin lays a hand on your arm: \"Let us keep to the matter at hand, my friend. " +
+		"A detective wins the truth with questions, not with tricks.\""
+)
+
+// historyBefore returns the done completions of the active branch preceding the replaced completion. If replaced is
+// nil, the whole history is returned.
+func historyBefore(investigation *models.Investigation, replaced *models.Completion) []models.Completion {
+	var completions []models.Completion
+	for _, completion := range investigation.Completions {
+		if replaced != nil && completion.Order >= replaced.Order {
+			break
+		}
+		if completion.Status == models.CompletionStatusDone {
+			completions = append(completions, completion)
+		}
+	}
+	return completions
+}
+
+// discoveredInHistory returns the IDs of the clues the player has discovered in the history.
+func discoveredInHistory(investigation *models.Investigation, history []models.Completion) map[string]bool {
+	inHistory := make(map[int64]bool, len(history))
+	for _, completion := range history {
+		inHistory[completion.ID] = true
+	}
+	discovered := make(map[string]bool, len(investigation.DiscoveredClues))
+	for _, discoveredClue := range investigation.DiscoveredClues {
+		if inHistory[discoveredClue.CompletionID] {
+			discovered[discoveredClue.Clue.ID] = true
+		}
+	}
+	return discovered
+}
+
+// undiscoveredClues returns the clues of the investigation target the player hasn't discovered in the history.
+func undiscoveredClues(investigation *models.Investigation, history []models.Completion) []models.Clue {
+	discovered := discoveredInHistory(investigation, history)
+	var clues []models.Clue
+	for _, clue := range investigation.Clues {
+		if !discovered[clue.ID] {
+			clues = append(clues, clue)
+		}
+	}
+	return clues
+}
+
+// availableClues returns the clues the investigation target can reveal when asked with the inquiry. A clue requiring
+// evidence is available when the player presents the evidence, and a clue found at a point of interest when the player
+// examines it. A clue stays available after it's discovered in the history so that the target doesn't take back what
+// they've revealed.
+func availableClues(
+	investigation *models.Investigation,
+	history []models.Completion,
+	inquiry models.Inquiry,
+) []models.Clue {
+	discovered := discoveredInHistory(investigation, history)
+	evidence := inquiry.Evidence
+	var clues []models.Clue
+	for _, clue := range investigation.Clues {
+		presented := clue.RequiresEvidence == nil ||
+			evidence != nil && evidence.Clue != nil && evidence.Clue.ID == clue.RequiresEvidence.ID
+		examined := clue.PointOfInterest == nil ||
+			inquiry.Examined != nil && inquiry.Examined.ID == clue.PointOfInterest.ID
+		if discovered[clue.ID] || presented && examined {
+			clues = append(clues, clue)
+		}
+	}
+	return clues
+}
+
+// parseInquiry reads the player's inquiry from the form. The player either asks a question, optionally presenting
+// evidence, or examines a point of interest of a scene with the examine form value. It returns false if the inquiry is
+// invalid.
+func (app *application) parseInquiry(
+	r *http.Request,
+	investigation *models.Investigation,
+) (models.Inquiry, bool, error) {
+	inquiry := models.Inquiry{Question: strings.TrimSpace(r.PostFormValue("question")), Evidence: nil, Examined: nil}
+	if pointOfInterestID := r.PostFormValue("examine"); pointOfInterestID != "" {
+		for i, pointOfInterest := range investigation.PointsOfInterest {
+			if pointOfInterest.ID == pointOfInterestID {
+				inquiry.Examined = &investigation.PointsOfInterest[i]
+				inquiry.Question = "Examine " + pointOfInterest.Name + "."
+				return inquiry, true, nil
+			}
+		}
+		return inquiry, false, nil
+	}
+	if inquiry.Question == "" || len(inquiry.Question) > maxQuestionLength {
+		return inquiry, false, nil
+	}
+	var (
+		ok  bool
+		err error
+	)
+	inquiry.Evidence, ok, err = app.parseEvidence(r, investigation)
+	return inquiry, ok, err
+}
+
+// parseEvidence reads the evidence the player presents with the question. The evidence form value is empty for plain
+// questions, "clue:<clue ID>" for a discovered clue, or "statement:<completion ID>" for a pinned answer of another
+// person. It returns false if the evidence can't be presented to the investigation target.
+func (app *application) parseEvidence(
+	r *http.Request,
+	investigation *models.Investigation,
+) (*models.Evidence, bool, error) {
+	value := r.PostFormValue("evidence")
+	if value == "" {
+		return nil, true, nil
+	}
+	if investigation.Target.Type != models.InvestigationTargetTypePerson {
+		return nil, false, nil
+	}
+	kind, id, _ := strings.Cut(value, ":")
+	switch kind {
+	case "clue":
+		for _, discoveredClue := range investigation.DiscoveredClues {
+			if discoveredClue.Clue.ID == id {
+				clue := discoveredClue.Clue
+				return &models.Evidence{Clue: &clue, Statement: nil}, true, nil
+			}
+		}
+	case "statement":
+		completionID, err := strconv.ParseInt(id, 10, 64)
+		if err != nil {
+			return nil, false, nil //nolint:nilerr // invalid input, not an error
+		}
+		ctx := r.Context()
+		userID := contexthelpers.AuthenticatedUserID(ctx)
+		statement, err := app.investigations.GetStatement(ctx, completionID, investigation.Case.ID, userID)
+		if errors.Is(err, sql.ErrNoRows) {
+			return nil, false, nil
+		}
+		if err != nil {
+			return nil, false, errors.Wrap(err, "get statement", slog.Int64("completion_id", completionID))
+		}
+		if statement.Target.ID != investigation.Target.ID {
+			return &models.Evidence{Clue: nil, Statement: statement}, true, nil
+		}
+	}
+	return nil, false, nil
+}
+
+// lastCompletionID returns the ID of the last done completion in the investigation or -1 if there's none.
+func lastCompletionID(investigation *models.Investigation) int64 {
+	for i := len(investigation.Completions) - 1; i >= 0; i-- {
+		if investigation.Completions[i].Status == models.CompletionStatusDone {
+			return investigation.Completions[i].ID
+		}
+	}
+	return -1
+}
+
+// findCompletion returns the done completion with given ID on the active branch of the investigation or nil if
+// there's none.
+func findCompletion(investigation *models.Investigation, completionID int64) *models.Completion {
+	for i, completion := range investigation.Completions {
+		if completion.ID == completionID && completion.Status == models.CompletionStatusDone {
+			return &investigation.Completions[i]
+		}
+	}
+	return nil
+}
+
+// investigateTargetPOST asks the investigation target a question.
+//
+// The answer is produced in the background and the player is redirected back to the investigation page, which
+// streams it from investigateTargetCompletionStreamGET.
 func (app *application) investigateTargetPOST(w http.ResponseWriter, r *http.Request) {
+	investigation := app.getInvestigation(w, r)
+	if investigation == nil {
+		return
+	}
+	inquiry, ok, err := app.parseInquiry(r, investigation)
+	if err != nil {
+		app.serverError(w, r, errors.Wrap(err, "parse inquiry"))
+		return
+	}
+	if !ok {
+		http.Error(w, http.StatusText(http.StatusBadRequest), http.StatusBadRequest)
+		return
+	}
+	app.ask(w, r, investigation, inquiry, nil)
+}
+
+// investigateTargetAlternativePOST branches the history at a completion on the active branch. The completion's
+// question is asked again to regenerate the answer or, if the form has a question, replaced with the edited question.
+// The evidence presented with the completion is presented again. The examined point of interest is examined again
+// only when the answer is regenerated, since the edited question asks something else.
+func (app *application) investigateTargetAlternativePOST(w http.ResponseWriter, r *http.Request) {
+	completionID, err := strconv.ParseInt(r.PathValue("completionID"), 10, 64)
+	if err != nil {
+		http.NotFound(w, r)
+		return
+	}
+	question := strings.TrimSpace(r.PostFormValue("question"))
+	if len(question) > maxQuestionLength {
+		http.Error(w, http.StatusText(http.StatusBadRequest), http.StatusBadRequest)
+		return
+	}
+	investigation := app.getInvestigation(w, r)
+	if investigation == nil {
+		return
+	}
+	replaced := findCompletion(investigation, completionID)
+	if replaced == nil {
+		http.NotFound(w, r)
+		return
+	}
+	inquiry := models.Inquiry{Question: replaced.Question, Evidence: replaced.Evidence, Examined: replaced.Examined}
+	if question != "" {
+		inquiry.Question = question
+		inquiry.Examined = nil
+	}
+	app.ask(w, r, investigation, inquiry, replaced)
+}
+
+// investigateTargetCompletionSelectPOST switches the investigation to the branch of the history containing the
+// completion.
+func (app *application) investigateTargetCompletionSelectPOST(w http.ResponseWriter, r *http.Request) {
 	ctx := r.Context()
 	userID := contexthelpers.AuthenticatedUserID(ctx)
-	investigationTargetID := r.PathValue("investigationTargetID")
-	investigation, err := app.investigations.Get(ctx, investigationTargetID, userID)
+	completionID, err := strconv.ParseInt(r.PathValue("completionID"), 10, 64)
 	if err != nil {
-		app.serverError(w, r, errors.Wrap(
-			err,
-			"get investigation",
-			slog.String("investigation_target_id", investigationTargetID),
-		))
+		http.NotFound(w, r)
 		return
 	}
-	flusher, ok := w.(http.Flusher)
-	if !ok {
-		app.logger.LogAttrs(ctx, slog.LevelWarn, "expected http.ResponseWriter to be an http.Flusher")
+	if err = app.investigations.SelectCompletion(ctx, completionID, userID); err != nil {
+		switch {
+		case errors.Is(err, sql.ErrNoRows):
+			http.NotFound(w, r)
+		case errors.Is(err, repositories.ErrInvalidParent):
+			// The player has switched branches in another tab since loading the page.
+			http.Error(w, http.StatusText(http.StatusConflict), http.StatusConflict)
+		default:
+			app.serverError(w, r, errors.Wrap(err, "select completion", slog.Int64("completion_id", completionID)))
+		}
+		return
+	}
+	http.Redirect(w, r, investigationPath(r), http.StatusSeeOther)
+}
+
+// screen matches the inquiry against the question guard's heuristics and logs flagged questions. The heuristics are
+// cheap enough for the request. The questions they let through are classified by the model in the background before
+// they're answered. Examining a point of interest asks a question written by us, so it isn't screened.
+func (app *application) screen(
+	ctx context.Context,
+	investigation *models.Investigation,
+	inquiry models.Inquiry,
+) guard.Classification {
+	if inquiry.Examined != nil {
+		return guard.Classification{Verdict: guard.VerdictInGame, Reason: "examined point of interest"}
+	}
+	classification := guard.MatchHeuristics(inquiry.Question)
+	if classification.Verdict != guard.VerdictInGame {
+		app.logFlaggedQuestion(ctx, investigation, inquiry, classification)
 	}
+	return classification
+}
 
-	// Respond with chunked transfer encoding to stream the response.
-	w.Header().Set("Content-Type", "text/plain")
-	w.Header().Set("Transfer-Encoding", "chunked")
+// logFlaggedQuestion logs the question the question guard flagged.
+func (app *application) logFlaggedQuestion(
+	ctx context.Context,
+	investigation *models.Investigation,
+	inquiry models.Inquiry,
+	classification guard.Classification,
+) {
+	app.logger.LogAttrs(ctx, slog.LevelWarn, "flagged question",
+		slog.String("verdict", string(classification.Verdict)),
+		slog.String("reason", classification.Reason),
+		slog.String("question", inquiry.Question),
+		slog.String("investigation_target_id", investigation.Target.ID),
+	)
+}
 
-	// Writing chunks to the response
-	for i := range 100 {
-		// Simulate some processing
-		time.Sleep(10 * time.Millisecond) //nolint:mnd // 10 milliseconds
+// refuse shows the investigation with the reason the player's question was refused.
+func (app *application) refuse(
+	w http.ResponseWriter,
+	r *http.Request,
+	investigation *models.Investigation,
+	gameState *models.GameState,
+	status int,
+	refusal string,
+) {
+	userID := contexthelpers.AuthenticatedUserID(r.Context())
+	notebook, err := app.notebooks.Get(r.Context(), investigation.Case.ID, userID)
+	if err != nil {
+		app.serverError(w, r, errors.Wrap(err, "get notebook", slog.String("case_id", investigation.Case.ID)))
+		return
+	}
+	data := newInvestigateTargetTemplateData(r, investigation, notebook, gameState, refusal)
+	app.render(w, r, status, "investigatetarget", data)
+}
 
-		// Write a chunk to the response
-		_, err = fmt.Fprintf(w, "Chunk %d, %s\n", i, investigation.Target.Name)
-		if err != nil {
+// refuseGameOver shows the investigation with the reason the rules of the game mode refused the player's question.
+func (app *application) refuseGameOver(w http.ResponseWriter, r *http.Request, investigation *models.Investigation) {
+	userID := contexthelpers.AuthenticatedUserID(r.Context())
+	gameState, err := app.gameModes.Get(r.Context(), investigation.Case.ID, userID)
+	if err != nil {
+		app.serverError(w, r, errors.Wrap(err, "get game state", slog.String("case_id", investigation.Case.ID)))
+		return
+	}
+	app.refuse(w, r, investigation, gameState, http.StatusForbidden, gameState.Refusal(investigation.Target.ID))
+}
+
+// ask enforces the rules of the game mode, screens the inquiry with the question guard's heuristics, stores it, and
+// starts answering it in the background. The inquiry continues the active branch or, if replaced is set, branches the
+// history at the replaced completion. The evidence, if any, confronts the investigation target and makes the clues
+// requiring it available. Likewise, the examined point of interest makes the clues found there available.
+func (app *application) ask(
+	w http.ResponseWriter,
+	r *http.Request,
+	investigation *models.Investigation,
+	inquiry models.Inquiry,
+	replaced *models.Completion,
+) {
+	ctx := r.Context()
+	userID := contexthelpers.AuthenticatedUserID(ctx)
+	investigationTargetID := investigation.Target.ID
+
+	// Refuse early to spare the work. The rules are enforced again when the completion is created.
+	gameState, err := app.gameModes.Get(ctx, investigation.Case.ID, userID)
+	if err != nil {
+		app.serverError(w, r, errors.Wrap(err, "get game state", slog.String("case_id", investigation.Case.ID)))
+		return
+	}
+	if refusal := gameState.Refusal(investigationTargetID); refusal != "" {
+		app.refuse(w, r, investigation, gameState, http.StatusForbidden, refusal)
+		return
+	}
+	classification := app.screen(ctx, investigation, inquiry)
+	if classification.Verdict == guard.VerdictInjection {
+		app.refuse(w, r, investigation, gameState, http.StatusUnprocessableEntity, injectionRefusal)
+		return
+	}
+
+	history := historyBefore(investigation, replaced)
+	confronted := *investigation
+	confronted.Clues = availableClues(investigation, history, inquiry)
+
+	var prompt, deflection, summaryPrompt *prompts.Prompt
+	// The investigation target deflects off-topic questions in character. The model classifier can flag the question
+	// in the background, so the deflection is rendered for every question.
+	if deflection, err = app.prompts.RenderCase(investigation.Case.ID, deflectionTemplate, investigation); err != nil {
+		app.serverError(w, r, errors.Wrap(err, "render deflection"))
+		return
+	}
+	if classification.Verdict == guard.VerdictOffTopic {
+		// Off-topic questions never reach the model.
+		prompt = deflection
+	} else if prompt, err = app.prompts.Build(&confronted); err != nil {
+		app.serverError(w, r, errors.Wrap(err, "build prompt", slog.String("investigation_target_id", investigationTargetID)))
+		return
+	}
+	if summaryPrompt, err = app.prompts.RenderCase(investigation.Case.ID, "summary", investigation); err != nil {
+		app.serverError(w, r, errors.Wrap(err, "render summary prompt"))
+		return
+	}
+
+	var completionID int64
+	if replaced == nil {
+		completionID, err = app.investigations.CreateCompletion(
+			ctx,
+			investigationTargetID,
+			userID,
+			lastCompletionID(investigation),
+			inquiry,
+			prompt.Version,
+		)
+	} else {
+		completionID, err = app.investigations.CreateAlternative(ctx, replaced.ID, userID, inquiry, prompt.Version)
+	}
+	if errors.Is(err, repositories.ErrGameOver) {
+		// Another question used up the budget while this one was being prepared.
+		app.refuseGameOver(w, r, investigation)
+		return
+	}
+	if err != nil {
+		if errors.Is(err, repositories.ErrInvalidParent) || errors.Is(err, sql.ErrNoRows) {
+			// Likely a double submission. The investigation has moved on since the player loaded the page.
+			http.Error(w, http.StatusText(http.StatusConflict), http.StatusConflict)
 			return
 		}
-		// Flush the buffer to ensure the chunk is sent immediately
-		app.logger.LogAttrs(ctx, slog.LevelDebug, "flushing chunk")
-		flusher.Flush()
+		app.serverError(w, r, errors.Wrap(err, "create completion"))
+		return
 	}
 
-	// http.Redirect(w, r, r.RequestURI, http.StatusSeeOther)
+	app.startCompletion(ctx, completionJob{
+		completionID:        completionID,
+		userID:              userID,
+		inquiry:             inquiry,
+		investigation:       investigation,
+		systemPrompt:        prompt.System,
+		summaryInstructions: summaryPrompt.System,
+		deflection:          deflection.System,
+		offTopic:            classification.Verdict == guard.VerdictOffTopic,
+		history:             history,
+		candidateClues:      undiscoveredClues(&confronted, history),
+	})
+
+	http.Redirect(w, r, investigationPath(r), http.StatusSeeOther)
 }
 
 const timeoutBody = `<html lang="en">
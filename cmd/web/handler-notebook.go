@@ -0,0 +1,244 @@
+package main
+
+import (
+	"database/sql"
+	"fmt"
+	"github.com/myrjola/sheerluck/internal/contexthelpers"
+	"github.com/myrjola/sheerluck/internal/errors"
+	"github.com/myrjola/sheerluck/internal/models"
+	"github.com/myrjola/sheerluck/internal/repositories"
+	"log/slog"
+	"net/http"
+	"net/url"
+	"strconv"
+	"strings"
+)
+
+// maxNoteLength mirrors the length check of the notes table.
+const maxNoteLength = 2047
+
+// notebookTemplateData renders the notebook component shared by the notebook page and the side panel of the
+// investigation page.
+type notebookTemplateData struct {
+	Notebook models.Notebook
+	// NotebookPath is the path of the notebook page the notebook forms post to.
+	NotebookPath string
+	// ReturnPath is the page the player returns to after submitting a notebook form.
+	ReturnPath string
+	// Tags are the investigation targets the player can tag the notes with.
+	Tags []models.InvestigationTarget
+	// DefaultTag is the ID of the investigation target new notes are tagged with by default.
+	DefaultTag string
+	// Editable enables editing and deleting the notes. Editing requires all the investigation targets of the case in
+	// Tags so that no tags are lost.
+	Editable bool
+}
+
+type notebookPageTemplateData struct {
+	BaseTemplateData
+
+	Case     models.Case
+	Notebook notebookTemplateData
+}
+
+// notebookPath returns the path of the notebook page of the request's case.
+func notebookPath(r *http.Request) string {
+	return fmt.Sprintf("/cases/%s/notebook", url.PathEscape(r.PathValue("caseID")))
+}
+
+// notebookReturnPath returns the page to return to after submitting a notebook form. Only the pages of the case are
+// allowed to avoid redirecting the player elsewhere.
+func notebookReturnPath(r *http.Request) string {
+	returnPath := r.PostFormValue("return")
+	if strings.HasPrefix(returnPath, fmt.Sprintf("/cases/%s/", url.PathEscape(r.PathValue("caseID")))) {
+		return returnPath
+	}
+	return notebookPath(r)
+}
+
+// notebookGET shows the player's notebook for the case.
+func (app *application) notebookGET(w http.ResponseWriter, r *http.Request) {
+	ctx := r.Context()
+	userID := contexthelpers.AuthenticatedUserID(ctx)
+	caseID := r.PathValue("caseID")
+	overview, err := app.cases.Get(ctx, caseID, userID)
+	if errors.Is(err, sql.ErrNoRows) {
+		http.NotFound(w, r)
+		return
+	}
+	if err != nil {
+		app.serverError(w, r, errors.Wrap(err, "get case overview", slog.String("case_id", caseID)))
+		return
+	}
+	notebook, err := app.notebooks.Get(ctx, caseID, userID)
+	if err != nil {
+		app.serverError(w, r, errors.Wrap(err, "get notebook", slog.String("case_id", caseID)))
+		return
+	}
+	tags := make([]models.InvestigationTarget, 0, len(overview.Targets))
+	for _, target := range overview.Targets {
+		// Locked targets stay hidden until the player unlocks them.
+		if !target.Locked {
+			tags = append(tags, target.Target)
+		}
+	}
+	app.render(w, r, http.StatusOK, "notebook", notebookPageTemplateData{
+		BaseTemplateData: newBaseTemplateData(r),
+		Case:             overview.Case,
+		Notebook: notebookTemplateData{
+			Notebook:     *notebook,
+			NotebookPath: notebookPath(r),
+			ReturnPath:   notebookPath(r),
+			Tags:         tags,
+			DefaultTag:   "",
+			Editable:     true,
+		},
+	})
+}
+
+// parseNoteForm reads the text and the tags of a note from the form. It returns false if the note is invalid.
+func parseNoteForm(r *http.Request) (string, []string, bool) {
+	text := strings.TrimSpace(r.PostFormValue("text"))
+	if text == "" || len(text) > maxNoteLength {
+		return "", nil, false
+	}
+	return text, r.PostForm["tag"], true
+}
+
+// notebookNotePOST writes a new note to the notebook.
+func (app *application) notebookNotePOST(w http.ResponseWriter, r *http.Request) {
+	ctx := r.Context()
+	userID := contexthelpers.AuthenticatedUserID(ctx)
+	caseID := r.PathValue("caseID")
+	text, tags, ok := parseNoteForm(r)
+	if !ok {
+		http.Error(w, http.StatusText(http.StatusBadRequest), http.StatusBadRequest)
+		return
+	}
+	if _, err := app.notebooks.CreateNote(ctx, caseID, userID, text, tags); err != nil {
+		switch {
+		case errors.Is(err, sql.ErrNoRows):
+			http.NotFound(w, r)
+		case errors.Is(err, repositories.ErrUnknownTarget):
+			http.Error(w, http.StatusText(http.StatusBadRequest), http.StatusBadRequest)
+		default:
+			app.serverError(w, r, errors.Wrap(err, "create note", slog.String("case_id", caseID)))
+		}
+		return
+	}
+	http.Redirect(w, r, notebookReturnPath(r), http.StatusSeeOther)
+}
+
+// notebookNoteEditPOST replaces the text and the tags of a note.
+func (app *application) notebookNoteEditPOST(w http.ResponseWriter, r *http.Request) {
+	ctx := r.Context()
+	userID := contexthelpers.AuthenticatedUserID(ctx)
+	caseID := r.PathValue("caseID")
+	noteID, err := strconv.ParseInt(r.PathValue("noteID"), 10, 64)
+	if err != nil {
+		http.NotFound(w, r)
+		return
+	}
+	text, tags, ok := parseNoteForm(r)
+	if !ok {
+		http.Error(w, http.StatusText(http.StatusBadRequest), http.StatusBadRequest)
+		return
+	}
+	if err = app.notebooks.UpdateNote(ctx, noteID, caseID, userID, text, tags); err != nil {
+		switch {
+		case errors.Is(err, sql.ErrNoRows):
+			http.NotFound(w, r)
+		case errors.Is(err, repositories.ErrUnknownTarget):
+			http.Error(w, http.StatusText(http.StatusBadRequest), http.StatusBadRequest)
+		default:
+			app.serverError(w, r, errors.Wrap(err, "update note", slog.Int64("note_id", noteID)))
+		}
+		return
+	}
+	http.Redirect(w, r, notebookReturnPath(r), http.StatusSeeOther)
+}
+
+// notebookNoteDeletePOST deletes a note.
+func (app *application) notebookNoteDeletePOST(w http.ResponseWriter, r *http.Request) {
+	ctx := r.Context()
+	userID := contexthelpers.AuthenticatedUserID(ctx)
+	noteID, err := strconv.ParseInt(r.PathValue("noteID"), 10, 64)
+	if err != nil {
+		http.NotFound(w, r)
+		return
+	}
+	if err = app.notebooks.DeleteNote(ctx, noteID, r.PathValue("caseID"), userID); err != nil {
+		if errors.Is(err, sql.ErrNoRows) {
+			http.NotFound(w, r)
+			return
+		}
+		app.serverError(w, r, errors.Wrap(err, "delete note", slog.Int64("note_id", noteID)))
+		return
+	}
+	http.Redirect(w, r, notebookReturnPath(r), http.StatusSeeOther)
+}
+
+// notebookPinnedCompletionPOST pins a question and its answer to the notebook.
+func (app *application) notebookPinnedCompletionPOST(w http.ResponseWriter, r *http.Request) {
+	ctx := r.Context()
+	userID := contexthelpers.AuthenticatedUserID(ctx)
+	completionID, err := strconv.ParseInt(r.PostFormValue("completion"), 10, 64)
+	if err != nil {
+		http.Error(w, http.StatusText(http.StatusBadRequest), http.StatusBadRequest)
+		return
+	}
+	if err = app.notebooks.PinCompletion(ctx, completionID, r.PathValue("caseID"), userID); err != nil {
+		if errors.Is(err, sql.ErrNoRows) {
+			http.Error(w, http.StatusText(http.StatusBadRequest), http.StatusBadRequest)
+			return
+		}
+		app.serverError(w, r, errors.Wrap(err, "pin completion", slog.Int64("completion_id", completionID)))
+		return
+	}
+	http.Redirect(w, r, notebookReturnPath(r), http.StatusSeeOther)
+}
+
+// notebookPinnedCompletionDeletePOST unpins a question and its answer from the notebook.
+func (app *application) notebookPinnedCompletionDeletePOST(w http.ResponseWriter, r *http.Request) {
+	ctx := r.Context()
+	userID := contexthelpers.AuthenticatedUserID(ctx)
+	completionID, err := strconv.ParseInt(r.PathValue("completionID"), 10, 64)
+	if err != nil {
+		http.NotFound(w, r)
+		return
+	}
+	if err = app.notebooks.UnpinCompletion(ctx, completionID, userID); err != nil {
+		app.serverError(w, r, errors.Wrap(err, "unpin completion", slog.Int64("completion_id", completionID)))
+		return
+	}
+	http.Redirect(w, r, notebookReturnPath(r), http.StatusSeeOther)
+}
+
+// notebookPinnedCluePOST pins a discovered clue to the notebook.
+func (app *application) notebookPinnedCluePOST(w http.ResponseWriter, r *http.Request) {
+	ctx := r.Context()
+	userID := contexthelpers.AuthenticatedUserID(ctx)
+	clueID := r.PostFormValue("clue")
+	if err := app.notebooks.PinClue(ctx, clueID, r.PathValue("caseID"), userID); err != nil {
+		if errors.Is(err, sql.ErrNoRows) {
+			// Only discovered clues can be pinned.
+			http.Error(w, http.StatusText(http.StatusBadRequest), http.StatusBadRequest)
+			return
+		}
+		app.serverError(w, r, errors.Wrap(err, "pin clue", slog.String("clue_id", clueID)))
+		return
+	}
+	http.Redirect(w, r, notebookReturnPath(r), http.StatusSeeOther)
+}
+
+// notebookPinnedClueDeletePOST unpins a clue from the notebook.
+func (app *application) notebookPinnedClueDeletePOST(w http.ResponseWriter, r *http.Request) {
+	ctx := r.Context()
+	userID := contexthelpers.AuthenticatedUserID(ctx)
+	clueID := r.PathValue("clueID")
+	if err := app.notebooks.UnpinClue(ctx, clueID, userID); err != nil {
+		app.serverError(w, r, errors.Wrap(err, "unpin clue", slog.String("clue_id", clueID)))
+		return
+	}
+	http.Redirect(w, r, notebookReturnPath(r), http.StatusSeeOther)
+}
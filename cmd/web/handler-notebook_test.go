@@ -0,0 +1,94 @@
+package main
+
+import (
+	"context"
+	"github.com/myrjola/sheerluck/internal/e2etest"
+	"github.com/stretchr/testify/require"
+	"net/http"
+	"net/url"
+	"os"
+	"strings"
+	"testing"
+)
+
+func Test_application_notebook(t *testing.T) {
+	ctx := context.Background()
+	server, err := e2etest.StartServer(ctx, os.Stdout, testLookupEnv, run)
+	require.NoError(t, err)
+	client := server.Client()
+	_, err = client.Register(ctx)
+	require.NoError(t, err)
+
+	notebookPath := "/cases/rue-morgue/notebook"
+	investigationPath := "/cases/rue-morgue/investigation-targets/le-bon"
+	doc, err := client.GetDoc(ctx, notebookPath)
+	require.NoError(t, err)
+	require.Equal(t, "Notebook", doc.Find("h1").Text())
+	require.Contains(t, doc.Find("#notes").Text(), "No notes yet.")
+
+	// Notes written in the side panel of the investigation page are tagged with the investigation target.
+	doc, err = client.SubmitFormValues(ctx, investigationPath, notebookPath+"/notes", url.Values{
+		"text":   {"Le Bon seems nervous."},
+		"tag":    {"le-bon"},
+		"return": {investigationPath},
+	})
+	require.NoError(t, err)
+	require.Equal(t, "Adolphe Le Bon", doc.Find("h1").Text(), "should return to the investigation page")
+	require.Contains(t, doc.Find("#notebook #notes li").Text(), "Le Bon seems nervous.")
+	require.Equal(t, "Adolphe Le Bon", doc.Find("#notebook [data-tag='le-bon']").Text())
+
+	// Pin an answer and the clue it revealed.
+	_, doc = askQuestion(ctx, t, client, investigationPath, "Where did you get the gold watch?")
+	completionID, ok := doc.Find("#completions input[name='completion']").Attr("value")
+	require.True(t, ok, "answers can be pinned")
+	doc, err = client.SubmitFormValues(ctx, investigationPath, notebookPath+"/pinned-completions", url.Values{
+		"completion": {completionID},
+		"return":     {investigationPath},
+	})
+	require.NoError(t, err)
+	require.Contains(t, doc.Find("#pinned-completions").Text(), "You asked: Where did you get the gold watch?")
+
+	// Undiscovered clues can't be pinned.
+	resp, err := client.PostFormValues(ctx, investigationPath, notebookPath+"/pinned-clues", url.Values{
+		"clue": {"rue-morgue-window-spring"},
+	})
+	require.NoError(t, err)
+	defer resp.Body.Close()
+	require.Equal(t, http.StatusBadRequest, resp.StatusCode)
+	doc, err = client.SubmitFormValues(ctx, investigationPath, notebookPath+"/pinned-clues", url.Values{
+		"clue":   {"le-bon-victim-belongings"},
+		"return": {investigationPath},
+	})
+	require.NoError(t, err)
+	require.Contains(t, doc.Find("#pinned-clues").Text(), "The victims' belongings in Adolphe's posession")
+
+	// Edit the note on the notebook page.
+	doc, err = client.GetDoc(ctx, notebookPath)
+	require.NoError(t, err)
+	require.Equal(t, 1, doc.Find("#pinned-completions form").Length())
+	require.Equal(t, 1, doc.Find("#pinned-clues form").Length())
+	editPath, ok := doc.Find("#notes details form").First().Attr("action")
+	require.True(t, ok)
+	require.True(t, strings.HasPrefix(editPath, notebookPath+"/notes/"))
+	doc, err = client.SubmitFormValues(ctx, notebookPath, editPath, url.Values{
+		"text": {"Le Bon carried the gold."},
+		"tag":  {"le-bon", "rue-morgue"},
+	})
+	require.NoError(t, err)
+	require.Equal(t, "Notebook", doc.Find("h1").Text(), "should return to the notebook page by default")
+	require.Contains(t, doc.Find("#notes li").Text(), "Le Bon carried the gold.")
+	require.Equal(t, 2, doc.Find("#notes [data-tag]").Length())
+
+	// Other pages are ignored as the return path.
+	doc, err = client.SubmitFormValues(ctx, notebookPath, editPath+"/delete", url.Values{
+		"return": {"https://example.com/"},
+	})
+	require.NoError(t, err)
+	require.Equal(t, "Notebook", doc.Find("h1").Text())
+	require.Contains(t, doc.Find("#notes").Text(), "No notes yet.")
+
+	resp, err = client.Get(ctx, "/cases/nonexistent/notebook")
+	require.NoError(t, err)
+	defer resp.Body.Close()
+	require.Equal(t, http.StatusNotFound, resp.StatusCode)
+}
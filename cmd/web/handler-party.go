@@ -0,0 +1,214 @@
+package main
+
+import (
+	"database/sql"
+	"fmt"
+	"github.com/myrjola/sheerluck/internal/contexthelpers"
+	"github.com/myrjola/sheerluck/internal/errors"
+	"github.com/myrjola/sheerluck/internal/models"
+	"github.com/myrjola/sheerluck/internal/random"
+	"github.com/myrjola/sheerluck/internal/repositories"
+	"log/slog"
+	"net/http"
+	"net/url"
+	"time"
+)
+
+const (
+	// inviteTokenLength is the number of random letters in the secret of an invite link.
+	inviteTokenLength = 32
+	// partyEventCompletion tells the party members that a member has got an answer.
+	partyEventCompletion = "completion"
+	// partyEventMember tells the party members that a member has joined or left.
+	partyEventMember = "member"
+)
+
+type partyTemplateData struct {
+	BaseTemplateData
+
+	Case models.Case
+	// Party is the player's party for the case or nil if they haven't joined one.
+	Party *models.Party
+}
+
+type partyInviteTemplateData struct {
+	BaseTemplateData
+
+	Party models.Party
+	// Member reports whether the player is already a member of the party.
+	Member bool
+	// OtherParty reports whether the player is a member of another party of the case and has to leave it first.
+	OtherParty bool
+}
+
+// partyPath returns the path of the party page of the request's case.
+func partyPath(r *http.Request) string {
+	return fmt.Sprintf("/cases/%s/party", url.PathEscape(r.PathValue("caseID")))
+}
+
+// partyGET shows the player's party for the case or a form to create one.
+func (app *application) partyGET(w http.ResponseWriter, r *http.Request) {
+	ctx := r.Context()
+	userID := contexthelpers.AuthenticatedUserID(ctx)
+	caseID := r.PathValue("caseID")
+	overview, err := app.cases.Get(ctx, caseID, userID)
+	if errors.Is(err, sql.ErrNoRows) {
+		http.NotFound(w, r)
+		return
+	}
+	if err != nil {
+		app.serverError(w, r, errors.Wrap(err, "get case overview", slog.String("case_id", caseID)))
+		return
+	}
+	party, err := app.parties.Get(ctx, caseID, userID)
+	if err != nil && !errors.Is(err, sql.ErrNoRows) {
+		app.serverError(w, r, errors.Wrap(err, "get party", slog.String("case_id", caseID)))
+		return
+	}
+	app.render(w, r, http.StatusOK, "party", partyTemplateData{
+		BaseTemplateData: newBaseTemplateData(r),
+		Case:             overview.Case,
+		Party:            party,
+	})
+}
+
+// partyPOST creates a party for the case owned by the player.
+func (app *application) partyPOST(w http.ResponseWriter, r *http.Request) {
+	ctx := r.Context()
+	userID := contexthelpers.AuthenticatedUserID(ctx)
+	caseID := r.PathValue("caseID")
+	inviteToken, err := random.Letters(inviteTokenLength)
+	if err != nil {
+		app.serverError(w, r, errors.Wrap(err, "generate invite token"))
+		return
+	}
+	if _, err = app.parties.Create(ctx, caseID, userID, inviteToken); err != nil {
+		switch {
+		case errors.Is(err, sql.ErrNoRows):
+			http.NotFound(w, r)
+		case errors.Is(err, repositories.ErrPartyMember):
+			// The player already has a party, e.g., created in another tab.
+			http.Redirect(w, r, partyPath(r), http.StatusSeeOther)
+		default:
+			app.serverError(w, r, errors.Wrap(err, "create party", slog.String("case_id", caseID)))
+		}
+		return
+	}
+	http.Redirect(w, r, partyPath(r), http.StatusSeeOther)
+}
+
+// partyLeavePOST removes the player from their party of the case. The party is disbanded if the player owns it.
+func (app *application) partyLeavePOST(w http.ResponseWriter, r *http.Request) {
+	ctx := r.Context()
+	userID := contexthelpers.AuthenticatedUserID(ctx)
+	caseID := r.PathValue("caseID")
+	partyID, err := app.parties.Leave(ctx, caseID, userID)
+	if err != nil && !errors.Is(err, sql.ErrNoRows) {
+		app.serverError(w, r, errors.Wrap(err, "leave party", slog.String("case_id", caseID)))
+		return
+	}
+	if err == nil {
+		app.partyHub.Publish(partyID, partyEventMember)
+	}
+	http.Redirect(w, r, partyPath(r), http.StatusSeeOther)
+}
+
+// partyInviteGET shows the invitation to join a party.
+func (app *application) partyInviteGET(w http.ResponseWriter, r *http.Request) {
+	ctx := r.Context()
+	userID := contexthelpers.AuthenticatedUserID(ctx)
+	party, err := app.parties.GetByInvite(ctx, r.PathValue("inviteToken"), userID)
+	if errors.Is(err, sql.ErrNoRows) {
+		http.NotFound(w, r)
+		return
+	}
+	if err != nil {
+		app.serverError(w, r, errors.Wrap(err, "get party by invite"))
+		return
+	}
+	current, err := app.parties.Get(ctx, party.Case.ID, userID)
+	if err != nil && !errors.Is(err, sql.ErrNoRows) {
+		app.serverError(w, r, errors.Wrap(err, "get current party", slog.String("case_id", party.Case.ID)))
+		return
+	}
+	app.render(w, r, http.StatusOK, "partyinvite", partyInviteTemplateData{
+		BaseTemplateData: newBaseTemplateData(r),
+		Party:            *party,
+		Member:           current != nil && current.ID == party.ID,
+		OtherParty:       current != nil && current.ID != party.ID,
+	})
+}
+
+// partyInvitePOST adds the player to the party of the invite.
+func (app *application) partyInvitePOST(w http.ResponseWriter, r *http.Request) {
+	ctx := r.Context()
+	userID := contexthelpers.AuthenticatedUserID(ctx)
+	inviteToken := r.PathValue("inviteToken")
+	caseID, err := app.parties.Join(ctx, inviteToken, userID)
+	if err != nil {
+		switch {
+		case errors.Is(err, sql.ErrNoRows):
+			http.NotFound(w, r)
+		case errors.Is(err, repositories.ErrPartyMember):
+			http.Error(w, http.StatusText(http.StatusConflict), http.StatusConflict)
+		default:
+			app.serverError(w, r, errors.Wrap(err, "join party"))
+		}
+		return
+	}
+	var party *models.Party
+	if party, err = app.parties.Get(ctx, caseID, userID); err != nil {
+		app.serverError(w, r, errors.Wrap(err, "get joined party", slog.String("case_id", caseID)))
+		return
+	}
+	app.partyHub.Publish(party.ID, partyEventMember)
+	http.Redirect(w, r, fmt.Sprintf("/cases/%s/party", url.PathEscape(caseID)), http.StatusSeeOther)
+}
+
+// partyEventsGET streams the events of the player's party for the case as Server-Sent Events. The event name tells
+// what happened, e.g., "completion" when a member has got an answer, and the pages fetch the party's state again.
+func (app *application) partyEventsGET(w http.ResponseWriter, r *http.Request) {
+	ctx := r.Context()
+	userID := contexthelpers.AuthenticatedUserID(ctx)
+	caseID := r.PathValue("caseID")
+	party, err := app.parties.Get(ctx, caseID, userID)
+	if errors.Is(err, sql.ErrNoRows) {
+		http.NotFound(w, r)
+		return
+	}
+	if err != nil {
+		app.serverError(w, r, errors.Wrap(err, "get party", slog.String("case_id", caseID)))
+		return
+	}
+
+	rc := http.NewResponseController(w)
+	// The members stay on the page for as long as they investigate.
+	if err = rc.SetWriteDeadline(time.Time{}); err != nil {
+		app.logger.LogAttrs(ctx, slog.LevelWarn, "could not clear write deadline", errors.SlogError(err))
+	}
+	w.Header().Set("Content-Type", "text/event-stream")
+	w.Header().Set("Cache-Control", "no-cache")
+	events := &sseEventWriter{w: w, rc: rc, id: 0}
+
+	channel, unsubscribe := app.partyHub.Subscribe(party.ID)
+	defer unsubscribe()
+	// Flush the headers so that the browser knows it's connected.
+	if err = rc.Flush(); err != nil {
+		app.logger.LogAttrs(ctx, slog.LevelDebug, "could not flush party stream", errors.SlogError(err))
+		return
+	}
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case event, ok := <-channel:
+			if !ok {
+				return
+			}
+			if err = events.write(event, ""); err != nil {
+				app.logger.LogAttrs(ctx, slog.LevelDebug, "party stream interrupted", errors.SlogError(err))
+				return
+			}
+		}
+	}
+}
@@ -0,0 +1,89 @@
+package main
+
+import (
+	"bufio"
+	"context"
+	"github.com/myrjola/sheerluck/internal/e2etest"
+	"github.com/stretchr/testify/require"
+	"net/http"
+	"os"
+	"testing"
+	"time"
+)
+
+func Test_application_party(t *testing.T) {
+	ctx := context.Background()
+	server, err := e2etest.StartServer(ctx, os.Stdout, testLookupEnv, run)
+	require.NoError(t, err)
+	owner := server.Client()
+	_, err = owner.Register(ctx)
+	require.NoError(t, err)
+	member, err := e2etest.NewClient(server.URL(), "localhost", "http://localhost:0")
+	require.NoError(t, err)
+	_, err = member.Register(ctx)
+	require.NoError(t, err)
+
+	partyPath := "/cases/rue-morgue/party"
+	doc, err := owner.SubmitForm(ctx, partyPath, partyPath)
+	require.NoError(t, err)
+	invitePath, ok := doc.Find("#invite a").Attr("href")
+	require.True(t, ok, "the owner gets an invite link")
+	require.Equal(t, 1, doc.Find("#party-members li").Length())
+
+	// Only the members can follow the party.
+	resp, err := member.Get(ctx, partyPath+"/events")
+	require.NoError(t, err)
+	defer resp.Body.Close()
+	require.Equal(t, http.StatusNotFound, resp.StatusCode)
+
+	doc, err = member.GetDoc(ctx, invitePath)
+	require.NoError(t, err)
+	require.Equal(t, 1, doc.Find("#party-members li").Length())
+	doc, err = member.SubmitForm(ctx, invitePath, invitePath)
+	require.NoError(t, err)
+	require.Equal(t, 2, doc.Find("#party-members li").Length(), "should redirect to the party page")
+	require.Equal(t, 1, doc.Find("#party-members li:contains('you')").Length())
+
+	// The member is notified live when the owner gets an answer.
+	streamCtx, cancel := context.WithTimeout(ctx, 10*time.Second)
+	defer cancel()
+	resp, err = member.Get(streamCtx, partyPath+"/events")
+	require.NoError(t, err)
+	defer resp.Body.Close()
+	require.Equal(t, "text/event-stream", resp.Header.Get("Content-Type"))
+	askQuestion(ctx, t, owner, "/cases/rue-morgue/investigation-targets/le-bon", "Where did you get the gold watch?")
+	scanner := bufio.NewScanner(resp.Body)
+	notified := false
+	for !notified && scanner.Scan() {
+		notified = scanner.Text() == "event: "+partyEventCompletion
+	}
+	require.True(t, notified, "member should be notified about the answer")
+
+	doc, err = member.GetDoc(ctx, partyPath)
+	require.NoError(t, err)
+	require.Contains(t, doc.Find("#party-completions").Text(), "You asked: Where did you get the gold watch?")
+	require.Equal(t, 1, doc.Find("#party-clues li").Length())
+	doc, err = member.GetDoc(ctx, "/cases/rue-morgue/investigation-targets/le-bon")
+	require.NoError(t, err)
+	require.Contains(t, doc.Find("#party-completions").Text(), "Where did you get the gold watch?")
+	require.Equal(t, 0, doc.Find("#completions article").Length(), "the member keeps their own history")
+	doc, err = owner.GetDoc(ctx, "/cases/rue-morgue/investigation-targets/le-bon")
+	require.NoError(t, err)
+	require.NotContains(t, doc.Find("#party-completions").Text(), "gold watch", "own questions are in the history")
+
+	doc, err = owner.GetDoc(ctx, invitePath)
+	require.NoError(t, err)
+	require.Contains(t, doc.Text(), "You are already a member of the party.")
+
+	// Disbanding the party ends it for the member too.
+	doc, err = owner.SubmitForm(ctx, partyPath, partyPath+"/leave")
+	require.NoError(t, err)
+	require.Equal(t, 1, doc.Find("form[action='/cases/rue-morgue/party'] button").Length())
+	doc, err = member.GetDoc(ctx, partyPath)
+	require.NoError(t, err)
+	require.Contains(t, doc.Text(), "Start a party")
+	resp, err = member.Get(ctx, invitePath)
+	require.NoError(t, err)
+	defer resp.Body.Close()
+	require.Equal(t, http.StatusNotFound, resp.StatusCode)
+}
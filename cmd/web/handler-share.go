@@ -0,0 +1,127 @@
+package main
+
+import (
+	"database/sql"
+	"github.com/myrjola/sheerluck/internal/contexthelpers"
+	"github.com/myrjola/sheerluck/internal/errors"
+	"github.com/myrjola/sheerluck/internal/models"
+	"github.com/myrjola/sheerluck/internal/sharetoken"
+	"github.com/myrjola/sheerluck/internal/transcript"
+	"log/slog"
+	"net/http"
+	"strconv"
+)
+
+// shareLink is a share of the player's solved case with the path of its link.
+type shareLink struct {
+	Share models.Share
+	Path  string
+}
+
+type replayTemplateData struct {
+	BaseTemplateData
+
+	// Transcript is the shared investigation without the player's notes.
+	Transcript transcript.Transcript
+	Solution   models.CaseSolution
+	// BlurSpoilers blurs the clues and the solution until the viewer reveals them.
+	BlurSpoilers bool
+}
+
+// sharePath returns the path of the link of the share.
+func (app *application) sharePath(share models.Share) string {
+	return "/shared/" + app.shareSigner.Sign(share.ID, share.Nonce)
+}
+
+// listShareLinks lists the links of the user's shares of the case.
+func (app *application) listShareLinks(r *http.Request, caseID string, userID []byte) ([]shareLink, error) {
+	shares, err := app.shares.List(r.Context(), caseID, userID)
+	if err != nil {
+		return nil, errors.Wrap(err, "list shares")
+	}
+	links := make([]shareLink, 0, len(shares))
+	for _, share := range shares {
+		links = append(links, shareLink{Share: share, Path: app.sharePath(share)})
+	}
+	return links, nil
+}
+
+// sharePOST creates a link sharing a replay of the player's solved case.
+func (app *application) sharePOST(w http.ResponseWriter, r *http.Request) {
+	ctx := r.Context()
+	userID := contexthelpers.AuthenticatedUserID(ctx)
+	caseID := r.PathValue("caseID")
+	blurSpoilers := r.PostFormValue("blur-spoilers") != ""
+	if _, err := app.shares.Create(ctx, caseID, userID, blurSpoilers); err != nil {
+		if errors.Is(err, sql.ErrNoRows) {
+			// Only solved cases can be shared.
+			http.NotFound(w, r)
+			return
+		}
+		app.serverError(w, r, errors.Wrap(err, "create share", slog.String("case_id", caseID)))
+		return
+	}
+	http.Redirect(w, r, accusationPath(r)+"#shares", http.StatusSeeOther)
+}
+
+// shareRevokePOST revokes the player's share so that its link stops working.
+func (app *application) shareRevokePOST(w http.ResponseWriter, r *http.Request) {
+	ctx := r.Context()
+	userID := contexthelpers.AuthenticatedUserID(ctx)
+	shareID, err := strconv.ParseInt(r.PathValue("shareID"), 10, 64)
+	if err != nil {
+		http.NotFound(w, r)
+		return
+	}
+	if err = app.shares.Revoke(ctx, shareID, r.PathValue("caseID"), userID); err != nil {
+		if errors.Is(err, sql.ErrNoRows) {
+			http.NotFound(w, r)
+			return
+		}
+		app.serverError(w, r, errors.Wrap(err, "revoke share", slog.Int64("share_id", shareID)))
+		return
+	}
+	http.Redirect(w, r, accusationPath(r)+"#shares", http.StatusSeeOther)
+}
+
+// sharedGET shows the read-only replay of the shared case to anyone with the link.
+func (app *application) sharedGET(w http.ResponseWriter, r *http.Request) {
+	ctx := r.Context()
+	shareID, nonce, err := app.shareSigner.Verify(r.PathValue("token"))
+	if errors.Is(err, sharetoken.ErrInvalidToken) {
+		http.NotFound(w, r)
+		return
+	}
+	if err != nil {
+		app.serverError(w, r, errors.Wrap(err, "verify share token"))
+		return
+	}
+	share, err := app.shares.Get(ctx, shareID, nonce)
+	if errors.Is(err, sql.ErrNoRows) {
+		// The share has been revoked.
+		http.NotFound(w, r)
+		return
+	}
+	if err != nil {
+		app.serverError(w, r, errors.Wrap(err, "get share", slog.Int64("share_id", shareID)))
+		return
+	}
+	t, err := app.getTranscript(ctx, share.CaseID, share.UserID)
+	if err != nil {
+		app.serverError(w, r, errors.Wrap(err, "get transcript", slog.Int64("share_id", shareID)))
+		return
+	}
+	// The notes are the player's private thoughts.
+	t.Notes = nil
+	solution, err := app.accusations.GetSolution(ctx, share.CaseID)
+	if err != nil {
+		app.serverError(w, r, errors.Wrap(err, "get solution", slog.Int64("share_id", shareID)))
+		return
+	}
+	app.render(w, r, http.StatusOK, "replay", replayTemplateData{
+		BaseTemplateData: newBaseTemplateData(r),
+		Transcript:       *t,
+		Solution:         *solution,
+		BlurSpoilers:     share.BlurSpoilers,
+	})
+}
@@ -0,0 +1,86 @@
+package main
+
+import (
+	"context"
+	"github.com/myrjola/sheerluck/internal/e2etest"
+	"github.com/stretchr/testify/require"
+	"net/http"
+	"net/url"
+	"os"
+	"testing"
+)
+
+func Test_application_share(t *testing.T) {
+	ctx := context.Background()
+	server, err := e2etest.StartServer(ctx, os.Stdout, testLookupEnv, run)
+	require.NoError(t, err)
+	client := server.Client()
+	_, err = client.Register(ctx)
+	require.NoError(t, err)
+
+	askQuestion(ctx, t, client, "/cases/rue-morgue/investigation-targets/le-bon", "Did you loan the victims money?")
+	notebookPath := "/cases/rue-morgue/notebook"
+	_, err = client.SubmitFormValues(ctx, notebookPath, notebookPath+"/notes", url.Values{
+		"text": {"My private hunch."},
+	})
+	require.NoError(t, err)
+
+	accusationPath := "/cases/rue-morgue/accusation"
+	_, err = client.SubmitFormValues(ctx, accusationPath, accusationPath, url.Values{
+		"culprit": {"rue-morgue-ourang-outang"},
+		"motive":  {"It panicked when the ladies screamed."},
+		"clue":    {"le-bon-last-meeting-with-the-victim"},
+	})
+	require.NoError(t, err)
+	doc, err := client.SubmitFormValues(ctx, accusationPath, "/cases/rue-morgue/shares", url.Values{
+		"blur-spoilers": {"on"},
+	})
+	require.NoError(t, err)
+	sharePath, ok := doc.Find("#shares li a").Attr("href")
+	require.True(t, ok, "the player gets a share link")
+
+	// Anyone with the link can watch the replay without an account.
+	viewer, err := e2etest.NewClient(server.URL(), "localhost", "http://localhost:0")
+	require.NoError(t, err)
+	doc, err = viewer.GetDoc(ctx, sharePath)
+	require.NoError(t, err)
+	require.Contains(t, doc.Find("#target-le-bon").Text(), "You asked: Did you loan the victims money?")
+	require.Equal(t, 1, doc.Find("#clues li[data-spoiler]").Length())
+	require.Equal(t, 1, doc.Find("#accusation [data-spoiler]").Length())
+	require.Contains(t, doc.Find("#solution [data-spoiler]").Text(), "The culprit was An Ourang-Outang.")
+	require.NotContains(t, doc.Text(), "My private hunch.", "the notes stay private")
+
+	// Tampered links don't work.
+	resp, err := viewer.Get(ctx, sharePath+"x")
+	require.NoError(t, err)
+	require.NoError(t, resp.Body.Close())
+	require.Equal(t, http.StatusNotFound, resp.StatusCode)
+
+	// Revoked links stop working.
+	doc, err = client.GetDoc(ctx, accusationPath)
+	require.NoError(t, err)
+	revokeAction, ok := doc.Find("#shares li form").Attr("action")
+	require.True(t, ok)
+	doc, err = client.SubmitForm(ctx, accusationPath, revokeAction)
+	require.NoError(t, err)
+	require.Equal(t, 0, doc.Find("#shares li").Length())
+	resp, err = viewer.Get(ctx, sharePath)
+	require.NoError(t, err)
+	require.NoError(t, resp.Body.Close())
+	require.Equal(t, http.StatusNotFound, resp.StatusCode)
+
+	// A revoked link stays revoked although the new share reuses its ID.
+	doc, err = client.SubmitFormValues(ctx, accusationPath, "/cases/rue-morgue/shares", url.Values{})
+	require.NoError(t, err)
+	newSharePath, ok := doc.Find("#shares li a").Attr("href")
+	require.True(t, ok)
+	require.NotEqual(t, sharePath, newSharePath)
+	resp, err = viewer.Get(ctx, sharePath)
+	require.NoError(t, err)
+	require.NoError(t, resp.Body.Close())
+	require.Equal(t, http.StatusNotFound, resp.StatusCode)
+	resp, err = viewer.Get(ctx, newSharePath)
+	require.NoError(t, err)
+	require.NoError(t, resp.Body.Close())
+	require.Equal(t, http.StatusOK, resp.StatusCode)
+}
@@ -0,0 +1,122 @@
+package main
+
+import (
+	"bytes"
+	"context"
+	"database/sql"
+	"encoding/json"
+	"fmt"
+	"github.com/myrjola/sheerluck/internal/contexthelpers"
+	"github.com/myrjola/sheerluck/internal/errors"
+	"github.com/myrjola/sheerluck/internal/models"
+	"github.com/myrjola/sheerluck/internal/transcript"
+	"log/slog"
+	"net/http"
+	"time"
+)
+
+type transcriptTemplateData struct {
+	BaseTemplateData
+
+	Transcript transcript.Transcript
+	// BlurSpoilers marks the clues and the accusation as spoilers.
+	BlurSpoilers bool
+}
+
+// getTranscript builds the transcript of the user's investigation of the case. It returns sql.ErrNoRows if the case
+// doesn't exist.
+func (app *application) getTranscript(
+	ctx context.Context,
+	caseID string,
+	userID []byte,
+) (*transcript.Transcript, error) {
+	overview, err := app.cases.Get(ctx, caseID, userID)
+	if err != nil {
+		return nil, errors.Wrap(err, "get case overview")
+	}
+	investigations := make([]models.Investigation, 0, len(overview.Targets))
+	for _, target := range overview.Targets {
+		// Locked targets stay hidden until the player unlocks them.
+		if target.Locked {
+			continue
+		}
+		var investigation *models.Investigation
+		if investigation, err = app.investigations.Get(ctx, target.Target.ID, userID); err != nil {
+			return nil, errors.Wrap(err, "get investigation", slog.String("investigation_target_id", target.Target.ID))
+		}
+		investigations = append(investigations, *investigation)
+	}
+	notebook, err := app.notebooks.Get(ctx, caseID, userID)
+	if err != nil {
+		return nil, errors.Wrap(err, "get notebook")
+	}
+	accusation, err := app.accusations.Get(ctx, caseID, userID)
+	if err != nil && !errors.Is(err, sql.ErrNoRows) {
+		return nil, errors.Wrap(err, "get accusation")
+	}
+	t := transcript.New(*overview, investigations, *notebook, accusation, time.Now())
+	return &t, nil
+}
+
+// transcriptResponse writes the transcript or the error getting it. It reports whether the transcript was got.
+func (app *application) transcriptResponse(w http.ResponseWriter, r *http.Request) (*transcript.Transcript, bool) {
+	t, err := app.getTranscript(r.Context(), r.PathValue("caseID"), contexthelpers.AuthenticatedUserID(r.Context()))
+	if errors.Is(err, sql.ErrNoRows) {
+		http.NotFound(w, r)
+		return nil, false
+	}
+	if err != nil {
+		app.serverError(w, r, errors.Wrap(err, "get transcript", slog.String("case_id", r.PathValue("caseID"))))
+		return nil, false
+	}
+	return t, true
+}
+
+// download writes body as a file attachment named after the case.
+func download(w http.ResponseWriter, t *transcript.Transcript, extension string, contentType string, body []byte) {
+	w.Header().Set("Content-Type", contentType)
+	w.Header().Set("Content-Disposition",
+		fmt.Sprintf("attachment; filename=\"%s-transcript.%s\"", t.Case.ID, extension))
+	_, _ = w.Write(body)
+}
+
+// transcriptGET shows the print-friendly transcript of the player's investigation.
+func (app *application) transcriptGET(w http.ResponseWriter, r *http.Request) {
+	t, ok := app.transcriptResponse(w, r)
+	if !ok {
+		return
+	}
+	app.render(w, r, http.StatusOK, "transcript", transcriptTemplateData{
+		BaseTemplateData: newBaseTemplateData(r),
+		Transcript:       *t,
+		BlurSpoilers:     false,
+	})
+}
+
+// transcriptMarkdownGET downloads the transcript of the player's investigation as Markdown.
+func (app *application) transcriptMarkdownGET(w http.ResponseWriter, r *http.Request) {
+	t, ok := app.transcriptResponse(w, r)
+	if !ok {
+		return
+	}
+	var buf bytes.Buffer
+	if err := t.WriteMarkdown(&buf); err != nil {
+		app.serverError(w, r, errors.Wrap(err, "write markdown transcript"))
+		return
+	}
+	download(w, t, "md", "text/markdown; charset=utf-8", buf.Bytes())
+}
+
+// transcriptJSONGET downloads the transcript of the player's investigation as JSON for analysis.
+func (app *application) transcriptJSONGET(w http.ResponseWriter, r *http.Request) {
+	t, ok := app.transcriptResponse(w, r)
+	if !ok {
+		return
+	}
+	body, err := json.MarshalIndent(t, "", "  ")
+	if err != nil {
+		app.serverError(w, r, errors.Wrap(err, "marshal transcript"))
+		return
+	}
+	download(w, t, "json", "application/json", body)
+}
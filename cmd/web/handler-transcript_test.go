@@ -0,0 +1,73 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"github.com/myrjola/sheerluck/internal/e2etest"
+	"github.com/myrjola/sheerluck/internal/transcript"
+	"github.com/stretchr/testify/require"
+	"io"
+	"net/http"
+	"net/url"
+	"os"
+	"testing"
+)
+
+func Test_application_transcript(t *testing.T) {
+	ctx := context.Background()
+	server, err := e2etest.StartServer(ctx, os.Stdout, testLookupEnv, run)
+	require.NoError(t, err)
+	client := server.Client()
+	_, err = client.Register(ctx)
+	require.NoError(t, err)
+
+	casePath := "/cases/rue-morgue"
+	doc, err := client.GetDoc(ctx, casePath+"/transcript")
+	require.NoError(t, err)
+	require.Contains(t, doc.Find("#target-le-bon").Text(), "No questions asked.")
+	require.Equal(t, 0, doc.Find("#target-gold-watch").Length(), "locked targets are left out")
+
+	askQuestion(ctx, t, client, casePath+"/investigation-targets/le-bon", "Where did you get the gold watch?")
+	_, err = client.SubmitFormValues(ctx, casePath+"/notebook", casePath+"/notebook/notes", url.Values{
+		"text": {"Le Bon seems nervous."},
+		"tag":  {"le-bon"},
+	})
+	require.NoError(t, err)
+
+	doc, err = client.GetDoc(ctx, casePath)
+	require.NoError(t, err)
+	require.Equal(t, 1, doc.Find("a[href='/cases/rue-morgue/transcript.json']").Length())
+
+	doc, err = client.GetDoc(ctx, casePath+"/transcript")
+	require.NoError(t, err)
+	require.Contains(t, doc.Find("#target-le-bon").Text(), "You asked: Where did you get the gold watch?")
+	require.Contains(t, doc.Find("#notes").Text(), "Le Bon seems nervous.")
+	require.Equal(t, 1, doc.Find("#target-gold-watch").Length(), "the answer unlocked the gold watch")
+
+	resp, err := client.Get(ctx, casePath+"/transcript.md")
+	require.NoError(t, err)
+	defer resp.Body.Close()
+	require.Equal(t, http.StatusOK, resp.StatusCode)
+	require.Equal(t, `attachment; filename="rue-morgue-transcript.md"`, resp.Header.Get("Content-Disposition"))
+	markdown, err := io.ReadAll(resp.Body)
+	require.NoError(t, err)
+	require.Contains(t, string(markdown), "**Q:** Where did you get the gold watch?")
+
+	resp, err = client.Get(ctx, casePath+"/transcript.json")
+	require.NoError(t, err)
+	defer resp.Body.Close()
+	require.Equal(t, "application/json", resp.Header.Get("Content-Type"))
+	var exported transcript.Transcript
+	require.NoError(t, json.NewDecoder(resp.Body).Decode(&exported))
+	require.Equal(t, transcript.Version, exported.Version)
+	require.Equal(t, "le-bon", exported.Targets[0].ID)
+	require.Len(t, exported.Targets[0].Completions, 1)
+	require.Contains(t, exported.Targets[0].Completions[0].Clues, "le-bon-victim-belongings")
+	require.Equal(t, []string{"le-bon"}, exported.Notes[0].Tags)
+	require.Nil(t, exported.Accusation)
+
+	resp, err = client.Get(ctx, "/cases/unknown/transcript.json")
+	require.NoError(t, err)
+	defer resp.Body.Close()
+	require.Equal(t, http.StatusNotFound, resp.StatusCode)
+}
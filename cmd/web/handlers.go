@@ -12,7 +12,8 @@ import (
 
 // pageTemplate returns a template for the given page name.
 //
-// pageName corresponds to directory inside ui/templates/pages folder. It has to include a template named "page".
+// pageName corresponds to directory inside ui/templates/pages folder. It has to include a template named "page". The
+// components in ui/templates/components are shared by all pages.
 func (app *application) pageTemplate(pageName string) (*template.Template, error) {
 	var err error
 	// We need to initialize the FuncMap before parsing the files. These will be overridden in the render function.
@@ -24,7 +25,9 @@ func (app *application) pageTemplate(pageName string) (*template.Template, error
 		"csrf": func() string {
 			panic("not implemented")
 		},
-	}).ParseFS(app.templateFS, "base.gohtml", fmt.Sprintf("pages/%s/*.gohtml", pageName)); err != nil {
+	}).ParseFS(
+		app.templateFS, "base.gohtml", "components/*.gohtml", fmt.Sprintf("pages/%s/*.gohtml", pageName),
+	); err != nil {
 		return nil, errors.Wrap(err, "new template")
 	}
 	return t, nil
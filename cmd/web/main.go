@@ -2,14 +2,22 @@ package main
 
 import (
 	"context"
+	"crypto/rand"
 	"github.com/alexedwards/scs/sqlite3store"
 	"github.com/alexedwards/scs/v2"
 	"github.com/myrjola/sheerluck/internal/ai"
+	"github.com/myrjola/sheerluck/internal/broker"
+	"github.com/myrjola/sheerluck/internal/casebundle"
+	"github.com/myrjola/sheerluck/internal/discovery"
 	"github.com/myrjola/sheerluck/internal/envstruct"
 	"github.com/myrjola/sheerluck/internal/errors"
+	"github.com/myrjola/sheerluck/internal/guard"
+	"github.com/myrjola/sheerluck/internal/hints"
 	"github.com/myrjola/sheerluck/internal/logging"
 	"github.com/myrjola/sheerluck/internal/pprofserver"
+	"github.com/myrjola/sheerluck/internal/prompts"
 	"github.com/myrjola/sheerluck/internal/repositories"
+	"github.com/myrjola/sheerluck/internal/sharetoken"
 	"github.com/myrjola/sheerluck/internal/sqlite"
 	"github.com/myrjola/sheerluck/internal/webauthnhandler"
 	"io/fs"
@@ -22,11 +30,31 @@ import (
 
 type application struct {
 	logger          *slog.Logger
-	aiClient        ai.Client
+	aiProvider      ai.Provider
+	prompts         *prompts.Builder
+	clueDetector    *discovery.Detector
+	questionGuard   *guard.Guard
+	dupin           *hints.Dupin
+	historyWindow   *ai.HistoryWindow
 	webAuthnHandler *webauthnhandler.WebAuthnHandler
 	sessionManager  *scs.SessionManager
+	cases           *repositories.CaseRepository
+	accusations     *repositories.AccusationRepository
 	investigations  *repositories.InvestigationRepository
+	notebooks       *repositories.NotebookRepository
+	gameModes       *repositories.GameModeRepository
+	hints           *repositories.HintRepository
+	parties         *repositories.PartyRepository
+	shares          *repositories.ShareRepository
+	quotas          *repositories.QuotaRepository
+	users           *repositories.UserRepository
+	quotaLimits     repositories.QuotaLimits
+	shareSigner     *sharetoken.Signer
 	templateFS      fs.FS
+	// completionBroker hands the answer stream of a completion from its producer to the SSE consumer.
+	completionBroker *broker.ChannelBroker[int64, string]
+	// partyHub notifies the members of a party about the events of the party with the SSE event name.
+	partyHub *broker.Hub[int64, string]
 }
 
 type config struct {
@@ -42,6 +70,81 @@ type config struct {
 	PProfAddr string `env:"SHEERLUCK_PPROF_ADDR" envDefault:""`
 	// TemplatePath is the path to the directory containing the HTML templates.
 	TemplatePath string `env:"SHEERLUCK_TEMPLATE_PATH" envDefault:""`
+	// PromptPath is the path to the directory containing the AI prompt templates.
+	PromptPath string `env:"SHEERLUCK_PROMPT_PATH" envDefault:""`
+	// CasePath is the path to the directory containing the case bundles imported on startup.
+	CasePath string `env:"SHEERLUCK_CASE_PATH" envDefault:""`
+	// AIProvider selects the LLM backend. One of "openai", "anthropic", or "scripted" for a deterministic fake.
+	AIProvider string `env:"SHEERLUCK_AI_PROVIDER" envDefault:"openai"`
+	// AIModel is the provider-specific model name.
+	AIModel string `env:"SHEERLUCK_AI_MODEL" envDefault:"gpt-4o-mini"`
+	// AIBaseURL overrides the provider's API endpoint, e.g., http://localhost:11434/v1 for a local Ollama server.
+	AIBaseURL string `env:"SHEERLUCK_AI_BASE_URL" envDefault:""`
+	// AITemperature controls the randomness of the answers.
+	AITemperature float64 `env:"SHEERLUCK_AI_TEMPERATURE" envDefault:"0.7"`
+	// AIMaxTokens caps the length of the answers.
+	AIMaxTokens int `env:"SHEERLUCK_AI_MAX_TOKENS" envDefault:"512"`
+	// AIContextTokens is the size of the model's context window. Older parts of long interrogations are summarised to
+	// fit the window.
+	AIContextTokens int `env:"SHEERLUCK_AI_CONTEXT_TOKENS" envDefault:"8192"`
+	// AIClueClassification enables an additional LLM pass confirming the clues matched by keywords in the answers.
+	AIClueClassification bool `env:"SHEERLUCK_AI_CLUE_CLASSIFICATION" envDefault:"false"`
+	// AIGuardModel enables an additional LLM pass with this model, preferably a cheap one, classifying the questions
+	// the heuristics of the question guard let through. Empty disables the pass.
+	AIGuardModel string `env:"SHEERLUCK_AI_GUARD_MODEL" envDefault:""`
+	// QuotaUserQuestionsPerMinute limits how often a player can ask questions. Zero disables the limit.
+	QuotaUserQuestionsPerMinute int `env:"SHEERLUCK_QUOTA_USER_QUESTIONS_PER_MINUTE" envDefault:"6"`
+	// QuotaUserTokensPerDay limits the AI tokens a player can use within a day. Zero disables the limit.
+	QuotaUserTokensPerDay int `env:"SHEERLUCK_QUOTA_USER_TOKENS_PER_DAY" envDefault:"200000"`
+	// QuotaGlobalQuestionsPerMinute limits how often all players together can ask questions. Zero disables the limit.
+	QuotaGlobalQuestionsPerMinute int `env:"SHEERLUCK_QUOTA_GLOBAL_QUESTIONS_PER_MINUTE" envDefault:"120"`
+	// QuotaGlobalTokensPerDay limits the AI tokens all players together can use within a day. Zero disables the limit.
+	QuotaGlobalTokensPerDay int `env:"SHEERLUCK_QUOTA_GLOBAL_TOKENS_PER_DAY" envDefault:"5000000"`
+	// ShareKey signs the links sharing replays of solved cases. A random key is generated if it's empty, which breaks
+	// the links on restart.
+	ShareKey string `env:"SHEERLUCK_SHARE_KEY" envDefault:""`
+	// OpenAIAPIKey authenticates against OpenAI when AIProvider is "openai".
+	OpenAIAPIKey string `env:"OPENAI_API_KEY" envDefault:""`
+	// AnthropicAPIKey authenticates against Anthropic when AIProvider is "anthropic".
+	AnthropicAPIKey string `env:"ANTHROPIC_API_KEY" envDefault:""`
+}
+
+// aiConfig picks the settings relevant for the configured AI provider.
+func (cfg config) aiConfig() ai.Config {
+	apiKey := cfg.OpenAIAPIKey
+	if cfg.AIProvider == ai.ProviderAnthropic {
+		apiKey = cfg.AnthropicAPIKey
+	}
+	return ai.Config{
+		Provider:    cfg.AIProvider,
+		Model:       cfg.AIModel,
+		BaseURL:     cfg.AIBaseURL,
+		APIKey:      apiKey,
+		Temperature: cfg.AITemperature,
+		MaxTokens:   cfg.AIMaxTokens,
+	}
+}
+
+// guardMaxTokens is enough for the question classifier to reply with its verdict.
+const guardMaxTokens = 8
+
+// guardAIConfig configures the question classifier of the question guard with the AI provider settings.
+func (cfg config) guardAIConfig() ai.Config {
+	aiConfig := cfg.aiConfig()
+	aiConfig.Model = cfg.AIGuardModel
+	aiConfig.Temperature = 0
+	aiConfig.MaxTokens = guardMaxTokens
+	return aiConfig
+}
+
+// quotaLimits collects the AI quota settings.
+func (cfg config) quotaLimits() repositories.QuotaLimits {
+	return repositories.QuotaLimits{
+		UserQuestionsPerMinute:   cfg.QuotaUserQuestionsPerMinute,
+		UserTokensPerDay:         cfg.QuotaUserTokensPerDay,
+		GlobalQuestionsPerMinute: cfg.QuotaGlobalQuestionsPerMinute,
+		GlobalTokensPerDay:       cfg.QuotaGlobalTokensPerDay,
+	}
 }
 
 func run(ctx context.Context, logger *slog.Logger, lookupEnv func(string) (string, bool)) error {
@@ -63,9 +166,17 @@ func run(ctx context.Context, logger *slog.Logger, lookupEnv func(string) (strin
 	}
 
 	var htmlTemplatePath string
-	if htmlTemplatePath, err = resolveAndVerifyTemplatePath(cfg.TemplatePath); err != nil {
+	if htmlTemplatePath, err = resolveAndVerifyTemplatePath(cfg.TemplatePath, "ui", "templates"); err != nil {
 		return errors.Wrap(err, "resolve template path")
 	}
+	var promptTemplatePath string
+	if promptTemplatePath, err = resolveAndVerifyTemplatePath(cfg.PromptPath, "ui", "prompts"); err != nil {
+		return errors.Wrap(err, "resolve prompt path")
+	}
+	var casePath string
+	if casePath, err = resolveAndVerifyTemplatePath(cfg.CasePath, "ui", "cases"); err != nil {
+		return errors.Wrap(err, "resolve case path")
+	}
 
 	db, err := sqlite.NewDatabase(ctx, cfg.SqliteURL, logger)
 	if err != nil {
@@ -73,6 +184,10 @@ func run(ctx context.Context, logger *slog.Logger, lookupEnv func(string) (strin
 	}
 	logger.LogAttrs(ctx, slog.LevelInfo, "connected to db")
 
+	if err = importCases(ctx, db, logger, os.DirFS(casePath)); err != nil {
+		return errors.Wrap(err, "import cases", slog.String("path", casePath))
+	}
+
 	sessionManager := initializeSessionManager(db)
 
 	fqdn := cfg.FQDN
@@ -84,15 +199,82 @@ func run(ctx context.Context, logger *slog.Logger, lookupEnv func(string) (strin
 		return errors.Wrap(err, "new webauthn handler")
 	}
 
+	var shareKey []byte
+	if shareKey, err = resolveShareKey(ctx, cfg.ShareKey, logger); err != nil {
+		return errors.Wrap(err, "resolve share key")
+	}
+
 	investigations := repositories.NewInvestigationRepository(db, logger)
+	quotas := repositories.NewQuotaRepository(db, logger)
+
+	var aiProvider ai.Provider
+	if aiProvider, err = ai.NewProvider(cfg.aiConfig()); err != nil {
+		return errors.Wrap(err, "new AI provider")
+	}
+	aiProvider = ai.NewMeteredProvider(aiProvider, newUsageRecorder(logger, quotas))
+
+	promptBuilder := prompts.NewBuilder(os.DirFS(promptTemplatePath), os.DirFS(casePath))
+	var clueClassifier ai.Provider
+	if cfg.AIClueClassification {
+		clueClassifier = aiProvider
+	}
+	var questionClassifier ai.Provider
+	if cfg.AIGuardModel != "" {
+		if questionClassifier, err = ai.NewProvider(cfg.guardAIConfig()); err != nil {
+			return errors.Wrap(err, "new question classifier")
+		}
+		questionClassifier = ai.NewMeteredProvider(questionClassifier, newUsageRecorder(logger, quotas))
+	}
+
+	completionBroker := broker.NewChannelBroker[int64, string]()
+	go func() {
+		defer func() {
+			if excp := recover(); excp != nil {
+				panicErr := errors.DecoratePanic(excp)
+				logger.LogAttrs(ctx, slog.LevelError, "completion broker panicked", errors.SlogError(panicErr))
+			}
+		}()
+		completionBroker.Start()
+	}()
+	defer completionBroker.Stop()
+
+	partyHub := broker.NewHub[int64, string]()
+	go func() {
+		defer func() {
+			if excp := recover(); excp != nil {
+				panicErr := errors.DecoratePanic(excp)
+				logger.LogAttrs(ctx, slog.LevelError, "party hub panicked", errors.SlogError(panicErr))
+			}
+		}()
+		partyHub.Start()
+	}()
+	defer partyHub.Stop()
 
 	app := application{
-		logger:          logger,
-		aiClient:        ai.NewClient(),
-		webAuthnHandler: webAuthnHandler,
-		sessionManager:  sessionManager,
-		investigations:  investigations,
-		templateFS:      os.DirFS(htmlTemplatePath),
+		logger:           logger,
+		aiProvider:       aiProvider,
+		prompts:          promptBuilder,
+		clueDetector:     discovery.NewDetector(promptBuilder, clueClassifier, logger),
+		questionGuard:    guard.NewGuard(promptBuilder, questionClassifier, logger),
+		dupin:            hints.NewDupin(promptBuilder, aiProvider, logger),
+		historyWindow:    ai.NewHistoryWindow(aiProvider, cfg.AIContextTokens, cfg.AIMaxTokens),
+		webAuthnHandler:  webAuthnHandler,
+		sessionManager:   sessionManager,
+		cases:            repositories.NewCaseRepository(db, logger),
+		accusations:      repositories.NewAccusationRepository(db, logger),
+		investigations:   investigations,
+		notebooks:        repositories.NewNotebookRepository(db, logger),
+		gameModes:        repositories.NewGameModeRepository(db, logger),
+		hints:            repositories.NewHintRepository(db, logger),
+		parties:          repositories.NewPartyRepository(db, logger),
+		shares:           repositories.NewShareRepository(db, logger),
+		quotas:           quotas,
+		users:            repositories.NewUserRepository(db, logger),
+		quotaLimits:      cfg.quotaLimits(),
+		shareSigner:      sharetoken.NewSigner(shareKey),
+		templateFS:       os.DirFS(htmlTemplatePath),
+		completionBroker: completionBroker,
+		partyHub:         partyHub,
 	}
 
 	if err = app.configureAndStartServer(ctx, cfg.Addr); err != nil {
@@ -101,6 +283,49 @@ func run(ctx context.Context, logger *slog.Logger, lookupEnv func(string) (strin
 	return nil
 }
 
+// shareKeyLength is the length of the generated share key matching the block size of HMAC-SHA256.
+const shareKeyLength = 64
+
+// resolveShareKey returns the configured share key or generates a random one if it's not configured.
+func resolveShareKey(ctx context.Context, configured string, logger *slog.Logger) ([]byte, error) {
+	if configured != "" {
+		return []byte(configured), nil
+	}
+	key := make([]byte, shareKeyLength)
+	if _, err := rand.Read(key); err != nil {
+		return nil, errors.Wrap(err, "generate share key")
+	}
+	logger.LogAttrs(ctx, slog.LevelWarn, "SHEERLUCK_SHARE_KEY not set, shared links stop working on restart")
+	return key, nil
+}
+
+// importCases imports the case bundles in fsys. Content deleted from the bundles is kept, since removing it would
+// remove the players' progress as well. Use cmd/importcase to prune it.
+func importCases(ctx context.Context, db *sqlite.Database, logger *slog.Logger, fsys fs.FS) error {
+	var (
+		bundles []*casebundle.Bundle
+		err     error
+	)
+	if bundles, err = casebundle.LoadAll(fsys); err != nil {
+		return errors.Wrap(err, "load case bundles")
+	}
+	importer := casebundle.NewImporter(db, logger)
+	for _, bundle := range bundles {
+		var diff *casebundle.Diff
+		if diff, err = importer.Import(ctx, bundle, casebundle.ImportOptions{Prune: false, DryRun: false}); err != nil {
+			return errors.Wrap(err, "import case bundle", slog.String("dir", bundle.Dir))
+		}
+		for _, difference := range diff.Differences {
+			if difference.Change == casebundle.ChangeStale {
+				logger.LogAttrs(ctx, slog.LevelWarn, "case content deleted from bundle",
+					slog.String("case_id", diff.CaseID), slog.String("kind", difference.Kind),
+					slog.String("id", difference.ID))
+			}
+		}
+	}
+	return nil
+}
+
 func initializeSessionManager(dbs *sqlite.Database) *scs.SessionManager {
 	sessionManager := scs.New()
 	sessionManager.Store = sqlite3store.NewWithCleanupInterval(dbs.ReadWrite, 24*time.Hour) //nolint:mnd // day
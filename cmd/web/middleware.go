@@ -8,6 +8,7 @@ import (
 	"github.com/myrjola/sheerluck/internal/errors"
 	"github.com/myrjola/sheerluck/internal/logging"
 	"github.com/myrjola/sheerluck/internal/random"
+	"github.com/myrjola/sheerluck/internal/repositories"
 	"log/slog"
 	"net/http"
 )
@@ -90,11 +91,54 @@ func (app *application) mustAuthenticate(next http.Handler) http.Handler {
 		isAuthenticated := contexthelpers.IsAuthenticated(r.Context())
 		if !isAuthenticated {
 			http.Redirect(w, r, "/", http.StatusSeeOther)
+			return
 		}
 		next.ServeHTTP(w, r)
 	})
 }
 
+// mustAdmin responds with 404 Not Found unless the user is an admin so that the admin pages stay hidden.
+func (app *application) mustAdmin(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		isAdmin, err := app.users.IsAdmin(r.Context(), contexthelpers.AuthenticatedUserID(r.Context()))
+		if err != nil {
+			app.serverError(w, r, errors.Wrap(err, "check admin"))
+			return
+		}
+		if !isAdmin {
+			http.NotFound(w, r)
+			return
+		}
+		next.ServeHTTP(w, r)
+	})
+}
+
+// enforceAIQuota records the question of the user and responds with 429 Too Many Requests instead of calling next if
+// the user or all users together have exceeded the AI quotas.
+func (app *application) enforceAIQuota(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		ctx := r.Context()
+		err := app.quotas.RecordQuestion(ctx, contexthelpers.AuthenticatedUserID(ctx), app.quotaLimits)
+		switch {
+		case err == nil:
+			next.ServeHTTP(w, r)
+		case errors.Is(err, repositories.ErrQuestionRateExceeded), errors.Is(err, repositories.ErrTokenQuotaExceeded):
+			app.logger.LogAttrs(ctx, slog.LevelWarn, "AI quota exceeded", errors.SlogError(err))
+			data := quotaExceededTemplateData{
+				BaseTemplateData: newBaseTemplateData(r),
+				ReturnPath:       r.URL.Path,
+				TryTomorrow:      errors.Is(err, repositories.ErrTokenQuotaExceeded),
+			}
+			if !data.TryTomorrow {
+				w.Header().Set("Retry-After", "60")
+			}
+			app.render(w, r, http.StatusTooManyRequests, "quotaexceeded", data)
+		default:
+			app.serverError(w, r, errors.Wrap(err, "record question"))
+		}
+	})
+}
+
 // serverSentMiddleware makes our session library scs work with Server Sent Events (SSE).
 // Use this instead of app.sessionManager.LoadAndSave.
 // See https://github.com/alexedwards/scs/issues/141#issuecomment-1807075358
@@ -0,0 +1,34 @@
+package main
+
+import (
+	"context"
+	"github.com/myrjola/sheerluck/internal/ai"
+	"github.com/myrjola/sheerluck/internal/contexthelpers"
+	"github.com/myrjola/sheerluck/internal/errors"
+	"github.com/myrjola/sheerluck/internal/repositories"
+	"log/slog"
+)
+
+type quotaExceededTemplateData struct {
+	BaseTemplateData
+
+	// ReturnPath is where the player can continue after resting.
+	ReturnPath string
+	// TryTomorrow is set when the daily quota is exceeded instead of the per-minute one.
+	TryTomorrow bool
+}
+
+// newUsageRecorder records the token usage reported by the AI provider for the authenticated user of the request.
+func newUsageRecorder(logger *slog.Logger, quotas *repositories.QuotaRepository) ai.UsageRecorder {
+	return func(ctx context.Context, usage ai.Usage) {
+		userID := contexthelpers.AuthenticatedUserID(ctx)
+		if userID == nil {
+			logger.LogAttrs(ctx, slog.LevelWarn, "AI usage without authenticated user",
+				slog.Int("prompt_tokens", usage.PromptTokens), slog.Int("completion_tokens", usage.CompletionTokens))
+			return
+		}
+		if err := quotas.RecordTokens(ctx, userID, usage.PromptTokens, usage.CompletionTokens); err != nil {
+			logger.LogAttrs(ctx, slog.LevelError, "could not record AI usage", errors.SlogError(err))
+		}
+	}
+}
@@ -13,15 +13,54 @@ func (app *application) routes() http.Handler {
 	mustSession := alice.New(session.Then, app.mustAuthenticate)
 	mustSessionStreaming := alice.New(common.Then, app.streamingAuthMiddleware,
 		app.webAuthnHandler.AuthenticateMiddleware, app.mustAuthenticate)
+	// mustSessionSlow serves the requests waiting for the model. They bound the wait with their own deadline.
+	mustSessionSlow := alice.New(common.Then, app.sessionManager.LoadAndSave,
+		app.webAuthnHandler.AuthenticateMiddleware, app.mustAuthenticate)
 
 	fileServer := http.FileServer(http.Dir("./ui/static/"))
 	mux.Handle("/", notStreaming.Then(cacheForeverHeaders(fileServer)))
 
 	mux.Handle("GET /{$}", session.ThenFunc(app.home))
+	mux.Handle("GET /cases/{caseID}", mustSession.ThenFunc(app.caseGET))
+	mux.Handle("POST /cases/{caseID}/game-mode", mustSession.ThenFunc(app.gameModePOST))
+	mux.Handle("GET /cases/{caseID}/accusation", mustSession.ThenFunc(app.accusationGET))
+	mux.Handle("POST /cases/{caseID}/accusation", mustSession.ThenFunc(app.accusationPOST))
+	mux.Handle("POST /cases/{caseID}/hints", mustSessionSlow.Append(app.enforceAIQuota).ThenFunc(app.hintPOST))
+	mux.Handle("GET /cases/{caseID}/notebook", mustSession.ThenFunc(app.notebookGET))
+	mux.Handle("POST /cases/{caseID}/notebook/notes", mustSession.ThenFunc(app.notebookNotePOST))
+	mux.Handle("POST /cases/{caseID}/notebook/notes/{noteID}", mustSession.ThenFunc(app.notebookNoteEditPOST))
+	mux.Handle("POST /cases/{caseID}/notebook/notes/{noteID}/delete", mustSession.ThenFunc(app.notebookNoteDeletePOST))
+	mux.Handle("POST /cases/{caseID}/notebook/pinned-completions",
+		mustSession.ThenFunc(app.notebookPinnedCompletionPOST))
+	mux.Handle("POST /cases/{caseID}/notebook/pinned-completions/{completionID}/delete",
+		mustSession.ThenFunc(app.notebookPinnedCompletionDeletePOST))
+	mux.Handle("POST /cases/{caseID}/notebook/pinned-clues", mustSession.ThenFunc(app.notebookPinnedCluePOST))
+	mux.Handle("POST /cases/{caseID}/notebook/pinned-clues/{clueID}/delete",
+		mustSession.ThenFunc(app.notebookPinnedClueDeletePOST))
+	mux.Handle("GET /cases/{caseID}/party", mustSession.ThenFunc(app.partyGET))
+	mux.Handle("POST /cases/{caseID}/party", mustSession.ThenFunc(app.partyPOST))
+	mux.Handle("POST /cases/{caseID}/party/leave", mustSession.ThenFunc(app.partyLeavePOST))
+	mux.Handle("GET /cases/{caseID}/party/events", mustSessionStreaming.ThenFunc(app.partyEventsGET))
+	mux.Handle("GET /parties/{inviteToken}", mustSession.ThenFunc(app.partyInviteGET))
+	mux.Handle("POST /parties/{inviteToken}", mustSession.ThenFunc(app.partyInvitePOST))
+	mux.Handle("POST /cases/{caseID}/shares", mustSession.ThenFunc(app.sharePOST))
+	mux.Handle("POST /cases/{caseID}/shares/{shareID}/revoke", mustSession.ThenFunc(app.shareRevokePOST))
+	mux.Handle("GET /shared/{token}", session.ThenFunc(app.sharedGET))
+	mux.Handle("GET /cases/{caseID}/transcript", mustSession.ThenFunc(app.transcriptGET))
+	mux.Handle("GET /cases/{caseID}/transcript.md", mustSession.ThenFunc(app.transcriptMarkdownGET))
+	mux.Handle("GET /cases/{caseID}/transcript.json", mustSession.ThenFunc(app.transcriptJSONGET))
 	mux.Handle("GET /cases/{caseID}/investigation-targets/{investigationTargetID}",
 		mustSession.ThenFunc(app.investigateTargetGET))
 	mux.Handle("POST /cases/{caseID}/investigation-targets/{investigationTargetID}",
-		mustSessionStreaming.ThenFunc(app.investigateTargetPOST))
+		mustSession.Append(app.enforceAIQuota).ThenFunc(app.investigateTargetPOST))
+	completionPath := "/cases/{caseID}/investigation-targets/{investigationTargetID}/completions/{completionID}"
+	mux.Handle("GET "+completionPath+"/stream",
+		mustSessionStreaming.ThenFunc(app.investigateTargetCompletionStreamGET))
+	mux.Handle("POST "+completionPath+"/alternatives",
+		mustSession.Append(app.enforceAIQuota).ThenFunc(app.investigateTargetAlternativePOST))
+	mux.Handle("POST "+completionPath+"/select", mustSession.ThenFunc(app.investigateTargetCompletionSelectPOST))
+
+	mux.Handle("GET /admin/usage", mustSession.Append(app.mustAdmin).ThenFunc(app.adminUsageGET))
 
 	mux.Handle("POST /api/registration/start", session.ThenFunc(app.beginRegistration))
 	mux.Handle("POST /api/registration/finish", session.ThenFunc(app.finishRegistration))
@@ -11,11 +11,13 @@ import (
 
 type BaseTemplateData struct {
 	Authenticated bool
+	CurrentPath   string
 }
 
 func newBaseTemplateData(r *http.Request) BaseTemplateData {
 	return BaseTemplateData{
 		Authenticated: contexthelpers.IsAuthenticated(r.Context()),
+		CurrentPath:   contexthelpers.CurrentPath(r.Context()),
 	}
 }
 
@@ -47,15 +49,15 @@ func findModuleDir() (string, error) {
 
 // resolveAndVerifyTemplatePath resolves the template path and verifies it.
 //
-// If the templatePath is empty, it will attempt to find it from the module root.
-func resolveAndVerifyTemplatePath(templatePath string) (string, error) {
+// If the templatePath is empty, it will attempt to find it from the module root under defaultDir, e.g., "ui/templates".
+func resolveAndVerifyTemplatePath(templatePath string, defaultDir ...string) (string, error) {
 	var err error
 	if templatePath == "" {
 		var modulePath string
 		if modulePath, err = findModuleDir(); err != nil {
 			return "", errors.Wrap(err, "find module dir")
 		}
-		templatePath = filepath.Join(modulePath, "ui", "templates")
+		templatePath = filepath.Join(append([]string{modulePath}, defaultDir...)...)
 	}
 	var stat os.FileInfo
 	if stat, err = os.Stat(templatePath); err != nil {
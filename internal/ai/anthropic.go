@@ -0,0 +1,237 @@
+package ai
+
+import (
+	"bufio"
+	"bytes"
+	"context"
+	"encoding/json"
+	"github.com/myrjola/sheerluck/internal/errors"
+	"io"
+	"log/slog"
+	"net/http"
+	"strings"
+)
+
+const (
+	anthropicDefaultBaseURL = "https://api.anthropic.com"
+	anthropicVersion        = "2023-06-01"
+)
+
+// AnthropicProvider talks to the Anthropic Messages API.
+//
+// See https://docs.anthropic.com/en/api/messages.
+type AnthropicProvider struct {
+	client      *http.Client
+	baseURL     string
+	apiKey      string
+	model       string
+	temperature float64
+	maxTokens   int
+}
+
+// NewAnthropicProvider creates an [AnthropicProvider]. An empty cfg.BaseURL uses the official Anthropic endpoint.
+func NewAnthropicProvider(cfg Config) *AnthropicProvider {
+	baseURL := cfg.BaseURL
+	if baseURL == "" {
+		baseURL = anthropicDefaultBaseURL
+	}
+	return &AnthropicProvider{
+		client:      &http.Client{},
+		baseURL:     strings.TrimSuffix(baseURL, "/"),
+		apiKey:      cfg.APIKey,
+		model:       cfg.Model,
+		temperature: cfg.Temperature,
+		maxTokens:   cfg.MaxTokens,
+	}
+}
+
+type anthropicMessage struct {
+	Role    string `json:"role"`
+	Content string `json:"content"`
+}
+
+type anthropicRequest struct {
+	Model       string             `json:"model"`
+	MaxTokens   int                `json:"max_tokens"`
+	Temperature float64            `json:"temperature"`
+	System      string             `json:"system,omitempty"`
+	Messages    []anthropicMessage `json:"messages"`
+	Stream      bool               `json:"stream"`
+}
+
+type anthropicUsage struct {
+	InputTokens  int `json:"input_tokens"`
+	OutputTokens int `json:"output_tokens"`
+}
+
+type anthropicResponse struct {
+	Content []struct {
+		Type string `json:"type"`
+		Text string `json:"text"`
+	} `json:"content"`
+	Usage anthropicUsage `json:"usage"`
+}
+
+// anthropicEvent covers the fields we need from all the server-sent event payloads of a streamed message.
+type anthropicEvent struct {
+	Type    string `json:"type"`
+	Message struct {
+		Usage anthropicUsage `json:"usage"`
+	} `json:"message"`
+	Delta struct {
+		Type string `json:"type"`
+		Text string `json:"text"`
+	} `json:"delta"`
+	Usage anthropicUsage `json:"usage"`
+	Error struct {
+		Type    string `json:"type"`
+		Message string `json:"message"`
+	} `json:"error"`
+}
+
+// post sends the messages to the Messages API and returns the response on success.
+//
+// Anthropic accepts only user and assistant messages, so the system messages are joined to the system prompt.
+func (p *AnthropicProvider) post(ctx context.Context, messages []Message, stream bool) (*http.Response, error) {
+	var (
+		system            []string
+		anthropicMessages = make([]anthropicMessage, 0, len(messages))
+	)
+	for _, message := range messages {
+		if message.Role == RoleSystem {
+			system = append(system, message.Content)
+			continue
+		}
+		anthropicMessages = append(anthropicMessages, anthropicMessage{
+			Role:    string(message.Role),
+			Content: message.Content,
+		})
+	}
+	body, err := json.Marshal(anthropicRequest{
+		Model:       p.model,
+		MaxTokens:   p.maxTokens,
+		Temperature: p.temperature,
+		System:      strings.Join(system, "\n\n"),
+		Messages:    anthropicMessages,
+		Stream:      stream,
+	})
+	if err != nil {
+		return nil, errors.Wrap(err, "marshal request")
+	}
+	var req *http.Request
+	if req, err = http.NewRequestWithContext(
+		ctx,
+		http.MethodPost,
+		p.baseURL+"/v1/messages",
+		bytes.NewReader(body),
+	); err != nil {
+		return nil, errors.Wrap(err, "new request")
+	}
+	req.Header.Set("Content-Type", "application/json")
+	req.Header.Set("X-Api-Key", p.apiKey)
+	req.Header.Set("Anthropic-Version", anthropicVersion)
+	var resp *http.Response
+	if resp, err = p.client.Do(req); err != nil {
+		return nil, errors.Wrap(err, "do request")
+	}
+	if resp.StatusCode != http.StatusOK {
+		errorBody, _ := io.ReadAll(io.LimitReader(resp.Body, 4096)) //nolint:mnd // enough to see what went wrong.
+		_ = resp.Body.Close()
+		return nil, errors.New("unexpected status code",
+			slog.Int("status", resp.StatusCode), slog.String("body", string(errorBody)))
+	}
+	return resp, nil
+}
+
+// Complete implements [Provider].
+func (p *AnthropicProvider) Complete(ctx context.Context, messages []Message) (*Completion, error) {
+	resp, err := p.post(ctx, messages, false)
+	if err != nil {
+		return nil, errors.Wrap(err, "post messages")
+	}
+	defer func() {
+		_ = resp.Body.Close()
+	}()
+	var response anthropicResponse
+	if err = json.NewDecoder(resp.Body).Decode(&response); err != nil {
+		return nil, errors.Wrap(err, "decode response")
+	}
+	var content strings.Builder
+	for _, block := range response.Content {
+		if block.Type == "text" {
+			content.WriteString(block.Text)
+		}
+	}
+	return &Completion{
+		Content: content.String(),
+		Usage: Usage{
+			PromptTokens:     response.Usage.InputTokens,
+			CompletionTokens: response.Usage.OutputTokens,
+		},
+	}, nil
+}
+
+// Stream implements [Provider].
+//
+//nolint:ireturn // the stream implementation is an internal detail.
+func (p *AnthropicProvider) Stream(ctx context.Context, messages []Message) (Stream, error) {
+	resp, err := p.post(ctx, messages, true)
+	if err != nil {
+		return nil, errors.Wrap(err, "post messages")
+	}
+	scanner := bufio.NewScanner(resp.Body)
+	maxEventSize := 1024 * 1024
+	scanner.Buffer(make([]byte, 0, maxEventSize), maxEventSize)
+	return &anthropicStream{body: resp.Body, scanner: scanner, usage: Usage{}}, nil
+}
+
+type anthropicStream struct {
+	body    io.ReadCloser
+	scanner *bufio.Scanner
+	usage   Usage
+}
+
+func (s *anthropicStream) Recv() (string, error) {
+	for s.scanner.Scan() {
+		data, ok := strings.CutPrefix(s.scanner.Text(), "data:")
+		if !ok {
+			// We only need the data lines since the payload repeats the event type.
+			continue
+		}
+		var event anthropicEvent
+		if err := json.Unmarshal([]byte(strings.TrimSpace(data)), &event); err != nil {
+			return "", errors.Wrap(err, "unmarshal event")
+		}
+		switch event.Type {
+		case "message_start":
+			s.usage.PromptTokens = event.Message.Usage.InputTokens
+			s.usage.CompletionTokens = event.Message.Usage.OutputTokens
+		case "content_block_delta":
+			if event.Delta.Type == "text_delta" && event.Delta.Text != "" {
+				return event.Delta.Text, nil
+			}
+		case "message_delta":
+			s.usage.CompletionTokens = event.Usage.OutputTokens
+		case "message_stop":
+			return "", io.EOF
+		case "error":
+			return "", errors.New("stream error",
+				slog.String("type", event.Error.Type), slog.String("message", event.Error.Message))
+		}
+	}
+	if err := s.scanner.Err(); err != nil {
+		return "", errors.Wrap(err, "scan stream")
+	}
+	return "", errors.Wrap(io.ErrUnexpectedEOF, "stream ended before message_stop")
+}
+
+func (s *anthropicStream) Usage() Usage {
+	return s.usage
+}
+
+func (s *anthropicStream) Close() error {
+	if err := s.body.Close(); err != nil {
+		return errors.Wrap(err, "close response body")
+	}
+	return nil
+}
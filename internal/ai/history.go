@@ -0,0 +1,217 @@
+package ai
+
+import (
+	"context"
+	"github.com/myrjola/sheerluck/internal/errors"
+	"log/slog"
+	"strings"
+	"unicode/utf8"
+)
+
+// ErrContextTooSmall is returned when not even the system prompt and the question fit the context window.
+var ErrContextTooSmall = errors.NewSentinel("context window too small")
+
+const (
+	// charsPerToken is a rough average of the characters per token in English text.
+	charsPerToken = 4
+	// messageOverheadTokens accounts for the role and delimiters of a chat message.
+	messageOverheadTokens = 4
+	// safetyMarginPercent of the context window is left unused, because ApproximateTokens undercounts the tokens of
+	// text that the tokenizers split finely, such as numbers, names, and languages other than English.
+	safetyMarginPercent = 15
+)
+
+// ApproximateTokens approximates the number of tokens text takes in the model's context window.
+//
+// The providers use different tokenizers and we don't ship any of them, so the approximation counts four characters
+// per token. It's only an approximation, which is why HistoryWindow leaves a safety margin in the context window.
+func ApproximateTokens(text string) int {
+	return (utf8.RuneCountInString(text) + charsPerToken - 1) / charsPerToken
+}
+
+func approximateMessageTokens(content string) int {
+	return ApproximateTokens(content) + messageOverheadTokens
+}
+
+// Turn is a question and its answer in a conversation.
+type Turn struct {
+	Question string
+	Answer   string
+	// Summary summarises the conversation up to and including this turn. It's empty if the conversation hasn't been
+	// summarised at this turn.
+	Summary string
+}
+
+func (t Turn) approximateTokens() int {
+	return approximateMessageTokens(t.Question) + approximateMessageTokens(t.Answer)
+}
+
+// Conversation is the input for [HistoryWindow.Fit].
+type Conversation struct {
+	// System is the system prompt.
+	System string
+	// SummaryInstructions is the system prompt for summarising the turns that no longer fit the context window.
+	SummaryInstructions string
+	// Turns is the conversation history from the oldest to the latest turn.
+	Turns []Turn
+	// Question is the new question to be answered.
+	Question string
+}
+
+// FittedConversation is a conversation fitted into the context window.
+type FittedConversation struct {
+	// Messages are the chat messages to send to the provider.
+	Messages []Message
+	// Summary summarises the turns that are not included verbatim. It's empty if all the turns fit.
+	Summary string
+	// SummarizedTurns is the number of oldest turns covered by Summary.
+	SummarizedTurns int
+	// NewSummary reports that Summary was produced while fitting. Persist it as the Summary of the turn at index
+	// SummarizedTurns-1 so that it doesn't have to be recomputed for the next question.
+	NewSummary bool
+}
+
+// HistoryWindow fits conversations into the context window of a model.
+//
+// The most recent turns are kept verbatim while older turns are replaced with a rolling summary. When the turns no
+// longer fit, the summary is extended to cover the older turns so that the verbatim turns take at most half of the
+// available tokens. This leaves room for several more questions before the summary has to be recomputed.
+//
+// The tokens are counted with ApproximateTokens and a safety margin of the context window is left unused in case the
+// approximation is too low.
+type HistoryWindow struct {
+	summarizer    Provider
+	contextTokens int
+	answerTokens  int
+}
+
+// NewHistoryWindow creates a HistoryWindow for a model with a context window of contextTokens of which answerTokens
+// are reserved for the answer. The summarizer produces the rolling summaries.
+func NewHistoryWindow(summarizer Provider, contextTokens int, answerTokens int) *HistoryWindow {
+	return &HistoryWindow{
+		summarizer:    summarizer,
+		contextTokens: contextTokens,
+		answerTokens:  answerTokens,
+	}
+}
+
+// Fit returns the chat messages for answering the question of the conversation within the context window.
+func (w *HistoryWindow) Fit(ctx context.Context, conversation Conversation) (*FittedConversation, error) {
+	var (
+		turns     = conversation.Turns
+		summary   string
+		summaryAt int
+		err       error
+	)
+	available := w.contextTokens*(100-safetyMarginPercent)/100 - w.answerTokens - //nolint:mnd // percent
+		approximateMessageTokens(conversation.System) - approximateMessageTokens(conversation.Question)
+	if available < 0 {
+		return nil, errors.Wrap(ErrContextTooSmall, "fit system prompt and question",
+			slog.Int("context_tokens", w.contextTokens), slog.Int("missing_tokens", -available))
+	}
+
+	// Continue from the latest persisted summary.
+	for i := len(turns) - 1; i >= 0; i-- {
+		if turns[i].Summary != "" {
+			summary = turns[i].Summary
+			summaryAt = i + 1
+			break
+		}
+	}
+
+	fitted := &FittedConversation{
+		Messages:        nil,
+		Summary:         summary,
+		SummarizedTurns: summaryAt,
+		NewSummary:      false,
+	}
+	if summaryTokens(summary)+approximateTurnTokens(turns[summaryAt:]) > available {
+		// Keep the recent turns fitting in half of the tokens and summarise the rest.
+		keepFrom := recentTurnsStart(turns[summaryAt:], available/2) + summaryAt //nolint:mnd // half
+		if keepFrom > summaryAt {
+			summary, err = w.summarize(ctx, conversation.SummaryInstructions, summary, turns[summaryAt:keepFrom])
+			if err != nil {
+				return nil, errors.Wrap(err, "summarize turns", slog.Int("from", summaryAt), slog.Int("to", keepFrom))
+			}
+			fitted.Summary = summary
+			fitted.SummarizedTurns = keepFrom
+			fitted.NewSummary = true
+			summaryAt = keepFrom
+		}
+	}
+
+	// The summary might be longer than requested, so drop the oldest verbatim turns until everything fits.
+	keepFrom := recentTurnsStart(turns[summaryAt:], available-summaryTokens(summary)) + summaryAt
+
+	messages := make([]Message, 0, 2*(len(turns)-keepFrom)+3) //nolint:mnd // Q&A pairs, prompts, and question.
+	messages = append(messages, Message{Role: RoleSystem, Content: conversation.System})
+	if summary != "" {
+		messages = append(messages, Message{Role: RoleSystem, Content: summaryMessage(summary)})
+	}
+	for _, turn := range turns[keepFrom:] {
+		messages = append(messages,
+			Message{Role: RoleUser, Content: turn.Question},
+			Message{Role: RoleAssistant, Content: turn.Answer},
+		)
+	}
+	fitted.Messages = append(messages, Message{Role: RoleUser, Content: conversation.Question})
+	return fitted, nil
+}
+
+// summarize asks the summarizer to extend the previous summary with the turns.
+func (w *HistoryWindow) summarize(
+	ctx context.Context,
+	instructions string,
+	previousSummary string,
+	turns []Turn,
+) (string, error) {
+	var transcript strings.Builder
+	if previousSummary != "" {
+		transcript.WriteString(summaryMessage(previousSummary))
+		transcript.WriteString("\n\n")
+	}
+	transcript.WriteString("Conversation to summarise:")
+	for _, turn := range turns {
+		transcript.WriteString("\n\nQuestion: ")
+		transcript.WriteString(turn.Question)
+		transcript.WriteString("\nAnswer: ")
+		transcript.WriteString(turn.Answer)
+	}
+	completion, err := w.summarizer.Complete(ctx, []Message{
+		{Role: RoleSystem, Content: instructions},
+		{Role: RoleUser, Content: transcript.String()},
+	})
+	if err != nil {
+		return "", errors.Wrap(err, "complete summary")
+	}
+	return strings.TrimSpace(completion.Content), nil
+}
+
+func summaryMessage(summary string) string {
+	return "Summary of the earlier conversation:\n" + summary
+}
+
+func summaryTokens(summary string) int {
+	if summary == "" {
+		return 0
+	}
+	return approximateMessageTokens(summaryMessage(summary))
+}
+
+func approximateTurnTokens(turns []Turn) int {
+	tokens := 0
+	for _, turn := range turns {
+		tokens += turn.approximateTokens()
+	}
+	return tokens
+}
+
+// recentTurnsStart returns the index of the oldest turn such that it and the turns after it fit in tokens.
+func recentTurnsStart(turns []Turn, tokens int) int {
+	start := len(turns)
+	for start > 0 && turns[start-1].approximateTokens() <= tokens {
+		tokens -= turns[start-1].approximateTokens()
+		start--
+	}
+	return start
+}
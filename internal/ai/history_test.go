@@ -0,0 +1,143 @@
+package ai_test
+
+import (
+	"context"
+	"fmt"
+	"github.com/myrjola/sheerluck/internal/ai"
+	"github.com/stretchr/testify/require"
+	"testing"
+)
+
+// newTurns creates turns of 19 approximated tokens each.
+func newTurns(count int) []ai.Turn {
+	turns := make([]ai.Turn, 0, count)
+	for i := range count {
+		turns = append(turns, ai.Turn{
+			Question: fmt.Sprint(i),
+			Answer:   fmt.Sprintf("Answer number %d padded to forty chars.", i),
+			Summary:  "",
+		})
+	}
+	return turns
+}
+
+func newConversation(turns []ai.Turn) ai.Conversation {
+	return ai.Conversation{
+		System:              "system",
+		SummaryInstructions: "Summarise.",
+		Turns:               turns,
+		Question:            "question?",
+	}
+}
+
+func TestApproximateTokens(t *testing.T) {
+	t.Parallel()
+	require.Equal(t, 0, ai.ApproximateTokens(""))
+	require.Equal(t, 1, ai.ApproximateTokens("four"))
+	require.Equal(t, 2, ai.ApproximateTokens("five!"))
+	require.Equal(t, 1, ai.ApproximateTokens("äöå"), "counts characters instead of bytes")
+}
+
+func TestHistoryWindow_Fit(t *testing.T) {
+	t.Parallel()
+	ctx := context.Background()
+	// 120 - 18 for the safety margin - 20 for the answer - 6 for the system prompt - 7 for the question leaves 69 tokens
+	// for the history.
+	const (
+		contextTokens = 120
+		answerTokens  = 20
+	)
+
+	t.Run("keeps all turns that fit", func(t *testing.T) {
+		t.Parallel()
+		summarizer := ai.NewScriptedProvider("Summary.")
+		window := ai.NewHistoryWindow(summarizer, contextTokens, answerTokens)
+		fitted, err := window.Fit(ctx, newConversation(newTurns(3)))
+		require.NoError(t, err)
+		require.False(t, fitted.NewSummary)
+		require.Empty(t, fitted.Summary)
+		require.Equal(t, 0, fitted.SummarizedTurns)
+		require.Len(t, fitted.Messages, 8)
+		require.Equal(t, ai.Message{Role: ai.RoleSystem, Content: "system"}, fitted.Messages[0])
+		require.Equal(t, ai.Message{Role: ai.RoleUser, Content: "0"}, fitted.Messages[1])
+		require.Equal(t, ai.Message{Role: ai.RoleUser, Content: "question?"}, fitted.Messages[7])
+		require.Empty(t, summarizer.Requests())
+	})
+
+	t.Run("leaves a safety margin", func(t *testing.T) {
+		t.Parallel()
+		summarizer := ai.NewScriptedProvider("Summary.")
+		window := ai.NewHistoryWindow(summarizer, contextTokens, answerTokens)
+		// The 76 tokens of the turns would fit without the safety margin.
+		fitted, err := window.Fit(ctx, newConversation(newTurns(4)))
+		require.NoError(t, err)
+		require.True(t, fitted.NewSummary)
+	})
+
+	t.Run("summarises older turns", func(t *testing.T) {
+		t.Parallel()
+		summarizer := ai.NewScriptedProvider("Summary.")
+		window := ai.NewHistoryWindow(summarizer, contextTokens, answerTokens)
+		fitted, err := window.Fit(ctx, newConversation(newTurns(5)))
+		require.NoError(t, err)
+		require.True(t, fitted.NewSummary)
+		require.Equal(t, "Summary.", fitted.Summary)
+		require.Equal(t, 4, fitted.SummarizedTurns, "only the latest turn fits in half of the history tokens")
+		require.Equal(t, []ai.Message{
+			{Role: ai.RoleSystem, Content: "system"},
+			{Role: ai.RoleSystem, Content: "Summary of the earlier conversation:\nSummary."},
+			{Role: ai.RoleUser, Content: "4"},
+			{Role: ai.RoleAssistant, Content: "Answer number 4 padded to forty chars."},
+			{Role: ai.RoleUser, Content: "question?"},
+		}, fitted.Messages)
+
+		requests := summarizer.Requests()
+		require.Len(t, requests, 1)
+		require.Equal(t, ai.Message{Role: ai.RoleSystem, Content: "Summarise."}, requests[0][0])
+		require.Contains(t, requests[0][1].Content, "Question: 3\nAnswer: Answer number 3 padded to forty chars.")
+		require.NotContains(t, requests[0][1].Content, "Question: 4")
+	})
+
+	t.Run("continues from the persisted summary", func(t *testing.T) {
+		t.Parallel()
+		summarizer := ai.NewScriptedProvider("New summary.")
+		window := ai.NewHistoryWindow(summarizer, contextTokens, answerTokens)
+		turns := newTurns(6)
+		turns[3].Summary = "Old summary."
+		fitted, err := window.Fit(ctx, newConversation(turns))
+		require.NoError(t, err)
+		require.False(t, fitted.NewSummary)
+		require.Equal(t, "Old summary.", fitted.Summary)
+		require.Equal(t, 4, fitted.SummarizedTurns)
+		require.Len(t, fitted.Messages, 7)
+		require.Equal(t, "Summary of the earlier conversation:\nOld summary.", fitted.Messages[1].Content)
+		require.Equal(t, "4", fitted.Messages[2].Content)
+		require.Empty(t, summarizer.Requests())
+	})
+
+	t.Run("extends the persisted summary", func(t *testing.T) {
+		t.Parallel()
+		summarizer := ai.NewScriptedProvider("New summary.")
+		window := ai.NewHistoryWindow(summarizer, contextTokens, answerTokens)
+		turns := newTurns(8)
+		turns[1].Summary = "Old summary."
+		fitted, err := window.Fit(ctx, newConversation(turns))
+		require.NoError(t, err)
+		require.True(t, fitted.NewSummary)
+		require.Equal(t, "New summary.", fitted.Summary)
+		require.Equal(t, 7, fitted.SummarizedTurns)
+
+		requests := summarizer.Requests()
+		require.Len(t, requests, 1)
+		require.Contains(t, requests[0][1].Content, "Summary of the earlier conversation:\nOld summary.")
+		require.Contains(t, requests[0][1].Content, "Question: 2\n")
+		require.NotContains(t, requests[0][1].Content, "Question: 1\n")
+	})
+
+	t.Run("rejects too small context windows", func(t *testing.T) {
+		t.Parallel()
+		window := ai.NewHistoryWindow(ai.NewScriptedProvider(), 30, answerTokens)
+		_, err := window.Fit(ctx, newConversation(nil))
+		require.ErrorIs(t, err, ai.ErrContextTooSmall)
+	})
+}
@@ -0,0 +1,68 @@
+package ai
+
+import (
+	"context"
+	"github.com/myrjola/sheerluck/internal/errors"
+	"sync"
+)
+
+// UsageRecorder receives the token usage reported by the provider. The context is the one of the request.
+type UsageRecorder func(ctx context.Context, usage Usage)
+
+// MeteredProvider is a [Provider] reporting the token usage of every request to a [UsageRecorder].
+type MeteredProvider struct {
+	provider Provider
+	record   UsageRecorder
+}
+
+// NewMeteredProvider wraps provider so that the usage of its requests is passed to record.
+func NewMeteredProvider(provider Provider, record UsageRecorder) *MeteredProvider {
+	return &MeteredProvider{
+		provider: provider,
+		record:   record,
+	}
+}
+
+// Complete implements [Provider].
+func (p *MeteredProvider) Complete(ctx context.Context, messages []Message) (*Completion, error) {
+	completion, err := p.provider.Complete(ctx, messages)
+	if err != nil {
+		return nil, errors.Wrap(err, "metered complete")
+	}
+	p.record(ctx, completion.Usage)
+	return completion, nil
+}
+
+// Stream implements [Provider]. The usage is recorded when the stream is closed.
+//
+//nolint:ireturn // the stream implementation is an internal detail.
+func (p *MeteredProvider) Stream(ctx context.Context, messages []Message) (Stream, error) {
+	stream, err := p.provider.Stream(ctx, messages)
+	if err != nil {
+		return nil, errors.Wrap(err, "metered stream")
+	}
+	return &meteredStream{
+		Stream: stream,
+		ctx:    ctx,
+		record: p.record,
+		once:   sync.Once{},
+	}, nil
+}
+
+type meteredStream struct {
+	Stream
+
+	ctx    context.Context //nolint:containedctx // the usage is recorded in the context of the request on Close.
+	record UsageRecorder
+	once   sync.Once
+}
+
+func (s *meteredStream) Close() error {
+	s.once.Do(func() {
+		s.record(s.ctx, s.Stream.Usage())
+	})
+	if err := s.Stream.Close(); err != nil {
+		return errors.Wrap(err, "close metered stream")
+	}
+	return nil
+}
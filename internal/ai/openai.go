@@ -0,0 +1,116 @@
+package ai
+
+import (
+	"context"
+	"github.com/myrjola/sheerluck/internal/errors"
+	"github.com/sashabaranov/go-openai"
+	"io"
+)
+
+// OpenAIProvider talks to the OpenAI Chat Completions API or any server compatible with it.
+type OpenAIProvider struct {
+	client      *openai.Client
+	model       string
+	temperature float32
+	maxTokens   int
+}
+
+// NewOpenAIProvider creates an [OpenAIProvider]. An empty cfg.BaseURL uses the official OpenAI endpoint.
+func NewOpenAIProvider(cfg Config) *OpenAIProvider {
+	clientConfig := openai.DefaultConfig(cfg.APIKey)
+	if cfg.BaseURL != "" {
+		clientConfig.BaseURL = cfg.BaseURL
+	}
+	return &OpenAIProvider{
+		client:      openai.NewClientWithConfig(clientConfig),
+		model:       cfg.Model,
+		temperature: float32(cfg.Temperature),
+		maxTokens:   cfg.MaxTokens,
+	}
+}
+
+func (p *OpenAIProvider) request(messages []Message) openai.ChatCompletionRequest {
+	openaiMessages := make([]openai.ChatCompletionMessage, len(messages))
+	for i, message := range messages {
+		openaiMessages[i] = openai.ChatCompletionMessage{ //nolint:exhaustruct // only role and content are needed.
+			Role:    string(message.Role),
+			Content: message.Content,
+		}
+	}
+	return openai.ChatCompletionRequest{ //nolint:exhaustruct // this is better for readability
+		Model:       p.model,
+		MaxTokens:   p.maxTokens,
+		Temperature: p.temperature,
+		Messages:    openaiMessages,
+	}
+}
+
+// Complete implements [Provider].
+func (p *OpenAIProvider) Complete(ctx context.Context, messages []Message) (*Completion, error) {
+	response, err := p.client.CreateChatCompletion(ctx, p.request(messages))
+	if err != nil {
+		return nil, errors.Wrap(err, "create chat completion")
+	}
+	if len(response.Choices) == 0 {
+		return nil, errors.New("no choices in chat completion")
+	}
+	return &Completion{
+		Content: response.Choices[0].Message.Content,
+		Usage: Usage{
+			PromptTokens:     response.Usage.PromptTokens,
+			CompletionTokens: response.Usage.CompletionTokens,
+		},
+	}, nil
+}
+
+// Stream implements [Provider].
+//
+//nolint:ireturn // the stream implementation is an internal detail.
+func (p *OpenAIProvider) Stream(ctx context.Context, messages []Message) (Stream, error) {
+	request := p.request(messages)
+	// The usage is reported in the last chunk only when requested.
+	request.StreamOptions = &openai.StreamOptions{IncludeUsage: true}
+	stream, err := p.client.CreateChatCompletionStream(ctx, request)
+	if err != nil {
+		return nil, errors.Wrap(err, "create chat completion stream")
+	}
+	return &openAIStream{stream: stream, usage: Usage{}}, nil
+}
+
+type openAIStream struct {
+	stream *openai.ChatCompletionStream
+	usage  Usage
+}
+
+func (s *openAIStream) Recv() (string, error) {
+	for {
+		response, err := s.stream.Recv()
+		if errors.Is(err, io.EOF) {
+			return "", io.EOF
+		}
+		if err != nil {
+			return "", errors.Wrap(err, "receive chat completion chunk")
+		}
+		if response.Usage != nil {
+			s.usage = Usage{
+				PromptTokens:     response.Usage.PromptTokens,
+				CompletionTokens: response.Usage.CompletionTokens,
+			}
+		}
+		if len(response.Choices) == 0 || response.Choices[0].Delta.Content == "" {
+			continue
+		}
+		return response.Choices[0].Delta.Content, nil
+	}
+}
+
+func (s *openAIStream) Usage() Usage {
+	return s.usage
+}
+
+func (s *openAIStream) Close() error {
+	if err := s.stream.Close(); err != nil {
+		return errors.Wrap(err, "close chat completion stream")
+	}
+	return nil
+}
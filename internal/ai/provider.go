@@ -0,0 +1,99 @@
+package ai
+
+import (
+	"context"
+	"github.com/myrjola/sheerluck/internal/errors"
+	"log/slog"
+)
+
+// ErrUnknownProvider is returned when the configured provider name doesn't match any backend.
+var ErrUnknownProvider = errors.NewSentinel("unknown AI provider")
+
+// Role is the author of a chat message.
+type Role string
+
+const (
+	RoleSystem    Role = "system"
+	RoleUser      Role = "user"
+	RoleAssistant Role = "assistant"
+)
+
+// Message is a single chat message sent to or received from the model.
+type Message struct {
+	Role    Role
+	Content string
+}
+
+// Usage is the token consumption reported by the provider.
+type Usage struct {
+	PromptTokens     int
+	CompletionTokens int
+}
+
+// TotalTokens returns the sum of prompt and completion tokens.
+func (u Usage) TotalTokens() int {
+	return u.PromptTokens + u.CompletionTokens
+}
+
+// Completion is a complete answer from the model.
+type Completion struct {
+	Content string
+	Usage   Usage
+}
+
+// Stream delivers an answer from the model chunk by chunk.
+type Stream interface {
+	// Recv returns the next chunk of the answer. It returns [io.EOF] once the answer is complete.
+	Recv() (string, error)
+	// Usage reports the consumed tokens. It's complete only after Recv has returned [io.EOF].
+	Usage() Usage
+	// Close releases the resources held by the stream.
+	Close() error
+}
+
+// Provider is a large language model backend.
+type Provider interface {
+	// Complete waits for the full answer to messages.
+	Complete(ctx context.Context, messages []Message) (*Completion, error)
+	// Stream starts answering messages and returns a stream of the answer chunks.
+	Stream(ctx context.Context, messages []Message) (Stream, error)
+}
+
+// Provider names accepted by [NewProvider].
+const (
+	ProviderOpenAI    = "openai"
+	ProviderAnthropic = "anthropic"
+	ProviderScripted  = "scripted"
+)
+
+// Config selects and configures a [Provider].
+type Config struct {
+	// Provider is one of ProviderOpenAI, ProviderAnthropic, or ProviderScripted.
+	Provider string
+	// Model is the provider-specific model name.
+	Model string
+	// BaseURL overrides the API endpoint, e.g., for a local OpenAI-compatible Ollama or llama.cpp server.
+	BaseURL string
+	// APIKey authenticates against the provider.
+	APIKey string
+	// Temperature controls the randomness of the answers.
+	Temperature float64
+	// MaxTokens caps the length of the answers.
+	MaxTokens int
+}
+
+// NewProvider creates the [Provider] described by cfg.
+//
+//nolint:ireturn // the provider is chosen by configuration.
+func NewProvider(cfg Config) (Provider, error) {
+	switch cfg.Provider {
+	case ProviderOpenAI:
+		return NewOpenAIProvider(cfg), nil
+	case ProviderAnthropic:
+		return NewAnthropicProvider(cfg), nil
+	case ProviderScripted:
+		return NewScriptedProvider(), nil
+	default:
+		return nil, errors.Wrap(ErrUnknownProvider, "new provider", slog.String("provider", cfg.Provider))
+	}
+}
@@ -0,0 +1,214 @@
+package ai_test
+
+import (
+	"context"
+	"encoding/json"
+	"github.com/myrjola/sheerluck/internal/ai"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+)
+
+// readStream collects the chunks of stream until it's finished.
+func readStream(t *testing.T, stream ai.Stream) string {
+	t.Helper()
+	var answer strings.Builder
+	for {
+		chunk, err := stream.Recv()
+		if err == io.EOF { //nolint:errorlint // io.EOF is returned unwrapped by contract.
+			break
+		}
+		require.NoError(t, err)
+		answer.WriteString(chunk)
+	}
+	require.NoError(t, stream.Close())
+	return answer.String()
+}
+
+func TestScriptedProvider(t *testing.T) {
+	t.Parallel()
+	ctx := context.Background()
+	messages := []ai.Message{{Role: ai.RoleUser, Content: "Who are you?"}}
+
+	echo := ai.NewScriptedProvider()
+	stream, err := echo.Stream(ctx, messages)
+	require.NoError(t, err)
+	require.Equal(t, "You asked: Who are you?", readStream(t, stream))
+	require.Equal(t, ai.Usage{PromptTokens: 3, CompletionTokens: 5}, stream.Usage())
+
+	scripted := ai.NewScriptedProvider("first", "second")
+	for _, want := range []string{"first", "second", "first"} {
+		var completion *ai.Completion
+		completion, err = scripted.Complete(ctx, messages)
+		require.NoError(t, err)
+		require.Equal(t, want, completion.Content)
+	}
+	require.Len(t, scripted.Requests(), 3)
+}
+
+func TestOpenAIProvider(t *testing.T) {
+	t.Parallel()
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		is := assert.New(t)
+		is.Equal("/v1/chat/completions", r.URL.Path)
+		var body struct {
+			Model    string `json:"model"`
+			Stream   bool   `json:"stream"`
+			Messages []struct {
+				Role    string `json:"role"`
+				Content string `json:"content"`
+			} `json:"messages"`
+		}
+		is.NoError(json.NewDecoder(r.Body).Decode(&body))
+		is.Equal("local-model", body.Model)
+		is.Equal("system", body.Messages[0].Role)
+		if !body.Stream {
+			_, _ = io.WriteString(w, `{"choices":[{"message":{"role":"assistant","content":"Bonjour"}}],
+"usage":{"prompt_tokens":7,"completion_tokens":1}}`)
+			return
+		}
+		w.Header().Set("Content-Type", "text/event-stream")
+		_, _ = io.WriteString(w, `data: {"choices":[{"delta":{"content":"Bon"}}]}
+
+data: {"choices":[{"delta":{"content":"jour"}}]}
+
+data: {"choices":[],"usage":{"prompt_tokens":7,"completion_tokens":2}}
+
+data: [DONE]
+
+`)
+	}))
+	t.Cleanup(server.Close)
+
+	provider := ai.NewOpenAIProvider(ai.Config{
+		Provider:    ai.ProviderOpenAI,
+		Model:       "local-model",
+		BaseURL:     server.URL + "/v1",
+		APIKey:      "",
+		Temperature: 0,
+		MaxTokens:   100,
+	})
+	messages := []ai.Message{
+		{Role: ai.RoleSystem, Content: "You are Adolphe Le Bon."},
+		{Role: ai.RoleUser, Content: "Hello"},
+	}
+	ctx := context.Background()
+
+	completion, err := provider.Complete(ctx, messages)
+	require.NoError(t, err)
+	require.Equal(t, "Bonjour", completion.Content)
+	require.Equal(t, ai.Usage{PromptTokens: 7, CompletionTokens: 1}, completion.Usage)
+
+	stream, err := provider.Stream(ctx, messages)
+	require.NoError(t, err)
+	require.Equal(t, "Bonjour", readStream(t, stream))
+	require.Equal(t, ai.Usage{PromptTokens: 7, CompletionTokens: 2}, stream.Usage())
+}
+
+func TestAnthropicProvider(t *testing.T) {
+	t.Parallel()
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		is := assert.New(t)
+		is.Equal("/v1/messages", r.URL.Path)
+		is.Equal("secret", r.Header.Get("X-Api-Key"))
+		var body struct {
+			System   string `json:"system"`
+			Stream   bool   `json:"stream"`
+			Messages []struct {
+				Role    string `json:"role"`
+				Content string `json:"content"`
+			} `json:"messages"`
+		}
+		is.NoError(json.NewDecoder(r.Body).Decode(&body))
+		is.Equal("You are Adolphe Le Bon.", body.System)
+		is.Len(body.Messages, 1)
+		if !body.Stream {
+			_, _ = io.WriteString(w, `{"content":[{"type":"text","text":"Bonjour"}],
+"usage":{"input_tokens":7,"output_tokens":1}}`)
+			return
+		}
+		w.Header().Set("Content-Type", "text/event-stream")
+		_, _ = io.WriteString(w, `event: message_start
+data: {"type":"message_start","message":{"usage":{"input_tokens":7,"output_tokens":1}}}
+
+event: content_block_delta
+data: {"type":"content_block_delta","index":0,"delta":{"type":"text_delta","text":"Bon"}}
+
+event: ping
+data: {"type":"ping"}
+
+event: content_block_delta
+data: {"type":"content_block_delta","index":0,"delta":{"type":"text_delta","text":"jour"}}
+
+event: message_delta
+data: {"type":"message_delta","delta":{"stop_reason":"end_turn"},"usage":{"output_tokens":2}}
+
+event: message_stop
+data: {"type":"message_stop"}
+
+`)
+	}))
+	t.Cleanup(server.Close)
+
+	provider := ai.NewAnthropicProvider(ai.Config{
+		Provider:    ai.ProviderAnthropic,
+		Model:       "claude",
+		BaseURL:     server.URL,
+		APIKey:      "secret",
+		Temperature: 0,
+		MaxTokens:   100,
+	})
+	messages := []ai.Message{
+		{Role: ai.RoleSystem, Content: "You are Adolphe Le Bon."},
+		{Role: ai.RoleUser, Content: "Hello"},
+	}
+	ctx := context.Background()
+
+	completion, err := provider.Complete(ctx, messages)
+	require.NoError(t, err)
+	require.Equal(t, "Bonjour", completion.Content)
+	require.Equal(t, ai.Usage{PromptTokens: 7, CompletionTokens: 1}, completion.Usage)
+
+	stream, err := provider.Stream(ctx, messages)
+	require.NoError(t, err)
+	require.Equal(t, "Bonjour", readStream(t, stream))
+	require.Equal(t, ai.Usage{PromptTokens: 7, CompletionTokens: 2}, stream.Usage())
+}
+
+func TestNewProvider(t *testing.T) {
+	t.Parallel()
+	_, err := ai.NewProvider(ai.Config{
+		Provider:    "unknown",
+		Model:       "",
+		BaseURL:     "",
+		APIKey:      "",
+		Temperature: 0,
+		MaxTokens:   0,
+	})
+	require.ErrorIs(t, err, ai.ErrUnknownProvider)
+}
+
+func TestMeteredProvider(t *testing.T) {
+	t.Parallel()
+	ctx := context.Background()
+	var recorded []ai.Usage
+	record := func(_ context.Context, usage ai.Usage) {
+		recorded = append(recorded, usage)
+	}
+	provider := ai.NewMeteredProvider(ai.NewScriptedProvider("Four words of answer."), record)
+	messages := []ai.Message{{Role: ai.RoleUser, Content: "Who are you?"}}
+
+	_, err := provider.Complete(ctx, messages)
+	require.NoError(t, err)
+	require.Equal(t, []ai.Usage{{PromptTokens: 3, CompletionTokens: 4}}, recorded)
+
+	stream, err := provider.Stream(ctx, messages)
+	require.NoError(t, err)
+	require.Equal(t, "Four words of answer.", readStream(t, stream))
+	require.NoError(t, stream.Close(), "closing twice records the usage once")
+	require.Equal(t, []ai.Usage{{PromptTokens: 3, CompletionTokens: 4}, {PromptTokens: 3, CompletionTokens: 4}}, recorded)
+}
@@ -0,0 +1,116 @@
+package ai
+
+import (
+	"context"
+	"github.com/myrjola/sheerluck/internal/errors"
+	"io"
+	"strings"
+	"sync"
+)
+
+// ScriptedProvider is a deterministic [Provider] for tests and offline development.
+//
+// It answers with the scripted responses in order, starting over when it runs out. Without a script, it echoes the
+// last user message. Every word, including its trailing whitespace, is streamed as a separate chunk and counted as one
+// token.
+type ScriptedProvider struct {
+	mu        sync.Mutex
+	responses []string
+	next      int
+	requests  [][]Message
+}
+
+// NewScriptedProvider creates a [ScriptedProvider] answering with responses.
+func NewScriptedProvider(responses ...string) *ScriptedProvider {
+	return &ScriptedProvider{
+		mu:        sync.Mutex{},
+		responses: responses,
+		next:      0,
+		requests:  nil,
+	}
+}
+
+// Requests returns the messages of every request the provider has received.
+func (p *ScriptedProvider) Requests() [][]Message {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	return append([][]Message(nil), p.requests...)
+}
+
+func (p *ScriptedProvider) answer(messages []Message) string {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	p.requests = append(p.requests, messages)
+	if len(p.responses) > 0 {
+		response := p.responses[p.next%len(p.responses)]
+		p.next++
+		return response
+	}
+	for i := len(messages) - 1; i >= 0; i-- {
+		if messages[i].Role == RoleUser {
+			return "You asked: " + messages[i].Content
+		}
+	}
+	return "You asked nothing."
+}
+
+func countTokens(messages []Message) int {
+	count := 0
+	for _, message := range messages {
+		count += len(strings.Fields(message.Content))
+	}
+	return count
+}
+
+// Complete implements [Provider].
+func (p *ScriptedProvider) Complete(_ context.Context, messages []Message) (*Completion, error) {
+	answer := p.answer(messages)
+	return &Completion{
+		Content: answer,
+		Usage: Usage{
+			PromptTokens:     countTokens(messages),
+			CompletionTokens: len(strings.Fields(answer)),
+		},
+	}, nil
+}
+
+// Stream implements [Provider].
+//
+//nolint:ireturn // the stream implementation is an internal detail.
+func (p *ScriptedProvider) Stream(ctx context.Context, messages []Message) (Stream, error) {
+	answer := p.answer(messages)
+	return &scriptedStream{
+		ctx:    ctx,
+		chunks: strings.SplitAfter(answer, " "),
+		usage: Usage{
+			PromptTokens:     countTokens(messages),
+			CompletionTokens: len(strings.Fields(answer)),
+		},
+	}, nil
+}
+
+type scriptedStream struct {
+	ctx    context.Context //nolint:containedctx // the stream is bound to the context like a real HTTP stream.
+	chunks []string
+	usage  Usage
+}
+
+func (s *scriptedStream) Recv() (string, error) {
+	if err := s.ctx.Err(); err != nil {
+		return "", errors.Wrap(err, "context done")
+	}
+	if len(s.chunks) == 0 {
+		return "", io.EOF
+	}
+	chunk := s.chunks[0]
+	s.chunks = s.chunks[1:]
+	return chunk, nil
+}
+
+func (s *scriptedStream) Usage() Usage {
+	return s.usage
+}
+
+func (s *scriptedStream) Close() error {
+	return nil
+}
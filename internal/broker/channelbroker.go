@@ -69,6 +69,10 @@ func (b *ChannelBroker[TID, TPayload]) Start() {
 			publishedChannels[publication.ID] = publication.Channel
 
 		case id := <-b.unpublishChannel:
+			// Unblock the subsequent subscribers. The first subscriber has already received the channel.
+			for _, subscriber := range subscriberLists[id] {
+				close(subscriber)
+			}
 			delete(publishedChannels, id)
 			delete(subscriberLists, id)
 		}
@@ -44,7 +44,9 @@ func TestChannelBroker(t *testing.T) {
 				subscriptionChan := <-b.Subscribe(id)
 
 				// Next subscriber
+				nextSubscriberDone := make(chan struct{})
 				go func() {
+					defer close(nextSubscriberDone)
 					nextSubscriptionChan, ok := <-b.Subscribe(id)
 					assert.Nil(t, nextSubscriptionChan, "subsequent subscriber received content")
 					assert.Falsef(t, ok, "channel not closed to signal producer is finished")
@@ -65,6 +67,7 @@ func TestChannelBroker(t *testing.T) {
 				require.Nil(t, nextSubscriptionChan, "last subscriber received content")
 				require.Falsef(t, ok, "last subscriber channel not closed to signal producer is finished")
 				require.True(t, producerFinished.Load(), "producer not finished before last subscriber unblocked")
+				<-nextSubscriberDone
 			},
 		},
 	}
@@ -0,0 +1,104 @@
+package broker
+
+// hubBufferSize is how many payloads a subscriber can fall behind before it starts missing them.
+const hubBufferSize = 16
+
+type hubSubscription[TID comparable, TPayload any] struct {
+	ID      TID
+	Channel chan TPayload
+}
+
+type hubPublication[TID comparable, TPayload any] struct {
+	ID      TID
+	Payload TPayload
+}
+
+// Hub fans out the payloads published with an ID to every subscriber of the ID.
+//
+// Unlike ChannelBroker, a Hub doesn't hand over a stream but notifies about events, e.g., to tell the members of a
+// party that one of them has asked a question. The subscribers are expected to fetch the persisted data themselves,
+// so a subscriber that falls behind misses payloads rather than blocks the publisher.
+type Hub[TID comparable, TPayload any] struct {
+	stopChannel        chan struct{}
+	publishChannel     chan hubPublication[TID, TPayload]
+	subscribeChannel   chan hubSubscription[TID, TPayload]
+	unsubscribeChannel chan hubSubscription[TID, TPayload]
+}
+
+// NewHub creates a new Hub. Use Start() to start the goroutine that handles it and Stop() to stop it.
+func NewHub[TID comparable, TPayload any]() *Hub[TID, TPayload] {
+	return &Hub[TID, TPayload]{
+		stopChannel:        make(chan struct{}),
+		publishChannel:     make(chan hubPublication[TID, TPayload]),
+		subscribeChannel:   make(chan hubSubscription[TID, TPayload]),
+		unsubscribeChannel: make(chan hubSubscription[TID, TPayload]),
+	}
+}
+
+// Start listening for publish, subscribe, and unsubscribe events. This function blocks until Stop() is called,
+// so it should be called in a goroutine. It does not handle panics, so it should be wrapped in a recover.
+func (h *Hub[TID, TPayload]) Start() {
+	subscribers := map[TID]map[chan TPayload]struct{}{}
+	for {
+		select {
+		case <-h.stopChannel:
+			return
+
+		case subscription := <-h.subscribeChannel:
+			if subscribers[subscription.ID] == nil {
+				subscribers[subscription.ID] = map[chan TPayload]struct{}{}
+			}
+			subscribers[subscription.ID][subscription.Channel] = struct{}{}
+
+		case subscription := <-h.unsubscribeChannel:
+			if _, ok := subscribers[subscription.ID][subscription.Channel]; ok {
+				delete(subscribers[subscription.ID], subscription.Channel)
+				close(subscription.Channel)
+			}
+			if len(subscribers[subscription.ID]) == 0 {
+				delete(subscribers, subscription.ID)
+			}
+
+		case publication := <-h.publishChannel:
+			for channel := range subscribers[publication.ID] {
+				select {
+				case channel <- publication.Payload:
+				default:
+					// The subscriber has fallen behind.
+				}
+			}
+		}
+	}
+}
+
+// Stop the goroutine that handles the hub. Publishing and subscribing do nothing after the hub is stopped.
+func (h *Hub[TID, TPayload]) Stop() {
+	close(h.stopChannel)
+}
+
+// Subscribe to the payloads published with ID. Returns a channel receiving the payloads and a function that
+// unsubscribes and closes the channel. The unsubscribe function must be called when the subscriber is done.
+func (h *Hub[TID, TPayload]) Subscribe(id TID) (chan TPayload, func()) {
+	subscription := hubSubscription[TID, TPayload]{
+		ID:      id,
+		Channel: make(chan TPayload, hubBufferSize),
+	}
+	select {
+	case h.subscribeChannel <- subscription:
+	case <-h.stopChannel:
+	}
+	return subscription.Channel, func() {
+		select {
+		case h.unsubscribeChannel <- subscription:
+		case <-h.stopChannel:
+		}
+	}
+}
+
+// Publish the payload to the current subscribers of ID.
+func (h *Hub[TID, TPayload]) Publish(id TID, payload TPayload) {
+	select {
+	case h.publishChannel <- hubPublication[TID, TPayload]{ID: id, Payload: payload}:
+	case <-h.stopChannel:
+	}
+}
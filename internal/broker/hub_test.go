@@ -0,0 +1,69 @@
+package broker_test
+
+import (
+	"github.com/myrjola/sheerluck/internal/broker"
+	"github.com/stretchr/testify/require"
+	"testing"
+)
+
+func TestHub(t *testing.T) {
+	type testCase struct {
+		name     string
+		testFunc func(h *broker.Hub[int, string])
+	}
+	tests := []testCase{
+		{
+			name: "every subscriber of the ID receives the payload",
+			testFunc: func(h *broker.Hub[int, string]) {
+				first, unsubscribeFirst := h.Subscribe(1)
+				defer unsubscribeFirst()
+				second, unsubscribeSecond := h.Subscribe(1)
+				defer unsubscribeSecond()
+				other, unsubscribeOther := h.Subscribe(2)
+				defer unsubscribeOther()
+				h.Publish(1, "hello")
+				require.Equal(t, "hello", <-first)
+				require.Equal(t, "hello", <-second)
+				h.Publish(2, "world")
+				require.Equal(t, "world", <-other, "other subscriber received a payload of another ID")
+			},
+		},
+		{
+			name: "unsubscribing closes the channel",
+			testFunc: func(h *broker.Hub[int, string]) {
+				channel, unsubscribe := h.Subscribe(1)
+				unsubscribe()
+				h.Publish(1, "hello")
+				_, ok := <-channel
+				require.False(t, ok, "channel not closed")
+			},
+		},
+		{
+			name: "slow subscribers don't block the publisher",
+			testFunc: func(h *broker.Hub[int, string]) {
+				channel, unsubscribe := h.Subscribe(1)
+				defer unsubscribe()
+				for range cap(channel) + 1 {
+					h.Publish(1, "hello")
+				}
+				require.Len(t, channel, cap(channel))
+			},
+		},
+		{
+			name: "publishing without subscribers does nothing",
+			testFunc: func(h *broker.Hub[int, string]) {
+				h.Publish(1, "hello")
+			},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			h := broker.NewHub[int, string]()
+			go h.Start()
+			t.Cleanup(func() {
+				h.Stop()
+			})
+			tt.testFunc(h)
+		})
+	}
+}
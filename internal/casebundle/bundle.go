@@ -0,0 +1,496 @@
+// Package casebundle loads case bundles and imports them into the database.
+//
+// A case bundle is a directory containing a case.yaml manifest that declares everything about a case: the catalogue
+// metadata, the investigation targets with their clues, the suspects, and the solution. The setting of the case and
+// the descriptions and secrets of the targets are the material the prompt templates turn into system prompts. Images
+// are referenced by URL. The templates in the prompts directory of a bundle override the prompt templates of the same
+// name for the case, see package prompts.
+//
+// The bundles replace hand-written SQL fixtures so that authors don't have to write SQL to add or change cases.
+package casebundle
+
+import (
+	"bytes"
+	"fmt"
+	"github.com/myrjola/sheerluck/internal/errors"
+	"github.com/myrjola/sheerluck/internal/models"
+	"gopkg.in/yaml.v3"
+	"io/fs"
+	"log/slog"
+	"path"
+	"slices"
+	"strconv"
+	"strings"
+)
+
+// ManifestName is the name of the manifest file in a bundle directory.
+const ManifestName = "case.yaml"
+
+// ErrInvalidBundle is returned when a bundle has problems that prevent importing it.
+var ErrInvalidBundle = errors.NewSentinel("invalid case bundle")
+
+// Bundle is a case declared in a case.yaml manifest.
+type Bundle struct {
+	// Dir is the directory of the bundle in the file system it was loaded from.
+	Dir             string    `yaml:"-"`
+	ID              string    `yaml:"id"`
+	Name            string    `yaml:"name"`
+	Author          string    `yaml:"author"`
+	Image           string    `yaml:"image"`
+	Difficulty      string    `yaml:"difficulty"`
+	PlayTimeMinutes int       `yaml:"play_time_minutes"`
+	Blurb           string    `yaml:"blurb"`
+	Setting         string    `yaml:"setting"`
+	Chapters        []Chapter `yaml:"chapters"`
+	Targets         []Target  `yaml:"targets"`
+	Suspects        []Suspect `yaml:"suspects"`
+	Solution        *Solution `yaml:"solution"`
+
+	// root is the parsed manifest for locating problems.
+	root *yaml.Node
+}
+
+// Chapter is a group of targets hidden from the player until they discover the clues unlocking it.
+type Chapter struct {
+	ID   string `yaml:"id"`
+	Name string `yaml:"name"`
+	// UnlockedBy are the IDs of the clues the player has to discover to unlock the chapter. A chapter without them is
+	// unlocked from the start.
+	UnlockedBy []string `yaml:"unlocked_by"`
+}
+
+// Target is an investigation target of a case.
+type Target struct {
+	ID          string `yaml:"id"`
+	Name        string `yaml:"name"`
+	ShortName   string `yaml:"short_name"`
+	Type        string `yaml:"type"`
+	Image       string `yaml:"image"`
+	Description string `yaml:"description"`
+	Secret      string `yaml:"secret"`
+	// Chapter is the ID of the chapter revealing the target. Targets without a chapter are available from the start.
+	Chapter string `yaml:"chapter"`
+	// AvailableMinutes is how long the target can be investigated in the clock game mode in in-game minutes from the
+	// start of the investigation, e.g., until a witness leaves town. Zero keeps the target available.
+	AvailableMinutes int    `yaml:"available_minutes"`
+	Clues            []Clue `yaml:"clues"`
+	// PointsOfInterest are the parts of a scene the player can examine.
+	PointsOfInterest []PointOfInterest `yaml:"points_of_interest"`
+	// Passages are the text of a document.
+	Passages []Passage `yaml:"passages"`
+}
+
+// Passage is a paragraph of a document.
+type Passage struct {
+	Text string `yaml:"text"`
+	// RedactedUntil is the ID of the clue the player has to discover to unredact the passage.
+	RedactedUntil string `yaml:"redacted_until"`
+}
+
+// PointOfInterest is a part of a scene the player can examine.
+type PointOfInterest struct {
+	ID string `yaml:"id"`
+	// Name completes "Examine ...", e.g., "the hearth".
+	Name        string `yaml:"name"`
+	Description string `yaml:"description"`
+}
+
+// Clue is a clue an investigation target can reveal.
+type Clue struct {
+	ID          string   `yaml:"id"`
+	Description string   `yaml:"description"`
+	Keywords    []string `yaml:"keywords"`
+	// RequiresEvidence is the ID of the clue the player has to confront the person with before they reveal this clue.
+	RequiresEvidence string `yaml:"requires_evidence"`
+	// PointOfInterest is the ID of the point of interest of the scene the player has to examine to find this clue.
+	PointOfInterest string `yaml:"point_of_interest"`
+	// Hints are the hints pointing to the clue from the vaguest to the most specific: a nudge, the target to question,
+	// and the topic to raise. The AI writes the missing ones.
+	Hints []string `yaml:"hints"`
+}
+
+// Suspect is a person the player can accuse.
+type Suspect struct {
+	ID          string `yaml:"id"`
+	Name        string `yaml:"name"`
+	Description string `yaml:"description"`
+}
+
+// Solution is what really happened in the case.
+type Solution struct {
+	// Culprit is the ID of the guilty suspect.
+	Culprit        string   `yaml:"culprit"`
+	Motive         string   `yaml:"motive"`
+	MotiveKeywords []string `yaml:"motive_keywords"`
+	// WrongMotiveKeywords are the keywords of the plausible but wrong motives. An accused motive mentioning more of them
+	// than of MotiveKeywords is wrong, so that listing every motive doesn't pass.
+	WrongMotiveKeywords []string `yaml:"wrong_motive_keywords"`
+	// Clues are the IDs of the clues supporting the solution.
+	Clues       []string `yaml:"clues"`
+	Explanation string   `yaml:"explanation"`
+}
+
+// Problem codes identify the kinds of problems.
+const (
+	CodeRequired         = "required"
+	CodeInvalidValue     = "invalid-value"
+	CodeDuplicateID      = "duplicate-id"
+	CodeUnknownReference = "unknown-reference"
+)
+
+// Problem is something wrong in a bundle.
+type Problem struct {
+	// Path locates the problem in the manifest, e.g., "targets[0].clues[1].keywords".
+	Path string
+	// Code identifies the kind of the problem, e.g., CodeRequired.
+	Code    string
+	Message string
+}
+
+func (p Problem) String() string {
+	return fmt.Sprintf("%s: %s", p.Path, p.Message)
+}
+
+// Load loads the bundle in the directory dir of fsys. Unknown fields in the manifest are rejected to catch typos.
+func Load(fsys fs.FS, dir string) (*Bundle, error) {
+	var (
+		manifest []byte
+		bundle   Bundle
+		err      error
+	)
+	manifestPath := path.Join(dir, ManifestName)
+	if manifest, err = fs.ReadFile(fsys, manifestPath); err != nil {
+		return nil, errors.Wrap(err, "read manifest", slog.String("path", manifestPath))
+	}
+	decoder := yaml.NewDecoder(bytes.NewReader(manifest))
+	decoder.KnownFields(true)
+	if err = decoder.Decode(&bundle); err != nil {
+		return nil, errors.Wrap(err, "decode manifest", slog.String("path", manifestPath))
+	}
+	var root yaml.Node
+	if err = yaml.Unmarshal(manifest, &root); err != nil {
+		return nil, errors.Wrap(err, "parse manifest", slog.String("path", manifestPath))
+	}
+	bundle.Dir = dir
+	bundle.root = &root
+	return &bundle, nil
+}
+
+// LoadAll loads the bundles in the subdirectories of the root of fsys that have a manifest.
+func LoadAll(fsys fs.FS) ([]*Bundle, error) {
+	var (
+		entries []fs.DirEntry
+		bundles []*Bundle
+		err     error
+	)
+	if entries, err = fs.ReadDir(fsys, "."); err != nil {
+		return nil, errors.Wrap(err, "read bundle directories")
+	}
+	for _, entry := range entries {
+		if !entry.IsDir() {
+			continue
+		}
+		if _, err = fs.Stat(fsys, path.Join(entry.Name(), ManifestName)); errors.Is(err, fs.ErrNotExist) {
+			continue
+		}
+		var bundle *Bundle
+		if bundle, err = Load(fsys, entry.Name()); err != nil {
+			return nil, errors.Wrap(err, "load bundle", slog.String("dir", entry.Name()))
+		}
+		bundles = append(bundles, bundle)
+	}
+	return bundles, nil
+}
+
+// ManifestPath returns the path of the bundle's manifest in the file system it was loaded from.
+func (b *Bundle) ManifestPath() string {
+	return path.Join(b.Dir, ManifestName)
+}
+
+// Line returns the line of the manifest the path of a Problem points to. The line of the closest existing parent is
+// returned if the path doesn't exist, e.g., for missing fields, and 0 if the bundle wasn't loaded from a manifest.
+func (b *Bundle) Line(problemPath string) int {
+	if b.root == nil || len(b.root.Content) == 0 {
+		return 0
+	}
+	node := b.root.Content[0]
+	line := node.Line
+	for _, segment := range strings.FieldsFunc(problemPath, func(r rune) bool { return r == '.' || r == '[' }) {
+		var next *yaml.Node
+		if index, err := strconv.Atoi(strings.TrimSuffix(segment, "]")); err == nil && node.Kind == yaml.SequenceNode {
+			if index < len(node.Content) {
+				next = node.Content[index]
+			}
+		} else if node.Kind == yaml.MappingNode {
+			for i := 0; i+1 < len(node.Content); i += 2 {
+				if node.Content[i].Value == segment {
+					next = node.Content[i+1]
+					line = node.Content[i].Line
+				}
+			}
+		}
+		if next == nil {
+			return line
+		}
+		node = next
+		if node.Kind != yaml.ScalarNode {
+			line = node.Line
+		}
+	}
+	return line
+}
+
+// problemList collects the problems of a bundle.
+type problemList []Problem
+
+func (p *problemList) report(path string, code string, format string, args ...any) {
+	*p = append(*p, Problem{Path: path, Code: code, Message: fmt.Sprintf(format, args...)})
+}
+
+func (p *problemList) required(path string, value string) {
+	if value == "" {
+		p.report(path, CodeRequired, "required")
+	}
+}
+
+// Problems returns the problems preventing the import of the bundle. It checks that the required fields are set, the
+// enumerations are valid, the IDs are unique, and the solution refers to the suspects and clues of the bundle.
+func (b *Bundle) Problems() []Problem {
+	var problems problemList
+	problems.required("id", b.ID)
+	if b.Dir != "" && path.Base(b.Dir) != b.ID {
+		// The prompt templates overridden by the case are looked up by the case ID.
+		problems.report("id", CodeInvalidValue, "must match the bundle directory %q", path.Base(b.Dir))
+	}
+	problems.required("name", b.Name)
+	problems.required("author", b.Author)
+	problems.required("image", b.Image)
+	difficulties := []string{
+		string(models.CaseDifficultyEasy), string(models.CaseDifficultyMedium), string(models.CaseDifficultyHard),
+	}
+	if !slices.Contains(difficulties, b.Difficulty) {
+		problems.report("difficulty", CodeInvalidValue, "must be one of %v", difficulties)
+	}
+	if b.PlayTimeMinutes <= 0 {
+		problems.report("play_time_minutes", CodeInvalidValue, "must be positive")
+	}
+
+	clueIDs := b.targetProblems(&problems)
+	b.evidenceProblems(&problems, clueIDs)
+	b.passageProblems(&problems, clueIDs)
+	b.chapterProblems(&problems, clueIDs)
+
+	suspectIDs := make(map[string]bool)
+	for i, suspect := range b.Suspects {
+		suspectPath := fmt.Sprintf("suspects[%d]", i)
+		problems.required(suspectPath+".id", suspect.ID)
+		if suspectIDs[suspect.ID] {
+			problems.report(suspectPath+".id", CodeDuplicateID, "duplicate suspect %q", suspect.ID)
+		}
+		suspectIDs[suspect.ID] = true
+		problems.required(suspectPath+".name", suspect.Name)
+	}
+
+	if b.Solution != nil {
+		if !suspectIDs[b.Solution.Culprit] {
+			problems.report("solution.culprit", CodeUnknownReference, "unknown suspect %q", b.Solution.Culprit)
+		}
+		problems.required("solution.motive", b.Solution.Motive)
+		if len(b.Solution.MotiveKeywords) == 0 {
+			problems.report("solution.motive_keywords", CodeRequired, "required")
+		}
+		for i, keyword := range b.Solution.WrongMotiveKeywords {
+			if slices.Contains(b.Solution.MotiveKeywords, keyword) {
+				problems.report(fmt.Sprintf("solution.wrong_motive_keywords[%d]", i), CodeInvalidValue,
+					"%q is a keyword of the motive", keyword)
+			}
+		}
+		problems.required("solution.explanation", b.Solution.Explanation)
+		for i, clueID := range b.Solution.Clues {
+			if !clueIDs[clueID] {
+				problems.report(fmt.Sprintf("solution.clues[%d]", i), CodeUnknownReference, "unknown clue %q", clueID)
+			}
+		}
+	}
+	return problems
+}
+
+// targetProblems checks the targets with their points of interest and clues. It returns the IDs of the clues.
+func (b *Bundle) targetProblems(problems *problemList) map[string]bool {
+	targetTypes := []string{
+		string(models.InvestigationTargetTypePerson),
+		string(models.InvestigationTargetTypeScene),
+		string(models.InvestigationTargetTypeItem),
+		string(models.InvestigationTargetTypeDocument),
+	}
+	targetIDs := make(map[string]bool)
+	clueIDs := make(map[string]bool)
+	pointsOfInterest := make(map[string]bool)
+	for i, target := range b.Targets {
+		targetPath := fmt.Sprintf("targets[%d]", i)
+		problems.required(targetPath+".id", target.ID)
+		if targetIDs[target.ID] {
+			problems.report(targetPath+".id", CodeDuplicateID, "duplicate target %q", target.ID)
+		}
+		targetIDs[target.ID] = true
+		problems.required(targetPath+".name", target.Name)
+		problems.required(targetPath+".short_name", target.ShortName)
+		problems.required(targetPath+".image", target.Image)
+		if !slices.Contains(targetTypes, target.Type) {
+			problems.report(targetPath+".type", CodeInvalidValue, "must be one of %v", targetTypes)
+		}
+		if target.AvailableMinutes < 0 {
+			problems.report(targetPath+".available_minutes", CodeInvalidValue, "must not be negative")
+		}
+		if len(target.PointsOfInterest) > 0 && target.Type != string(models.InvestigationTargetTypeScene) {
+			problems.report(targetPath+".points_of_interest", CodeInvalidValue, "only scenes have points of interest")
+		}
+		targetPointsOfInterest := make(map[string]bool)
+		for j, pointOfInterest := range target.PointsOfInterest {
+			pointOfInterestPath := fmt.Sprintf("%s.points_of_interest[%d]", targetPath, j)
+			problems.required(pointOfInterestPath+".id", pointOfInterest.ID)
+			if pointsOfInterest[pointOfInterest.ID] {
+				problems.report(pointOfInterestPath+".id", CodeDuplicateID, "duplicate point of interest %q",
+					pointOfInterest.ID)
+			}
+			pointsOfInterest[pointOfInterest.ID] = true
+			targetPointsOfInterest[pointOfInterest.ID] = true
+			problems.required(pointOfInterestPath+".name", pointOfInterest.Name)
+		}
+		for j, clue := range target.Clues {
+			cluePath := fmt.Sprintf("%s.clues[%d]", targetPath, j)
+			problems.required(cluePath+".id", clue.ID)
+			if clueIDs[clue.ID] {
+				problems.report(cluePath+".id", CodeDuplicateID, "duplicate clue %q", clue.ID)
+			}
+			clueIDs[clue.ID] = true
+			problems.required(cluePath+".description", clue.Description)
+			if len(clue.Keywords) == 0 {
+				problems.report(cluePath+".keywords", CodeRequired, "required")
+			}
+			if clue.PointOfInterest != "" && !targetPointsOfInterest[clue.PointOfInterest] {
+				problems.report(cluePath+".point_of_interest", CodeUnknownReference,
+					"unknown point of interest %q of target %q", clue.PointOfInterest, target.ID)
+			}
+			if len(clue.Hints) > int(models.HintLevelTopic) {
+				problems.report(cluePath+".hints", CodeInvalidValue, "at most %d hints", models.HintLevelTopic)
+			}
+			for k, hint := range clue.Hints {
+				problems.required(fmt.Sprintf("%s.hints[%d]", cluePath, k), hint)
+			}
+		}
+	}
+	return clueIDs
+}
+
+// evidenceProblems checks the evidence requirements of the clues. Evidence can only be presented to persons and the
+// prerequisites must not form cycles, since a clue revealed only by confronting the person with itself could never be
+// discovered.
+func (b *Bundle) evidenceProblems(problems *problemList, clueIDs map[string]bool) {
+	requirements := make(map[string]string)
+	for _, target := range b.Targets {
+		for _, clue := range target.Clues {
+			requirements[clue.ID] = clue.RequiresEvidence
+		}
+	}
+	for i, target := range b.Targets {
+		for j, clue := range target.Clues {
+			if clue.RequiresEvidence == "" {
+				continue
+			}
+			requiresPath := fmt.Sprintf("targets[%d].clues[%d].requires_evidence", i, j)
+			switch {
+			case !clueIDs[clue.RequiresEvidence]:
+				problems.report(requiresPath, CodeUnknownReference, "unknown clue %q", clue.RequiresEvidence)
+			case target.Type != string(models.InvestigationTargetTypePerson):
+				problems.report(requiresPath, CodeInvalidValue, "only persons can be confronted with evidence")
+			case requiresItself(requirements, clue.ID):
+				problems.report(requiresPath, CodeInvalidValue, "clue %q requires itself as evidence", clue.ID)
+			}
+		}
+	}
+}
+
+// passageProblems checks the text of the documents. Only documents have passages and every document needs them, since
+// the document is read rather than questioned.
+func (b *Bundle) passageProblems(problems *problemList, clueIDs map[string]bool) {
+	for i, target := range b.Targets {
+		targetPath := fmt.Sprintf("targets[%d]", i)
+		isDocument := target.Type == string(models.InvestigationTargetTypeDocument)
+		switch {
+		case isDocument && len(target.Passages) == 0:
+			problems.report(targetPath+".passages", CodeRequired, "required")
+		case !isDocument && len(target.Passages) > 0:
+			problems.report(targetPath+".passages", CodeInvalidValue, "only documents have passages")
+		}
+		for j, passage := range target.Passages {
+			passagePath := fmt.Sprintf("%s.passages[%d]", targetPath, j)
+			problems.required(passagePath+".text", passage.Text)
+			if passage.RedactedUntil != "" && !clueIDs[passage.RedactedUntil] {
+				problems.report(passagePath+".redacted_until", CodeUnknownReference, "unknown clue %q",
+					passage.RedactedUntil)
+			}
+		}
+	}
+}
+
+// chapterProblems checks the chapters and the targets referring to them. A chapter can't be unlocked by a clue of its
+// own targets, since the player couldn't question them before the chapter is unlocked.
+func (b *Bundle) chapterProblems(problems *problemList, clueIDs map[string]bool) {
+	chapterIDs := make(map[string]bool)
+	for i, chapter := range b.Chapters {
+		chapterPath := fmt.Sprintf("chapters[%d]", i)
+		problems.required(chapterPath+".id", chapter.ID)
+		if chapterIDs[chapter.ID] {
+			problems.report(chapterPath+".id", CodeDuplicateID, "duplicate chapter %q", chapter.ID)
+		}
+		chapterIDs[chapter.ID] = true
+		problems.required(chapterPath+".name", chapter.Name)
+	}
+	clueChapters := make(map[string]string)
+	for i, target := range b.Targets {
+		if target.Chapter != "" && !chapterIDs[target.Chapter] {
+			problems.report(fmt.Sprintf("targets[%d].chapter", i), CodeUnknownReference, "unknown chapter %q",
+				target.Chapter)
+		}
+		for _, clue := range target.Clues {
+			clueChapters[clue.ID] = target.Chapter
+		}
+	}
+	for i, chapter := range b.Chapters {
+		for j, clueID := range chapter.UnlockedBy {
+			unlockedByPath := fmt.Sprintf("chapters[%d].unlocked_by[%d]", i, j)
+			switch {
+			case !clueIDs[clueID]:
+				problems.report(unlockedByPath, CodeUnknownReference, "unknown clue %q", clueID)
+			case clueChapters[clueID] == chapter.ID:
+				problems.report(unlockedByPath, CodeInvalidValue, "clue %q is revealed in the chapter it unlocks", clueID)
+			}
+		}
+	}
+}
+
+// requiresItself reports whether following the evidence requirements from the clue leads back to it.
+func requiresItself(requirements map[string]string, clueID string) bool {
+	visited := make(map[string]bool)
+	for next := requirements[clueID]; next != "" && !visited[next]; next = requirements[next] {
+		if next == clueID {
+			return true
+		}
+		visited[next] = true
+	}
+	return false
+}
+
+// Validate returns ErrInvalidBundle listing the problems of the bundle or nil if there are none.
+func (b *Bundle) Validate() error {
+	problems := b.Problems()
+	if len(problems) == 0 {
+		return nil
+	}
+	messages := make([]string, 0, len(problems))
+	for _, problem := range problems {
+		messages = append(messages, problem.String())
+	}
+	return errors.Wrap(ErrInvalidBundle, strings.Join(messages, "; "), slog.String("dir", b.Dir))
+}
@@ -0,0 +1,340 @@
+package casebundle_test
+
+import (
+	"github.com/myrjola/sheerluck/internal/casebundle"
+	"github.com/stretchr/testify/require"
+	"os"
+	"testing"
+	"testing/fstest"
+)
+
+func TestLoadAll(t *testing.T) {
+	t.Parallel()
+	bundles, err := casebundle.LoadAll(os.DirFS("../../ui/cases"))
+	require.NoError(t, err)
+	require.NotEmpty(t, bundles)
+	for _, bundle := range bundles {
+		require.Empty(t, bundle.Problems(), "bundle %s has problems", bundle.Dir)
+	}
+}
+
+func TestLoad(t *testing.T) {
+	t.Parallel()
+
+	t.Run("rejects unknown fields", func(t *testing.T) {
+		t.Parallel()
+		fsys := fstest.MapFS{"typo/case.yaml": {Data: []byte("id: typo\nnmae: Typo\n")}}
+		_, err := casebundle.Load(fsys, "typo")
+		require.ErrorContains(t, err, "field nmae not found")
+	})
+
+	t.Run("reports problems", func(t *testing.T) {
+		t.Parallel()
+		manifest := `id: broken
+name: Broken
+author: Nobody
+image: https://example.com/broken.webp
+difficulty: impossible
+play_time_minutes: 10
+targets:
+  - id: witness
+    name: The Witness
+    short_name: Witness
+    type: ghost
+    image: https://example.com/witness.webp
+    available_minutes: -60
+    clues:
+      - id: clue
+        description: A clue.
+        keywords: [clue]
+      - id: clue
+        description: The same clue.
+suspects:
+  - id: butler
+    name: The Butler
+solution:
+  culprit: gardener
+  motive: Revenge.
+  motive_keywords: [revenge]
+  wrong_motive_keywords: [greed, revenge]
+  clues: [clue, missing]
+  explanation: The gardener did it.
+`
+		fsys := fstest.MapFS{"broken-case/case.yaml": {Data: []byte(manifest)}}
+		bundle, err := casebundle.Load(fsys, "broken-case")
+		require.NoError(t, err)
+		var problems []string
+		for _, problem := range bundle.Problems() {
+			problems = append(problems, problem.String())
+		}
+		require.Equal(t, []string{
+			`id: must match the bundle directory "broken-case"`,
+			`difficulty: must be one of [easy medium hard]`,
+			`targets[0].type: must be one of [person scene item document]`,
+			`targets[0].available_minutes: must not be negative`,
+			`targets[0].clues[1].id: duplicate clue "clue"`,
+			`targets[0].clues[1].keywords: required`,
+			`solution.culprit: unknown suspect "gardener"`,
+			`solution.wrong_motive_keywords[1]: "revenge" is a keyword of the motive`,
+			`solution.clues[1]: unknown clue "missing"`,
+		}, problems)
+		require.ErrorIs(t, bundle.Validate(), casebundle.ErrInvalidBundle)
+	})
+	t.Run("reports evidence requirement problems", func(t *testing.T) {
+		t.Parallel()
+		manifest := `id: evidence
+name: Evidence
+author: Nobody
+image: https://example.com/evidence.webp
+difficulty: easy
+play_time_minutes: 10
+targets:
+  - id: witness
+    name: The Witness
+    short_name: Witness
+    type: person
+    image: https://example.com/witness.webp
+    clues:
+      - id: alibi
+        description: The alibi.
+        keywords: [alibi]
+        requires_evidence: lie
+      - id: lie
+        description: The lie.
+        keywords: [lie]
+        requires_evidence: alibi
+      - id: confession
+        description: The confession.
+        keywords: [confession]
+        requires_evidence: missing
+      - id: motive
+        description: The motive.
+        keywords: [motive]
+        requires_evidence: footprint
+  - id: garden
+    name: The Garden
+    short_name: Garden
+    type: scene
+    image: https://example.com/garden.webp
+    clues:
+      - id: footprint
+        description: A footprint.
+        keywords: [footprint]
+        requires_evidence: confession
+`
+		fsys := fstest.MapFS{"evidence/case.yaml": {Data: []byte(manifest)}}
+		bundle, err := casebundle.Load(fsys, "evidence")
+		require.NoError(t, err)
+		var problems []string
+		for _, problem := range bundle.Problems() {
+			problems = append(problems, problem.String())
+		}
+		require.Equal(t, []string{
+			`targets[0].clues[0].requires_evidence: clue "alibi" requires itself as evidence`,
+			`targets[0].clues[1].requires_evidence: clue "lie" requires itself as evidence`,
+			`targets[0].clues[2].requires_evidence: unknown clue "missing"`,
+			`targets[1].clues[0].requires_evidence: only persons can be confronted with evidence`,
+		}, problems)
+	})
+
+	t.Run("reports point of interest problems", func(t *testing.T) {
+		t.Parallel()
+		manifest := `id: places
+name: Places
+author: Nobody
+image: https://example.com/places.webp
+difficulty: easy
+play_time_minutes: 10
+targets:
+  - id: witness
+    name: The Witness
+    short_name: Witness
+    type: person
+    image: https://example.com/witness.webp
+    points_of_interest:
+      - id: pocket
+        name: the pocket
+    clues:
+      - id: alibi
+        description: The alibi.
+        keywords: [alibi]
+        point_of_interest: shed
+  - id: garden
+    name: The Garden
+    short_name: Garden
+    type: scene
+    image: https://example.com/garden.webp
+    points_of_interest:
+      - id: shed
+        name: the shed
+      - id: shed
+      - id: flowerbed
+        name: the flowerbed
+    clues:
+      - id: footprint
+        description: A footprint.
+        keywords: [footprint]
+        point_of_interest: flowerbed
+      - id: spade
+        description: A spade.
+        keywords: [spade]
+        point_of_interest: pocket
+`
+		fsys := fstest.MapFS{"places/case.yaml": {Data: []byte(manifest)}}
+		bundle, err := casebundle.Load(fsys, "places")
+		require.NoError(t, err)
+		var problems []string
+		for _, problem := range bundle.Problems() {
+			problems = append(problems, problem.String())
+		}
+		require.Equal(t, []string{
+			`targets[0].points_of_interest: only scenes have points of interest`,
+			`targets[0].clues[0].point_of_interest: unknown point of interest "shed" of target "witness"`,
+			`targets[1].points_of_interest[1].id: duplicate point of interest "shed"`,
+			`targets[1].points_of_interest[1].name: required`,
+			`targets[1].clues[1].point_of_interest: unknown point of interest "pocket" of target "garden"`,
+		}, problems)
+	})
+
+	t.Run("reports passage problems", func(t *testing.T) {
+		t.Parallel()
+		manifest := `id: papers
+name: Papers
+author: Nobody
+image: https://example.com/papers.webp
+difficulty: easy
+play_time_minutes: 10
+targets:
+  - id: letter
+    name: The Letter
+    short_name: Letter
+    type: document
+    image: https://example.com/letter.webp
+    passages:
+      - text: Dear Sir,
+      - text: Meet me at midnight.
+        redacted_until: signature
+      - text: Yours truly.
+        redacted_until: missing
+      - redacted_until: signature
+    clues:
+      - id: signature
+        description: The signature.
+        keywords: [signature]
+  - id: diary
+    name: The Diary
+    short_name: Diary
+    type: document
+    image: https://example.com/diary.webp
+  - id: knife
+    name: The Knife
+    short_name: Knife
+    type: item
+    image: https://example.com/knife.webp
+    passages:
+      - text: Made in Sheffield.
+`
+		fsys := fstest.MapFS{"papers/case.yaml": {Data: []byte(manifest)}}
+		bundle, err := casebundle.Load(fsys, "papers")
+		require.NoError(t, err)
+		var problems []string
+		for _, problem := range bundle.Problems() {
+			problems = append(problems, problem.String())
+		}
+		require.Equal(t, []string{
+			`targets[0].passages[2].redacted_until: unknown clue "missing"`,
+			`targets[0].passages[3].text: required`,
+			`targets[1].passages: required`,
+			`targets[2].passages: only documents have passages`,
+		}, problems)
+	})
+
+	t.Run("reports chapter problems", func(t *testing.T) {
+		t.Parallel()
+		manifest := `id: acts
+name: Acts
+author: Nobody
+image: https://example.com/acts.webp
+difficulty: easy
+play_time_minutes: 10
+chapters:
+  - id: second-act
+    name: The Second Act
+    unlocked_by: [confession, missing, letter]
+  - id: second-act
+targets:
+  - id: butler
+    name: The Butler
+    short_name: Butler
+    type: person
+    image: https://example.com/butler.webp
+    clues:
+      - id: confession
+        description: The confession.
+        keywords: [confess]
+  - id: maid
+    name: The Maid
+    short_name: Maid
+    type: person
+    chapter: second-act
+    image: https://example.com/maid.webp
+    clues:
+      - id: letter
+        description: The letter.
+        keywords: [letter]
+  - id: cook
+    name: The Cook
+    short_name: Cook
+    type: person
+    chapter: third-act
+    image: https://example.com/cook.webp
+`
+		fsys := fstest.MapFS{"acts/case.yaml": {Data: []byte(manifest)}}
+		bundle, err := casebundle.Load(fsys, "acts")
+		require.NoError(t, err)
+		var problems []string
+		for _, problem := range bundle.Problems() {
+			problems = append(problems, problem.String())
+		}
+		require.Equal(t, []string{
+			`chapters[1].id: duplicate chapter "second-act"`,
+			`chapters[1].name: required`,
+			`targets[2].chapter: unknown chapter "third-act"`,
+			`chapters[0].unlocked_by[1]: unknown clue "missing"`,
+			`chapters[0].unlocked_by[2]: clue "letter" is revealed in the chapter it unlocks`,
+		}, problems)
+	})
+
+	t.Run("reports hint problems", func(t *testing.T) {
+		t.Parallel()
+		manifest := `id: hints
+name: Hints
+author: Nobody
+image: https://example.com/hints.webp
+difficulty: easy
+play_time_minutes: 10
+targets:
+  - id: butler
+    name: The Butler
+    short_name: Butler
+    type: person
+    image: https://example.com/butler.webp
+    clues:
+      - id: confession
+        description: The confession.
+        keywords: [confess]
+        hints: [Someone is hiding something., "", Ask about the silver., One too many.]
+`
+		fsys := fstest.MapFS{"hints/case.yaml": {Data: []byte(manifest)}}
+		bundle, err := casebundle.Load(fsys, "hints")
+		require.NoError(t, err)
+		var problems []string
+		for _, problem := range bundle.Problems() {
+			problems = append(problems, problem.String())
+		}
+		require.Equal(t, []string{
+			`targets[0].clues[0].hints: at most 3 hints`,
+			`targets[0].clues[0].hints[1]: required`,
+		}, problems)
+	})
+}
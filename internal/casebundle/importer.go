@@ -0,0 +1,522 @@
+package casebundle
+
+import (
+	"context"
+	"database/sql"
+	"fmt"
+	"github.com/myrjola/sheerluck/internal/errors"
+	"github.com/myrjola/sheerluck/internal/sqlite"
+	"log/slog"
+	"slices"
+	"strings"
+)
+
+// Change is how an import changes a row in the database.
+type Change string
+
+const (
+	ChangeAdded   Change = "added"
+	ChangeUpdated Change = "updated"
+	ChangeRemoved Change = "removed"
+	// ChangeStale means the row was deleted from the bundle but kept in the database because pruning was disabled.
+	ChangeStale Change = "stale"
+)
+
+// Difference is a row changed by an import.
+type Difference struct {
+	Change Change
+	// Kind is the kind of the row, e.g., "target" or "clue".
+	Kind string
+	ID   string
+}
+
+func (d Difference) String() string {
+	return fmt.Sprintf("%s %s %s", d.Change, d.Kind, d.ID)
+}
+
+// Diff describes the changes an import made to the database.
+type Diff struct {
+	CaseID      string
+	Differences []Difference
+	// Unchanged is the number of rows that were already up to date.
+	Unchanged int
+}
+
+// Changed reports whether the import added, updated, or removed rows.
+func (d *Diff) Changed() bool {
+	return slices.ContainsFunc(d.Differences, func(difference Difference) bool {
+		return difference.Change != ChangeStale
+	})
+}
+
+// ImportOptions configures Importer.Import.
+type ImportOptions struct {
+	// Prune removes the targets, clues, suspects, and solution clues that were deleted from the bundle. Removing them
+	// also removes the players' progress related to them, e.g., the interrogations of a removed target.
+	Prune bool
+	// DryRun rolls back the import after computing the diff.
+	DryRun bool
+}
+
+// Importer upserts case bundles into the database.
+type Importer struct {
+	database *sqlite.Database
+	logger   *slog.Logger
+}
+
+func NewImporter(dbs *sqlite.Database, logger *slog.Logger) *Importer {
+	return &Importer{
+		database: dbs,
+		logger:   logger.With("source", "Importer"),
+	}
+}
+
+// row is a row of a table as declared in the bundle.
+type row struct {
+	// id identifies the row in the diff.
+	id     string
+	values []any
+}
+
+// table describes how a kind of bundle content is stored in the database.
+type table struct {
+	kind string
+	name string
+	// columns of the table starting with the keys columns identifying the rows.
+	columns []string
+	keys    int
+	// existing selects the ID and the columns of the rows belonging to the case named by the @case_id parameter.
+	existing string
+	// owner selects the ID of the case the row identified by the parameter belongs to. It's empty for the tables the
+	// case ID is a key of.
+	owner string
+	rows  []row
+}
+
+// tables returns the content of the bundle per table in the order it has to be inserted to satisfy the foreign keys.
+func (b *Bundle) tables() []table {
+	caseTable := table{
+		kind: "case",
+		name: "cases",
+		columns: []string{
+			"id", "name", "author", "image_path", "difficulty", "play_time_minutes", "blurb", "setting",
+		},
+		keys: 1,
+		existing: `SELECT id, id, name, author, image_path, difficulty, play_time_minutes, blurb, setting
+FROM cases
+WHERE id = @case_id`,
+		owner: "",
+		rows: []row{{id: b.ID, values: []any{
+			b.ID, b.Name, b.Author, b.Image, b.Difficulty, int64(b.PlayTimeMinutes), b.Blurb, b.Setting,
+		}}},
+	}
+	suspects := table{
+		kind:     "suspect",
+		name:     "suspects",
+		columns:  []string{"id", "name", "description", "case_id"},
+		keys:     1,
+		existing: `SELECT id, id, name, description, case_id FROM suspects WHERE case_id = @case_id`,
+		owner:    `SELECT case_id FROM suspects WHERE id = ?`,
+		rows:     nil,
+	}
+	for _, suspect := range b.Suspects {
+		suspects.rows = append(suspects.rows, row{id: suspect.ID, values: []any{
+			suspect.ID, suspect.Name, suspect.Description, b.ID,
+		}})
+	}
+	solution := table{
+		kind:    "solution",
+		name:    "case_solutions",
+		columns: []string{"case_id", "culprit_id", "motive", "motive_keywords", "wrong_motive_keywords", "explanation"},
+		keys:    1,
+		existing: `SELECT case_id, case_id, culprit_id, motive, motive_keywords, wrong_motive_keywords, explanation
+FROM case_solutions
+WHERE case_id = @case_id`,
+		owner: "",
+		rows:  nil,
+	}
+	solutionClues := table{
+		kind:     "solution clue",
+		name:     "case_solution_clues",
+		columns:  []string{"case_id", "clue_id"},
+		keys:     2, //nolint:mnd // case and clue
+		existing: `SELECT clue_id, case_id, clue_id FROM case_solution_clues WHERE case_id = @case_id`,
+		owner:    "",
+		rows:     nil,
+	}
+	if b.Solution != nil {
+		solution.rows = []row{{id: b.ID, values: []any{
+			b.ID, b.Solution.Culprit, b.Solution.Motive, strings.Join(b.Solution.MotiveKeywords, ","),
+			strings.Join(b.Solution.WrongMotiveKeywords, ","), b.Solution.Explanation,
+		}}}
+		for _, clueID := range b.Solution.Clues {
+			solutionClues.rows = append(solutionClues.rows, row{id: clueID, values: []any{b.ID, clueID}})
+		}
+	}
+	chapters, chapterPrerequisites := b.chapterTables()
+	tables := []table{caseTable, chapters}
+	tables = append(tables, b.targetTables()...)
+	return append(tables, chapterPrerequisites, suspects, solution, solutionClues)
+}
+
+// targetTables returns the targets and their content in the order it has to be inserted.
+func (b *Bundle) targetTables() []table {
+	targets := table{
+		kind: "target",
+		name: "investigation_targets",
+		columns: []string{
+			"id", "name", "short_name", "type", "image_path", "description", "secret", "available_minutes", "case_id",
+			"chapter_id",
+		},
+		keys: 1,
+		existing: `SELECT id,
+       id,
+       name,
+       short_name,
+       type,
+       image_path,
+       description,
+       secret,
+       available_minutes,
+       case_id,
+       chapter_id
+FROM investigation_targets
+WHERE case_id = @case_id`,
+		owner: `SELECT case_id FROM investigation_targets WHERE id = ?`,
+		rows:  nil,
+	}
+	clues := table{
+		kind: "clue",
+		name: "clues",
+		columns: []string{
+			"id", "description", "keywords", "investigation_target_id", "requires_evidence_id", "point_of_interest_id",
+		},
+		keys: 1,
+		existing: `SELECT c.id,
+       c.id,
+       c.description,
+       c.keywords,
+       c.investigation_target_id,
+       c.requires_evidence_id,
+       c.point_of_interest_id
+FROM clues c
+         JOIN investigation_targets t ON t.id = c.investigation_target_id
+WHERE t.case_id = @case_id`,
+		owner: `SELECT t.case_id
+FROM clues c
+         JOIN investigation_targets t ON t.id = c.investigation_target_id
+WHERE c.id = ?`,
+		rows: nil,
+	}
+	pointsOfInterest := table{
+		kind:    "point of interest",
+		name:    "points_of_interest",
+		columns: []string{"id", "name", "description", "position", "investigation_target_id"},
+		keys:    1,
+		existing: `SELECT p.id, p.id, p.name, p.description, p.position, p.investigation_target_id
+FROM points_of_interest p
+         JOIN investigation_targets t ON t.id = p.investigation_target_id
+WHERE t.case_id = @case_id`,
+		owner: `SELECT t.case_id
+FROM points_of_interest p
+         JOIN investigation_targets t ON t.id = p.investigation_target_id
+WHERE p.id = ?`,
+		rows: nil,
+	}
+	passages := table{
+		kind:    "passage",
+		name:    "passages",
+		columns: []string{"investigation_target_id", "position", "text", "redacted_until_clue_id"},
+		keys:    2, //nolint:mnd // target and position
+		existing: `SELECT p.investigation_target_id || '[' || p.position || ']',
+       p.investigation_target_id,
+       p.position,
+       p.text,
+       p.redacted_until_clue_id
+FROM passages p
+         JOIN investigation_targets t ON t.id = p.investigation_target_id
+WHERE t.case_id = @case_id`,
+		owner: "",
+		rows:  nil,
+	}
+	clueHints := table{
+		kind:    "clue hint",
+		name:    "clue_hints",
+		columns: []string{"clue_id", "level", "text"},
+		keys:    2, //nolint:mnd // clue and level
+		existing: `SELECT h.clue_id || '[' || h.level || ']', h.clue_id, h.level, h.text
+FROM clue_hints h
+         JOIN clues c ON c.id = h.clue_id
+         JOIN investigation_targets t ON t.id = c.investigation_target_id
+WHERE t.case_id = @case_id`,
+		owner: "",
+		rows:  nil,
+	}
+	for _, target := range b.Targets {
+		targets.rows = append(targets.rows, row{id: target.ID, values: []any{
+			target.ID, target.Name, target.ShortName, target.Type, target.Image, target.Description, target.Secret,
+			int64(target.AvailableMinutes), b.ID, nullIfEmpty(target.Chapter),
+		}})
+		for j, pointOfInterest := range target.PointsOfInterest {
+			pointsOfInterest.rows = append(pointsOfInterest.rows, row{id: pointOfInterest.ID, values: []any{
+				pointOfInterest.ID, pointOfInterest.Name, pointOfInterest.Description, int64(j), target.ID,
+			}})
+		}
+		for _, clue := range target.Clues {
+			clues.rows = append(clues.rows, row{id: clue.ID, values: []any{
+				clue.ID, clue.Description, strings.Join(clue.Keywords, ","), target.ID, nullIfEmpty(clue.RequiresEvidence),
+				nullIfEmpty(clue.PointOfInterest),
+			}})
+			for j, hint := range clue.Hints {
+				level := int64(j) + 1
+				clueHints.rows = append(clueHints.rows, row{id: fmt.Sprintf("%s[%d]", clue.ID, level), values: []any{
+					clue.ID, level, hint,
+				}})
+			}
+		}
+		for j, passage := range target.Passages {
+			passages.rows = append(passages.rows, row{id: fmt.Sprintf("%s[%d]", target.ID, j), values: []any{
+				target.ID, int64(j), passage.Text, nullIfEmpty(passage.RedactedUntil),
+			}})
+		}
+	}
+	return []table{targets, pointsOfInterest, clues, clueHints, passages}
+}
+
+// chapterTables returns the chapters and the clues unlocking them.
+func (b *Bundle) chapterTables() (table, table) {
+	chapters := table{
+		kind:     "chapter",
+		name:     "chapters",
+		columns:  []string{"id", "name", "position", "case_id"},
+		keys:     1,
+		existing: `SELECT id, id, name, position, case_id FROM chapters WHERE case_id = @case_id`,
+		owner:    `SELECT case_id FROM chapters WHERE id = ?`,
+		rows:     nil,
+	}
+	prerequisites := table{
+		kind:    "chapter prerequisite",
+		name:    "chapter_prerequisites",
+		columns: []string{"chapter_id", "clue_id"},
+		keys:    2, //nolint:mnd // chapter and clue
+		existing: `SELECT p.chapter_id || '/' || p.clue_id, p.chapter_id, p.clue_id
+FROM chapter_prerequisites p
+         JOIN chapters c ON c.id = p.chapter_id
+WHERE c.case_id = @case_id`,
+		owner: "",
+		rows:  nil,
+	}
+	for i, chapter := range b.Chapters {
+		chapters.rows = append(chapters.rows, row{id: chapter.ID, values: []any{
+			chapter.ID, chapter.Name, int64(i), b.ID,
+		}})
+		for _, clueID := range chapter.UnlockedBy {
+			prerequisites.rows = append(prerequisites.rows, row{id: chapter.ID + "/" + clueID, values: []any{
+				chapter.ID, clueID,
+			}})
+		}
+	}
+	return chapters, prerequisites
+}
+
+// Import validates the bundle and upserts it into the database in a single transaction. The returned diff lists the
+// changes. ErrInvalidBundle is returned if the bundle has problems.
+func (i *Importer) Import(ctx context.Context, bundle *Bundle, options ImportOptions) (*Diff, error) {
+	var (
+		tx  *sql.Tx
+		err error
+	)
+	if err = bundle.Validate(); err != nil {
+		return nil, errors.Wrap(err, "validate bundle")
+	}
+	if tx, err = i.database.ReadWrite.BeginTx(ctx, nil); err != nil {
+		return nil, errors.Wrap(err, "begin transaction")
+	}
+	defer func() {
+		if err = tx.Rollback(); err != nil && !errors.Is(err, sql.ErrTxDone) {
+			err = errors.Wrap(err, "rollback transaction")
+			i.logger.LogAttrs(ctx, slog.LevelError, "failed to rollback transaction", errors.SlogError(err))
+		}
+	}()
+
+	diff := &Diff{CaseID: bundle.ID, Differences: nil, Unchanged: 0}
+	tables := bundle.tables()
+	stale := make([]map[string][]any, len(tables))
+	for j, t := range tables {
+		if stale[j], err = i.upsert(ctx, tx, bundle.ID, t, diff); err != nil {
+			return nil, errors.Wrap(err, "upsert", slog.String("table", t.name))
+		}
+	}
+	// Remove in reverse order so that the referencing rows are removed first.
+	for j := len(tables) - 1; j >= 0; j-- {
+		ids := make([]string, 0, len(stale[j]))
+		for id := range stale[j] {
+			ids = append(ids, id)
+		}
+		slices.Sort(ids)
+		for _, id := range ids {
+			if !options.Prune {
+				diff.Differences = append(diff.Differences, Difference{Change: ChangeStale, Kind: tables[j].kind, ID: id})
+				continue
+			}
+			if err = remove(ctx, tx, tables[j], stale[j][id]); err != nil {
+				return nil, errors.Wrap(err, "remove", slog.String("table", tables[j].name), slog.String("id", id))
+			}
+			diff.Differences = append(diff.Differences, Difference{Change: ChangeRemoved, Kind: tables[j].kind, ID: id})
+		}
+	}
+
+	if options.DryRun {
+		return diff, nil
+	}
+	if err = tx.Commit(); err != nil {
+		return nil, errors.Wrap(err, "commit transaction")
+	}
+	i.logger.LogAttrs(ctx, slog.LevelInfo, "imported case bundle", slog.String("case_id", bundle.ID),
+		slog.Int("differences", len(diff.Differences)), slog.Int("unchanged", diff.Unchanged))
+	return diff, nil
+}
+
+// upsert inserts the new rows of the table, updates the changed ones, and returns the existing rows deleted from the
+// bundle by ID. ErrInvalidBundle is returned if a new row has the ID of another case's row.
+func (i *Importer) upsert(
+	ctx context.Context,
+	tx *sql.Tx,
+	caseID string,
+	t table,
+	diff *Diff,
+) (map[string][]any, error) {
+	var (
+		existing map[string][]any
+		err      error
+	)
+	if existing, err = i.queryExisting(ctx, tx, caseID, t); err != nil {
+		return nil, errors.Wrap(err, "query existing rows")
+	}
+
+	assignments := make([]string, 0, len(t.columns)-t.keys)
+	for _, column := range t.columns[t.keys:] {
+		assignments = append(assignments, fmt.Sprintf("%s = excluded.%s", column, column))
+	}
+	onConflict := "DO NOTHING"
+	if len(assignments) > 0 {
+		onConflict = "DO UPDATE SET " + strings.Join(assignments, ", ")
+	}
+	stmt := fmt.Sprintf("INSERT INTO %s (%s) VALUES (%s) ON CONFLICT (%s) %s", //nolint:gosec // constant identifiers
+		t.name,
+		strings.Join(t.columns, ", "),
+		strings.TrimSuffix(strings.Repeat("?, ", len(t.columns)), ", "),
+		strings.Join(t.columns[:t.keys], ", "),
+		onConflict,
+	)
+
+	for _, r := range t.rows {
+		change := ChangeAdded
+		if values, ok := existing[r.id]; ok {
+			delete(existing, r.id)
+			if slices.Equal(normalize(values), normalize(r.values)) {
+				diff.Unchanged++
+				continue
+			}
+			change = ChangeUpdated
+		} else if err = checkOwner(ctx, tx, caseID, t, r); err != nil {
+			return nil, err
+		}
+		if _, err = tx.ExecContext(ctx, stmt, r.values...); err != nil {
+			return nil, errors.Wrap(err, "upsert row", slog.String("id", r.id))
+		}
+		diff.Differences = append(diff.Differences, Difference{Change: change, Kind: t.kind, ID: r.id})
+	}
+	return existing, nil
+}
+
+// queryExisting returns the rows of the table belonging to the case by ID.
+func (i *Importer) queryExisting(
+	ctx context.Context,
+	tx *sql.Tx,
+	caseID string,
+	t table,
+) (map[string][]any, error) {
+	var (
+		rows *sql.Rows
+		err  error
+	)
+	if rows, err = tx.QueryContext(ctx, t.existing, sql.Named("case_id", caseID)); err != nil {
+		return nil, errors.Wrap(err, "query rows")
+	}
+	defer func() {
+		if err = rows.Close(); err != nil {
+			err = errors.Wrap(err, "close rows")
+			i.logger.Error("could not close rows", errors.SlogError(err))
+		}
+	}()
+	existing := make(map[string][]any)
+	for rows.Next() {
+		var id string
+		values := make([]any, len(t.columns))
+		dest := []any{&id}
+		for j := range values {
+			dest = append(dest, &values[j])
+		}
+		if err = rows.Scan(dest...); err != nil {
+			return nil, errors.Wrap(err, "scan row")
+		}
+		existing[id] = values
+	}
+	if err = rows.Err(); err != nil {
+		return nil, errors.Wrap(err, "rows error")
+	}
+	return existing, nil
+}
+
+// checkOwner returns ErrInvalidBundle if the row to be added belongs to another case, because upserting it would take
+// the row over from the other case.
+func checkOwner(ctx context.Context, tx *sql.Tx, caseID string, t table, r row) error {
+	if t.owner == "" {
+		return nil
+	}
+	var owner string
+	err := tx.QueryRowContext(ctx, t.owner, r.id).Scan(&owner)
+	if errors.Is(err, sql.ErrNoRows) {
+		return nil
+	}
+	if err != nil {
+		return errors.Wrap(err, "query owner", slog.String("id", r.id))
+	}
+	return errors.Wrap(ErrInvalidBundle, fmt.Sprintf("%s %q belongs to case %q", t.kind, r.id, owner),
+		slog.String("case_id", caseID))
+}
+
+// remove deletes the row of the table identified by the key columns of values.
+func remove(ctx context.Context, tx *sql.Tx, t table, values []any) error {
+	conditions := make([]string, 0, t.keys)
+	for _, column := range t.columns[:t.keys] {
+		conditions = append(conditions, column+" = ?")
+	}
+	stmt := fmt.Sprintf("DELETE FROM %s WHERE %s", //nolint:gosec // constant identifiers
+		t.name, strings.Join(conditions, " AND "))
+	if _, err := tx.ExecContext(ctx, stmt, values[:t.keys]...); err != nil {
+		return errors.Wrap(err, "delete row")
+	}
+	return nil
+}
+
+// nullIfEmpty returns nil for storing an empty optional reference as NULL.
+func nullIfEmpty(value string) any {
+	if value == "" {
+		return nil
+	}
+	return value
+}
+
+// normalize formats the values for comparing the database rows with the bundle.
+func normalize(values []any) []string {
+	normalized := make([]string, 0, len(values))
+	for _, value := range values {
+		if b, ok := value.([]byte); ok {
+			value = string(b)
+		}
+		normalized = append(normalized, fmt.Sprint(value))
+	}
+	return normalized
+}
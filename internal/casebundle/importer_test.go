@@ -0,0 +1,105 @@
+package casebundle_test
+
+import (
+	"context"
+	"github.com/myrjola/sheerluck/internal/casebundle"
+	"github.com/myrjola/sheerluck/internal/sqlite"
+	"github.com/myrjola/sheerluck/internal/testhelpers"
+	"github.com/stretchr/testify/require"
+	"io"
+	"os"
+	"testing"
+)
+
+func loadRueMorgue(t *testing.T) *casebundle.Bundle {
+	t.Helper()
+	bundle, err := casebundle.Load(os.DirFS("../../ui/cases"), "rue-morgue")
+	require.NoError(t, err)
+	return bundle
+}
+
+func differences(diff *casebundle.Diff) []string {
+	var lines []string
+	for _, difference := range diff.Differences {
+		lines = append(lines, difference.String())
+	}
+	return lines
+}
+
+func TestImporter_Import(t *testing.T) {
+	t.Parallel()
+	ctx := context.Background()
+	logger := testhelpers.NewLogger(io.Discard)
+	dbs, err := sqlite.NewDatabase(ctx, ":memory:", logger)
+	require.NoError(t, err)
+	importer := casebundle.NewImporter(dbs, logger)
+	keep := casebundle.ImportOptions{Prune: false, DryRun: false}
+
+	// A dry run doesn't change the database.
+	diff, err := importer.Import(ctx, loadRueMorgue(t), casebundle.ImportOptions{Prune: false, DryRun: true})
+	require.NoError(t, err)
+	require.True(t, diff.Changed())
+	var count int
+	require.NoError(t, dbs.ReadOnly.QueryRowContext(ctx, "SELECT COUNT(*) FROM cases").Scan(&count))
+	require.Zero(t, count)
+
+	diff, err = importer.Import(ctx, loadRueMorgue(t), keep)
+	require.NoError(t, err)
+	require.Contains(t, differences(diff), "added case rue-morgue")
+	require.Contains(t, differences(diff), "added target le-bon")
+	require.Contains(t, differences(diff), "added clue le-bon-victim-belongings")
+	require.Contains(t, differences(diff), "added solution rue-morgue")
+	require.Contains(t, differences(diff), "added chapter rue-morgue-seized-belongings")
+	require.Contains(t, differences(diff), "added clue hint rue-morgue-window-spring[3]")
+	require.Contains(t, differences(diff),
+		"added chapter prerequisite rue-morgue-seized-belongings/le-bon-victim-belongings")
+	require.Zero(t, diff.Unchanged)
+
+	// Importing again changes nothing.
+	diff, err = importer.Import(ctx, loadRueMorgue(t), keep)
+	require.NoError(t, err)
+	require.False(t, diff.Changed())
+	require.Empty(t, diff.Differences)
+	require.Positive(t, diff.Unchanged)
+
+	// Content deleted from the bundle is kept unless pruned.
+	bundle := loadRueMorgue(t)
+	bundle.Blurb = "A new blurb."
+	bundle.Targets[0].Clues = bundle.Targets[0].Clues[:2]
+	bundle.Suspects = bundle.Suspects[:len(bundle.Suspects)-1]
+	diff, err = importer.Import(ctx, bundle, keep)
+	require.NoError(t, err)
+	require.Equal(t, []string{
+		"updated case rue-morgue",
+		"stale suspect rue-morgue-robber",
+		"stale clue le-bon-unannounced-delivery",
+	}, differences(diff))
+
+	diff, err = importer.Import(ctx, bundle, casebundle.ImportOptions{Prune: true, DryRun: false})
+	require.NoError(t, err)
+	require.Equal(t, []string{
+		"removed suspect rue-morgue-robber",
+		"removed clue le-bon-unannounced-delivery",
+	}, differences(diff))
+	require.NoError(t, dbs.ReadOnly.QueryRowContext(ctx,
+		"SELECT COUNT(*) FROM clues WHERE id = 'le-bon-unannounced-delivery'").Scan(&count))
+	require.Zero(t, count)
+
+	// Another case can't take over the rows of the case.
+	other := loadRueMorgue(t)
+	other.ID = "rue-morgue-copy"
+	other.Dir = "rue-morgue-copy"
+	other.Name = "The Murders in the Rue Morgue, Again"
+	_, err = importer.Import(ctx, other, keep)
+	require.ErrorIs(t, err, casebundle.ErrInvalidBundle)
+	require.ErrorContains(t, err, `chapter "rue-morgue-seized-belongings" belongs to case "rue-morgue"`)
+	var caseID string
+	require.NoError(t, dbs.ReadOnly.QueryRowContext(ctx,
+		"SELECT case_id FROM investigation_targets WHERE id = 'le-bon'").Scan(&caseID))
+	require.Equal(t, "rue-morgue", caseID)
+
+	// Invalid bundles are not imported.
+	bundle.Solution.Culprit = "nobody"
+	_, err = importer.Import(ctx, bundle, keep)
+	require.ErrorIs(t, err, casebundle.ErrInvalidBundle)
+}
@@ -0,0 +1,357 @@
+package casebundle
+
+import (
+	"context"
+	"fmt"
+	"github.com/myrjola/sheerluck/internal/errors"
+	"github.com/myrjola/sheerluck/internal/sqlite"
+	"io/fs"
+	"log/slog"
+	"net/http"
+	"slices"
+	"strings"
+	"unicode"
+	"unicode/utf8"
+)
+
+// Lint codes identify the kinds of content problems found by Lint in addition to the Problem codes.
+const (
+	CodeTooLong          = "too-long"
+	CodeMissingImage     = "missing-image"
+	CodeUnreachableClue  = "unreachable-clue"
+	CodeDuplicateKeyword = "duplicate-keyword"
+)
+
+// Severity tells whether a Diagnostic prevents importing the bundle.
+type Severity string
+
+const (
+	SeverityError   Severity = "error"
+	SeverityWarning Severity = "warning"
+)
+
+// Diagnostic is a problem found in a bundle by Lint.
+type Diagnostic struct {
+	// File is the path of the manifest.
+	File string `json:"file"`
+	// Line is the line in the manifest or 0 if unknown.
+	Line int `json:"line"`
+	// Path locates the problem in the manifest, e.g., "targets[0].clues[1].keywords".
+	Path     string   `json:"path"`
+	Severity Severity `json:"severity"`
+	Code     string   `json:"code"`
+	Message  string   `json:"message"`
+}
+
+func (d Diagnostic) String() string {
+	return fmt.Sprintf("%s:%d: %s: %s: %s [%s]", d.File, d.Line, d.Severity, d.Path, d.Message, d.Code)
+}
+
+// LintOptions configures Lint.
+type LintOptions struct {
+	// Static is the file system the web server serves static files from. Images with an absolute path, e.g.,
+	// /images/le-bon.webp, must exist in it.
+	Static fs.FS
+	// HTTPClient checks that the images referenced by URL exist. The URLs aren't checked if it's nil.
+	HTTPClient *http.Client
+}
+
+// Lint checks the bundles for the problems preventing their import and for content problems that SQLite or the
+// players would otherwise run into:
+//
+//   - the IDs shared by all cases must be unique across bundles,
+//   - the texts must fit the length() CHECK limits of the schema,
+//   - the images must exist,
+//   - every clue must have a keyword that can match an answer and be reachable through the chapters and the evidence
+//     it requires,
+//   - the same keyword shouldn't reveal clues of different targets.
+//
+// The diagnostics are ordered by file and line.
+func Lint(ctx context.Context, bundles []*Bundle, options LintOptions) []Diagnostic {
+	var diagnostics []Diagnostic
+	for _, bundle := range bundles {
+		linter := &bundleLinter{bundle: bundle, options: options, diagnostics: nil}
+		linter.lint(ctx)
+		diagnostics = append(diagnostics, linter.diagnostics...)
+	}
+	diagnostics = append(diagnostics, lintDuplicateIDs(bundles)...)
+	slices.SortStableFunc(diagnostics, func(a, b Diagnostic) int {
+		if c := strings.Compare(a.File, b.File); c != 0 {
+			return c
+		}
+		return a.Line - b.Line
+	})
+	return diagnostics
+}
+
+type bundleLinter struct {
+	bundle      *Bundle
+	options     LintOptions
+	diagnostics []Diagnostic
+}
+
+func (l *bundleLinter) report(severity Severity, path string, code string, format string, args ...any) {
+	l.diagnostics = append(l.diagnostics, Diagnostic{
+		File:     l.bundle.ManifestPath(),
+		Line:     l.bundle.Line(path),
+		Path:     path,
+		Severity: severity,
+		Code:     code,
+		Message:  fmt.Sprintf(format, args...),
+	})
+}
+
+func (l *bundleLinter) lint(ctx context.Context) {
+	b := l.bundle
+	for _, problem := range b.Problems() {
+		l.report(SeverityError, problem.Path, problem.Code, "%s", problem.Message)
+	}
+
+	limits := sqlite.LengthLimits()
+	fits := func(path string, table string, column string, value string) {
+		if limit, ok := limits[table][column]; ok && utf8.RuneCountInString(value) > limit {
+			l.report(SeverityError, path, CodeTooLong, "%d characters exceed the limit of %d",
+				utf8.RuneCountInString(value), limit)
+		}
+	}
+	fits("id", "cases", "id", b.ID)
+	fits("name", "cases", "name", b.Name)
+	fits("author", "cases", "author", b.Author)
+	fits("image", "cases", "image_path", b.Image)
+	fits("blurb", "cases", "blurb", b.Blurb)
+	fits("setting", "cases", "setting", b.Setting)
+	l.checkImage(ctx, "image", b.Image)
+	for i, chapter := range b.Chapters {
+		fits(fmt.Sprintf("chapters[%d].id", i), "chapters", "id", chapter.ID)
+		fits(fmt.Sprintf("chapters[%d].name", i), "chapters", "name", chapter.Name)
+	}
+
+	keywordTargets := make(map[string]string)
+	for i, target := range b.Targets {
+		targetPath := fmt.Sprintf("targets[%d]", i)
+		fits(targetPath+".id", "investigation_targets", "id", target.ID)
+		fits(targetPath+".name", "investigation_targets", "name", target.Name)
+		fits(targetPath+".short_name", "investigation_targets", "short_name", target.ShortName)
+		fits(targetPath+".image", "investigation_targets", "image_path", target.Image)
+		fits(targetPath+".description", "investigation_targets", "description", target.Description)
+		fits(targetPath+".secret", "investigation_targets", "secret", target.Secret)
+		l.checkImage(ctx, targetPath+".image", target.Image)
+		for j, pointOfInterest := range target.PointsOfInterest {
+			pointOfInterestPath := fmt.Sprintf("%s.points_of_interest[%d]", targetPath, j)
+			fits(pointOfInterestPath+".id", "points_of_interest", "id", pointOfInterest.ID)
+			fits(pointOfInterestPath+".name", "points_of_interest", "name", pointOfInterest.Name)
+			fits(pointOfInterestPath+".description", "points_of_interest", "description", pointOfInterest.Description)
+		}
+		for j, passage := range target.Passages {
+			fits(fmt.Sprintf("%s.passages[%d].text", targetPath, j), "passages", "text", passage.Text)
+		}
+		for j, clue := range target.Clues {
+			cluePath := fmt.Sprintf("%s.clues[%d]", targetPath, j)
+			fits(cluePath+".id", "clues", "id", clue.ID)
+			fits(cluePath+".description", "clues", "description", clue.Description)
+			fits(cluePath+".keywords", "clues", "keywords", strings.Join(clue.Keywords, ","))
+			for k, hint := range clue.Hints {
+				fits(fmt.Sprintf("%s.hints[%d]", cluePath, k), "clue_hints", "text", hint)
+			}
+			if len(clue.Keywords) > 0 && !slices.ContainsFunc(clue.Keywords, matchable) {
+				l.report(SeverityError, cluePath+".keywords", CodeUnreachableClue,
+					"clue %q can't be discovered since none of its keywords has letters or digits", clue.ID)
+			}
+			for k, keyword := range clue.Keywords {
+				normalized := strings.ToLower(strings.TrimSpace(keyword))
+				if other, ok := keywordTargets[normalized]; ok && other != target.ID {
+					l.report(SeverityWarning, fmt.Sprintf("%s.keywords[%d]", cluePath, k), CodeDuplicateKeyword,
+						"keyword %q is also used by target %q", keyword, other)
+				}
+				keywordTargets[normalized] = target.ID
+			}
+		}
+	}
+
+	for i, suspect := range b.Suspects {
+		suspectPath := fmt.Sprintf("suspects[%d]", i)
+		fits(suspectPath+".id", "suspects", "id", suspect.ID)
+		fits(suspectPath+".name", "suspects", "name", suspect.Name)
+		fits(suspectPath+".description", "suspects", "description", suspect.Description)
+	}
+
+	l.lintReachability()
+
+	if b.Solution != nil {
+		fits("solution.motive", "case_solutions", "motive", b.Solution.Motive)
+		fits("solution.motive_keywords", "case_solutions", "motive_keywords",
+			strings.Join(b.Solution.MotiveKeywords, ","))
+		fits("solution.wrong_motive_keywords", "case_solutions", "wrong_motive_keywords",
+			strings.Join(b.Solution.WrongMotiveKeywords, ","))
+		fits("solution.explanation", "case_solutions", "explanation", b.Solution.Explanation)
+	}
+}
+
+// lintReachability reports the clues the player can never discover. The discoverable clues are computed as a fixed
+// point: the targets outside chapters are available from the start, a chapter is unlocked once all the clues unlocking
+// it are discoverable, and a clue is discoverable once its target is available, it has a matchable keyword, and the
+// evidence it requires is discoverable. References to unknown chapters and clues are reported as problems, so they
+// don't make clues unreachable here.
+func (l *bundleLinter) lintReachability() {
+	b := l.bundle
+	chapterIDs := make(map[string]bool)
+	for _, chapter := range b.Chapters {
+		chapterIDs[chapter.ID] = true
+	}
+	clueIDs := make(map[string]bool)
+	for _, target := range b.Targets {
+		for _, clue := range target.Clues {
+			clueIDs[clue.ID] = true
+		}
+	}
+	unlocked := make(map[string]bool)
+	reachable := make(map[string]bool)
+	available := func(chapterID string) bool {
+		return chapterID == "" || !chapterIDs[chapterID] || unlocked[chapterID]
+	}
+	for changed := true; changed; {
+		changed = false
+		for _, chapter := range b.Chapters {
+			if !unlocked[chapter.ID] && !slices.ContainsFunc(chapter.UnlockedBy, func(clueID string) bool {
+				return clueIDs[clueID] && !reachable[clueID]
+			}) {
+				unlocked[chapter.ID] = true
+				changed = true
+			}
+		}
+		for _, target := range b.Targets {
+			for _, clue := range target.Clues {
+				if reachable[clue.ID] || !available(target.Chapter) || !slices.ContainsFunc(clue.Keywords, matchable) {
+					continue
+				}
+				if clue.RequiresEvidence == "" || !clueIDs[clue.RequiresEvidence] || reachable[clue.RequiresEvidence] {
+					reachable[clue.ID] = true
+					changed = true
+				}
+			}
+		}
+	}
+
+	for i, target := range b.Targets {
+		for j, clue := range target.Clues {
+			cluePath := fmt.Sprintf("targets[%d].clues[%d]", i, j)
+			switch {
+			case reachable[clue.ID] || !slices.ContainsFunc(clue.Keywords, matchable):
+				// The clues without matchable keywords are reported with the keywords.
+			case !available(target.Chapter):
+				l.report(SeverityError, cluePath, CodeUnreachableClue,
+					"clue %q can't be discovered since chapter %q is never unlocked", clue.ID, target.Chapter)
+			default:
+				l.report(SeverityError, cluePath+".requires_evidence", CodeUnreachableClue,
+					"clue %q can't be discovered since the evidence %q it requires can't be discovered", clue.ID,
+					clue.RequiresEvidence)
+			}
+		}
+	}
+	if b.Solution != nil {
+		for i, clueID := range b.Solution.Clues {
+			if clueIDs[clueID] && !reachable[clueID] {
+				l.report(SeverityError, fmt.Sprintf("solution.clues[%d]", i), CodeUnreachableClue,
+					"solution clue %q can't be discovered", clueID)
+			}
+		}
+	}
+}
+
+// checkImage reports the image if it doesn't exist.
+func (l *bundleLinter) checkImage(ctx context.Context, path string, image string) {
+	switch {
+	case image == "":
+		// Reported as a missing field.
+	case strings.HasPrefix(image, "/"):
+		if l.options.Static == nil {
+			return
+		}
+		if _, err := fs.Stat(l.options.Static, strings.TrimPrefix(image, "/")); err != nil {
+			l.report(SeverityError, path, CodeMissingImage, "image %q not found in the static files", image)
+		}
+	case strings.HasPrefix(image, "https://") || strings.HasPrefix(image, "http://"):
+		if l.options.HTTPClient == nil {
+			return
+		}
+		if err := checkURL(ctx, l.options.HTTPClient, image); err != nil {
+			l.report(SeverityError, path, CodeMissingImage, "image %q not found: %v", image, err)
+		}
+	default:
+		l.report(SeverityError, path, CodeMissingImage,
+			"image %q must be a URL or an absolute path to the static files", image)
+	}
+}
+
+func checkURL(ctx context.Context, client *http.Client, url string) error {
+	req, err := http.NewRequestWithContext(ctx, http.MethodHead, url, nil)
+	if err != nil {
+		return errors.Wrap(err, "new request")
+	}
+	resp, err := client.Do(req)
+	if err != nil {
+		return errors.Wrap(err, "request image")
+	}
+	if err = resp.Body.Close(); err != nil {
+		return errors.Wrap(err, "close body")
+	}
+	if resp.StatusCode != http.StatusOK {
+		return errors.New("unexpected status", slog.Int("status", resp.StatusCode))
+	}
+	return nil
+}
+
+// matchable reports whether the keyword can match words in an answer.
+func matchable(keyword string) bool {
+	return strings.ContainsFunc(keyword, func(r rune) bool {
+		return unicode.IsLetter(r) || unicode.IsDigit(r)
+	})
+}
+
+// lintDuplicateIDs reports the IDs that are unique in their bundle but used by other bundles as well. The cases share
+// the tables of the targets, clues, and suspects, so the importer refuses the bundle imported after the other.
+func lintDuplicateIDs(bundles []*Bundle) []Diagnostic {
+	var diagnostics []Diagnostic
+	owners := make(map[string]*Bundle)
+	claim := func(bundle *Bundle, kind string, path string, id string) {
+		key := kind + "/" + id
+		owner, ok := owners[key]
+		if !ok {
+			owners[key] = bundle
+			return
+		}
+		if owner == bundle {
+			return
+		}
+		diagnostics = append(diagnostics, Diagnostic{
+			File:     bundle.ManifestPath(),
+			Line:     bundle.Line(path),
+			Path:     path,
+			Severity: SeverityError,
+			Code:     CodeDuplicateID,
+			Message:  fmt.Sprintf("%s %q is also declared in %s", kind, id, owner.ManifestPath()),
+		})
+	}
+	for _, bundle := range bundles {
+		claim(bundle, "case", "id", bundle.ID)
+		claim(bundle, "case name", "name", bundle.Name)
+		for i, chapter := range bundle.Chapters {
+			claim(bundle, "chapter", fmt.Sprintf("chapters[%d].id", i), chapter.ID)
+		}
+		for i, target := range bundle.Targets {
+			claim(bundle, "target", fmt.Sprintf("targets[%d].id", i), target.ID)
+			claim(bundle, "target name", fmt.Sprintf("targets[%d].name", i), target.Name)
+			for j, clue := range target.Clues {
+				claim(bundle, "clue", fmt.Sprintf("targets[%d].clues[%d].id", i, j), clue.ID)
+			}
+			for j, pointOfInterest := range target.PointsOfInterest {
+				claim(bundle, "point of interest", fmt.Sprintf("targets[%d].points_of_interest[%d].id", i, j),
+					pointOfInterest.ID)
+			}
+		}
+		for i, suspect := range bundle.Suspects {
+			claim(bundle, "suspect", fmt.Sprintf("suspects[%d].id", i), suspect.ID)
+		}
+	}
+	return diagnostics
+}
@@ -0,0 +1,188 @@
+package casebundle_test
+
+import (
+	"context"
+	"github.com/myrjola/sheerluck/internal/casebundle"
+	"github.com/stretchr/testify/require"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"strings"
+	"testing"
+	"testing/fstest"
+)
+
+func TestLint(t *testing.T) {
+	t.Parallel()
+	ctx := context.Background()
+
+	t.Run("accepts the shipped bundles", func(t *testing.T) {
+		t.Parallel()
+		bundles, err := casebundle.LoadAll(os.DirFS("../../ui/cases"))
+		require.NoError(t, err)
+		diagnostics := casebundle.Lint(ctx, bundles, casebundle.LintOptions{
+			Static:     os.DirFS("../../ui/static"),
+			HTTPClient: nil,
+		})
+		require.Empty(t, diagnostics)
+	})
+
+	t.Run("reports content problems", func(t *testing.T) {
+		t.Parallel()
+		server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			if r.URL.Path != "/found.webp" {
+				http.NotFound(w, r)
+			}
+		}))
+		t.Cleanup(server.Close)
+		manifest := `id: lint
+name: Lint
+author: Nobody
+image: ` + server.URL + `/missing.webp
+play_time_minutes: 10
+difficulty: easy
+setting: ` + strings.Repeat("x", 4096) + `
+targets:
+  - id: witness
+    name: The Witness
+    short_name: Witness
+    type: person
+    image: /images/missing.webp
+    clues:
+      - id: punctuation
+        description: A clue nobody finds.
+        keywords: ["?!"]
+  - id: scene
+    name: The Scene
+    short_name: Scene
+    type: scene
+    image: ` + server.URL + `/found.webp
+    clues:
+      - id: rue-morgue-window-spring
+        description: A clue sharing a keyword with the witness.
+        keywords: [punctuation, "?!"]
+suspects:
+  - id: butler
+    name: The Butler
+solution:
+  culprit: butler
+  motive: Revenge.
+  motive_keywords: [revenge]
+  clues: [punctuation]
+  explanation: The butler did it.
+`
+		fsys := fstest.MapFS{"lint/case.yaml": {Data: []byte(manifest)}}
+		bundle, err := casebundle.Load(fsys, "lint")
+		require.NoError(t, err)
+		shipped, err := casebundle.LoadAll(os.DirFS("../../ui/cases"))
+		require.NoError(t, err)
+
+		diagnostics := casebundle.Lint(ctx, append(shipped, bundle), casebundle.LintOptions{
+			Static:     fstest.MapFS{"images/found.webp": {Data: []byte("webp")}},
+			HTTPClient: server.Client(),
+		})
+		var lines []string
+		for _, diagnostic := range diagnostics {
+			if diagnostic.File == "lint/case.yaml" {
+				lines = append(lines, diagnostic.String())
+			}
+		}
+		require.Equal(t, []string{
+			`lint/case.yaml:4: error: image: image "` + server.URL +
+				`/missing.webp" not found: unexpected status [missing-image]`,
+			`lint/case.yaml:7: error: setting: 4096 characters exceed the limit of 2047 [too-long]`,
+			`lint/case.yaml:13: error: targets[0].image: image "/images/missing.webp" not found in the static files ` +
+				`[missing-image]`,
+			`lint/case.yaml:17: error: targets[0].clues[0].keywords: clue "punctuation" can't be discovered since ` +
+				`none of its keywords has letters or digits [unreachable-clue]`,
+			`lint/case.yaml:24: error: targets[1].clues[0].id: clue "rue-morgue-window-spring" is also declared in ` +
+				`rue-morgue/case.yaml [duplicate-id]`,
+			`lint/case.yaml:26: warning: targets[1].clues[0].keywords[1]: keyword "?!" is also used by target ` +
+				`"witness" [duplicate-keyword]`,
+			`lint/case.yaml:34: error: solution.clues[0]: solution clue "punctuation" can't be discovered ` +
+				`[unreachable-clue]`,
+		}, lines)
+	})
+
+	t.Run("reports unreachable clues", func(t *testing.T) {
+		t.Parallel()
+		manifest := `id: reach
+name: Reach
+author: Nobody
+image: /images/reach.webp
+play_time_minutes: 10
+difficulty: easy
+chapters:
+  - id: attic
+    name: The Attic
+    unlocked_by: [cellar-key]
+  - id: cellar
+    name: The Cellar
+    unlocked_by: [attic-key]
+targets:
+  - id: witness
+    name: The Witness
+    short_name: Witness
+    type: person
+    image: /images/reach.webp
+    clues:
+      - id: alibi
+        description: The alibi.
+        keywords: [alibi]
+      - id: confession
+        description: The confession.
+        keywords: [confession]
+        requires_evidence: attic-key
+  - id: attic
+    name: The Attic
+    short_name: Attic
+    type: scene
+    image: /images/reach.webp
+    chapter: attic
+    clues:
+      - id: attic-key
+        description: The key to the attic.
+        keywords: [attic]
+  - id: cellar
+    name: The Cellar
+    short_name: Cellar
+    type: scene
+    image: /images/reach.webp
+    chapter: cellar
+    clues:
+      - id: cellar-key
+        description: The key to the cellar.
+        keywords: [cellar]
+suspects:
+  - id: butler
+    name: The Butler
+solution:
+  culprit: butler
+  motive: Revenge.
+  motive_keywords: [revenge]
+  clues: [alibi, confession]
+  explanation: The butler did it.
+`
+		fsys := fstest.MapFS{"reach/case.yaml": {Data: []byte(manifest)}}
+		bundle, err := casebundle.Load(fsys, "reach")
+		require.NoError(t, err)
+		diagnostics := casebundle.Lint(ctx, []*casebundle.Bundle{bundle}, casebundle.LintOptions{
+			Static:     fstest.MapFS{"images/reach.webp": {Data: []byte("webp")}},
+			HTTPClient: nil,
+		})
+		var lines []string
+		for _, diagnostic := range diagnostics {
+			lines = append(lines, diagnostic.String())
+		}
+		require.Equal(t, []string{
+			`reach/case.yaml:27: error: targets[0].clues[1].requires_evidence: clue "confession" can't be discovered ` +
+				`since the evidence "attic-key" it requires can't be discovered [unreachable-clue]`,
+			`reach/case.yaml:35: error: targets[1].clues[0]: clue "attic-key" can't be discovered since chapter ` +
+				`"attic" is never unlocked [unreachable-clue]`,
+			`reach/case.yaml:45: error: targets[2].clues[0]: clue "cellar-key" can't be discovered since chapter ` +
+				`"cellar" is never unlocked [unreachable-clue]`,
+			`reach/case.yaml:55: error: solution.clues[1]: solution clue "confession" can't be discovered ` +
+				`[unreachable-clue]`,
+		}, lines)
+	})
+}
@@ -0,0 +1,150 @@
+// Package discovery detects the clues a player uncovers in the answers of the investigation targets.
+package discovery
+
+import (
+	"context"
+	"github.com/myrjola/sheerluck/internal/ai"
+	"github.com/myrjola/sheerluck/internal/errors"
+	"github.com/myrjola/sheerluck/internal/models"
+	"github.com/myrjola/sheerluck/internal/prompts"
+	"log/slog"
+	"strconv"
+	"strings"
+	"unicode"
+)
+
+// classificationTemplate is the prompt template used to confirm the keyword matches.
+const classificationTemplate = "clue-classification"
+
+// Detector finds the clues revealed by an answer.
+//
+// Clues are first matched by their keywords. If a classifier is configured, an additional LLM pass confirms which of
+// the matched clues the answer actually reveals, so that, e.g., a denial mentioning a keyword doesn't count.
+type Detector struct {
+	prompts    *prompts.Builder
+	classifier ai.Provider
+	logger     *slog.Logger
+}
+
+// NewDetector creates a Detector. Set classifier to nil to detect the clues by keyword matching alone.
+func NewDetector(promptBuilder *prompts.Builder, classifier ai.Provider, logger *slog.Logger) *Detector {
+	return &Detector{
+		prompts:    promptBuilder,
+		classifier: classifier,
+		logger:     logger.With("source", "discovery.Detector"),
+	}
+}
+
+// classificationData is the data for the clue classification prompt template.
+type classificationData struct {
+	Question string
+	Answer   string
+	Clues    []models.Clue
+}
+
+// Detect returns the candidate clues revealed by the answer to the question.
+func (d *Detector) Detect(
+	ctx context.Context,
+	candidates []models.Clue,
+	question string,
+	answer string,
+) ([]models.Clue, error) {
+	matched := MatchKeywords(candidates, answer)
+	if len(matched) == 0 || d.classifier == nil {
+		return matched, nil
+	}
+
+	prompt, err := d.prompts.Render(classificationTemplate, classificationData{
+		Question: question,
+		Answer:   answer,
+		Clues:    matched,
+	})
+	if err != nil {
+		return nil, errors.Wrap(err, "render classification prompt")
+	}
+	var completion *ai.Completion
+	messages := []ai.Message{{Role: ai.RoleUser, Content: prompt.System}}
+	if completion, err = d.classifier.Complete(ctx, messages); err != nil {
+		return nil, errors.Wrap(err, "classify clues")
+	}
+
+	var revealed []models.Clue
+	for _, index := range parseIndices(completion.Content, len(matched)) {
+		revealed = append(revealed, matched[index])
+	}
+	d.logger.LogAttrs(ctx, slog.LevelDebug, "classified clues",
+		slog.Int("matched", len(matched)),
+		slog.Int("revealed", len(revealed)),
+		slog.String("reply", completion.Content),
+		slog.String("prompt_version", prompt.Version),
+	)
+	return revealed, nil
+}
+
+// parseIndices parses the clue numbers from the classifier reply and ignores numbers outside [0, count).
+func parseIndices(reply string, count int) []int {
+	var (
+		indices []int
+		seen    = make(map[int]bool)
+	)
+	for _, field := range strings.FieldsFunc(reply, func(r rune) bool { return !unicode.IsDigit(r) }) {
+		index, err := strconv.Atoi(field)
+		if err != nil || index >= count || seen[index] {
+			continue
+		}
+		seen[index] = true
+		indices = append(indices, index)
+	}
+	return indices
+}
+
+// MatchKeywords returns the clues having a keyword in the text.
+//
+// The matching ignores case and punctuation, and a keyword matches words starting with it so that "victim" matches
+// "victims". Hyphenated keywords such as "last-seen" match the words separately, e.g., "last seen".
+func MatchKeywords(clues []models.Clue, text string) []models.Clue {
+	var matched []models.Clue
+	normalizedText := normalize(text)
+	for _, clue := range clues {
+		if containsKeyword(normalizedText, clue.Keywords) {
+			matched = append(matched, clue)
+		}
+	}
+	return matched
+}
+
+// ContainsKeyword reports whether the text has any of the keywords. The keywords are matched like in [MatchKeywords].
+func ContainsKeyword(text string, keywords []string) bool {
+	return containsKeyword(normalize(text), keywords)
+}
+
+// CountKeywords returns how many of the keywords the text has. The keywords are matched like in [MatchKeywords].
+func CountKeywords(text string, keywords []string) int {
+	normalizedText := normalize(text)
+	count := 0
+	for _, keyword := range keywords {
+		if containsKeyword(normalizedText, []string{keyword}) {
+			count++
+		}
+	}
+	return count
+}
+
+func containsKeyword(normalizedText string, keywords []string) bool {
+	for _, keyword := range keywords {
+		normalizedKeyword := normalize(keyword)
+		if normalizedKeyword != " " && strings.Contains(normalizedText, normalizedKeyword) {
+			return true
+		}
+	}
+	return false
+}
+
+// normalize lower cases the text and replaces everything but letters and digits with single spaces. The result
+// starts with a space so that words can be matched by their start.
+func normalize(text string) string {
+	words := strings.FieldsFunc(strings.ToLower(text), func(r rune) bool {
+		return !unicode.IsLetter(r) && !unicode.IsDigit(r)
+	})
+	return " " + strings.Join(words, " ")
+}
@@ -0,0 +1,130 @@
+package discovery_test
+
+import (
+	"context"
+	"github.com/myrjola/sheerluck/internal/ai"
+	"github.com/myrjola/sheerluck/internal/discovery"
+	"github.com/myrjola/sheerluck/internal/models"
+	"github.com/myrjola/sheerluck/internal/prompts"
+	"github.com/myrjola/sheerluck/internal/testhelpers"
+	"github.com/stretchr/testify/require"
+	"io"
+	"os"
+	"testing"
+)
+
+var (
+	belongingsClue = models.Clue{
+		ID:               "le-bon-victim-belongings",
+		Description:      "The victims' belongings were given to him as collateral for a debt.",
+		Keywords:         []string{"gold", "watch", "scissors"},
+		RequiresEvidence: nil,
+		PointOfInterest:  nil,
+	}
+	lastMeetingClue = models.Clue{
+		ID:               "le-bon-last-meeting-with-the-victim",
+		Description:      "He met the victims the day before the murder.",
+		Keywords:         []string{"last-seen", "loan"},
+		RequiresEvidence: nil,
+		PointOfInterest:  nil,
+	}
+	candidates = []models.Clue{belongingsClue, lastMeetingClue}
+)
+
+func clueIDs(clues []models.Clue) []string {
+	var ids []string
+	for _, clue := range clues {
+		ids = append(ids, clue.ID)
+	}
+	return ids
+}
+
+func TestMatchKeywords(t *testing.T) {
+	t.Parallel()
+	tests := []struct {
+		name string
+		text string
+		want []string
+	}{
+		{
+			name: "no keywords",
+			text: "I was at the bank all day.",
+			want: nil,
+		},
+		{
+			name: "ignores case and punctuation",
+			text: "The WATCH? Madame gave it to me.",
+			want: []string{"le-bon-victim-belongings"},
+		},
+		{
+			name: "matches word prefixes",
+			text: "They were loaning money from the bank.",
+			want: []string{"le-bon-last-meeting-with-the-victim"},
+		},
+		{
+			name: "does not match inside words",
+			text: "The marigold was in bloom.",
+			want: nil,
+		},
+		{
+			name: "matches hyphenated keywords as separate words",
+			text: "I last seen them on Tuesday. The gold was theirs.",
+			want: []string{"le-bon-victim-belongings", "le-bon-last-meeting-with-the-victim"},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			t.Parallel()
+			require.Equal(t, tt.want, clueIDs(discovery.MatchKeywords(candidates, tt.text)))
+		})
+	}
+}
+
+func TestDetector_Detect(t *testing.T) {
+	t.Parallel()
+	ctx := context.Background()
+	logger := testhelpers.NewLogger(io.Discard)
+	promptBuilder := prompts.NewBuilder(os.DirFS("../../ui/prompts"), nil)
+	answer := "The gold watch was a collateral. I last seen them when I delivered the loan."
+
+	t.Run("keywords only", func(t *testing.T) {
+		t.Parallel()
+		detector := discovery.NewDetector(promptBuilder, nil, logger)
+		clues, err := detector.Detect(ctx, candidates, "Where did you get the watch?", answer)
+		require.NoError(t, err)
+		require.Equal(t, []string{"le-bon-victim-belongings", "le-bon-last-meeting-with-the-victim"}, clueIDs(clues))
+	})
+
+	t.Run("classifier confirms matches", func(t *testing.T) {
+		t.Parallel()
+		classifier := ai.NewScriptedProvider("1, 7, 1")
+		detector := discovery.NewDetector(promptBuilder, classifier, logger)
+		clues, err := detector.Detect(ctx, candidates, "Where did you get the watch?", answer)
+		require.NoError(t, err)
+		require.Equal(t, []string{"le-bon-last-meeting-with-the-victim"}, clueIDs(clues))
+
+		requests := classifier.Requests()
+		require.Len(t, requests, 1)
+		require.Contains(t, requests[0][0].Content, "0. "+belongingsClue.Description)
+		require.Contains(t, requests[0][0].Content, "1. "+lastMeetingClue.Description)
+		require.Contains(t, requests[0][0].Content, answer)
+	})
+
+	t.Run("classifier rejects matches", func(t *testing.T) {
+		t.Parallel()
+		detector := discovery.NewDetector(promptBuilder, ai.NewScriptedProvider("none"), logger)
+		clues, err := detector.Detect(ctx, candidates, "Where did you get the watch?", answer)
+		require.NoError(t, err)
+		require.Empty(t, clues)
+	})
+
+	t.Run("classifier is skipped without matches", func(t *testing.T) {
+		t.Parallel()
+		classifier := ai.NewScriptedProvider("0")
+		detector := discovery.NewDetector(promptBuilder, classifier, logger)
+		clues, err := detector.Detect(ctx, candidates, "Who are you?", "A bank clerk.")
+		require.NoError(t, err)
+		require.Empty(t, clues)
+		require.Empty(t, classifier.Requests())
+	})
+}
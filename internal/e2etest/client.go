@@ -366,7 +366,48 @@ func (c *Client) SubmitForm(
 	ctx context.Context,
 	formURLPath string,
 	formActionURLPath string,
+) (*goquery.Document, error) {
+	return c.SubmitFormValues(ctx, formURLPath, formActionURLPath, neturl.Values{})
+}
+
+// SubmitFormValues submits a form at formUrlPath with action formActionUrlPath filled with formValues and returns the
+// response document.
+func (c *Client) SubmitFormValues(
+	ctx context.Context,
+	formURLPath string,
+	formActionURLPath string,
+	formValues neturl.Values,
 ) (*goquery.Document, error) {
+	var (
+		doc  *goquery.Document
+		resp *http.Response
+		err  error
+	)
+	if resp, err = c.PostFormValues(ctx, formURLPath, formActionURLPath, formValues); err != nil {
+		return nil, errors.Wrap(err, "post form values")
+	}
+	defer func() {
+		_ = resp.Body.Close()
+	}()
+	if http.StatusOK != resp.StatusCode {
+		return nil, errors.New("unexpected status code", slog.Int("status", resp.StatusCode))
+	}
+
+	// Parse the response
+	if doc, err = goquery.NewDocumentFromReader(resp.Body); err != nil {
+		return nil, errors.Wrap(err, "create document from reader")
+	}
+	return doc, nil
+}
+
+// PostFormValues submits a form at formUrlPath with action formActionUrlPath filled with formValues and returns the
+// response regardless of its status code. The caller must close the response body.
+func (c *Client) PostFormValues(
+	ctx context.Context,
+	formURLPath string,
+	formActionURLPath string,
+	formValues neturl.Values,
+) (*http.Response, error) {
 	var (
 		doc *goquery.Document
 		err error
@@ -383,7 +424,10 @@ func (c *Client) SubmitForm(
 
 	// Build form data
 	formData := neturl.Values{}
-	formData.Add("csrf_token", csrfToken)
+	for key, values := range formValues {
+		formData[key] = values
+	}
+	formData.Set("csrf_token", csrfToken)
 	data := strings.NewReader(formData.Encode())
 
 	// Submit the form
@@ -396,16 +440,5 @@ func (c *Client) SubmitForm(
 	if resp, err = c.client.Do(req); err != nil {
 		return nil, errors.Wrap(err, "do request")
 	}
-	defer func() {
-		_ = resp.Body.Close()
-	}()
-	if http.StatusOK != resp.StatusCode {
-		return nil, errors.New("unexpected status code", slog.Int("status", resp.StatusCode))
-	}
-
-	// Parse the response
-	if doc, err = goquery.NewDocumentFromReader(resp.Body); err != nil {
-		return nil, errors.Wrap(err, "create document from reader")
-	}
-	return doc, nil
+	return resp, nil
 }
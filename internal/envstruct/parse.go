@@ -4,6 +4,7 @@ import (
 	"github.com/myrjola/sheerluck/internal/errors"
 	"log/slog"
 	"reflect"
+	"strconv"
 )
 
 var (
@@ -17,6 +18,9 @@ var (
 // Fields in the struct v must be tagged with `env:"ENV_VAR"` where ENV_VAR is the name of the environment variable.
 // If no environment variable matching ENV_VAR is provided, the field must be tagged with default value
 // `envDefault:"value"` or else ErrEnvNotSet is returned.
+//
+// Supported field types are string, bool, int, and float64. Values that can't be parsed into the field type
+// result in ErrInvalidValue.
 func Populate(v any, lookupEnv func(string) (string, bool)) error {
 	ptrRef := reflect.ValueOf(v)
 	if ptrRef.Kind() != reflect.Ptr {
@@ -48,15 +52,6 @@ func Populate(v any, lookupEnv func(string) (string, bool)) error {
 				continue
 			}
 
-			if refField.Kind() != reflect.String {
-				errorList = append(errorList, errors.Wrap(ErrInvalidValue, "only strings are supported",
-					slog.String("envVarName", envVarName),
-					slog.String("fieldType", refField.Kind().String()),
-					slog.String("fieldName", refTypeField.Name),
-				))
-				continue
-			}
-
 			var (
 				val string
 				err error
@@ -66,7 +61,14 @@ func Populate(v any, lookupEnv func(string) (string, bool)) error {
 				continue
 			}
 
-			refField.Set(reflect.ValueOf(val))
+			if err = setField(refField, val); err != nil {
+				errorList = append(errorList, errors.Wrap(err, "set field",
+					slog.String("envVarName", envVarName),
+					slog.String("fieldType", refField.Kind().String()),
+					slog.String("fieldName", refTypeField.Name),
+				))
+				continue
+			}
 		}
 	}
 
@@ -78,6 +80,35 @@ func Populate(v any, lookupEnv func(string) (string, bool)) error {
 	return nil
 }
 
+// setField parses val according to the kind of field and sets it.
+func setField(field reflect.Value, val string) error {
+	switch field.Kind() { //nolint:exhaustive // only the listed kinds are supported.
+	case reflect.String:
+		field.SetString(val)
+	case reflect.Bool:
+		parsed, err := strconv.ParseBool(val)
+		if err != nil {
+			return errors.Wrap(ErrInvalidValue, "parse bool", slog.String("value", val))
+		}
+		field.SetBool(parsed)
+	case reflect.Int:
+		parsed, err := strconv.Atoi(val)
+		if err != nil {
+			return errors.Wrap(ErrInvalidValue, "parse int", slog.String("value", val))
+		}
+		field.SetInt(int64(parsed))
+	case reflect.Float64:
+		parsed, err := strconv.ParseFloat(val, 64)
+		if err != nil {
+			return errors.Wrap(ErrInvalidValue, "parse float", slog.String("value", val))
+		}
+		field.SetFloat(parsed)
+	default:
+		return errors.Wrap(ErrInvalidValue, "unsupported field type")
+	}
+	return nil
+}
+
 func envLookupWithFallback(
 	envVarName string, tag reflect.StructTag, lookupEnv func(string) (string, bool)) (string, error) {
 	envVarValue, ok := lookupEnv(envVarName)
@@ -100,12 +100,53 @@ func TestPopulate(t *testing.T) {
 			wantErr: nil,
 		},
 		{
-			name: "only accepts strings",
+			name: "parses numbers",
+			args: args{
+				v: &struct { //nolint:exhaustruct // populated later
+					Int   int     `env:"INT"`
+					Float float64 `env:"FLOAT" envDefault:"0.5"`
+				}{},
+				lookupEnv: func(s string) (string, bool) { return "42", s == "INT" },
+			},
+			want: &struct {
+				Int   int
+				Float float64
+			}{Int: 42, Float: 0.5},
+			wantErr: nil,
+		},
+		{
+			name: "parses booleans",
+			args: args{
+				v: &struct { //nolint:exhaustruct // populated later
+					Enabled  bool `env:"ENABLED"`
+					Disabled bool `env:"DISABLED" envDefault:"false"`
+				}{},
+				lookupEnv: func(s string) (string, bool) { return "true", s == "ENABLED" },
+			},
+			want: &struct {
+				Enabled  bool
+				Disabled bool
+			}{Enabled: true, Disabled: false},
+			wantErr: nil,
+		},
+		{
+			name: "rejects invalid number",
 			args: args{
 				v: &struct { //nolint:exhaustruct // populated later
 					EnvVar int `env:"ENV_VAR"`
 				}{},
-				lookupEnv: func(_ string) (string, bool) { return "", false },
+				lookupEnv: func(_ string) (string, bool) { return "forty-two", true },
+			},
+			want:    nil,
+			wantErr: envstruct.ErrInvalidValue,
+		},
+		{
+			name: "rejects unsupported types",
+			args: args{
+				v: &struct { //nolint:exhaustruct // populated later
+					EnvVar []string `env:"ENV_VAR"`
+				}{},
+				lookupEnv: func(_ string) (string, bool) { return "value", true },
 			},
 			want:    nil,
 			wantErr: envstruct.ErrInvalidValue,
@@ -0,0 +1,185 @@
+// Package guard screens the detective's questions before they reach the investigation targets.
+package guard
+
+import (
+	"context"
+	"github.com/myrjola/sheerluck/internal/ai"
+	"github.com/myrjola/sheerluck/internal/errors"
+	"github.com/myrjola/sheerluck/internal/models"
+	"github.com/myrjola/sheerluck/internal/prompts"
+	"log/slog"
+	"regexp"
+	"strings"
+)
+
+// classificationTemplate is the prompt template used to classify the questions the heuristics let through.
+const classificationTemplate = "question-classification"
+
+// Verdict classifies a question.
+type Verdict string
+
+const (
+	// VerdictInGame is a question the investigation target should answer.
+	VerdictInGame Verdict = "in-game"
+	// VerdictOffTopic is a question unrelated to the case, e.g., a request to write code. The investigation target
+	// deflects it in character.
+	VerdictOffTopic Verdict = "off-topic"
+	// VerdictInjection is an attempt to override the instructions of the investigation target, e.g., to make it
+	// reveal the solution. It's refused.
+	VerdictInjection Verdict = "injection"
+)
+
+// Classification is the verdict on a question.
+type Classification struct {
+	Verdict Verdict
+	// Reason names the heuristic or the classifier that reached the verdict.
+	Reason string
+}
+
+// heuristic flags questions matching pattern with verdict.
+type heuristic struct {
+	reason  string
+	verdict Verdict
+	pattern *regexp.Regexp
+}
+
+// heuristics are matched against the lower-cased question. They catch the most common attempts cheaply, so keep them
+// to phrases about the AI and the game. Detectives ask witnesses about their instructions and secrets, so the classifier
+// judges those questions in context.
+var heuristics = []heuristic{ //nolint:gochecknoglobals // compiled once
+	{
+		reason:  "ignore instructions",
+		verdict: VerdictInjection,
+		pattern: regexp.MustCompile(
+			`\b(ignore|disregard|forget|override|bypass)\b.{0,40}\b((all|previous|prior|above|earlier) ` +
+				`(instructions?|prompts?|rules)|your (instructions?|prompts?|programming|guidelines|rules)|` +
+				`system prompt|rules of the game)\b`,
+		),
+	},
+	{
+		reason:  "prompt extraction",
+		verdict: VerdictInjection,
+		pattern: regexp.MustCompile(`\b(system prompt|your prompts?|(initial|hidden|original) prompts?)\b`),
+	},
+	{
+		reason:  "role override",
+		verdict: VerdictInjection,
+		pattern: regexp.MustCompile(
+			`\b((you are now|act as|pretend to be) (an? )?(\w+ )?(ai|assistant|chatbot|narrator|game master)|` +
+				`developer mode|jailbreak|dan mode)\b`,
+		),
+	},
+	{
+		reason:  "fake system message",
+		verdict: VerdictInjection,
+		pattern: regexp.MustCompile(`(^|\n)\s*(system|assistant|admin(istrator)?)\s*:|\[/?(inst|system)\]|<\|`),
+	},
+	{
+		reason:  "solution request",
+		verdict: VerdictInjection,
+		pattern: regexp.MustCompile(`\bsolution to (the|this) (case|game|mystery)\b`),
+	},
+	{
+		reason:  "artificial intelligence",
+		verdict: VerdictOffTopic,
+		pattern: regexp.MustCompile(
+			`\b(language model|large language|llm|chatgpt|openai|anthropic|claude|gpt-?\d|artificial intelligence)\b`,
+		),
+	},
+	{
+		reason:  "writing task",
+		verdict: VerdictOffTopic,
+		pattern: regexp.MustCompile(
+			`\b(write|compose|generate) (me )?(an? |some )?(code|program|script|function|essay|poem|story|song|email)\b`,
+		),
+	},
+	{
+		reason:  "programming",
+		verdict: VerdictOffTopic,
+		pattern: regexp.MustCompile(`\b(python|javascript|typescript|golang|java|sql|html|css)\b`),
+	},
+}
+
+// MatchHeuristics classifies the question with the heuristics. Questions that no heuristic flags are in-game.
+func MatchHeuristics(question string) Classification {
+	lowerQuestion := strings.ToLower(question)
+	for _, h := range heuristics {
+		if h.pattern.MatchString(lowerQuestion) {
+			return Classification{Verdict: h.verdict, Reason: h.reason}
+		}
+	}
+	return Classification{Verdict: VerdictInGame, Reason: "heuristics"}
+}
+
+// Guard classifies the questions as in-game, off-topic, or injection attempts.
+//
+// The questions are first matched against heuristics. If a classifier is configured, an additional LLM pass
+// classifies the questions the heuristics didn't flag.
+type Guard struct {
+	prompts    *prompts.Builder
+	classifier ai.Provider
+	logger     *slog.Logger
+}
+
+// NewGuard creates a Guard. Set classifier to nil to classify the questions by the heuristics alone.
+func NewGuard(promptBuilder *prompts.Builder, classifier ai.Provider, logger *slog.Logger) *Guard {
+	return &Guard{
+		prompts:    promptBuilder,
+		classifier: classifier,
+		logger:     logger.With("source", "guard.Guard"),
+	}
+}
+
+// classificationData is the data for the question classification prompt template.
+type classificationData struct {
+	Investigation *models.Investigation
+	Question      string
+}
+
+// Classify classifies the question asked in the investigation. It waits for the classifier if one is configured, so
+// call it outside the deadlines of the requests.
+func (g *Guard) Classify(
+	ctx context.Context,
+	investigation *models.Investigation,
+	question string,
+) (Classification, error) {
+	classification := MatchHeuristics(question)
+	if classification.Verdict != VerdictInGame || g.classifier == nil {
+		return classification, nil
+	}
+
+	prompt, err := g.prompts.Render(classificationTemplate, classificationData{
+		Investigation: investigation,
+		Question:      question,
+	})
+	if err != nil {
+		return classification, errors.Wrap(err, "render classification prompt")
+	}
+	var completion *ai.Completion
+	messages := []ai.Message{{Role: ai.RoleUser, Content: prompt.System}}
+	if completion, err = g.classifier.Complete(ctx, messages); err != nil {
+		return classification, errors.Wrap(err, "classify question")
+	}
+
+	classification = Classification{Verdict: parseVerdict(completion.Content), Reason: "classifier"}
+	g.logger.LogAttrs(ctx, slog.LevelDebug, "classified question",
+		slog.String("verdict", string(classification.Verdict)),
+		slog.String("reply", completion.Content),
+		slog.String("prompt_version", prompt.Version),
+	)
+	return classification, nil
+}
+
+// parseVerdict parses the classifier reply. Replies the classifier didn't follow the instructions with are in-game,
+// because the character prompts guard against the injections as well.
+func parseVerdict(reply string) Verdict {
+	upperReply := strings.ToUpper(reply)
+	switch {
+	case strings.Contains(upperReply, "INJECTION"):
+		return VerdictInjection
+	case strings.Contains(upperReply, "OFF_TOPIC"), strings.Contains(upperReply, "OFF-TOPIC"):
+		return VerdictOffTopic
+	default:
+		return VerdictInGame
+	}
+}
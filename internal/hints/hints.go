@@ -0,0 +1,88 @@
+// Package hints gives the stuck player graduated hints pointing to the clues they haven't discovered yet.
+//
+// The hints pointing to a clue start vague, then name the investigation target to question, and finally the topic to
+// raise. Authors can write the hints in the case content. Dupin, played by the AI, writes the missing ones.
+package hints
+
+import (
+	"context"
+	"github.com/myrjola/sheerluck/internal/ai"
+	"github.com/myrjola/sheerluck/internal/errors"
+	"github.com/myrjola/sheerluck/internal/models"
+	"github.com/myrjola/sheerluck/internal/prompts"
+	"log/slog"
+	"strings"
+)
+
+// hintTemplate is the prompt template used to write the hints that haven't been authored.
+const hintTemplate = "hint"
+
+// Next chooses the clue to give the next hint about and the level of the hint given the hints taken so far.
+//
+// The hints continue with the first candidate whose hints haven't reached HintLevelTopic yet, so that the player gets
+// more specific hints about one clue before moving on to the next. ok is false if there are no candidates or every
+// candidate has been hinted at the topic level, since another hint would only repeat one the player has paid for.
+func Next(candidates []models.HintCandidate, taken []models.Hint) (models.HintCandidate, models.HintLevel, bool) {
+	levels := make(map[string]models.HintLevel)
+	for _, hint := range taken {
+		levels[hint.ClueID] = max(levels[hint.ClueID], hint.Level)
+	}
+	for _, candidate := range candidates {
+		if level := levels[candidate.Clue.ID]; level < models.HintLevelTopic {
+			return candidate, level + 1, true
+		}
+	}
+	return models.HintCandidate{}, 0, false //nolint:exhaustruct // no candidate
+}
+
+// Dupin writes the hints the authors haven't.
+type Dupin struct {
+	prompts  *prompts.Builder
+	provider ai.Provider
+	logger   *slog.Logger
+}
+
+// NewDupin creates a Dupin writing the missing hints with provider.
+func NewDupin(promptBuilder *prompts.Builder, provider ai.Provider, logger *slog.Logger) *Dupin {
+	return &Dupin{
+		prompts:  promptBuilder,
+		provider: provider,
+		logger:   logger.With("source", "hints.Dupin"),
+	}
+}
+
+// hintData is the data for the hint prompt template.
+type hintData struct {
+	Case      models.Case
+	Candidate models.HintCandidate
+	Level     models.HintLevel
+}
+
+// Hint returns the hint of the level about the candidate clue. The authored hint is preferred and the AI writes the
+// hint if there's none.
+func (d *Dupin) Hint(
+	ctx context.Context,
+	c models.Case,
+	candidate models.HintCandidate,
+	level models.HintLevel,
+) (string, error) {
+	if hint := candidate.AuthoredHint(level); hint != "" {
+		return hint, nil
+	}
+
+	prompt, err := d.prompts.RenderCase(c.ID, hintTemplate, hintData{Case: c, Candidate: candidate, Level: level})
+	if err != nil {
+		return "", errors.Wrap(err, "render hint prompt")
+	}
+	var completion *ai.Completion
+	messages := []ai.Message{{Role: ai.RoleUser, Content: prompt.System}}
+	if completion, err = d.provider.Complete(ctx, messages); err != nil {
+		return "", errors.Wrap(err, "write hint")
+	}
+	d.logger.LogAttrs(ctx, slog.LevelDebug, "wrote hint",
+		slog.String("clue_id", candidate.Clue.ID),
+		slog.Int("level", int(level)),
+		slog.String("prompt_version", prompt.Version),
+	)
+	return strings.TrimSpace(completion.Content), nil
+}
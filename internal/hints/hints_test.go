@@ -0,0 +1,149 @@
+package hints_test
+
+import (
+	"context"
+	"github.com/myrjola/sheerluck/internal/ai"
+	"github.com/myrjola/sheerluck/internal/hints"
+	"github.com/myrjola/sheerluck/internal/models"
+	"github.com/myrjola/sheerluck/internal/prompts"
+	"github.com/myrjola/sheerluck/internal/testhelpers"
+	"github.com/stretchr/testify/require"
+	"io"
+	"os"
+	"testing"
+	"time"
+)
+
+func newCandidate(targetName string, clueID string, authored ...string) models.HintCandidate {
+	return models.HintCandidate{
+		Target: models.InvestigationTarget{
+			ID:          targetName,
+			Name:        targetName,
+			ShortName:   targetName,
+			Type:        models.InvestigationTargetTypePerson,
+			ImagePath:   "",
+			Description: "",
+			Secret:      "",
+		},
+		Clue: models.Clue{
+			ID:               clueID,
+			Description:      "The description of " + clueID + ".",
+			Keywords:         nil,
+			RequiresEvidence: nil,
+			PointOfInterest:  nil,
+		},
+		Hints: authored,
+	}
+}
+
+func newHint(clueID string, level models.HintLevel) models.Hint {
+	return models.Hint{ID: 0, ClueID: clueID, Level: level, Text: "", Created: time.Time{}}
+}
+
+func TestNext(t *testing.T) {
+	t.Parallel()
+	candidates := []models.HintCandidate{newCandidate("Adolphe", "loan"), newCandidate("Adolphe", "watch")}
+	tests := []struct {
+		name       string
+		candidates []models.HintCandidate
+		taken      []models.Hint
+		wantClueID string
+		wantLevel  models.HintLevel
+		wantOK     bool
+	}{
+		{
+			name:       "no candidates",
+			candidates: nil,
+			taken:      nil,
+			wantClueID: "",
+			wantLevel:  0,
+			wantOK:     false,
+		},
+		{
+			name:       "first hint is vague",
+			candidates: candidates,
+			taken:      nil,
+			wantClueID: "loan",
+			wantLevel:  models.HintLevelVague,
+			wantOK:     true,
+		},
+		{
+			name:       "hints get more specific",
+			candidates: candidates,
+			taken:      []models.Hint{newHint("loan", models.HintLevelVague)},
+			wantClueID: "loan",
+			wantLevel:  models.HintLevelTarget,
+			wantOK:     true,
+		},
+		{
+			name:       "moves on after the topic",
+			candidates: candidates,
+			taken:      []models.Hint{newHint("loan", models.HintLevelTopic), newHint("gone", models.HintLevelVague)},
+			wantClueID: "watch",
+			wantLevel:  models.HintLevelVague,
+			wantOK:     true,
+		},
+		{
+			name:       "every topic hinted",
+			candidates: candidates,
+			taken:      []models.Hint{newHint("watch", models.HintLevelTopic), newHint("loan", models.HintLevelTopic)},
+			wantClueID: "",
+			wantLevel:  0,
+			wantOK:     false,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			t.Parallel()
+			candidate, level, ok := hints.Next(tt.candidates, tt.taken)
+			require.Equal(t, tt.wantOK, ok)
+			require.Equal(t, tt.wantClueID, candidate.Clue.ID)
+			require.Equal(t, tt.wantLevel, level)
+		})
+	}
+}
+
+func TestDupin_Hint(t *testing.T) {
+	t.Parallel()
+	ctx := context.Background()
+	logger := testhelpers.NewLogger(io.Discard)
+	promptBuilder := prompts.NewBuilder(os.DirFS("../../ui/prompts"), nil)
+	rueMorgue := models.Case{
+		ID:              "rue-morgue",
+		Name:            "The Murders in the Rue Morgue",
+		Author:          "Edgar Allan Poe",
+		ImagePath:       "",
+		Setting:         "",
+		Difficulty:      models.CaseDifficultyEasy,
+		PlayTimeMinutes: 0,
+		Blurb:           "",
+	}
+	candidate := newCandidate("Adolphe Le Bon", "loan", "Money matters.", "Ask the clerk.")
+
+	t.Run("authored hint", func(t *testing.T) {
+		t.Parallel()
+		provider := ai.NewScriptedProvider("A written hint.")
+		dupin := hints.NewDupin(promptBuilder, provider, logger)
+		hint, err := dupin.Hint(ctx, rueMorgue, candidate, models.HintLevelTarget)
+		require.NoError(t, err)
+		require.Equal(t, "Ask the clerk.", hint)
+		require.Empty(t, provider.Requests())
+	})
+
+	t.Run("written hint", func(t *testing.T) {
+		t.Parallel()
+		provider := ai.NewScriptedProvider(" Ask him about the loan. ")
+		dupin := hints.NewDupin(promptBuilder, provider, logger)
+		hint, err := dupin.Hint(ctx, rueMorgue, candidate, models.HintLevelTopic)
+		require.NoError(t, err)
+		require.Equal(t, "Ask him about the loan.", hint)
+
+		requests := provider.Requests()
+		require.Len(t, requests, 1)
+		prompt := requests[0][0].Content
+		require.Contains(t, prompt, "The Murders in the Rue Morgue")
+		require.Contains(t, prompt, "Fact: The description of loan.")
+		require.Contains(t, prompt, "Found by questioning Adolphe Le Bon.")
+		require.Contains(t, prompt, "which topic to raise")
+	})
+}
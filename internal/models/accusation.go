@@ -0,0 +1,51 @@
+package models
+
+import "time"
+
+// Suspect is a person who could have committed the crime of a case.
+type Suspect struct {
+	ID          string
+	Name        string
+	Description string
+}
+
+// CaseSolution is what really happened in a case. It must never be shown to the AI.
+type CaseSolution struct {
+	CaseID  string
+	Culprit Suspect
+	Motive  string
+	// MotiveKeywords are matched against the accused motive to grade it.
+	MotiveKeywords []string
+	// WrongMotiveKeywords are the keywords of the plausible but wrong motives that mustn't outnumber MotiveKeywords in
+	// the accused motive.
+	WrongMotiveKeywords []string
+	// Clues are the clues supporting the solution.
+	Clues []Clue
+	// Explanation tells the player what happened after they've made their accusation.
+	Explanation string
+}
+
+// Accusation is the player's solution to a case and its grading.
+type Accusation struct {
+	ID      int64
+	CaseID  string
+	Culprit Suspect
+	Motive  string
+	// Clues are the discovered clues the player presented to support the accusation.
+	Clues []AccusedClue
+	// Questions is the number of questions the player had asked when accusing.
+	Questions int
+	// Hints is the number of hints the player had taken when accusing.
+	Hints          int
+	CulpritCorrect bool
+	MotiveCorrect  bool
+	Score          int
+	Created        time.Time
+}
+
+// AccusedClue is a clue presented in an accusation.
+type AccusedClue struct {
+	Clue Clue
+	// Correct reports whether the clue supports the solution.
+	Correct bool
+}
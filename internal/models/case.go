@@ -0,0 +1,52 @@
+package models
+
+// CaseStatus is the player's progress in a case as shown in the case catalogue.
+type CaseStatus string
+
+const (
+	CaseStatusNotStarted CaseStatus = "not-started"
+	CaseStatusInProgress CaseStatus = "in-progress"
+	// CaseStatusSolved means the player has made an accusation.
+	CaseStatusSolved CaseStatus = "solved"
+)
+
+// CaseSummary is a case in the case catalogue.
+type CaseSummary struct {
+	Case   Case
+	Status CaseStatus
+}
+
+// CaseOverview is the player's progress in a case.
+type CaseOverview struct {
+	Case Case
+	// Targets are the investigation targets of the case with the player's progress in investigating them.
+	Targets []InvestigationTargetProgress
+	// DiscoveredClues are the clues the player has discovered on the active branches in the order of discovery.
+	DiscoveredClues []DiscoveredClue
+	// Hints are the hints the player has taken in the order they were given.
+	Hints []Hint
+}
+
+// InvestigationTargetProgress is the player's progress in investigating a target.
+type InvestigationTargetProgress struct {
+	// Target is the investigation target. Its Secret is left empty since the overview is shown to the player.
+	Target InvestigationTarget
+	// Questions is the number of answered questions on the active branch.
+	Questions int
+	// Clues is the number of clues the target can reveal.
+	Clues int
+	// DiscoveredClues is the number of the target's clues the player has discovered.
+	DiscoveredClues int
+	// Chapter is the chapter the target is introduced in or nil if the target is available from the start.
+	Chapter *Chapter
+	// Locked reports whether the player has yet to unlock the target's chapter. Only the image and the type of a
+	// locked target are set so that the overview doesn't spoil it.
+	Locked bool
+}
+
+// Chapter is an act of a case. Its investigation targets are locked until the player discovers the clues unlocking
+// it.
+type Chapter struct {
+	ID   string
+	Name string
+}
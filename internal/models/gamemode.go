@@ -0,0 +1,144 @@
+package models
+
+import (
+	"fmt"
+	"time"
+)
+
+// GameMode is how the player chose to play a case.
+type GameMode string
+
+const (
+	// GameModeRelaxed lets the player ask as many questions as they like.
+	GameModeRelaxed GameMode = "relaxed"
+	// GameModeCaseBudget limits the questions asked in the whole case to CaseQuestionBudget.
+	GameModeCaseBudget GameMode = "case-budget"
+	// GameModeTargetBudget limits the questions asked from every investigation target to TargetQuestionBudget.
+	GameModeTargetBudget GameMode = "target-budget"
+	// GameModeClock advances an in-game clock by ClockMinutesPerQuestion with every question. The targets with limited
+	// availability can't be investigated after their time has passed.
+	GameModeClock GameMode = "clock"
+)
+
+const (
+	// CaseQuestionBudget is the number of questions the player can ask in the case budget mode.
+	CaseQuestionBudget = 30
+	// TargetQuestionBudget is the number of questions the player can ask from a target in the target budget mode.
+	TargetQuestionBudget = 8
+	// ClockMinutesPerQuestion is how many in-game minutes a question takes in the clock mode.
+	ClockMinutesPerQuestion = 15
+	// clockStartHour is the in-game hour the investigation starts at in the clock mode.
+	clockStartHour = 8
+)
+
+// GameModes are the game modes in the order they're offered to the player.
+var GameModes = []GameMode{GameModeRelaxed, GameModeCaseBudget, GameModeTargetBudget, GameModeClock}
+
+// Name is the name of the game mode shown to the player.
+func (m GameMode) Name() string {
+	switch m {
+	case GameModeCaseBudget:
+		return "Limited questions"
+	case GameModeTargetBudget:
+		return "Limited questions per witness"
+	case GameModeClock:
+		return "Against the clock"
+	case GameModeRelaxed:
+		return "Relaxed"
+	}
+	return string(m)
+}
+
+// Description explains the rules of the game mode to the player.
+func (m GameMode) Description() string {
+	switch m {
+	case GameModeCaseBudget:
+		return fmt.Sprintf("You can ask %d questions in the whole case.", CaseQuestionBudget)
+	case GameModeTargetBudget:
+		return fmt.Sprintf("You can ask %d questions from every person, scene, item, and document.",
+			TargetQuestionBudget)
+	case GameModeClock:
+		return fmt.Sprintf("Every question takes %d minutes and some witnesses won't wait for you.",
+			ClockMinutesPerQuestion)
+	case GameModeRelaxed:
+		return "Ask as many questions as you like."
+	}
+	return ""
+}
+
+// GameState is the player's progress in a case measured against the rules of the game mode.
+type GameState struct {
+	Mode GameMode
+	// Chosen reports whether the player has chosen the game mode. Players who haven't chosen play the relaxed mode.
+	Chosen bool
+	// Questions is the number of questions the player has asked in the case on every branch of the history.
+	// Regenerated answers and edited questions count as well.
+	Questions int
+	// TargetQuestions is the number of questions asked by investigation target ID.
+	TargetQuestions map[string]int
+	// AvailableMinutes is how long the targets with limited availability can be investigated in the clock mode by
+	// investigation target ID.
+	AvailableMinutes map[string]int
+}
+
+// Started reports whether the player has started the case by choosing the game mode or asking a question. The game
+// mode can't be changed after that.
+func (s GameState) Started() bool {
+	return s.Chosen || s.Questions > 0
+}
+
+// CaseQuestionsLeft returns the number of questions left in the case budget mode.
+func (s GameState) CaseQuestionsLeft() int {
+	return max(CaseQuestionBudget-s.Questions, 0)
+}
+
+// TargetQuestionsLeft returns the number of questions left for the investigation target in the target budget mode.
+func (s GameState) TargetQuestionsLeft(targetID string) int {
+	return max(TargetQuestionBudget-s.TargetQuestions[targetID], 0)
+}
+
+// Elapsed returns the in-game time spent on the investigation in the clock mode.
+func (s GameState) Elapsed() time.Duration {
+	return time.Duration(s.Questions*ClockMinutesPerQuestion) * time.Minute
+}
+
+// Clock returns the in-game time of day in the clock mode, e.g., "09:45".
+func (s GameState) Clock() string {
+	return clockTime(s.Elapsed())
+}
+
+// AvailableUntil returns the in-game time of day after which the investigation target can't be investigated in the
+// clock mode or an empty string if the target is always available.
+func (s GameState) AvailableUntil(targetID string) string {
+	minutes, ok := s.AvailableMinutes[targetID]
+	if !ok {
+		return ""
+	}
+	return clockTime(time.Duration(minutes) * time.Minute)
+}
+
+// clockTime formats the in-game time of day the duration after the start of the investigation.
+func clockTime(elapsed time.Duration) string {
+	return time.Date(0, 1, 1, clockStartHour, 0, 0, 0, time.UTC).Add(elapsed).Format("15:04")
+}
+
+// Refusal explains why the player can't ask more questions from the investigation target or returns an empty string
+// if they can.
+func (s GameState) Refusal(targetID string) string {
+	switch s.Mode {
+	case GameModeCaseBudget:
+		if s.CaseQuestionsLeft() == 0 {
+			return "You have asked all your questions. Time to name the culprit."
+		}
+	case GameModeTargetBudget:
+		if s.TargetQuestionsLeft(targetID) == 0 {
+			return "You have asked all your questions here."
+		}
+	case GameModeClock:
+		if minutes, ok := s.AvailableMinutes[targetID]; ok && s.Elapsed() >= time.Duration(minutes)*time.Minute {
+			return fmt.Sprintf("It's past %s. You're too late.", s.AvailableUntil(targetID))
+		}
+	case GameModeRelaxed:
+	}
+	return ""
+}
@@ -0,0 +1,44 @@
+package models
+
+import "time"
+
+// HintLevel is how specific a hint is. The hints pointing to a clue get more specific every time the player asks for
+// one.
+type HintLevel int
+
+const (
+	// HintLevelVague nudges the player without naming the investigation target or the topic.
+	HintLevelVague HintLevel = 1
+	// HintLevelTarget names the investigation target to question.
+	HintLevelTarget HintLevel = 2
+	// HintLevelTopic names the topic to raise with the investigation target.
+	HintLevelTopic HintLevel = 3
+)
+
+// Hint is a hint Dupin gave the player.
+type Hint struct {
+	ID int64
+	// ClueID is the clue the hint points to. It's empty if the clue has been removed from the case.
+	ClueID  string
+	Level   HintLevel
+	Text    string
+	Created time.Time
+}
+
+// HintCandidate is an undiscovered clue the player can be given a hint about.
+type HintCandidate struct {
+	// Target is the investigation target revealing the clue. Its Secret is left empty.
+	Target InvestigationTarget
+	Clue   Clue
+	// Hints are the hints authored for the clue from the vaguest to the most specific. Dupin writes the levels
+	// without an authored hint himself.
+	Hints []string
+}
+
+// AuthoredHint returns the hint authored for the level or an empty string if there's none.
+func (c HintCandidate) AuthoredHint(level HintLevel) string {
+	if int(level) > len(c.Hints) {
+		return ""
+	}
+	return c.Hints[level-1]
+}
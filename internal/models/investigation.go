@@ -1,33 +1,173 @@
 package models
 
+import "time"
+
 // Investigation holds the state of an ongoing investigation.
 // It targets a person or a scene. It contains all the completions
 // (AI-chat questions and answers) and relevant clues.
 type Investigation struct {
-	Target      InvestigationTarget
+	Case   Case
+	Target InvestigationTarget
+	// Completions are the active branch of the history.
 	Completions []Completion
+	// Clues are the clues the target can reveal. They are meant for the AI and must not be shown to the player
+	// before they're discovered. The clues requiring evidence are revealed only when the player confronts the target
+	// with it.
+	Clues []Clue
+	// DiscoveredClues are the clues the player has discovered on the active branches of the whole case in the order of
+	// discovery.
+	DiscoveredClues []DiscoveredClue
+	// PointsOfInterest are the parts of a scene the player can examine. Persons have none.
+	PointsOfInterest []PointOfInterest
+	// Passages are the text of a document in reading order. Other targets have none.
+	Passages []Passage
+	// Locked reports whether the player has yet to unlock the chapter the target is introduced in.
+	Locked bool
+}
+
+// Passage is a paragraph of a document. A redacted passage is blacked out until the player discovers the clue
+// unredacting it.
+type Passage struct {
+	// Text is empty while the passage is redacted so that it's shown neither to the player nor to the AI.
+	Text     string
+	Redacted bool
+}
+
+// Case is a mystery consisting of investigation targets and clues.
+type Case struct {
+	ID        string
+	Name      string
+	Author    string
+	ImagePath string
+	// Setting is the background of the case shared with all investigation targets.
+	Setting string
+	// Difficulty is how hard the case is to solve.
+	Difficulty CaseDifficulty
+	// PlayTimeMinutes is the estimated time to solve the case.
+	PlayTimeMinutes int
+	// Blurb is the short introduction shown in the case catalogue.
+	Blurb string
+}
+
+// CaseDifficulty is the difficulty level of a case.
+type CaseDifficulty string
+
+const (
+	CaseDifficultyEasy   CaseDifficulty = "easy"
+	CaseDifficultyMedium CaseDifficulty = "medium"
+	CaseDifficultyHard   CaseDifficulty = "hard"
+)
+
+// Clue is a piece of information an investigation target can reveal.
+type Clue struct {
+	ID          string
+	Description string
+	Keywords    []string
+	// RequiresEvidence is the clue the player has to present to the investigation target before it reveals this clue.
+	// It's nil if the clue can be revealed by questioning alone. Only the ID and the description are populated.
+	RequiresEvidence *Clue
+	// PointOfInterest is the part of a scene the player has to examine before the scene reveals this clue. It's nil if
+	// the clue can be found anywhere in the scene.
+	PointOfInterest *PointOfInterest
+}
+
+// PointOfInterest is a part of a scene the player can examine.
+type PointOfInterest struct {
+	ID string
+	// Name completes "Examine ...", e.g., "the hearth".
+	Name        string
+	Description string
+}
+
+// DiscoveredClue is a clue the player has uncovered.
+type DiscoveredClue struct {
+	Clue Clue
+	// InvestigationTargetID is the target that revealed the clue.
+	InvestigationTargetID string
+	// CompletionID is the completion whose answer revealed the clue.
+	CompletionID int64
+	Discovered   time.Time
 }
 
 type InvestigationTargetType string
 
 const (
-	InvestigationTargetTypePerson InvestigationTargetType = "person"
-	InvestigationTargetTypeScene  InvestigationTargetType = "scene"
+	InvestigationTargetTypePerson   InvestigationTargetType = "person"
+	InvestigationTargetTypeScene    InvestigationTargetType = "scene"
+	InvestigationTargetTypeItem     InvestigationTargetType = "item"
+	InvestigationTargetTypeDocument InvestigationTargetType = "document"
 )
 
-// InvestigationTarget is a person or a scene that is being investigated.
+// InvestigationTarget is a person, a scene, an item, or a document that is being investigated.
 type InvestigationTarget struct {
 	ID        string
 	Name      string
 	ShortName string
 	Type      InvestigationTargetType
 	ImagePath string
+	// Description tells who the person is or what the scene, the item, or the document looks like.
+	Description string
+	// Secret is what the person is hiding or what is not obvious at first glance.
+	Secret string
 }
 
+// CompletionStatus is the lifecycle state of a completion as modelled in spec/completion.tla.
+type CompletionStatus string
+
+const (
+	// CompletionStatusCreated means the question is stored and the answer is ready to be streamed.
+	CompletionStatusCreated CompletionStatus = "created"
+	// CompletionStatusStreaming means the answer is being streamed from the AI provider.
+	CompletionStatusStreaming CompletionStatus = "streaming"
+	// CompletionStatusDone means the answer is complete and part of the history.
+	CompletionStatusDone CompletionStatus = "done"
+	// CompletionStatusError means the answer failed and the question can be asked again.
+	CompletionStatusError CompletionStatus = "error"
+)
+
 // Completion is a question and answer pair that is part of an investigation.
 type Completion struct {
-	ID       int64
+	ID int64
+	// ParentID points to the previous completion in the history. It's nil for the first completion.
+	ParentID *int64
 	Order    int64
+	Status   CompletionStatus
+	Question string
+	Answer   string
+	// Summary summarises the history up to and including this completion for the AI. It's usually empty.
+	Summary string
+	// Alternatives are the IDs of the done completions sharing the parent with this one, i.e., the regenerated
+	// answers and edited questions, in the order they were asked. It includes this completion if it's done.
+	// It's only populated for the completions of an Investigation.
+	Alternatives []int64
+	// Evidence is what the player presented with the question. It's nil for plain questions.
+	Evidence *Evidence
+	// Examined is the point of interest of a scene the player examined with the question or nil.
+	Examined *PointOfInterest
+}
+
+// Inquiry is a question the player puts to an investigation target together with what accompanies it.
+type Inquiry struct {
+	Question string
+	// Evidence is what the player presents to a person or nil.
+	Evidence *Evidence
+	// Examined is the point of interest of a scene the player examines or nil.
+	Examined *PointOfInterest
+}
+
+// Evidence is presented to a person to confront them with it. Either Clue or Statement is set.
+type Evidence struct {
+	// Clue is a clue the player has discovered.
+	Clue *Clue
+	// Statement is what another investigation target has told the player.
+	Statement *Statement
+}
+
+// Statement is an answer of an investigation target the player can present as evidence to another target.
+type Statement struct {
+	CompletionID int64
+	// Target is the investigation target that made the statement. Only the ID, name, and short name are populated.
+	Target   InvestigationTarget
 	Question string
 	Answer   string
 }
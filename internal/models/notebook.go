@@ -0,0 +1,61 @@
+package models
+
+import "time"
+
+// Notebook is the player's notebook for a case.
+type Notebook struct {
+	// Notes are the player's notes from the newest to the oldest.
+	Notes []Note
+	// PinnedCompletions are the questions and answers the player has pinned in the order of pinning.
+	PinnedCompletions []PinnedCompletion
+	// PinnedClues are the discovered clues the player has pinned in the order of pinning.
+	PinnedClues []PinnedClue
+}
+
+// HasPinnedCompletion reports whether the completion is pinned to the notebook.
+func (n Notebook) HasPinnedCompletion(completionID int64) bool {
+	for _, pinned := range n.PinnedCompletions {
+		if pinned.Completion.ID == completionID {
+			return true
+		}
+	}
+	return false
+}
+
+// HasPinnedClue reports whether the clue is pinned to the notebook.
+func (n Notebook) HasPinnedClue(clueID string) bool {
+	for _, pinned := range n.PinnedClues {
+		if pinned.Clue.ID == clueID {
+			return true
+		}
+	}
+	return false
+}
+
+// Note is a free-text note in the notebook.
+type Note struct {
+	ID   int64
+	Text string
+	// Tags are the investigation targets the note is about in alphabetical order. Their Description and Secret are
+	// left empty.
+	Tags    []InvestigationTarget
+	Created time.Time
+	Updated time.Time
+}
+
+// PinnedCompletion is a question and answer pinned to the notebook.
+type PinnedCompletion struct {
+	// Completion is the pinned completion. Only its ID, Question, and Answer are populated.
+	Completion Completion
+	// Target is the investigation target that answered. Its Description and Secret are left empty.
+	Target InvestigationTarget
+	Pinned time.Time
+}
+
+// PinnedClue is a discovered clue pinned to the notebook.
+type PinnedClue struct {
+	Clue Clue
+	// InvestigationTargetID is the target that revealed the clue.
+	InvestigationTargetID string
+	Pinned                time.Time
+}
@@ -0,0 +1,58 @@
+package models
+
+import "time"
+
+// Party is a group of players investigating a case together. The members see each other's interrogations and
+// discoveries while keeping their own history and score.
+type Party struct {
+	ID int64
+	// Case is the case the party investigates. Only the ID and the name are populated.
+	Case Case
+	// InviteToken is the secret of the invite link.
+	InviteToken string
+	// Members are the players of the party in the order of joining. The owner is the first member.
+	Members []PartyMember
+	// Completions are the questions the members have asked in the party on their active branches in the order of
+	// asking. Only the done ones are included.
+	Completions []PartyCompletion
+	// DiscoveredClues are the clues the members have discovered in the party in the order of first discovery.
+	DiscoveredClues []PartyClue
+	Created         time.Time
+}
+
+// PartyMember is a player in a party.
+type PartyMember struct {
+	DisplayName string
+	Owner       bool
+	// Self reports whether the member is the player viewing the party.
+	Self   bool
+	Joined time.Time
+}
+
+// PartyCompletion is a question a party member has asked and its answer.
+type PartyCompletion struct {
+	Member PartyMember
+	// Target is the investigation target that answered. Its Description and Secret are left empty.
+	Target InvestigationTarget
+	// Completion is the question and answer. Only its ID, Question, and Answer are populated.
+	Completion Completion
+}
+
+// PartyClue is a clue a party member has discovered.
+type PartyClue struct {
+	Clue   Clue
+	Member PartyMember
+	// InvestigationTargetID is the target that revealed the clue.
+	InvestigationTargetID string
+	Discovered            time.Time
+}
+
+// OwnedBySelf reports whether the player viewing the party owns it.
+func (p Party) OwnedBySelf() bool {
+	for _, member := range p.Members {
+		if member.Self {
+			return member.Owner
+		}
+	}
+	return false
+}
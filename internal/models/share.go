@@ -0,0 +1,16 @@
+package models
+
+import "time"
+
+// Share is a link sharing a read-only replay of the player's solved case with people without an account.
+type Share struct {
+	ID int64
+	// Nonce is signed into the link with the ID so that the link of a revoked share doesn't work for a new share that
+	// reuses the ID.
+	Nonce  string
+	CaseID string
+	UserID []byte
+	// BlurSpoilers hides the clues and the solution until the viewer reveals them.
+	BlurSpoilers bool
+	Created      time.Time
+}
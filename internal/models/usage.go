@@ -0,0 +1,22 @@
+package models
+
+// AIUsage is the AI usage within the quota periods.
+type AIUsage struct {
+	QuestionsLastMinute int
+	QuestionsLastDay    int
+	TokensLastDay       int
+}
+
+// UserAIUsage is the AI usage of a single user.
+type UserAIUsage struct {
+	UserID      []byte
+	DisplayName string
+	Usage       AIUsage
+}
+
+// AIUsageReport summarises the AI usage for admins.
+type AIUsageReport struct {
+	Global AIUsage
+	// Users lists the users with usage within the last day, heaviest token users first.
+	Users []UserAIUsage
+}
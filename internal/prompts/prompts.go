@@ -0,0 +1,149 @@
+// Package prompts turns case content into system prompts for the AI provider.
+//
+// The prompts are text templates that are read from a file system on every build so that authors can iterate on them
+// without recompiling or restarting the server. The file system contains:
+//
+//   - common.gotmpl with templates shared by all investigation target types,
+//   - one template per investigation target type named after the type, e.g., person.gotmpl and scene.gotmpl,
+//     executed with the models.Investigation of the player,
+//   - task-specific templates such as clue-classification.gotmpl rendered with Builder.Render.
+//
+// A case can override any of the templates with a template of the same name in the prompts directory of its case
+// bundle, e.g., rue-morgue/prompts/deflection.gotmpl. The overrides are resolved by Builder.Build and
+// Builder.RenderCase before the templates of the file system.
+//
+// Besides the text/template builtins, the templates can use join to join strings and withoutEvidence and
+// requiringEvidence to split the clues by whether the player has to present evidence to reveal them.
+package prompts
+
+import (
+	"bytes"
+	"crypto/sha256"
+	"encoding/hex"
+	"github.com/myrjola/sheerluck/internal/errors"
+	"github.com/myrjola/sheerluck/internal/models"
+	"io/fs"
+	"log/slog"
+	"path"
+	"strings"
+	"text/template"
+)
+
+const (
+	commonTemplate = "common.gotmpl"
+	// casePromptDir is the directory of the case bundle overriding the templates.
+	casePromptDir = "prompts"
+	// versionHashLength is the number of hex characters of the template hash included in the version.
+	versionHashLength = 12
+)
+
+// Prompt is a rendered system prompt.
+type Prompt struct {
+	// Version identifies the templates the prompt was rendered from, e.g., "person@3f2a9c0d1b7e". It changes whenever
+	// the templates change so that answers can be traced back to the prompt that produced them.
+	Version string
+	// System is the system prompt describing the investigation target to the AI.
+	System string
+}
+
+// Builder renders system prompts from the templates in its file system.
+type Builder struct {
+	fsys fs.FS
+	// caseFS contains the case bundles overriding the templates in their prompts directories.
+	caseFS fs.FS
+}
+
+// NewBuilder creates a Builder reading the templates from fsys and the case overrides from the case bundle
+// directories of caseFS. Set caseFS to nil to use the templates of fsys for every case.
+func NewBuilder(fsys fs.FS, caseFS fs.FS) *Builder {
+	return &Builder{fsys: fsys, caseFS: caseFS}
+}
+
+// Build renders the system prompt for the investigation target.
+func (b *Builder) Build(investigation *models.Investigation) (*Prompt, error) {
+	prompt, err := b.RenderCase(investigation.Case.ID, string(investigation.Target.Type), investigation)
+	if err != nil {
+		return nil, errors.Wrap(err, "render target prompt")
+	}
+	return prompt, nil
+}
+
+// Render executes the template name.gotmpl with data. Use Build for investigation target prompts and RenderCase for
+// the prompts the cases can override.
+func (b *Builder) Render(name string, data any) (*Prompt, error) {
+	return b.RenderCase("", name, data)
+}
+
+// RenderCase executes the template name.gotmpl with data preferring the templates overridden by the case.
+func (b *Builder) RenderCase(caseID string, name string, data any) (*Prompt, error) {
+	var (
+		err    error
+		tmpl   *template.Template
+		buf    bytes.Buffer
+		source []byte
+	)
+	targetTemplate := name + ".gotmpl"
+	hash := sha256.New()
+	tmpl = template.New("prompt").Funcs(template.FuncMap{
+		"join":              strings.Join,
+		"withoutEvidence":   withoutEvidence,
+		"requiringEvidence": requiringEvidence,
+	})
+	for _, fileName := range []string{commonTemplate, targetTemplate} {
+		if source, err = b.readTemplate(caseID, fileName); err != nil {
+			return nil, errors.Wrap(err, "read prompt template", slog.String("template", fileName))
+		}
+		_, _ = hash.Write(source) // Writing to a hash never fails.
+		if _, err = tmpl.New(fileName).Parse(string(source)); err != nil {
+			return nil, errors.Wrap(err, "parse prompt template", slog.String("template", fileName))
+		}
+	}
+	if err = tmpl.ExecuteTemplate(&buf, targetTemplate, data); err != nil {
+		return nil, errors.Wrap(err, "execute prompt template", slog.String("template", targetTemplate))
+	}
+
+	return &Prompt{
+		Version: name + "@" + hex.EncodeToString(hash.Sum(nil))[:versionHashLength],
+		System:  strings.TrimSpace(buf.String()),
+	}, nil
+}
+
+// readTemplate reads the template overridden by the case or the one in the file system if the case doesn't override it.
+func (b *Builder) readTemplate(caseID string, fileName string) ([]byte, error) {
+	if b.caseFS != nil && caseID != "" {
+		source, err := fs.ReadFile(b.caseFS, path.Join(caseID, casePromptDir, fileName))
+		if err == nil {
+			return source, nil
+		}
+		if !errors.Is(err, fs.ErrNotExist) {
+			return nil, errors.Wrap(err, "read case template", slog.String("case_id", caseID))
+		}
+	}
+	source, err := fs.ReadFile(b.fsys, fileName)
+	if err != nil {
+		return nil, errors.Wrap(err, "read global template")
+	}
+	return source, nil
+}
+
+// withoutEvidence returns the clues that can be revealed by questioning alone.
+func withoutEvidence(clues []models.Clue) []models.Clue {
+	var result []models.Clue
+	for _, clue := range clues {
+		if clue.RequiresEvidence == nil {
+			result = append(result, clue)
+		}
+	}
+	return result
+}
+
+// requiringEvidence returns the clues that are revealed only when the player presents evidence.
+func requiringEvidence(clues []models.Clue) []models.Clue {
+	var result []models.Clue
+	for _, clue := range clues {
+		if clue.RequiresEvidence != nil {
+			result = append(result, clue)
+		}
+	}
+	return result
+}
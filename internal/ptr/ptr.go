@@ -3,3 +3,11 @@ package ptr
 func Ref[T any](v T) *T {
 	return &v
 }
+
+// Deref returns the value p points to or fallback if p is nil.
+func Deref[T any](p *T, fallback T) T {
+	if p == nil {
+		return fallback
+	}
+	return *p
+}
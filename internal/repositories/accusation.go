@@ -0,0 +1,256 @@
+package repositories
+
+import (
+	"context"
+	"database/sql"
+	"github.com/myrjola/sheerluck/internal/errors"
+	"github.com/myrjola/sheerluck/internal/models"
+	"github.com/myrjola/sheerluck/internal/sqlite"
+	"log/slog"
+	"time"
+)
+
+// ErrAlreadyAccused is returned when the player has already made an accusation in the case.
+var ErrAlreadyAccused = errors.NewSentinel("already accused")
+
+type AccusationRepository struct {
+	database *sqlite.Database
+	logger   *slog.Logger
+}
+
+func NewAccusationRepository(dbs *sqlite.Database, logger *slog.Logger) *AccusationRepository {
+	return &AccusationRepository{
+		database: dbs,
+		logger:   logger.With("source", "AccusationRepository"),
+	}
+}
+
+// ListSuspects lists the suspects of the case in alphabetical order.
+func (r *AccusationRepository) ListSuspects(ctx context.Context, caseID string) ([]models.Suspect, error) {
+	var (
+		suspects []models.Suspect
+		err      error
+		rows     *sql.Rows
+	)
+	stmt := `SELECT id, name, description FROM suspects WHERE case_id = ? ORDER BY name`
+	if rows, err = r.database.ReadOnly.QueryContext(ctx, stmt, caseID); err != nil {
+		return nil, errors.Wrap(err, "query suspects")
+	}
+	defer func() {
+		if err = rows.Close(); err != nil {
+			err = errors.Wrap(err, "close rows")
+			r.logger.Error("could not close rows", errors.SlogError(err))
+		}
+	}()
+	for rows.Next() {
+		var suspect models.Suspect
+		if err = rows.Scan(&suspect.ID, &suspect.Name, &suspect.Description); err != nil {
+			return nil, errors.Wrap(err, "scan suspect")
+		}
+		suspects = append(suspects, suspect)
+	}
+	if err = rows.Err(); err != nil {
+		return nil, errors.Wrap(err, "rows error")
+	}
+	return suspects, nil
+}
+
+// GetSolution reads the solution of the case. sql.ErrNoRows is returned if the case has no solution.
+//
+// The solution must never end up in a prompt.
+func (r *AccusationRepository) GetSolution(ctx context.Context, caseID string) (*models.CaseSolution, error) {
+	var (
+		solution            models.CaseSolution
+		motiveKeywords      string
+		wrongMotiveKeywords string
+		err                 error
+		rows                *sql.Rows
+	)
+	stmt := `SELECT s.case_id,
+       su.id,
+       su.name,
+       su.description,
+       s.motive,
+       s.motive_keywords,
+       s.wrong_motive_keywords,
+       s.explanation
+FROM case_solutions s
+         JOIN suspects su ON su.id = s.culprit_id
+WHERE s.case_id = ?`
+	if err = r.database.ReadOnly.QueryRowContext(ctx, stmt, caseID).Scan(
+		&solution.CaseID,
+		&solution.Culprit.ID,
+		&solution.Culprit.Name,
+		&solution.Culprit.Description,
+		&solution.Motive,
+		&motiveKeywords,
+		&wrongMotiveKeywords,
+		&solution.Explanation,
+	); err != nil {
+		return nil, errors.Wrap(err, "read solution", slog.String("case_id", caseID))
+	}
+	solution.MotiveKeywords = splitKeywords(motiveKeywords)
+	solution.WrongMotiveKeywords = splitKeywords(wrongMotiveKeywords)
+
+	stmt = `SELECT c.id, c.description, c.keywords
+FROM case_solution_clues s
+         JOIN clues c ON c.id = s.clue_id
+WHERE s.case_id = ?
+ORDER BY c.id`
+	if rows, err = r.database.ReadOnly.QueryContext(ctx, stmt, caseID); err != nil {
+		return nil, errors.Wrap(err, "query solution clues")
+	}
+	defer func() {
+		if err = rows.Close(); err != nil {
+			err = errors.Wrap(err, "close rows")
+			r.logger.Error("could not close rows", errors.SlogError(err))
+		}
+	}()
+	for rows.Next() {
+		var (
+			clue     models.Clue
+			keywords string
+		)
+		if err = rows.Scan(&clue.ID, &clue.Description, &keywords); err != nil {
+			return nil, errors.Wrap(err, "scan solution clue")
+		}
+		clue.Keywords = splitKeywords(keywords)
+		solution.Clues = append(solution.Clues, clue)
+	}
+	if err = rows.Err(); err != nil {
+		return nil, errors.Wrap(err, "rows error")
+	}
+	return &solution, nil
+}
+
+// Create stores the graded accusation of the user and returns its ID. ErrAlreadyAccused is returned if the user has
+// already made an accusation in the case.
+func (r *AccusationRepository) Create(
+	ctx context.Context,
+	userID []byte,
+	accusation *models.Accusation,
+) (int64, error) {
+	var (
+		tx  *sql.Tx
+		err error
+	)
+	if tx, err = r.database.ReadWrite.BeginTx(ctx, nil); err != nil {
+		return 0, errors.Wrap(err, "begin transaction")
+	}
+	defer rollback(ctx, r.logger, tx)
+
+	var accused bool
+	stmt := `SELECT EXISTS (SELECT 1 FROM accusations WHERE user_id = ? AND case_id = ?)`
+	if err = tx.QueryRowContext(ctx, stmt, userID, accusation.CaseID).Scan(&accused); err != nil {
+		return 0, errors.Wrap(err, "query existing accusation")
+	}
+	if accused {
+		return 0, errors.Wrap(ErrAlreadyAccused, "validate accusation", slog.String("case_id", accusation.CaseID))
+	}
+
+	var accusationID int64
+	stmt = `INSERT INTO accusations (user_id, case_id, culprit_id, motive, culprit_correct, motive_correct, questions,
+                         hints, score)
+VALUES (@user_id, @case_id, @culprit_id, @motive, @culprit_correct, @motive_correct, @questions, @hints, @score)
+RETURNING id`
+	if err = tx.QueryRowContext(ctx, stmt,
+		sql.Named("user_id", userID),
+		sql.Named("case_id", accusation.CaseID),
+		sql.Named("culprit_id", accusation.Culprit.ID),
+		sql.Named("motive", accusation.Motive),
+		sql.Named("culprit_correct", accusation.CulpritCorrect),
+		sql.Named("motive_correct", accusation.MotiveCorrect),
+		sql.Named("questions", accusation.Questions),
+		sql.Named("hints", accusation.Hints),
+		sql.Named("score", accusation.Score),
+	).Scan(&accusationID); err != nil {
+		return 0, errors.Wrap(err, "insert accusation")
+	}
+
+	stmt = `INSERT INTO accusation_clues (accusation_id, clue_id, correct) VALUES (?, ?, ?)`
+	for _, clue := range accusation.Clues {
+		if _, err = tx.ExecContext(ctx, stmt, accusationID, clue.Clue.ID, clue.Correct); err != nil {
+			return 0, errors.Wrap(err, "insert accusation clue", slog.String("clue_id", clue.Clue.ID))
+		}
+	}
+
+	if err = tx.Commit(); err != nil {
+		return 0, errors.Wrap(err, "commit transaction")
+	}
+	return accusationID, nil
+}
+
+// Get reads the user's accusation in the case. sql.ErrNoRows is returned if the user hasn't made an accusation.
+func (r *AccusationRepository) Get(ctx context.Context, caseID string, userID []byte) (*models.Accusation, error) {
+	var (
+		accusation models.Accusation
+		created    string
+		err        error
+		rows       *sql.Rows
+	)
+	stmt := `SELECT a.id,
+       a.case_id,
+       s.id,
+       s.name,
+       s.description,
+       a.motive,
+       a.questions,
+       a.hints,
+       a.culprit_correct,
+       a.motive_correct,
+       a.score,
+       a.created
+FROM accusations a
+         JOIN suspects s ON s.id = a.culprit_id
+WHERE a.case_id = ?
+  AND a.user_id = ?`
+	if err = r.database.ReadOnly.QueryRowContext(ctx, stmt, caseID, userID).Scan(
+		&accusation.ID,
+		&accusation.CaseID,
+		&accusation.Culprit.ID,
+		&accusation.Culprit.Name,
+		&accusation.Culprit.Description,
+		&accusation.Motive,
+		&accusation.Questions,
+		&accusation.Hints,
+		&accusation.CulpritCorrect,
+		&accusation.MotiveCorrect,
+		&accusation.Score,
+		&created,
+	); err != nil {
+		return nil, errors.Wrap(err, "read accusation", slog.String("case_id", caseID))
+	}
+	if accusation.Created, err = time.Parse(time.RFC3339Nano, created); err != nil {
+		return nil, errors.Wrap(err, "parse accusation time", slog.String("created", created))
+	}
+
+	stmt = `SELECT c.id, c.description, c.keywords, a.correct
+FROM accusation_clues a
+         JOIN clues c ON c.id = a.clue_id
+WHERE a.accusation_id = ?
+ORDER BY c.id`
+	if rows, err = r.database.ReadOnly.QueryContext(ctx, stmt, accusation.ID); err != nil {
+		return nil, errors.Wrap(err, "query accusation clues")
+	}
+	defer func() {
+		if err = rows.Close(); err != nil {
+			err = errors.Wrap(err, "close rows")
+			r.logger.Error("could not close rows", errors.SlogError(err))
+		}
+	}()
+	for rows.Next() {
+		var (
+			clue     models.AccusedClue
+			keywords string
+		)
+		if err = rows.Scan(&clue.Clue.ID, &clue.Clue.Description, &keywords, &clue.Correct); err != nil {
+			return nil, errors.Wrap(err, "scan accusation clue")
+		}
+		clue.Clue.Keywords = splitKeywords(keywords)
+		accusation.Clues = append(accusation.Clues, clue)
+	}
+	if err = rows.Err(); err != nil {
+		return nil, errors.Wrap(err, "rows error")
+	}
+	return &accusation, nil
+}
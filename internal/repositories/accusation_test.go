@@ -0,0 +1,73 @@
+package repositories_test
+
+import (
+	"context"
+	"database/sql"
+	"github.com/myrjola/sheerluck/internal/models"
+	"github.com/myrjola/sheerluck/internal/repositories"
+	"github.com/myrjola/sheerluck/internal/testhelpers"
+	"github.com/stretchr/testify/require"
+	"io"
+	"testing"
+	"time"
+)
+
+func TestAccusationRepository(t *testing.T) {
+	t.Parallel()
+	ctx := context.Background()
+	logger := testhelpers.NewLogger(io.Discard)
+	dbs := newTestDB(t, logger)
+	repo := repositories.NewAccusationRepository(dbs, logger)
+	userID := []byte{1}
+
+	suspects, err := repo.ListSuspects(ctx, "rue-morgue")
+	require.NoError(t, err)
+	require.Len(t, suspects, 4)
+
+	solution, err := repo.GetSolution(ctx, "rue-morgue")
+	require.NoError(t, err)
+	require.Equal(t, "rue-morgue-ourang-outang", solution.Culprit.ID)
+	require.NotEmpty(t, solution.MotiveKeywords)
+	require.Contains(t, solution.WrongMotiveKeywords, "greed")
+	require.Len(t, solution.Clues, 3)
+	_, err = repo.GetSolution(ctx, "nonexistent")
+	require.ErrorIs(t, err, sql.ErrNoRows)
+
+	_, err = repo.Get(ctx, "rue-morgue", userID)
+	require.ErrorIs(t, err, sql.ErrNoRows)
+
+	accusation := &models.Accusation{
+		ID:      0,
+		CaseID:  "rue-morgue",
+		Culprit: suspects[0],
+		Motive:  "Greed.",
+		Clues: []models.AccusedClue{
+			{Clue: solution.Clues[0], Correct: true},
+		},
+		Questions:      3,
+		Hints:          2,
+		CulpritCorrect: false,
+		MotiveCorrect:  false,
+		Score:          10,
+		Created:        time.Time{},
+	}
+	id, err := repo.Create(ctx, userID, accusation)
+	require.NoError(t, err)
+	_, err = repo.Create(ctx, userID, accusation)
+	require.ErrorIs(t, err, repositories.ErrAlreadyAccused)
+
+	got, err := repo.Get(ctx, "rue-morgue", userID)
+	require.NoError(t, err)
+	require.Equal(t, id, got.ID)
+	require.Equal(t, suspects[0], got.Culprit)
+	require.Equal(t, "Greed.", got.Motive)
+	require.Equal(t, accusation.Clues, got.Clues)
+	require.Equal(t, 3, got.Questions)
+	require.Equal(t, 2, got.Hints)
+	require.Equal(t, 10, got.Score)
+	require.WithinDuration(t, time.Now(), got.Created, time.Minute)
+
+	cases, err := repositories.NewCaseRepository(dbs, logger).List(ctx, userID)
+	require.NoError(t, err)
+	require.Equal(t, models.CaseStatusSolved, cases[0].Status)
+}
@@ -0,0 +1,225 @@
+package repositories
+
+import (
+	"context"
+	"database/sql"
+	"github.com/myrjola/sheerluck/internal/errors"
+	"github.com/myrjola/sheerluck/internal/models"
+	"github.com/myrjola/sheerluck/internal/sqlite"
+	"log/slog"
+)
+
+type CaseRepository struct {
+	database *sqlite.Database
+	logger   *slog.Logger
+}
+
+func NewCaseRepository(dbs *sqlite.Database, logger *slog.Logger) *CaseRepository {
+	return &CaseRepository{
+		database: dbs,
+		logger:   logger.With("source", "CaseRepository"),
+	}
+}
+
+// Get reads the overview of the user's progress in the case. sql.ErrNoRows is returned if the case doesn't exist.
+func (r *CaseRepository) Get(ctx context.Context, caseID string, userID []byte) (*models.CaseOverview, error) {
+	var (
+		overview models.CaseOverview
+		err      error
+	)
+
+	stmt := `SELECT id, name, author, image_path, setting, difficulty, play_time_minutes, blurb
+FROM cases
+WHERE id = ?`
+	if err = r.database.ReadOnly.QueryRowContext(ctx, stmt, caseID).Scan(
+		&overview.Case.ID,
+		&overview.Case.Name,
+		&overview.Case.Author,
+		&overview.Case.ImagePath,
+		&overview.Case.Setting,
+		&overview.Case.Difficulty,
+		&overview.Case.PlayTimeMinutes,
+		&overview.Case.Blurb,
+	); err != nil {
+		return nil, errors.Wrap(err, "read case", slog.String("case_id", caseID))
+	}
+
+	if overview.Targets, err = r.listTargetProgress(ctx, caseID, userID); err != nil {
+		return nil, errors.Wrap(err, "list investigation target progress")
+	}
+	if overview.DiscoveredClues, err = listDiscoveredClues(
+		ctx, r.database.ReadOnly, r.logger, caseID, userID,
+	); err != nil {
+		return nil, errors.Wrap(err, "list discovered clues")
+	}
+	if overview.Hints, err = listHints(ctx, r.database.ReadOnly, r.logger, caseID, userID); err != nil {
+		return nil, errors.Wrap(err, "list hints")
+	}
+
+	return &overview, nil
+}
+
+// List lists the cases of the catalogue with the user's status in them. The user ID is nil for anonymous visitors,
+// for whom every case is not started.
+func (r *CaseRepository) List(ctx context.Context, userID []byte) ([]models.CaseSummary, error) {
+	var (
+		cases []models.CaseSummary
+		err   error
+		rows  *sql.Rows
+	)
+	stmt := `SELECT c.id,
+       c.name,
+       c.author,
+       c.image_path,
+       c.setting,
+       c.difficulty,
+       c.play_time_minutes,
+       c.blurb,
+       CASE
+           WHEN EXISTS (SELECT 1 FROM accusations a WHERE a.case_id = c.id AND a.user_id = @user_id) THEN 'solved'
+           WHEN EXISTS (SELECT 1
+                        FROM completions co
+                                 JOIN investigation_targets t ON t.id = co.investigation_target_id
+                        WHERE t.case_id = c.id
+                          AND co.user_id = @user_id) THEN 'in-progress'
+           ELSE 'not-started' END AS status
+FROM cases c
+ORDER BY c.name`
+	if rows, err = r.database.ReadOnly.QueryContext(ctx, stmt, sql.Named("user_id", userID)); err != nil {
+		return nil, errors.Wrap(err, "query cases")
+	}
+	defer func() {
+		if err = rows.Close(); err != nil {
+			err = errors.Wrap(err, "close rows")
+			r.logger.Error("could not close rows", errors.SlogError(err))
+		}
+	}()
+	for rows.Next() {
+		var summary models.CaseSummary
+		if err = rows.Scan(
+			&summary.Case.ID,
+			&summary.Case.Name,
+			&summary.Case.Author,
+			&summary.Case.ImagePath,
+			&summary.Case.Setting,
+			&summary.Case.Difficulty,
+			&summary.Case.PlayTimeMinutes,
+			&summary.Case.Blurb,
+			&summary.Status,
+		); err != nil {
+			return nil, errors.Wrap(err, "scan case")
+		}
+		cases = append(cases, summary)
+	}
+	if err = rows.Err(); err != nil {
+		return nil, errors.Wrap(err, "rows error")
+	}
+	return cases, nil
+}
+
+func (r *CaseRepository) listTargetProgress(
+	ctx context.Context,
+	caseID string,
+	userID []byte,
+) ([]models.InvestigationTargetProgress, error) {
+	var (
+		targets []models.InvestigationTargetProgress
+		err     error
+		rows    *sql.Rows
+	)
+	// The targets available from the start first, then the chapters in order. Within a chapter, persons first, then
+	// scenes, items and documents.
+	stmt := `SELECT t.id,
+       t.name,
+       t.short_name,
+       t.type,
+       t.image_path,
+       t.description,
+       ch.id,
+       ch.name,
+       EXISTS (SELECT 1 FROM chapter_prerequisites p WHERE p.chapter_id = t.chapter_id)
+           AND NOT EXISTS (SELECT 1
+                           FROM unlocked_chapters u
+                           WHERE u.chapter_id = t.chapter_id
+                             AND u.user_id = @user_id)                      AS locked,
+       (SELECT COUNT(*)
+        FROM completions c
+        WHERE c.investigation_target_id = t.id
+          AND c.user_id = @user_id
+          AND c.active = 1
+          AND c.status = 'done')                                           AS questions,
+       (SELECT COUNT(*) FROM clues cl WHERE cl.investigation_target_id = t.id) AS clues,
+       (SELECT COUNT(DISTINCT d.clue_id)
+        FROM discovered_clues d
+                 JOIN clues cl ON cl.id = d.clue_id
+                 JOIN completions c ON c.id = d.completion_id
+        WHERE cl.investigation_target_id = t.id
+          AND d.user_id = @user_id
+          AND c.active = 1)                                                AS discovered_clues
+FROM investigation_targets t
+         LEFT JOIN chapters ch ON ch.id = t.chapter_id
+WHERE t.case_id = @case_id
+ORDER BY COALESCE(ch.position + 1, 0),
+         CASE t.type WHEN 'person' THEN 0 WHEN 'scene' THEN 1 WHEN 'item' THEN 2 ELSE 3 END,
+         t.name`
+	if rows, err = r.database.ReadOnly.QueryContext(ctx, stmt,
+		sql.Named("case_id", caseID),
+		sql.Named("user_id", userID),
+	); err != nil {
+		return nil, errors.Wrap(err, "query investigation targets")
+	}
+	defer func() {
+		if err = rows.Close(); err != nil {
+			err = errors.Wrap(err, "close rows")
+			r.logger.Error("could not close rows", errors.SlogError(err))
+		}
+	}()
+	for rows.Next() {
+		var (
+			target  models.InvestigationTargetProgress
+			chapter chapterColumns
+		)
+		if err = rows.Scan(
+			&target.Target.ID,
+			&target.Target.Name,
+			&target.Target.ShortName,
+			&target.Target.Type,
+			&target.Target.ImagePath,
+			&target.Target.Description,
+			&chapter.id,
+			&chapter.name,
+			&target.Locked,
+			&target.Questions,
+			&target.Clues,
+			&target.DiscoveredClues,
+		); err != nil {
+			return nil, errors.Wrap(err, "scan investigation target")
+		}
+		target.Chapter = chapter.chapter()
+		if target.Locked {
+			target.Target.ID = ""
+			target.Target.Name = ""
+			target.Target.ShortName = ""
+			target.Target.Description = ""
+		}
+		targets = append(targets, target)
+	}
+	if err = rows.Err(); err != nil {
+		return nil, errors.Wrap(err, "rows error")
+	}
+	return targets, nil
+}
+
+// chapterColumns are the nullable columns of the chapter joined to an investigation target.
+type chapterColumns struct {
+	id   sql.NullString
+	name sql.NullString
+}
+
+// chapter returns the joined chapter or nil if there's none.
+func (c chapterColumns) chapter() *models.Chapter {
+	if !c.id.Valid {
+		return nil
+	}
+	return &models.Chapter{ID: c.id.String, Name: c.name.String}
+}
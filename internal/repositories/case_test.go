@@ -0,0 +1,133 @@
+package repositories_test
+
+import (
+	"context"
+	"database/sql"
+	"github.com/myrjola/sheerluck/internal/models"
+	"github.com/myrjola/sheerluck/internal/repositories"
+	"github.com/myrjola/sheerluck/internal/testhelpers"
+	"github.com/stretchr/testify/require"
+	"io"
+	"testing"
+)
+
+func TestCaseRepository_Get(t *testing.T) {
+	t.Parallel()
+	ctx := context.Background()
+	logger := testhelpers.NewLogger(io.Discard)
+	dbs := newTestDB(t, logger)
+	repo := repositories.NewCaseRepository(dbs, logger)
+	investigations := repositories.NewInvestigationRepository(dbs, logger)
+	require.NoError(t, investigations.DiscoverClues(ctx, 2, []byte{1}, []string{"le-bon-victim-belongings"}))
+
+	overview, err := repo.Get(ctx, "rue-morgue", []byte{1})
+	require.NoError(t, err)
+	require.Equal(t, "The Murders in the Rue Morgue", overview.Case.Name)
+	require.Equal(t, []models.InvestigationTargetProgress{
+		{
+			Target: models.InvestigationTarget{
+				ID:          "le-bon",
+				Name:        "Adolphe Le Bon",
+				ShortName:   "Adolphe",
+				Type:        models.InvestigationTargetTypePerson,
+				ImagePath:   "https://myrjola.twic.pics/sheerluck/adolphe_le-bon.webp",
+				Description: "A timid bank clerk.",
+				Secret:      "",
+			},
+			Questions:       3,
+			Clues:           3,
+			DiscoveredClues: 1,
+			Chapter:         nil,
+			Locked:          false,
+		},
+		{
+			Target: models.InvestigationTarget{
+				ID:          "rue-morgue",
+				Name:        "Rue Morgue Murder Scene",
+				ShortName:   "Rue Morgue",
+				Type:        models.InvestigationTargetTypeScene,
+				ImagePath:   "https://myrjola.twic.pics/sheerluck/rue-morgue.webp",
+				Description: "A ransacked chamber.",
+				Secret:      "",
+			},
+			Questions:       0,
+			Clues:           3,
+			DiscoveredClues: 0,
+			Chapter:         nil,
+			Locked:          false,
+		},
+		{
+			Target: models.InvestigationTarget{
+				ID:          "gazette-des-tribunaux",
+				Name:        "The Gazette des Tribunaux",
+				ShortName:   "Gazette",
+				Type:        models.InvestigationTargetTypeDocument,
+				ImagePath:   "https://myrjola.twic.pics/sheerluck/rue-morgue.webp",
+				Description: "The evening edition reporting on the murders and the depositions of the witnesses.",
+				Secret:      "",
+			},
+			Questions:       0,
+			Clues:           1,
+			DiscoveredClues: 0,
+			Chapter:         nil,
+			Locked:          false,
+		},
+		{
+			Target: models.InvestigationTarget{
+				ID:          "",
+				Name:        "",
+				ShortName:   "",
+				Type:        models.InvestigationTargetTypeItem,
+				ImagePath:   "https://myrjola.twic.pics/sheerluck/rue-morgue.webp",
+				Description: "",
+				Secret:      "",
+			},
+			Questions:       0,
+			Clues:           1,
+			DiscoveredClues: 0,
+			Chapter:         &models.Chapter{ID: "rue-morgue-seized-belongings", Name: "The Seized Belongings"},
+			Locked:          true,
+		},
+	}, overview.Targets, "persons come first, then scenes, items and documents, then the chapters")
+	require.Len(t, overview.DiscoveredClues, 1)
+	require.Equal(t, "le-bon-victim-belongings", overview.DiscoveredClues[0].Clue.ID)
+
+	chapterIDs, err := investigations.UnlockChapters(ctx, 2, []byte{1})
+	require.NoError(t, err)
+	require.Equal(t, []string{"rue-morgue-seized-belongings"}, chapterIDs)
+	overview, err = repo.Get(ctx, "rue-morgue", []byte{1})
+	require.NoError(t, err)
+	require.False(t, overview.Targets[3].Locked)
+	require.Equal(t, "Madame L'Espanaye's Gold Watch", overview.Targets[3].Target.Name)
+
+	overview, err = repo.Get(ctx, "rue-morgue", []byte{2})
+	require.NoError(t, err)
+	require.Equal(t, 0, overview.Targets[0].Questions)
+	require.Equal(t, 1, overview.Targets[1].Questions, "failed completions are not counted")
+	require.True(t, overview.Targets[3].Locked, "chapters are unlocked per player")
+	require.Empty(t, overview.DiscoveredClues)
+
+	_, err = repo.Get(ctx, "nonexistent", []byte{1})
+	require.ErrorIs(t, err, sql.ErrNoRows)
+}
+
+func TestCaseRepository_List(t *testing.T) {
+	t.Parallel()
+	ctx := context.Background()
+	logger := testhelpers.NewLogger(io.Discard)
+	repo := repositories.NewCaseRepository(newTestDB(t, logger), logger)
+
+	cases, err := repo.List(ctx, []byte{1})
+	require.NoError(t, err)
+	require.Len(t, cases, 1)
+	require.Equal(t, "rue-morgue", cases[0].Case.ID)
+	require.Equal(t, models.CaseDifficultyEasy, cases[0].Case.Difficulty)
+	require.Positive(t, cases[0].Case.PlayTimeMinutes)
+	require.NotEmpty(t, cases[0].Case.Blurb)
+	require.Equal(t, models.CaseStatusInProgress, cases[0].Status)
+
+	cases, err = repo.List(ctx, nil)
+	require.NoError(t, err)
+	require.Len(t, cases, 1)
+	require.Equal(t, models.CaseStatusNotStarted, cases[0].Status)
+}
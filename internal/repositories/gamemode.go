@@ -0,0 +1,181 @@
+package repositories
+
+import (
+	"context"
+	"database/sql"
+	"github.com/myrjola/sheerluck/internal/errors"
+	"github.com/myrjola/sheerluck/internal/models"
+	"github.com/myrjola/sheerluck/internal/sqlite"
+	"log/slog"
+)
+
+var (
+	// ErrCaseStarted is returned when the player chooses the game mode of a case they have already started.
+	ErrCaseStarted = errors.NewSentinel("case already started")
+	// ErrGameOver is returned when the rules of the game mode refuse the player's question.
+	ErrGameOver = errors.NewSentinel("game mode refuses the question")
+)
+
+// querier is implemented by both *sql.DB and *sql.Tx.
+type querier interface {
+	queryRower
+	QueryContext(ctx context.Context, query string, args ...any) (*sql.Rows, error)
+}
+
+type GameModeRepository struct {
+	database *sqlite.Database
+	logger   *slog.Logger
+}
+
+func NewGameModeRepository(dbs *sqlite.Database, logger *slog.Logger) *GameModeRepository {
+	return &GameModeRepository{
+		database: dbs,
+		logger:   logger.With("source", "GameModeRepository"),
+	}
+}
+
+// Choose starts the case for the user in the game mode. sql.ErrNoRows is returned if the case doesn't exist and
+// ErrCaseStarted if the user has already chosen a game mode or asked a question in the case.
+func (r *GameModeRepository) Choose(ctx context.Context, caseID string, userID []byte, mode models.GameMode) error {
+	var (
+		tx      *sql.Tx
+		result  sql.Result
+		started bool
+		err     error
+	)
+	if tx, err = r.database.ReadWrite.BeginTx(ctx, nil); err != nil {
+		return errors.Wrap(err, "begin transaction")
+	}
+	defer rollback(ctx, r.logger, tx)
+
+	stmt := `SELECT EXISTS (SELECT 1 FROM game_modes WHERE case_id = @case_id AND user_id = @user_id)
+           OR EXISTS (SELECT 1
+                      FROM completions c
+                               JOIN investigation_targets t ON t.id = c.investigation_target_id
+                      WHERE t.case_id = @case_id
+                        AND c.user_id = @user_id)`
+	if err = tx.QueryRowContext(ctx, stmt,
+		sql.Named("case_id", caseID),
+		sql.Named("user_id", userID),
+	).Scan(&started); err != nil {
+		return errors.Wrap(err, "query started")
+	}
+	if started {
+		return errors.Wrap(ErrCaseStarted, "validate game mode", slog.String("case_id", caseID))
+	}
+	stmt = `INSERT INTO game_modes (user_id, case_id, mode) SELECT ?, id, ? FROM cases WHERE id = ?`
+	if result, err = tx.ExecContext(ctx, stmt, userID, mode, caseID); err != nil {
+		return errors.Wrap(err, "insert game mode", slog.String("case_id", caseID))
+	}
+	if err = requireAffected(result); err != nil {
+		return errors.Wrap(err, "insert game mode", slog.String("case_id", caseID))
+	}
+
+	if err = tx.Commit(); err != nil {
+		return errors.Wrap(err, "commit transaction")
+	}
+	return nil
+}
+
+// Get returns the user's progress in the case measured against the rules of the game mode. The questions being
+// answered count as well, but not the ones that failed.
+func (r *GameModeRepository) Get(ctx context.Context, caseID string, userID []byte) (*models.GameState, error) {
+	state, err := queryGameState(ctx, r.database.ReadOnly, r.logger, caseID, userID)
+	if err != nil {
+		return nil, errors.Wrap(err, "query game state", slog.String("case_id", caseID))
+	}
+	return state, nil
+}
+
+// queryGameState reads the user's progress in the case. See GameModeRepository.Get.
+func queryGameState(
+	ctx context.Context,
+	db querier,
+	logger *slog.Logger,
+	caseID string,
+	userID []byte,
+) (*models.GameState, error) {
+	var (
+		err  error
+		rows *sql.Rows
+	)
+	state := models.GameState{
+		Mode:             models.GameModeRelaxed,
+		Chosen:           true,
+		Questions:        0,
+		TargetQuestions:  make(map[string]int),
+		AvailableMinutes: make(map[string]int),
+	}
+	stmt := `SELECT mode FROM game_modes WHERE case_id = ? AND user_id = ?`
+	err = db.QueryRowContext(ctx, stmt, caseID, userID).Scan(&state.Mode)
+	if errors.Is(err, sql.ErrNoRows) {
+		state.Chosen = false
+	} else if err != nil {
+		return nil, errors.Wrap(err, "query game mode")
+	}
+
+	stmt = `SELECT t.id,
+       t.available_minutes,
+       (SELECT COUNT(*)
+        FROM completions c
+        WHERE c.investigation_target_id = t.id
+          AND c.user_id = @user_id
+          AND c.status <> 'error') AS questions
+FROM investigation_targets t
+WHERE t.case_id = @case_id`
+	if rows, err = db.QueryContext(ctx, stmt,
+		sql.Named("case_id", caseID),
+		sql.Named("user_id", userID),
+	); err != nil {
+		return nil, errors.Wrap(err, "query target questions")
+	}
+	defer func() {
+		if err = rows.Close(); err != nil {
+			err = errors.Wrap(err, "close rows")
+			logger.Error("could not close rows", errors.SlogError(err))
+		}
+	}()
+	for rows.Next() {
+		var (
+			targetID                    string
+			availableMinutes, questions int
+		)
+		if err = rows.Scan(&targetID, &availableMinutes, &questions); err != nil {
+			return nil, errors.Wrap(err, "scan target questions")
+		}
+		if availableMinutes > 0 {
+			state.AvailableMinutes[targetID] = availableMinutes
+		}
+		state.TargetQuestions[targetID] = questions
+		state.Questions += questions
+	}
+	if err = rows.Err(); err != nil {
+		return nil, errors.Wrap(err, "rows error")
+	}
+	return &state, nil
+}
+
+// enforceGameMode returns ErrGameOver if the rules of the user's game mode refuse another question to the
+// investigation target. Call it in the transaction creating the completion so that concurrent questions can't exceed
+// the budget.
+func enforceGameMode(
+	ctx context.Context,
+	tx *sql.Tx,
+	logger *slog.Logger,
+	investigationTargetID string,
+	userID []byte,
+) error {
+	var caseID string
+	stmt := `SELECT case_id FROM investigation_targets WHERE id = ?`
+	if err := tx.QueryRowContext(ctx, stmt, investigationTargetID).Scan(&caseID); err != nil {
+		return errors.Wrap(err, "query case ID")
+	}
+	state, err := queryGameState(ctx, tx, logger, caseID, userID)
+	if err != nil {
+		return errors.Wrap(err, "query game state", slog.String("case_id", caseID))
+	}
+	if refusal := state.Refusal(investigationTargetID); refusal != "" {
+		return errors.Wrap(ErrGameOver, "enforce game mode", slog.String("refusal", refusal))
+	}
+	return nil
+}
@@ -0,0 +1,74 @@
+package repositories_test
+
+import (
+	"context"
+	"database/sql"
+	"github.com/myrjola/sheerluck/internal/models"
+	"github.com/myrjola/sheerluck/internal/repositories"
+	"github.com/myrjola/sheerluck/internal/testhelpers"
+	"github.com/stretchr/testify/require"
+	"io"
+	"testing"
+)
+
+func TestGameModeRepository(t *testing.T) {
+	t.Parallel()
+	ctx := context.Background()
+	logger := testhelpers.NewLogger(io.Discard)
+	dbs := newTestDB(t, logger)
+	repo := repositories.NewGameModeRepository(dbs, logger)
+
+	// The questions that failed to be answered don't count.
+	state, err := repo.Get(ctx, "rue-morgue", []byte{2})
+	require.NoError(t, err)
+	require.Equal(t, models.GameModeRelaxed, state.Mode)
+	require.False(t, state.Chosen)
+	require.Equal(t, 1, state.Questions)
+	require.Equal(t, 1, state.TargetQuestions["rue-morgue"])
+	require.Equal(t, map[string]int{"le-bon": 240}, state.AvailableMinutes)
+	err = repo.Choose(ctx, "rue-morgue", []byte{2}, models.GameModeClock)
+	require.ErrorIs(t, err, repositories.ErrCaseStarted, "the case has been started by asking a question")
+
+	_, err = dbs.ReadWrite.ExecContext(ctx, "INSERT INTO users (id, display_name) VALUES (X'03', 'Test user 3')")
+	require.NoError(t, err)
+	userID := []byte{3}
+	err = repo.Choose(ctx, "nonexistent", userID, models.GameModeClock)
+	require.ErrorIs(t, err, sql.ErrNoRows)
+	require.NoError(t, repo.Choose(ctx, "rue-morgue", userID, models.GameModeTargetBudget))
+	err = repo.Choose(ctx, "rue-morgue", userID, models.GameModeRelaxed)
+	require.ErrorIs(t, err, repositories.ErrCaseStarted, "the game mode can't be changed")
+	state, err = repo.Get(ctx, "rue-morgue", userID)
+	require.NoError(t, err)
+	require.Equal(t, models.GameModeTargetBudget, state.Mode)
+	require.True(t, state.Chosen)
+	require.Equal(t, 0, state.Questions)
+	require.Equal(t, models.TargetQuestionBudget, state.TargetQuestionsLeft("le-bon"))
+
+	// The questions being answered count against the budget, which is enforced when the completion is created.
+	_, err = dbs.ReadWrite.ExecContext(ctx,
+		`INSERT INTO game_modes (user_id, case_id, mode) VALUES (X'01', 'rue-morgue', 'case-budget')`)
+	require.NoError(t, err)
+	_, err = dbs.ReadWrite.ExecContext(ctx, `WITH RECURSIVE n(i) AS (SELECT 3 UNION ALL SELECT i + 1 FROM n WHERE i < 28)
+INSERT INTO completions (user_id, investigation_target_id, "order", question, answer, status)
+SELECT X'01', 'le-bon', i, 'Why?', 'Because.', 'done' FROM n`)
+	require.NoError(t, err)
+	_, err = dbs.ReadWrite.ExecContext(ctx, `INSERT INTO completions (user_id, investigation_target_id, "order", question,
+                         answer, status)
+VALUES (X'01', 'rue-morgue', 0, 'Where am I?', '', 'streaming')`)
+	require.NoError(t, err)
+	state, err = repo.Get(ctx, "rue-morgue", []byte{1})
+	require.NoError(t, err)
+	require.Equal(t, models.CaseQuestionBudget, state.Questions)
+	require.Equal(t, 0, state.CaseQuestionsLeft())
+	var lastID int64
+	err = dbs.ReadOnly.QueryRowContext(ctx,
+		`SELECT id FROM completions WHERE user_id = X'01' AND investigation_target_id = 'le-bon' AND "order" = 28`,
+	).Scan(&lastID)
+	require.NoError(t, err)
+	investigations := repositories.NewInvestigationRepository(dbs, logger)
+	inquiry := models.Inquiry{Question: "One more?", Evidence: nil, Examined: nil}
+	_, err = investigations.CreateCompletion(ctx, "le-bon", []byte{1}, lastID, inquiry, "")
+	require.ErrorIs(t, err, repositories.ErrGameOver)
+	_, err = investigations.CreateAlternative(ctx, lastID, []byte{1}, inquiry, "")
+	require.ErrorIs(t, err, repositories.ErrGameOver)
+}
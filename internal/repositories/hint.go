@@ -0,0 +1,232 @@
+package repositories
+
+import (
+	"context"
+	"database/sql"
+	"github.com/myrjola/sheerluck/internal/errors"
+	"github.com/myrjola/sheerluck/internal/models"
+	"github.com/myrjola/sheerluck/internal/sqlite"
+	"log/slog"
+	"time"
+)
+
+type HintRepository struct {
+	database *sqlite.Database
+	logger   *slog.Logger
+}
+
+func NewHintRepository(dbs *sqlite.Database, logger *slog.Logger) *HintRepository {
+	return &HintRepository{
+		database: dbs,
+		logger:   logger.With("source", "HintRepository"),
+	}
+}
+
+// ListCandidates lists the clues of the case the user can be given hints about: the undiscovered clues of the
+// unlocked investigation targets whose required evidence the user has discovered. The clues supporting the solution
+// come first, then the clues in the order of the targets in the case overview.
+func (r *HintRepository) ListCandidates(
+	ctx context.Context,
+	caseID string,
+	userID []byte,
+) ([]models.HintCandidate, error) {
+	var (
+		candidates []models.HintCandidate
+		err        error
+		rows       *sql.Rows
+	)
+	stmt := `SELECT t.id,
+       t.name,
+       t.short_name,
+       t.type,
+       t.image_path,
+       t.description,
+       cl.id,
+       cl.description,
+       cl.keywords,
+       p.id,
+       p.name,
+       p.description
+FROM clues cl
+         JOIN investigation_targets t ON t.id = cl.investigation_target_id
+         LEFT JOIN chapters ch ON ch.id = t.chapter_id
+         LEFT JOIN points_of_interest p ON p.id = cl.point_of_interest_id
+WHERE t.case_id = @case_id
+  AND NOT (EXISTS (SELECT 1 FROM chapter_prerequisites cp WHERE cp.chapter_id = t.chapter_id)
+    AND NOT EXISTS (SELECT 1 FROM unlocked_chapters u WHERE u.chapter_id = t.chapter_id AND u.user_id = @user_id))
+  AND NOT EXISTS (SELECT 1
+                  FROM discovered_clues d
+                           JOIN completions c ON c.id = d.completion_id
+                  WHERE d.clue_id = cl.id
+                    AND d.user_id = @user_id
+                    AND c.active = 1)
+  AND (cl.requires_evidence_id IS NULL OR EXISTS (SELECT 1
+                                                  FROM discovered_clues d
+                                                           JOIN completions c ON c.id = d.completion_id
+                                                  WHERE d.clue_id = cl.requires_evidence_id
+                                                    AND d.user_id = @user_id
+                                                    AND c.active = 1))
+ORDER BY EXISTS (SELECT 1 FROM case_solution_clues s WHERE s.clue_id = cl.id) DESC,
+         COALESCE(ch.position + 1, 0),
+         CASE t.type WHEN 'person' THEN 0 WHEN 'scene' THEN 1 WHEN 'item' THEN 2 ELSE 3 END,
+         t.name,
+         cl.id`
+	if rows, err = r.database.ReadOnly.QueryContext(ctx, stmt,
+		sql.Named("case_id", caseID),
+		sql.Named("user_id", userID),
+	); err != nil {
+		return nil, errors.Wrap(err, "query hint candidates")
+	}
+	defer func() {
+		if err = rows.Close(); err != nil {
+			err = errors.Wrap(err, "close rows")
+			r.logger.Error("could not close rows", errors.SlogError(err))
+		}
+	}()
+	for rows.Next() {
+		var (
+			candidate       models.HintCandidate
+			keywords        string
+			pointOfInterest pointOfInterestColumns
+		)
+		if err = rows.Scan(
+			&candidate.Target.ID,
+			&candidate.Target.Name,
+			&candidate.Target.ShortName,
+			&candidate.Target.Type,
+			&candidate.Target.ImagePath,
+			&candidate.Target.Description,
+			&candidate.Clue.ID,
+			&candidate.Clue.Description,
+			&keywords,
+			&pointOfInterest.id,
+			&pointOfInterest.name,
+			&pointOfInterest.description,
+		); err != nil {
+			return nil, errors.Wrap(err, "scan hint candidate")
+		}
+		candidate.Clue.Keywords = splitKeywords(keywords)
+		candidate.Clue.PointOfInterest = pointOfInterest.pointOfInterest()
+		candidates = append(candidates, candidate)
+	}
+	if err = rows.Err(); err != nil {
+		return nil, errors.Wrap(err, "rows error")
+	}
+
+	var authored map[string][]string
+	if authored, err = r.listAuthoredHints(ctx, caseID); err != nil {
+		return nil, errors.Wrap(err, "list authored hints")
+	}
+	for i := range candidates {
+		candidates[i].Hints = authored[candidates[i].Clue.ID]
+	}
+	return candidates, nil
+}
+
+// listAuthoredHints lists the hints authored for the clues of the case by clue ID from the vaguest to the most
+// specific.
+func (r *HintRepository) listAuthoredHints(ctx context.Context, caseID string) (map[string][]string, error) {
+	var (
+		err  error
+		rows *sql.Rows
+	)
+	authored := make(map[string][]string)
+	stmt := `SELECT h.clue_id, h.text
+FROM clue_hints h
+         JOIN clues c ON c.id = h.clue_id
+         JOIN investigation_targets t ON t.id = c.investigation_target_id
+WHERE t.case_id = ?
+ORDER BY h.clue_id, h.level`
+	if rows, err = r.database.ReadOnly.QueryContext(ctx, stmt, caseID); err != nil {
+		return nil, errors.Wrap(err, "query authored hints")
+	}
+	defer func() {
+		if err = rows.Close(); err != nil {
+			err = errors.Wrap(err, "close rows")
+			r.logger.Error("could not close rows", errors.SlogError(err))
+		}
+	}()
+	for rows.Next() {
+		var clueID, text string
+		if err = rows.Scan(&clueID, &text); err != nil {
+			return nil, errors.Wrap(err, "scan authored hint")
+		}
+		authored[clueID] = append(authored[clueID], text)
+	}
+	if err = rows.Err(); err != nil {
+		return nil, errors.Wrap(err, "rows error")
+	}
+	return authored, nil
+}
+
+// Create records the hint the user was given in the case and returns its ID.
+func (r *HintRepository) Create(
+	ctx context.Context,
+	caseID string,
+	userID []byte,
+	hint models.Hint,
+) (int64, error) {
+	var (
+		hintID int64
+		err    error
+	)
+	stmt := `INSERT INTO hints (user_id, case_id, clue_id, level, text)
+VALUES (@user_id, @case_id, @clue_id, @level, @text)
+RETURNING id`
+	if err = r.database.ReadWrite.QueryRowContext(ctx, stmt,
+		sql.Named("user_id", userID),
+		sql.Named("case_id", caseID),
+		sql.Named("clue_id", hint.ClueID),
+		sql.Named("level", hint.Level),
+		sql.Named("text", hint.Text),
+	).Scan(&hintID); err != nil {
+		return 0, errors.Wrap(err, "insert hint", slog.String("case_id", caseID))
+	}
+	return hintID, nil
+}
+
+// listHints lists the hints the user has taken in the case in the order they were given.
+func listHints(
+	ctx context.Context,
+	db *sql.DB,
+	logger *slog.Logger,
+	caseID string,
+	userID []byte,
+) ([]models.Hint, error) {
+	var (
+		hints []models.Hint
+		err   error
+		rows  *sql.Rows
+	)
+	stmt := `SELECT id, COALESCE(clue_id, ''), level, text, created
+FROM hints
+WHERE user_id = ?
+  AND case_id = ?
+ORDER BY id`
+	if rows, err = db.QueryContext(ctx, stmt, userID, caseID); err != nil {
+		return nil, errors.Wrap(err, "query hints")
+	}
+	defer func() {
+		if err = rows.Close(); err != nil {
+			err = errors.Wrap(err, "close rows")
+			logger.Error("could not close rows", errors.SlogError(err))
+		}
+	}()
+	for rows.Next() {
+		var (
+			hint    models.Hint
+			created string
+		)
+		if err = rows.Scan(&hint.ID, &hint.ClueID, &hint.Level, &hint.Text, &created); err != nil {
+			return nil, errors.Wrap(err, "scan hint")
+		}
+		if hint.Created, err = time.Parse(time.RFC3339Nano, created); err != nil {
+			return nil, errors.Wrap(err, "parse hint time", slog.String("created", created))
+		}
+		hints = append(hints, hint)
+	}
+	if err = rows.Err(); err != nil {
+		return nil, errors.Wrap(err, "rows error")
+	}
+	return hints, nil
+}
@@ -0,0 +1,76 @@
+package repositories_test
+
+import (
+	"context"
+	"github.com/myrjola/sheerluck/internal/models"
+	"github.com/myrjola/sheerluck/internal/repositories"
+	"github.com/myrjola/sheerluck/internal/testhelpers"
+	"github.com/stretchr/testify/require"
+	"io"
+	"testing"
+	"time"
+)
+
+func candidateClueIDs(candidates []models.HintCandidate) []string {
+	ids := make([]string, 0, len(candidates))
+	for _, candidate := range candidates {
+		ids = append(ids, candidate.Clue.ID)
+	}
+	return ids
+}
+
+func TestHintRepository(t *testing.T) {
+	t.Parallel()
+	ctx := context.Background()
+	logger := testhelpers.NewLogger(io.Discard)
+	dbs := newTestDB(t, logger)
+	repo := repositories.NewHintRepository(dbs, logger)
+	investigations := repositories.NewInvestigationRepository(dbs, logger)
+	userID := []byte{2}
+
+	candidates, err := repo.ListCandidates(ctx, "rue-morgue", userID)
+	require.NoError(t, err)
+	require.Equal(t, []string{
+		"le-bon-last-meeting-with-the-victim",
+		"rue-morgue-tufts-of-hair",
+		"rue-morgue-window-spring",
+		"le-bon-victim-belongings",
+		"rue-morgue-untouched-gold",
+		"gazette-shrill-voice",
+	}, candidateClueIDs(candidates), "the clues of the solution come first without locked clues and evidence")
+	require.Equal(t, "Adolphe Le Bon", candidates[0].Target.Name)
+	require.Len(t, candidates[0].Hints, 3)
+	require.Len(t, candidates[1].Hints, 1)
+	require.Equal(t, "the hearth", candidates[1].Clue.PointOfInterest.Name)
+	require.Empty(t, candidates[3].Hints)
+
+	// Discovering the untouched gold makes the delivery it's evidence for a candidate.
+	require.NoError(t, investigations.DiscoverClues(ctx, 4, userID, []string{"rue-morgue-untouched-gold"}))
+	candidates, err = repo.ListCandidates(ctx, "rue-morgue", userID)
+	require.NoError(t, err)
+	require.NotContains(t, candidateClueIDs(candidates), "rue-morgue-untouched-gold")
+	require.Contains(t, candidateClueIDs(candidates), "le-bon-unannounced-delivery")
+
+	hint := models.Hint{
+		ID:      0,
+		ClueID:  "rue-morgue-tufts-of-hair",
+		Level:   models.HintLevelVague,
+		Text:    "Not every trace need belong to a man.",
+		Created: time.Time{},
+	}
+	hintID, err := repo.Create(ctx, "rue-morgue", userID, hint)
+	require.NoError(t, err)
+
+	overview, err := repositories.NewCaseRepository(dbs, logger).Get(ctx, "rue-morgue", userID)
+	require.NoError(t, err)
+	require.Len(t, overview.Hints, 1)
+	require.Equal(t, hintID, overview.Hints[0].ID)
+	require.Equal(t, "rue-morgue-tufts-of-hair", overview.Hints[0].ClueID)
+	require.Equal(t, models.HintLevelVague, overview.Hints[0].Level)
+	require.Equal(t, hint.Text, overview.Hints[0].Text)
+	require.WithinDuration(t, time.Now(), overview.Hints[0].Created, time.Minute)
+
+	overview, err = repositories.NewCaseRepository(dbs, logger).Get(ctx, "rue-morgue", []byte{1})
+	require.NoError(t, err)
+	require.Empty(t, overview.Hints, "hints are per player")
+}
@@ -5,8 +5,18 @@ import (
 	"database/sql"
 	"github.com/myrjola/sheerluck/internal/errors"
 	"github.com/myrjola/sheerluck/internal/models"
+	"github.com/myrjola/sheerluck/internal/ptr"
 	"github.com/myrjola/sheerluck/internal/sqlite"
 	"log/slog"
+	"strings"
+	"time"
+)
+
+var (
+	// ErrInvalidParent is returned when a new completion would not continue the investigation's history.
+	ErrInvalidParent = errors.NewSentinel("invalid parent completion")
+	// ErrInvalidTransition is returned when the completion is not in a status that allows the transition.
+	ErrInvalidTransition = errors.NewSentinel("invalid completion status transition")
 )
 
 type InvestigationRepository struct {
@@ -21,33 +31,126 @@ func NewInvestigationRepository(dbs *sqlite.Database, logger *slog.Logger) *Inve
 	}
 }
 
+// Get reads the investigation of given target and user including the case, the clues the target can reveal, and the
+// points of interest of a scene.
 func (r *InvestigationRepository) Get(
 	ctx context.Context,
 	investigationTargetID string,
 	userID []byte,
 ) (*models.Investigation, error) {
 	var (
-		investigationTarget models.InvestigationTarget
-		completions         []models.Completion
-		err                 error
-		rows                *sql.Rows
+		investigation models.Investigation
+		err           error
 	)
 
-	stmt := `SELECT id, name, short_name, type, image_path FROM investigation_targets WHERE id = ?`
-	if err = r.database.ReadOnly.QueryRowContext(ctx, stmt, investigationTargetID).Scan(
-		&investigationTarget.ID,
-		&investigationTarget.Name,
-		&investigationTarget.ShortName,
-		&investigationTarget.Type,
-		&investigationTarget.ImagePath,
+	stmt := `SELECT t.id,
+       t.name,
+       t.short_name,
+       t.type,
+       t.image_path,
+       t.description,
+       t.secret,
+       c.id,
+       c.name,
+       c.author,
+       c.image_path,
+       c.setting,
+       c.difficulty,
+       c.play_time_minutes,
+       c.blurb,
+       EXISTS (SELECT 1 FROM chapter_prerequisites p WHERE p.chapter_id = t.chapter_id)
+           AND NOT EXISTS (SELECT 1
+                           FROM unlocked_chapters u
+                           WHERE u.chapter_id = t.chapter_id
+                             AND u.user_id = @user_id)
+FROM investigation_targets t
+         JOIN cases c ON c.id = t.case_id
+WHERE t.id = @investigation_target_id`
+	if err = r.database.ReadOnly.QueryRowContext(ctx, stmt,
+		sql.Named("investigation_target_id", investigationTargetID),
+		sql.Named("user_id", userID),
+	).Scan(
+		&investigation.Target.ID,
+		&investigation.Target.Name,
+		&investigation.Target.ShortName,
+		&investigation.Target.Type,
+		&investigation.Target.ImagePath,
+		&investigation.Target.Description,
+		&investigation.Target.Secret,
+		&investigation.Case.ID,
+		&investigation.Case.Name,
+		&investigation.Case.Author,
+		&investigation.Case.ImagePath,
+		&investigation.Case.Setting,
+		&investigation.Case.Difficulty,
+		&investigation.Case.PlayTimeMinutes,
+		&investigation.Case.Blurb,
+		&investigation.Locked,
 	); err != nil {
 		return nil, errors.Wrap(err, "read investigation target")
 	}
 
-	stmt = `SELECT id, "order", question, answer
-	FROM completions
-	WHERE user_id = ? AND investigation_target_id = ?
-	ORDER BY "order"`
+	if investigation.Completions, err = r.listCompletions(ctx, investigationTargetID, userID); err != nil {
+		return nil, errors.Wrap(err, "list completions")
+	}
+	if investigation.Clues, err = r.listClues(ctx, investigationTargetID); err != nil {
+		return nil, errors.Wrap(err, "list clues")
+	}
+	if investigation.DiscoveredClues, err = listDiscoveredClues(
+		ctx, r.database.ReadOnly, r.logger, investigation.Case.ID, userID,
+	); err != nil {
+		return nil, errors.Wrap(err, "list discovered clues")
+	}
+	if investigation.PointsOfInterest, err = r.listPointsOfInterest(ctx, investigationTargetID); err != nil {
+		return nil, errors.Wrap(err, "list points of interest")
+	}
+	if investigation.Passages, err = r.listPassages(
+		ctx, investigationTargetID, investigation.DiscoveredClues,
+	); err != nil {
+		return nil, errors.Wrap(err, "list passages")
+	}
+
+	return &investigation, nil
+}
+
+func (r *InvestigationRepository) listCompletions(
+	ctx context.Context,
+	investigationTargetID string,
+	userID []byte,
+) ([]models.Completion, error) {
+	var (
+		completions []models.Completion
+		err         error
+		rows        *sql.Rows
+	)
+	stmt := `SELECT c.id,
+       c.parent_id,
+       c."order",
+       c.status,
+       c.question,
+       c.answer,
+       c.summary,
+       ec.id,
+       ec.description,
+       s.id,
+       st.id,
+       st.name,
+       st.short_name,
+       st.type,
+       s.question,
+       s.answer,
+       p.id,
+       p.name,
+       p.description
+FROM completions c
+         LEFT JOIN clues ec ON ec.id = c.evidence_clue_id
+         LEFT JOIN completions s ON s.id = c.evidence_completion_id
+         LEFT JOIN investigation_targets st ON st.id = s.investigation_target_id
+         LEFT JOIN points_of_interest p ON p.id = c.point_of_interest_id
+WHERE c.user_id = ?
+  AND c.investigation_target_id = ?
+  AND c.active = 1
+ORDER BY c."order"`
 	if rows, err = r.database.ReadOnly.QueryContext(ctx, stmt, userID, investigationTargetID); err != nil {
 		return nil, errors.Wrap(err, "query completions")
 	}
@@ -60,58 +163,972 @@ func (r *InvestigationRepository) Get(
 	for rows.Next() {
 		var (
 			completion models.Completion
+			clue       evidenceClueColumns
+			statement  statementColumns
+			examined   pointOfInterestColumns
 		)
-		if err = rows.Scan(&completion.ID, &completion.Order, &completion.Question, &completion.Answer); err != nil {
+		if err = rows.Scan(
+			&completion.ID,
+			&completion.ParentID,
+			&completion.Order,
+			&completion.Status,
+			&completion.Question,
+			&completion.Answer,
+			&completion.Summary,
+			&clue.id,
+			&clue.description,
+			&statement.completionID,
+			&statement.targetID,
+			&statement.targetName,
+			&statement.targetShortName,
+			&statement.targetType,
+			&statement.question,
+			&statement.answer,
+			&examined.id,
+			&examined.name,
+			&examined.description,
+		); err != nil {
 			return nil, errors.Wrap(err, "scan completion")
 		}
+		completion.Evidence = newEvidence(clue, statement)
+		completion.Examined = examined.pointOfInterest()
 		completions = append(completions, completion)
 	}
 	if err = rows.Err(); err != nil {
 		return nil, errors.Wrap(err, "rows error")
 	}
 
-	investigation := models.Investigation{
-		Target:      investigationTarget,
-		Completions: completions,
+	var alternatives map[int64][]int64
+	if alternatives, err = r.listAlternatives(ctx, investigationTargetID, userID); err != nil {
+		return nil, errors.Wrap(err, "list alternatives")
+	}
+	for i, completion := range completions {
+		if completion.Status == models.CompletionStatusDone {
+			completions[i].Alternatives = alternatives[ptr.Deref(completion.ParentID, -1)]
+		}
 	}
+	return completions, nil
+}
 
-	return &investigation, nil
+// listAlternatives lists the done completions branching from the active branch grouped by their parent ID. The
+// first completions of the history are grouped under -1.
+func (r *InvestigationRepository) listAlternatives(
+	ctx context.Context,
+	investigationTargetID string,
+	userID []byte,
+) (map[int64][]int64, error) {
+	var (
+		alternatives = make(map[int64][]int64)
+		err          error
+		rows         *sql.Rows
+	)
+	stmt := `SELECT id, COALESCE(parent_id, -1)
+FROM completions
+WHERE user_id = @user_id
+  AND investigation_target_id = @investigation_target_id
+  AND status = 'done'
+  AND (parent_id IS NULL OR parent_id IN (SELECT id
+                                          FROM completions
+                                          WHERE user_id = @user_id
+                                            AND investigation_target_id = @investigation_target_id
+                                            AND active = 1))
+ORDER BY id`
+	if rows, err = r.database.ReadOnly.QueryContext(ctx, stmt,
+		sql.Named("user_id", userID),
+		sql.Named("investigation_target_id", investigationTargetID),
+	); err != nil {
+		return nil, errors.Wrap(err, "query alternatives")
+	}
+	defer func() {
+		if err = rows.Close(); err != nil {
+			err = errors.Wrap(err, "close rows")
+			r.logger.Error("could not close rows", errors.SlogError(err))
+		}
+	}()
+	for rows.Next() {
+		var id, parentID int64
+		if err = rows.Scan(&id, &parentID); err != nil {
+			return nil, errors.Wrap(err, "scan alternative")
+		}
+		alternatives[parentID] = append(alternatives[parentID], id)
+	}
+	if err = rows.Err(); err != nil {
+		return nil, errors.Wrap(err, "rows error")
+	}
+	return alternatives, nil
+}
+
+// evidenceClueColumns are the nullable columns of a clue joined to another row, e.g., the evidence of a completion.
+type evidenceClueColumns struct {
+	id          sql.NullString
+	description sql.NullString
+}
+
+// clue returns the joined clue or nil if there's none.
+func (c evidenceClueColumns) clue() *models.Clue {
+	if !c.id.Valid {
+		return nil
+	}
+	return &models.Clue{
+		ID:               c.id.String,
+		Description:      c.description.String,
+		Keywords:         nil,
+		RequiresEvidence: nil,
+		PointOfInterest:  nil,
+	}
+}
+
+// pointOfInterestColumns are the nullable columns of a point of interest joined to another row.
+type pointOfInterestColumns struct {
+	id          sql.NullString
+	name        sql.NullString
+	description sql.NullString
 }
 
-// FinishCompletion adds a new completion to the investigation for given investigation target and user.
+// pointOfInterest returns the joined point of interest or nil if there's none.
+func (c pointOfInterestColumns) pointOfInterest() *models.PointOfInterest {
+	if !c.id.Valid {
+		return nil
+	}
+	return &models.PointOfInterest{ID: c.id.String, Name: c.name.String, Description: c.description.String}
+}
+
+// statementColumns are the nullable columns of the statement presented as evidence.
+type statementColumns struct {
+	completionID    sql.NullInt64
+	targetID        sql.NullString
+	targetName      sql.NullString
+	targetShortName sql.NullString
+	targetType      sql.NullString
+	question        sql.NullString
+	answer          sql.NullString
+}
+
+// statement returns the joined statement or nil if there's none.
+func (c statementColumns) statement() *models.Statement {
+	if !c.completionID.Valid {
+		return nil
+	}
+	return &models.Statement{
+		CompletionID: c.completionID.Int64,
+		Target: models.InvestigationTarget{
+			ID:          c.targetID.String,
+			Name:        c.targetName.String,
+			ShortName:   c.targetShortName.String,
+			Type:        models.InvestigationTargetType(c.targetType.String),
+			ImagePath:   "",
+			Description: "",
+			Secret:      "",
+		},
+		Question: c.question.String,
+		Answer:   c.answer.String,
+	}
+}
+
+// newEvidence returns the evidence presented with a completion or nil if none was presented.
+func newEvidence(clue evidenceClueColumns, statement statementColumns) *models.Evidence {
+	evidence := models.Evidence{Clue: clue.clue(), Statement: statement.statement()}
+	if evidence.Clue == nil && evidence.Statement == nil {
+		return nil
+	}
+	return &evidence
+}
+
+func (r *InvestigationRepository) listClues(ctx context.Context, investigationTargetID string) ([]models.Clue, error) {
+	var (
+		clues []models.Clue
+		err   error
+		rows  *sql.Rows
+	)
+	stmt := `SELECT c.id, c.description, c.keywords, r.id, r.description, p.id, p.name, p.description
+FROM clues c
+         LEFT JOIN clues r ON r.id = c.requires_evidence_id
+         LEFT JOIN points_of_interest p ON p.id = c.point_of_interest_id
+WHERE c.investigation_target_id = ?
+ORDER BY c.id`
+	if rows, err = r.database.ReadOnly.QueryContext(ctx, stmt, investigationTargetID); err != nil {
+		return nil, errors.Wrap(err, "query clues")
+	}
+	defer func() {
+		if err = rows.Close(); err != nil {
+			err = errors.Wrap(err, "close rows")
+			r.logger.Error("could not close rows", errors.SlogError(err))
+		}
+	}()
+	for rows.Next() {
+		var (
+			clue     models.Clue
+			keywords string
+			required evidenceClueColumns
+			location pointOfInterestColumns
+		)
+		if err = rows.Scan(
+			&clue.ID,
+			&clue.Description,
+			&keywords,
+			&required.id,
+			&required.description,
+			&location.id,
+			&location.name,
+			&location.description,
+		); err != nil {
+			return nil, errors.Wrap(err, "scan clue")
+		}
+		clue.Keywords = splitKeywords(keywords)
+		clue.RequiresEvidence = required.clue()
+		clue.PointOfInterest = location.pointOfInterest()
+		clues = append(clues, clue)
+	}
+	if err = rows.Err(); err != nil {
+		return nil, errors.Wrap(err, "rows error")
+	}
+	return clues, nil
+}
+
+func (r *InvestigationRepository) listPointsOfInterest(
+	ctx context.Context,
+	investigationTargetID string,
+) ([]models.PointOfInterest, error) {
+	var (
+		pointsOfInterest []models.PointOfInterest
+		err              error
+		rows             *sql.Rows
+	)
+	stmt := `SELECT id, name, description
+FROM points_of_interest
+WHERE investigation_target_id = ?
+ORDER BY position, id`
+	if rows, err = r.database.ReadOnly.QueryContext(ctx, stmt, investigationTargetID); err != nil {
+		return nil, errors.Wrap(err, "query points of interest")
+	}
+	defer func() {
+		if err = rows.Close(); err != nil {
+			err = errors.Wrap(err, "close rows")
+			r.logger.Error("could not close rows", errors.SlogError(err))
+		}
+	}()
+	for rows.Next() {
+		var pointOfInterest models.PointOfInterest
+		if err = rows.Scan(&pointOfInterest.ID, &pointOfInterest.Name, &pointOfInterest.Description); err != nil {
+			return nil, errors.Wrap(err, "scan point of interest")
+		}
+		pointsOfInterest = append(pointsOfInterest, pointOfInterest)
+	}
+	if err = rows.Err(); err != nil {
+		return nil, errors.Wrap(err, "rows error")
+	}
+	return pointsOfInterest, nil
+}
+
+// listPassages lists the passages of a document. The passages are redacted until the player discovers the clue
+// unredacting them.
+func (r *InvestigationRepository) listPassages(
+	ctx context.Context,
+	investigationTargetID string,
+	discoveredClues []models.DiscoveredClue,
+) ([]models.Passage, error) {
+	var (
+		passages []models.Passage
+		err      error
+		rows     *sql.Rows
+	)
+	discovered := make(map[string]bool, len(discoveredClues))
+	for _, discoveredClue := range discoveredClues {
+		discovered[discoveredClue.Clue.ID] = true
+	}
+	stmt := `SELECT text, redacted_until_clue_id
+FROM passages
+WHERE investigation_target_id = ?
+ORDER BY position`
+	if rows, err = r.database.ReadOnly.QueryContext(ctx, stmt, investigationTargetID); err != nil {
+		return nil, errors.Wrap(err, "query passages")
+	}
+	defer func() {
+		if err = rows.Close(); err != nil {
+			err = errors.Wrap(err, "close rows")
+			r.logger.Error("could not close rows", errors.SlogError(err))
+		}
+	}()
+	for rows.Next() {
+		var (
+			passage         models.Passage
+			redactedUntilID sql.NullString
+		)
+		if err = rows.Scan(&passage.Text, &redactedUntilID); err != nil {
+			return nil, errors.Wrap(err, "scan passage")
+		}
+		if redactedUntilID.Valid && !discovered[redactedUntilID.String] {
+			passage.Text = ""
+			passage.Redacted = true
+		}
+		passages = append(passages, passage)
+	}
+	if err = rows.Err(); err != nil {
+		return nil, errors.Wrap(err, "rows error")
+	}
+	return passages, nil
+}
+
+// listDiscoveredClues lists the clues the user has discovered on the active branches of the case.
+func listDiscoveredClues(
+	ctx context.Context,
+	db *sql.DB,
+	logger *slog.Logger,
+	caseID string,
+	userID []byte,
+) ([]models.DiscoveredClue, error) {
+	var (
+		discoveredClues []models.DiscoveredClue
+		err             error
+		rows            *sql.Rows
+	)
+	// A clue can be discovered on several branches. The first discovery on the active branch counts.
+	stmt := `SELECT c.id, c.description, c.keywords, c.investigation_target_id, d.completion_id, d.created
+FROM (SELECT d.clue_id,
+             d.completion_id,
+             d.created,
+             ROW_NUMBER() OVER (PARTITION BY d.clue_id ORDER BY d.created, d.completion_id) AS discovery
+      FROM discovered_clues d
+               JOIN completions comp ON comp.id = d.completion_id
+      WHERE d.user_id = ?
+        AND comp.active = 1) d
+         JOIN clues c ON c.id = d.clue_id
+         JOIN investigation_targets t ON t.id = c.investigation_target_id
+WHERE d.discovery = 1
+  AND t.case_id = ?
+ORDER BY d.created, c.id`
+	if rows, err = db.QueryContext(ctx, stmt, userID, caseID); err != nil {
+		return nil, errors.Wrap(err, "query discovered clues")
+	}
+	defer func() {
+		if err = rows.Close(); err != nil {
+			err = errors.Wrap(err, "close rows")
+			logger.Error("could not close rows", errors.SlogError(err))
+		}
+	}()
+	for rows.Next() {
+		var (
+			discoveredClue models.DiscoveredClue
+			keywords       string
+			discovered     string
+		)
+		if err = rows.Scan(
+			&discoveredClue.Clue.ID,
+			&discoveredClue.Clue.Description,
+			&keywords,
+			&discoveredClue.InvestigationTargetID,
+			&discoveredClue.CompletionID,
+			&discovered,
+		); err != nil {
+			return nil, errors.Wrap(err, "scan discovered clue")
+		}
+		discoveredClue.Clue.Keywords = splitKeywords(keywords)
+		if discoveredClue.Discovered, err = time.Parse(time.RFC3339Nano, discovered); err != nil {
+			return nil, errors.Wrap(err, "parse discovery time", slog.String("created", discovered))
+		}
+		discoveredClues = append(discoveredClues, discoveredClue)
+	}
+	if err = rows.Err(); err != nil {
+		return nil, errors.Wrap(err, "rows error")
+	}
+	return discoveredClues, nil
+}
+
+// splitKeywords parses the comma-separated keywords of a clue.
+func splitKeywords(keywords string) []string {
+	var result []string
+	for _, keyword := range strings.Split(keywords, ",") {
+		if keyword = strings.TrimSpace(keyword); keyword != "" {
+			result = append(result, keyword)
+		}
+	}
+	return result
+}
+
+// GetCompletion reads a single completion of the user.
+func (r *InvestigationRepository) GetCompletion(
+	ctx context.Context,
+	completionID int64,
+	userID []byte,
+) (*models.Completion, error) {
+	var completion models.Completion
+	stmt := `SELECT id, parent_id, "order", status, question, answer, summary
+FROM completions
+WHERE id = ?
+  AND user_id = ?`
+	if err := r.database.ReadOnly.QueryRowContext(ctx, stmt, completionID, userID).Scan(
+		&completion.ID,
+		&completion.ParentID,
+		&completion.Order,
+		&completion.Status,
+		&completion.Question,
+		&completion.Answer,
+		&completion.Summary,
+	); err != nil {
+		return nil, errors.Wrap(err, "read completion", slog.Int64("completion_id", completionID))
+	}
+	return &completion, nil
+}
+
+// GetStatement reads the user's done completion on the active branch of a person in the case as a statement the player
+// can present as evidence to another person. sql.ErrNoRows is returned if the user has no such completion.
+func (r *InvestigationRepository) GetStatement(
+	ctx context.Context,
+	completionID int64,
+	caseID string,
+	userID []byte,
+) (*models.Statement, error) {
+	var statement statementColumns
+	stmt := `SELECT c.id, t.id, t.name, t.short_name, t.type, c.question, c.answer
+FROM completions c
+         JOIN investigation_targets t ON t.id = c.investigation_target_id
+WHERE c.id = ?
+  AND c.user_id = ?
+  AND c.status = 'done'
+  AND c.active = 1
+  AND t.case_id = ?
+  AND t.type = 'person'`
+	if err := r.database.ReadOnly.QueryRowContext(ctx, stmt, completionID, userID, caseID).Scan(
+		&statement.completionID,
+		&statement.targetID,
+		&statement.targetName,
+		&statement.targetShortName,
+		&statement.targetType,
+		&statement.question,
+		&statement.answer,
+	); err != nil {
+		return nil, errors.Wrap(err, "read statement", slog.Int64("completion_id", completionID))
+	}
+	return statement.statement(), nil
+}
+
+// CreateCompletion stores a new question for given investigation target and user and returns the completion ID.
 //
-// The completion is added to the end of the completions list. The order of the completion is determined by the previous
-// completion. If no previous completion exists, set previousCompletionID to -1.
-func (r *InvestigationRepository) FinishCompletion(
+// The completion starts in the created status. Unfinished completions of the investigation are removed first, since
+// only one completion can be in progress at a time. The parent has to be the last completion on the active branch of
+// the investigation and it has to be done. If no previous completion exists, set parentID to -1. ErrInvalidParent is
+// returned otherwise. Use CreateAlternative to branch the history at an earlier completion. ErrGameOver is returned if
+// the rules of the user's game mode refuse the question.
+//
+// The inquiry is the question with the evidence presented or the point of interest examined. The promptVersion
+// identifies the system prompt the answer will be generated with.
+func (r *InvestigationRepository) CreateCompletion(
 	ctx context.Context,
 	investigationTargetID string,
 	userID []byte,
-	previousCompletionID int64,
-	question string,
-	answer string,
+	parentID int64,
+	inquiry models.Inquiry,
+	promptVersion string,
+) (int64, error) {
+	var (
+		tx  *sql.Tx
+		err error
+	)
+	if tx, err = r.database.ReadWrite.BeginTx(ctx, nil); err != nil {
+		return 0, errors.Wrap(err, "begin transaction")
+	}
+	defer rollback(ctx, r.logger, tx)
+
+	if err = deleteUnfinishedCompletions(ctx, tx, investigationTargetID, userID); err != nil {
+		return 0, errors.Wrap(err, "delete unfinished completions")
+	}
+	if err = enforceGameMode(ctx, tx, r.logger, investigationTargetID, userID); err != nil {
+		return 0, errors.Wrap(err, "enforce game mode")
+	}
+
+	// The parent has to be done and the last one on the active branch to keep it contiguous.
+	stmt := `SELECT CASE
+           WHEN @parent_id = -1 THEN 0
+           ELSE (SELECT "order" + 1
+                 FROM completions
+                 WHERE id = @parent_id
+                   AND user_id = @user_id
+                   AND investigation_target_id = @investigation_target_id
+                   AND status = 'done'
+                   AND active = 1) END AS "order",
+       (SELECT COUNT(*)
+        FROM completions
+        WHERE user_id = @user_id
+          AND investigation_target_id = @investigation_target_id
+          AND active = 1
+          AND (@parent_id = -1 OR "order" > (SELECT "order" FROM completions WHERE id = @parent_id))) AS later`
+	var (
+		order sql.NullInt64
+		later int64
+	)
+	if err = tx.QueryRowContext(ctx, stmt,
+		sql.Named("parent_id", parentID),
+		sql.Named("user_id", userID),
+		sql.Named("investigation_target_id", investigationTargetID),
+	).Scan(&order, &later); err != nil {
+		return 0, errors.Wrap(err, "query order")
+	}
+	if !order.Valid || later > 0 {
+		return 0, errors.Wrap(ErrInvalidParent, "validate parent",
+			slog.Int64("parent_id", parentID), slog.Int64("later_completions", later))
+	}
+
+	var completionID int64
+	if completionID, err = insertCompletion(ctx, tx, investigationTargetID, userID, parentID, order.Int64, inquiry,
+		promptVersion); err != nil {
+		return 0, errors.Wrap(err, "insert completion")
+	}
+
+	if err = tx.Commit(); err != nil {
+		return 0, errors.Wrap(err, "commit transaction")
+	}
+	return completionID, nil
+}
+
+// CreateAlternative stores a new question in place of the user's done completion on the active branch and returns
+// the new completion ID. Use it to regenerate an answer or to edit a question.
+//
+// The history branches at the replaced completion. The replaced completion and the completions following it leave the
+// active branch, but they're kept so that the player can switch back to them with SelectCompletion. Like with
+// CreateCompletion, unfinished completions are removed first and ErrGameOver is returned if the rules of the user's
+// game mode refuse the question. sql.ErrNoRows is returned if the user has no such completion.
+func (r *InvestigationRepository) CreateAlternative(
+	ctx context.Context,
+	replacedCompletionID int64,
+	userID []byte,
+	inquiry models.Inquiry,
+	promptVersion string,
+) (int64, error) {
+	var (
+		tx                    *sql.Tx
+		err                   error
+		investigationTargetID string
+		parentID              int64
+		order                 int64
+	)
+	if tx, err = r.database.ReadWrite.BeginTx(ctx, nil); err != nil {
+		return 0, errors.Wrap(err, "begin transaction")
+	}
+	defer rollback(ctx, r.logger, tx)
+
+	stmt := `SELECT investigation_target_id, COALESCE(parent_id, -1), "order"
+FROM completions
+WHERE id = ?
+  AND user_id = ?
+  AND status = 'done'
+  AND active = 1`
+	if err = tx.QueryRowContext(ctx, stmt, replacedCompletionID, userID).Scan(
+		&investigationTargetID,
+		&parentID,
+		&order,
+	); err != nil {
+		return 0, errors.Wrap(err, "read replaced completion", slog.Int64("completion_id", replacedCompletionID))
+	}
+
+	if err = deleteUnfinishedCompletions(ctx, tx, investigationTargetID, userID); err != nil {
+		return 0, errors.Wrap(err, "delete unfinished completions")
+	}
+	if err = enforceGameMode(ctx, tx, r.logger, investigationTargetID, userID); err != nil {
+		return 0, errors.Wrap(err, "enforce game mode")
+	}
+	if err = deactivateCompletions(ctx, tx, investigationTargetID, userID, order); err != nil {
+		return 0, errors.Wrap(err, "deactivate replaced completions")
+	}
+
+	var completionID int64
+	if completionID, err = insertCompletion(ctx, tx, investigationTargetID, userID, parentID, order, inquiry,
+		promptVersion); err != nil {
+		return 0, errors.Wrap(err, "insert completion")
+	}
+
+	if err = tx.Commit(); err != nil {
+		return 0, errors.Wrap(err, "commit transaction")
+	}
+	return completionID, nil
+}
+
+// SelectCompletion switches the active branch of the investigation to the user's done completion, which has to be an
+// alternative of a completion on the active branch. The branch continues with the most recently active completions
+// following the selected one.
+//
+// Unfinished completions are removed. sql.ErrNoRows is returned if the user has no such completion and
+// ErrInvalidParent if its parent isn't on the active branch.
+func (r *InvestigationRepository) SelectCompletion(ctx context.Context, completionID int64, userID []byte) error {
+	var (
+		tx                    *sql.Tx
+		err                   error
+		investigationTargetID string
+		order                 int64
+		parentActive          bool
+	)
+	if tx, err = r.database.ReadWrite.BeginTx(ctx, nil); err != nil {
+		return errors.Wrap(err, "begin transaction")
+	}
+	defer rollback(ctx, r.logger, tx)
+
+	stmt := `SELECT c.investigation_target_id, c."order", COALESCE(p.active, 1)
+FROM completions c
+         LEFT JOIN completions p ON p.id = c.parent_id
+WHERE c.id = ?
+  AND c.user_id = ?
+  AND c.status = 'done'`
+	if err = tx.QueryRowContext(ctx, stmt, completionID, userID).Scan(
+		&investigationTargetID,
+		&order,
+		&parentActive,
+	); err != nil {
+		return errors.Wrap(err, "read selected completion", slog.Int64("completion_id", completionID))
+	}
+	if !parentActive {
+		return errors.Wrap(ErrInvalidParent, "validate parent", slog.Int64("completion_id", completionID))
+	}
+
+	if err = deleteUnfinishedCompletions(ctx, tx, investigationTargetID, userID); err != nil {
+		return errors.Wrap(err, "delete unfinished completions")
+	}
+	if err = deactivateCompletions(ctx, tx, investigationTargetID, userID, order); err != nil {
+		return errors.Wrap(err, "deactivate completions")
+	}
+
+	// Follow the most recently active done child until the branch ends.
+	stmt = `WITH RECURSIVE branch(id) AS (SELECT ?
+                               UNION ALL
+                               SELECT (SELECT c.id
+                                       FROM completions c
+                                       WHERE c.parent_id = branch.id
+                                         AND c.status = 'done'
+                                       ORDER BY c.activated DESC, c.id DESC
+                                       LIMIT 1)
+                               FROM branch
+                               WHERE branch.id IS NOT NULL)
+UPDATE completions
+SET active    = 1,
+    activated = STRFTIME('%Y-%m-%dT%H:%M:%fZ')
+WHERE id IN (SELECT id FROM branch WHERE id IS NOT NULL)`
+	if _, err = tx.ExecContext(ctx, stmt, completionID); err != nil {
+		return errors.Wrap(err, "activate branch")
+	}
+
+	if err = tx.Commit(); err != nil {
+		return errors.Wrap(err, "commit transaction")
+	}
+	return nil
+}
+
+// deleteUnfinishedCompletions removes the completions of the investigation that are not done, since only one
+// completion can be in progress at a time.
+func deleteUnfinishedCompletions(ctx context.Context, tx *sql.Tx, investigationTargetID string, userID []byte) error {
+	stmt := `DELETE
+FROM completions
+WHERE user_id = ?
+  AND investigation_target_id = ?
+  AND status IN ('created', 'streaming', 'error')`
+	if _, err := tx.ExecContext(ctx, stmt, userID, investigationTargetID); err != nil {
+		return errors.Wrap(err, "delete completions")
+	}
+	return nil
+}
+
+// deactivateCompletions removes the completions from given order onwards from the active branch of the investigation.
+func deactivateCompletions(
+	ctx context.Context,
+	tx *sql.Tx,
+	investigationTargetID string,
+	userID []byte,
+	fromOrder int64,
 ) error {
-	stmt := `WITH new_order AS (
-SELECT   
-       CASE WHEN @previous_completion_id IS -1
-       THEN 0
-       ELSE MAX("order") + 1
-       END AS "order"
-	   FROM completions
-	   WHERE id = @previous_completion_id
-		 AND investigation_target_id = @investigation_target_id
-		 AND user_id = @user_id)
-INSERT
-INTO completions (user_id, investigation_target_id, question, answer, "order")
-VALUES (@user_id, @investigation_target_id, @question, @answer, (SELECT "order" FROM new_order));`
-	params := []any{
+	stmt := `UPDATE completions
+SET active = 0
+WHERE user_id = ?
+  AND investigation_target_id = ?
+  AND active = 1
+  AND "order" >= ?`
+	if _, err := tx.ExecContext(ctx, stmt, userID, investigationTargetID, fromOrder); err != nil {
+		return errors.Wrap(err, "update completions")
+	}
+	return nil
+}
+
+// insertCompletion inserts a created completion at the end of the active branch. Set parentID to -1 for the first
+// completion of the history. The completion is shared with the player's party in the case if they have one.
+func insertCompletion(
+	ctx context.Context,
+	tx *sql.Tx,
+	investigationTargetID string,
+	userID []byte,
+	parentID int64,
+	order int64,
+	inquiry models.Inquiry,
+	promptVersion string,
+) (int64, error) {
+	var (
+		evidenceClueID       *string
+		evidenceCompletionID *int64
+		pointOfInterestID    *string
+	)
+	if evidence := inquiry.Evidence; evidence != nil && evidence.Clue != nil {
+		evidenceClueID = &evidence.Clue.ID
+	}
+	if evidence := inquiry.Evidence; evidence != nil && evidence.Statement != nil {
+		evidenceCompletionID = &evidence.Statement.CompletionID
+	}
+	if inquiry.Examined != nil {
+		pointOfInterestID = &inquiry.Examined.ID
+	}
+	stmt := `INSERT INTO completions (parent_id, user_id, investigation_target_id, "order", question, prompt_version,
+                         status, evidence_clue_id, evidence_completion_id, point_of_interest_id, party_id)
+VALUES (NULLIF(@parent_id, -1), @user_id, @investigation_target_id, @order, @question, @prompt_version, 'created',
+        @evidence_clue_id, @evidence_completion_id, @point_of_interest_id,
+        (SELECT pm.party_id
+         FROM party_members pm
+                  JOIN investigation_targets t ON t.case_id = pm.case_id
+         WHERE pm.user_id = @user_id
+           AND t.id = @investigation_target_id))
+RETURNING id`
+	var completionID int64
+	if err := tx.QueryRowContext(ctx, stmt,
+		sql.Named("evidence_clue_id", evidenceClueID),
+		sql.Named("evidence_completion_id", evidenceCompletionID),
+		sql.Named("point_of_interest_id", pointOfInterestID),
+		sql.Named("parent_id", parentID),
 		sql.Named("user_id", userID),
 		sql.Named("investigation_target_id", investigationTargetID),
-		sql.Named("question", question),
-		sql.Named("answer", answer),
-		sql.Named("previous_completion_id", previousCompletionID),
+		sql.Named("order", order),
+		sql.Named("question", inquiry.Question),
+		sql.Named("prompt_version", promptVersion),
+	).Scan(&completionID); err != nil {
+		return 0, errors.Wrap(err, "insert")
+	}
+	return completionID, nil
+}
+
+// DiscoverClues records that the answer of the user's completion revealed the clues. Clues the user has already
+// discovered on the active branch keep their original discovery, but the new one counts if the player switches to
+// another branch.
+func (r *InvestigationRepository) DiscoverClues(
+	ctx context.Context,
+	completionID int64,
+	userID []byte,
+	clueIDs []string,
+) error {
+	var (
+		tx  *sql.Tx
+		err error
+	)
+	if tx, err = r.database.ReadWrite.BeginTx(ctx, nil); err != nil {
+		return errors.Wrap(err, "begin transaction")
+	}
+	defer rollback(ctx, r.logger, tx)
+
+	// The clue has to belong to the investigation target of the completion, which has to be part of the history.
+	stmt := `INSERT INTO discovered_clues (user_id, clue_id, completion_id)
+SELECT completions.user_id, clues.id, completions.id
+FROM completions
+         JOIN clues ON clues.investigation_target_id = completions.investigation_target_id
+WHERE completions.id = ?
+  AND completions.user_id = ?
+  AND completions.status = 'done'
+  AND clues.id = ?
+ON CONFLICT (completion_id, clue_id) DO NOTHING`
+	for _, clueID := range clueIDs {
+		if _, err = tx.ExecContext(ctx, stmt, completionID, userID, clueID); err != nil {
+			return errors.Wrap(err, "insert discovered clue", slog.String("clue_id", clueID))
+		}
+	}
+
+	if err = tx.Commit(); err != nil {
+		return errors.Wrap(err, "commit transaction")
+	}
+	return nil
+}
+
+// UnlockChapters unlocks the chapters of the completion's case whose prerequisites the user has discovered on the
+// active branches and records the completion after which they were unlocked. It returns the IDs of the newly unlocked
+// chapters.
+func (r *InvestigationRepository) UnlockChapters(
+	ctx context.Context,
+	completionID int64,
+	userID []byte,
+) ([]string, error) {
+	var (
+		chapterIDs []string
+		err        error
+		rows       *sql.Rows
+	)
+	stmt := `INSERT INTO unlocked_chapters (user_id, chapter_id, completion_id)
+SELECT c.user_id, ch.id, c.id
+FROM completions c
+         JOIN investigation_targets t ON t.id = c.investigation_target_id
+         JOIN chapters ch ON ch.case_id = t.case_id
+WHERE c.id = @completion_id
+  AND c.user_id = @user_id
+  AND EXISTS (SELECT 1 FROM chapter_prerequisites p WHERE p.chapter_id = ch.id)
+  AND NOT EXISTS (SELECT 1
+                  FROM chapter_prerequisites p
+                  WHERE p.chapter_id = ch.id
+                    AND NOT EXISTS (SELECT 1
+                                    FROM discovered_clues d
+                                             JOIN completions dc ON dc.id = d.completion_id
+                                    WHERE d.clue_id = p.clue_id
+                                      AND d.user_id = @user_id
+                                      AND dc.active = 1))
+ON CONFLICT (user_id, chapter_id) DO NOTHING
+RETURNING chapter_id`
+	if rows, err = r.database.ReadWrite.QueryContext(ctx, stmt,
+		sql.Named("completion_id", completionID),
+		sql.Named("user_id", userID),
+	); err != nil {
+		return nil, errors.Wrap(err, "insert unlocked chapters")
+	}
+	defer func() {
+		if err = rows.Close(); err != nil {
+			err = errors.Wrap(err, "close rows")
+			r.logger.Error("could not close rows", errors.SlogError(err))
+		}
+	}()
+	for rows.Next() {
+		var chapterID string
+		if err = rows.Scan(&chapterID); err != nil {
+			return nil, errors.Wrap(err, "scan chapter ID")
+		}
+		chapterIDs = append(chapterIDs, chapterID)
+	}
+	if err = rows.Err(); err != nil {
+		return nil, errors.Wrap(err, "rows error")
+	}
+	return chapterIDs, nil
+}
+
+// ListCompletionClues lists the clues revealed by the user's completion.
+func (r *InvestigationRepository) ListCompletionClues(
+	ctx context.Context,
+	completionID int64,
+	userID []byte,
+) ([]models.Clue, error) {
+	var (
+		clues []models.Clue
+		err   error
+		rows  *sql.Rows
+	)
+	stmt := `SELECT c.id, c.description, c.keywords
+FROM discovered_clues d
+         JOIN clues c ON c.id = d.clue_id
+WHERE d.completion_id = ?
+  AND d.user_id = ?
+ORDER BY c.id`
+	if rows, err = r.database.ReadOnly.QueryContext(ctx, stmt, completionID, userID); err != nil {
+		return nil, errors.Wrap(err, "query completion clues")
+	}
+	defer func() {
+		if err = rows.Close(); err != nil {
+			err = errors.Wrap(err, "close rows")
+			r.logger.Error("could not close rows", errors.SlogError(err))
+		}
+	}()
+	for rows.Next() {
+		var (
+			clue     models.Clue
+			keywords string
+		)
+		if err = rows.Scan(&clue.ID, &clue.Description, &keywords); err != nil {
+			return nil, errors.Wrap(err, "scan clue")
+		}
+		clue.Keywords = splitKeywords(keywords)
+		clues = append(clues, clue)
 	}
-	if _, err := r.database.ReadWrite.ExecContext(ctx, stmt, params...); err != nil {
-		return errors.Wrap(err, "insert completion")
+	if err = rows.Err(); err != nil {
+		return nil, errors.Wrap(err, "rows error")
+	}
+	return clues, nil
+}
+
+// SaveSummary stores the summary of the history up to and including the user's done completion.
+//
+// sql.ErrNoRows is returned if the user has no such done completion.
+func (r *InvestigationRepository) SaveSummary(
+	ctx context.Context,
+	completionID int64,
+	userID []byte,
+	summary string,
+) error {
+	var (
+		result   sql.Result
+		affected int64
+		err      error
+	)
+	stmt := `UPDATE completions
+SET summary = ?
+WHERE id = ?
+  AND user_id = ?
+  AND status = 'done'`
+	if result, err = r.database.ReadWrite.ExecContext(ctx, stmt, summary, completionID, userID); err != nil {
+		return errors.Wrap(err, "update completion summary")
+	}
+	if affected, err = result.RowsAffected(); err != nil {
+		return errors.Wrap(err, "rows affected")
+	}
+	if affected != 1 {
+		return errors.Wrap(sql.ErrNoRows, "update completion summary", slog.Int64("completion_id", completionID))
 	}
 	return nil
 }
+
+// StartStreaming marks a created completion as streaming.
+func (r *InvestigationRepository) StartStreaming(ctx context.Context, completionID int64, userID []byte) error {
+	stmt := `UPDATE completions
+SET status = 'streaming'
+WHERE id = ?
+  AND user_id = ?
+  AND status = 'created'`
+	return r.transition(ctx, "streaming", stmt, completionID, userID)
+}
+
+// FinishCompletion stores the answer of a streaming completion and marks it as done.
+func (r *InvestigationRepository) FinishCompletion(
+	ctx context.Context,
+	completionID int64,
+	userID []byte,
+	answer string,
+) error {
+	stmt := `UPDATE completions
+SET status = 'done',
+    answer = ?
+WHERE id = ?
+  AND user_id = ?
+  AND status = 'streaming'`
+	return r.transition(ctx, "done", stmt, answer, completionID, userID)
+}
+
+// FailCompletion marks a created or streaming completion as failed so that the question can be asked again.
+func (r *InvestigationRepository) FailCompletion(ctx context.Context, completionID int64, userID []byte) error {
+	stmt := `UPDATE completions
+SET status = 'error'
+WHERE id = ?
+  AND user_id = ?
+  AND status IN ('created', 'streaming')`
+	return r.transition(ctx, "error", stmt, completionID, userID)
+}
+
+// transition executes a status update statement and returns ErrInvalidTransition if no completion was in a status
+// allowing the transition.
+func (r *InvestigationRepository) transition(ctx context.Context, status, stmt string, args ...any) error {
+	var (
+		result   sql.Result
+		affected int64
+		err      error
+	)
+	if result, err = r.database.ReadWrite.ExecContext(ctx, stmt, args...); err != nil {
+		return errors.Wrap(err, "update completion status", slog.String("status", status))
+	}
+	if affected, err = result.RowsAffected(); err != nil {
+		return errors.Wrap(err, "rows affected")
+	}
+	if affected != 1 {
+		return errors.Wrap(ErrInvalidTransition, "update completion status", slog.String("status", status))
+	}
+	return nil
+}
+
+// rollback rolls back the transaction unless it's already committed. Use it with defer right after beginning the
+// transaction.
+func rollback(ctx context.Context, logger *slog.Logger, tx *sql.Tx) {
+	if err := tx.Rollback(); err != nil && !errors.Is(err, sql.ErrTxDone) {
+		err = errors.Wrap(err, "rollback transaction")
+		logger.LogAttrs(ctx, slog.LevelError, "failed to rollback transaction", errors.SlogError(err))
+	}
+}
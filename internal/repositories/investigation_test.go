@@ -2,15 +2,24 @@ package repositories_test
 
 import (
 	"context"
+	"database/sql"
 	"github.com/myrjola/sheerluck/internal/models"
+	"github.com/myrjola/sheerluck/internal/ptr"
 	"github.com/myrjola/sheerluck/internal/repositories"
 	"github.com/myrjola/sheerluck/internal/testhelpers"
 	"github.com/stretchr/testify/require"
 	"io"
 	"os"
+	"strings"
 	"testing"
+	"time"
 )
 
+// newInquiry returns an inquiry with a plain question.
+func newInquiry(question string) models.Inquiry {
+	return models.Inquiry{Question: question, Evidence: nil, Examined: nil}
+}
+
 func TestInvestigationRepository_Get(t *testing.T) {
 	t.Parallel()
 	logger := testhelpers.NewLogger(io.Discard)
@@ -21,82 +30,109 @@ func TestInvestigationRepository_Get(t *testing.T) {
 		name                  string
 		investigationTargetID string
 		userID                []byte
-		wantInvestigation     models.Investigation
+		wantTarget            models.InvestigationTarget
+		wantCompletions       []models.Completion
+		wantClueIDs           []string
 		wantErr               bool
 	}{
 		{
 			name:                  "Without completions",
 			investigationTargetID: "rue-morgue",
 			userID:                []byte{1},
-			wantInvestigation: models.Investigation{
-				Target: models.InvestigationTarget{
-					ID:        "rue-morgue",
-					Name:      "Rue Morgue Murder Scene",
-					ShortName: "Rue Morgue",
-					Type:      models.InvestigationTargetTypeScene,
-					ImagePath: "https://myrjola.twic.pics/sheerluck/rue-morgue.webp",
-				},
-				Completions: nil,
+			wantTarget: models.InvestigationTarget{
+				ID:          "rue-morgue",
+				Name:        "Rue Morgue Murder Scene",
+				ShortName:   "Rue Morgue",
+				Type:        models.InvestigationTargetTypeScene,
+				ImagePath:   "https://myrjola.twic.pics/sheerluck/rue-morgue.webp",
+				Description: "A ransacked chamber.",
+				Secret:      "",
 			},
-			wantErr: false,
+			wantCompletions: nil,
+			wantClueIDs:     []string{"rue-morgue-tufts-of-hair", "rue-morgue-untouched-gold", "rue-morgue-window-spring"},
+			wantErr:         false,
 		},
 		{
 			name:                  "With completions",
 			investigationTargetID: "le-bon",
 			userID:                []byte{1},
-			wantInvestigation: models.Investigation{
-				Target: models.InvestigationTarget{
-					ID:        "le-bon",
-					Name:      "Adolphe Le Bon",
-					ShortName: "Adolphe",
-					Type:      models.InvestigationTargetTypePerson,
-					ImagePath: "https://myrjola.twic.pics/sheerluck/adolphe_le-bon.webp",
+			wantTarget: models.InvestigationTarget{
+				ID:          "le-bon",
+				Name:        "Adolphe Le Bon",
+				ShortName:   "Adolphe",
+				Type:        models.InvestigationTargetTypePerson,
+				ImagePath:   "https://myrjola.twic.pics/sheerluck/adolphe_le-bon.webp",
+				Description: "A timid bank clerk.",
+				Secret:      "He carried the money without telling his employer.",
+			},
+			wantCompletions: []models.Completion{
+				{
+					ID:           1,
+					ParentID:     nil,
+					Order:        0,
+					Status:       models.CompletionStatusDone,
+					Question:     "What is your name?",
+					Answer:       "Adolphe Le Bon",
+					Summary:      "",
+					Alternatives: []int64{1},
+					Evidence:     nil,
+					Examined:     nil,
+				},
+				{
+					ID:           2,
+					ParentID:     ptr.Ref(int64(1)),
+					Order:        1,
+					Status:       models.CompletionStatusDone,
+					Question:     "What is your occupation?",
+					Answer:       "Bank clerc",
+					Summary:      "",
+					Alternatives: []int64{2},
+					Evidence:     nil,
+					Examined:     nil,
 				},
-				Completions: []models.Completion{
-					{
-						ID:       1,
-						Order:    0,
-						Question: "What is your name?",
-						Answer:   "Adolphe Le Bon",
-					},
-					{
-						ID:       2,
-						Order:    1,
-						Question: "What is your occupation?",
-						Answer:   "Bank clerc",
-					},
-					{
-						ID:       3,
-						Order:    2,
-						Question: "What is your address?",
-						Answer:   "Rue Morgue",
-					},
+				{
+					ID:           3,
+					ParentID:     ptr.Ref(int64(2)),
+					Order:        2,
+					Status:       models.CompletionStatusDone,
+					Question:     "What is your address?",
+					Answer:       "Rue Morgue",
+					Summary:      "",
+					Alternatives: []int64{3},
+					Evidence:     nil,
+					Examined:     nil,
 				},
 			},
+			wantClueIDs: []string{
+				"le-bon-last-meeting-with-the-victim", "le-bon-unannounced-delivery", "le-bon-victim-belongings",
+			},
 			wantErr: false,
 		},
 		{
 			name:                  "Invalid user name returns empty completions",
 			investigationTargetID: "rue-morgue",
 			userID:                []byte("nonexistent"),
-			wantInvestigation: models.Investigation{
-				Target: models.InvestigationTarget{
-					ID:        "rue-morgue",
-					Name:      "Rue Morgue Murder Scene",
-					ShortName: "Rue Morgue",
-					Type:      models.InvestigationTargetTypeScene,
-					ImagePath: "https://myrjola.twic.pics/sheerluck/rue-morgue.webp",
-				},
-				Completions: nil,
+			wantTarget: models.InvestigationTarget{
+				ID:          "rue-morgue",
+				Name:        "Rue Morgue Murder Scene",
+				ShortName:   "Rue Morgue",
+				Type:        models.InvestigationTargetTypeScene,
+				ImagePath:   "https://myrjola.twic.pics/sheerluck/rue-morgue.webp",
+				Description: "A ransacked chamber.",
+				Secret:      "",
 			},
-			wantErr: false,
+			wantCompletions: nil,
+			wantClueIDs:     []string{"rue-morgue-tufts-of-hair", "rue-morgue-untouched-gold", "rue-morgue-window-spring"},
+			wantErr:         false,
 		},
 		{
 			name:                  "Invalid investigation target",
 			investigationTargetID: "nonexistent",
 			userID:                []byte{1},
 			wantErr:               true,
-			wantInvestigation:     models.Investigation{}, //nolint:exhaustruct // expected to be empty
+			wantTarget:            models.InvestigationTarget{}, //nolint:exhaustruct // expected to be empty
+			wantCompletions:       nil,
+			wantClueIDs:           nil,
 		},
 	}
 	for _, tt := range tests {
@@ -112,67 +148,89 @@ func TestInvestigationRepository_Get(t *testing.T) {
 
 			require.NoError(t, err, "failed to read investigation")
 			require.NotNilf(t, investigation, "investigation not found")
-			require.Equal(t, tt.wantInvestigation.Target, investigation.Target, "investigation target mismatch")
-			require.Equal(t, tt.wantInvestigation.Completions, investigation.Completions, "completions mismatch")
+			require.Equal(t, tt.wantTarget, investigation.Target, "investigation target mismatch")
+			require.Equal(t, tt.wantCompletions, investigation.Completions, "completions mismatch")
+			require.Equal(t, "rue-morgue", investigation.Case.ID, "case mismatch")
+			require.NotEmpty(t, investigation.Case.Setting, "case setting missing")
+			var clueIDs []string
+			for _, clue := range investigation.Clues {
+				require.NotEmpty(t, clue.Keywords, "clue keywords missing")
+				clueIDs = append(clueIDs, clue.ID)
+			}
+			require.Equal(t, tt.wantClueIDs, clueIDs, "clues mismatch")
 		})
 	}
 }
-func TestInvestigationRepository_FinishCompletion(t *testing.T) {
+func TestInvestigationRepository_CreateCompletion(t *testing.T) {
 	t.Parallel()
 	tests := []struct {
 		name                  string
 		investigationTargetID string
 		userID                []byte
-		previousCompletionID  int64
+		parentID              int64
 		wantErr               bool
 	}{
 		{
 			name:                  "first completion",
 			investigationTargetID: "rue-morgue",
 			userID:                []byte{1},
-			previousCompletionID:  -1,
+			parentID:              -1,
 			wantErr:               false,
 		},
 		{
-			name:                  "second completion for user",
+			name:                  "replaces failed completion",
 			investigationTargetID: "rue-morgue",
 			userID:                []byte{2},
-			previousCompletionID:  4,
+			parentID:              4,
 			wantErr:               false,
 		},
 		{
 			name:                  "not allowed to target invalid completion",
 			investigationTargetID: "rue-morgue",
 			userID:                []byte{1},
-			previousCompletionID:  0,
+			parentID:              0,
 			wantErr:               true,
 		},
 		{
 			name:                  "fourth completion",
 			investigationTargetID: "le-bon",
 			userID:                []byte{1},
-			previousCompletionID:  3,
+			parentID:              3,
 			wantErr:               false,
 		},
 		{
 			name:                  "has to target last completion in investigation",
 			investigationTargetID: "le-bon",
 			userID:                []byte{1},
-			previousCompletionID:  2,
+			parentID:              2,
+			wantErr:               true,
+		},
+		{
+			name:                  "first completion only for empty investigation",
+			investigationTargetID: "le-bon",
+			userID:                []byte{1},
+			parentID:              -1,
+			wantErr:               true,
+		},
+		{
+			name:                  "not allowed to target other user's completion",
+			investigationTargetID: "le-bon",
+			userID:                []byte{2},
+			parentID:              3,
 			wantErr:               true,
 		},
 		{
 			name:                  "invalid investigation target",
 			investigationTargetID: "nonexistent",
 			userID:                []byte{1},
-			previousCompletionID:  -1,
+			parentID:              -1,
 			wantErr:               true,
 		},
 		{
 			name:                  "invalid user",
 			investigationTargetID: "le-bon",
 			userID:                []byte("nonexistent"),
-			previousCompletionID:  -1,
+			parentID:              -1,
 			wantErr:               true,
 		},
 	}
@@ -183,25 +241,248 @@ func TestInvestigationRepository_FinishCompletion(t *testing.T) {
 			dbs := newTestDB(t, logger)
 			repo := repositories.NewInvestigationRepository(dbs, logger)
 			ctx := context.TODO()
-			var err error
-			err = repo.FinishCompletion(ctx, tt.investigationTargetID, tt.userID, tt.previousCompletionID, "question", "answer")
+			completionID, err := repo.CreateCompletion(
+				ctx, tt.investigationTargetID, tt.userID, tt.parentID, newInquiry("question"), "person@test",
+			)
 			if tt.wantErr {
 				require.Error(t, err, "expected error")
 				return
 			}
-			require.NoError(t, err, "failed to read completion")
+			require.NoError(t, err, "failed to create completion")
 			var investigation *models.Investigation
 			investigation, err = repo.Get(ctx, tt.investigationTargetID, tt.userID)
 			require.NoError(t, err, "failed to read investigation")
 			numCompletions := len(investigation.Completions)
 			lastCompletion := investigation.Completions[numCompletions-1]
+			require.Equal(t, completionID, lastCompletion.ID, "wrong ID")
 			require.Equal(t, lastCompletion.Order, int64(numCompletions-1), "wrong order")
+			require.Equal(t, models.CompletionStatusCreated, lastCompletion.Status, "wrong status")
 			require.Equal(t, "question", lastCompletion.Question, "question mismatch")
-			require.Equal(t, "answer", lastCompletion.Answer, "answer mismatch")
+			require.Empty(t, lastCompletion.Answer, "answer should be empty")
+			if tt.parentID == -1 {
+				require.Nil(t, lastCompletion.ParentID, "first completion should not have parent")
+			} else {
+				require.Equal(t, ptr.Ref(tt.parentID), lastCompletion.ParentID, "parent mismatch")
+			}
 		})
 	}
 }
 
+func TestInvestigationRepository_CompletionLifecycle(t *testing.T) {
+	t.Parallel()
+	logger := testhelpers.NewLogger(os.Stdout)
+	dbs := newTestDB(t, logger)
+	repo := repositories.NewInvestigationRepository(dbs, logger)
+	ctx := context.TODO()
+	userID := []byte{1}
+	otherUserID := []byte{2}
+	investigationTargetID := "le-bon"
+
+	lastStatus := func() models.CompletionStatus {
+		investigation, err := repo.Get(ctx, investigationTargetID, userID)
+		require.NoError(t, err)
+		return investigation.Completions[len(investigation.Completions)-1].Status
+	}
+
+	// A failed completion stays in the history until the question is asked again.
+	completionID, err := repo.CreateCompletion(ctx, investigationTargetID, userID, 3, newInquiry("question"),
+		"person@test")
+	require.NoError(t, err)
+	require.ErrorIs(t, repo.FinishCompletion(ctx, completionID, userID, "answer"), repositories.ErrInvalidTransition,
+		"must stream before finishing")
+	require.NoError(t, repo.FailCompletion(ctx, completionID, userID))
+	require.Equal(t, models.CompletionStatusError, lastStatus())
+	require.ErrorIs(t, repo.StartStreaming(ctx, completionID, userID), repositories.ErrInvalidTransition,
+		"failed completion can't be streamed")
+
+	// Asking again wipes the failed completion.
+	completionID, err = repo.CreateCompletion(ctx, investigationTargetID, userID, 3, newInquiry("question again"),
+		"person@test")
+	require.NoError(t, err)
+	require.ErrorIs(t, repo.StartStreaming(ctx, completionID, otherUserID), repositories.ErrInvalidTransition,
+		"other users can't stream the completion")
+	require.NoError(t, repo.StartStreaming(ctx, completionID, userID))
+	require.Equal(t, models.CompletionStatusStreaming, lastStatus())
+	require.NoError(t, repo.FinishCompletion(ctx, completionID, userID, "answer"))
+	require.ErrorIs(t, repo.FailCompletion(ctx, completionID, userID), repositories.ErrInvalidTransition,
+		"done completion can't fail")
+
+	investigation, err := repo.Get(ctx, investigationTargetID, userID)
+	require.NoError(t, err)
+	require.Len(t, investigation.Completions, 4)
+	last := investigation.Completions[3]
+	require.Equal(t, models.CompletionStatusDone, last.Status)
+	require.Equal(t, "question again", last.Question)
+	require.Equal(t, "answer", last.Answer)
+
+	// A new completion wipes the unfinished ones.
+	completionID, err = repo.CreateCompletion(ctx, investigationTargetID, userID, last.ID, newInquiry("abandoned"),
+		"person@test")
+	require.NoError(t, err)
+	require.NoError(t, repo.StartStreaming(ctx, completionID, userID))
+	_, err = repo.CreateCompletion(ctx, investigationTargetID, userID, last.ID, newInquiry("replacement"), "person@test")
+	require.NoError(t, err)
+	require.ErrorIs(t, repo.FinishCompletion(ctx, completionID, userID, "answer"), repositories.ErrInvalidTransition,
+		"abandoned completion is removed")
+	investigation, err = repo.Get(ctx, investigationTargetID, userID)
+	require.NoError(t, err)
+	require.Len(t, investigation.Completions, 5)
+	require.Equal(t, "replacement", investigation.Completions[4].Question)
+}
+
+func TestInvestigationRepository_Evidence(t *testing.T) {
+	t.Parallel()
+	logger := testhelpers.NewLogger(io.Discard)
+	dbs := newTestDB(t, logger)
+	repo := repositories.NewInvestigationRepository(dbs, logger)
+	ctx := context.TODO()
+	userID := []byte{1}
+
+	statement, err := repo.GetStatement(ctx, 2, "rue-morgue", userID)
+	require.NoError(t, err)
+	require.Equal(t, "What is your occupation?", statement.Question)
+	require.Equal(t, "Bank clerc", statement.Answer)
+	require.Equal(t, "Adolphe Le Bon", statement.Target.Name)
+	_, err = repo.GetStatement(ctx, 2, "rue-morgue", []byte{2})
+	require.ErrorIs(t, err, sql.ErrNoRows, "other users' statements are not found")
+	_, err = repo.GetStatement(ctx, 2, "nonexistent", userID)
+	require.ErrorIs(t, err, sql.ErrNoRows, "statements of other cases are not found")
+	_, err = repo.GetStatement(ctx, 4, "rue-morgue", []byte{2})
+	require.ErrorIs(t, err, sql.ErrNoRows, "scenes make no statements")
+
+	investigation, err := repo.Get(ctx, "le-bon", userID)
+	require.NoError(t, err)
+	for _, clue := range investigation.Clues {
+		if clue.ID == "le-bon-unannounced-delivery" {
+			require.Equal(t, "rue-morgue-untouched-gold", clue.RequiresEvidence.ID)
+		} else {
+			require.Nil(t, clue.RequiresEvidence, clue.ID)
+		}
+	}
+
+	evidence := &models.Evidence{Clue: &investigation.Clues[0], Statement: nil}
+	inquiry := models.Inquiry{Question: "Explain this!", Evidence: evidence, Examined: nil}
+	_, err = repo.CreateCompletion(ctx, "le-bon", userID, 3, inquiry, "person@test")
+	require.NoError(t, err)
+	investigation, err = repo.Get(ctx, "le-bon", userID)
+	require.NoError(t, err)
+	require.Nil(t, investigation.Completions[2].Evidence)
+	require.Equal(t, investigation.Clues[0].Description, investigation.Completions[3].Evidence.Clue.Description)
+	require.Nil(t, investigation.Completions[3].Evidence.Statement)
+
+	inquiry.Evidence = &models.Evidence{Clue: nil, Statement: statement}
+	_, err = repo.CreateAlternative(ctx, 3, userID, inquiry, "person@test")
+	require.NoError(t, err)
+	investigation, err = repo.Get(ctx, "le-bon", userID)
+	require.NoError(t, err)
+	require.Len(t, investigation.Completions, 3, "the unfinished completion is removed")
+	require.Nil(t, investigation.Completions[2].Evidence.Clue)
+	require.Equal(t, *statement, *investigation.Completions[2].Evidence.Statement)
+
+	// The statements left on an inactive branch by regenerating the answer can't be presented.
+	regeneratedID, err := repo.CreateAlternative(ctx, 2, userID, newInquiry("What is your occupation?"), "person@test")
+	require.NoError(t, err)
+	require.NoError(t, repo.StartStreaming(ctx, regeneratedID, userID))
+	require.NoError(t, repo.FinishCompletion(ctx, regeneratedID, userID, "Banker"))
+	_, err = repo.GetStatement(ctx, 2, "rue-morgue", userID)
+	require.ErrorIs(t, err, sql.ErrNoRows, "statements of inactive branches are not found")
+	statement, err = repo.GetStatement(ctx, regeneratedID, "rue-morgue", userID)
+	require.NoError(t, err)
+	require.Equal(t, "Banker", statement.Answer)
+}
+
+func TestInvestigationRepository_PointsOfInterest(t *testing.T) {
+	t.Parallel()
+	logger := testhelpers.NewLogger(io.Discard)
+	dbs := newTestDB(t, logger)
+	repo := repositories.NewInvestigationRepository(dbs, logger)
+	ctx := context.TODO()
+	userID := []byte{2}
+
+	investigation, err := repo.Get(ctx, "rue-morgue", userID)
+	require.NoError(t, err)
+	require.Len(t, investigation.PointsOfInterest, 5)
+	floor := investigation.PointsOfInterest[2]
+	require.Equal(t, "rue-morgue-floor", floor.ID, "the points of interest are in the order of the case bundle")
+	require.Equal(t, "the money on the floor", floor.Name)
+	for _, clue := range investigation.Clues {
+		require.NotNil(t, clue.PointOfInterest, clue.ID)
+		if clue.ID == "rue-morgue-untouched-gold" {
+			require.Equal(t, floor, *clue.PointOfInterest)
+		}
+	}
+
+	inquiry := models.Inquiry{Question: "Examine the money on the floor.", Evidence: nil, Examined: &floor}
+	_, err = repo.CreateCompletion(ctx, "rue-morgue", userID, 4, inquiry, "scene@test")
+	require.NoError(t, err)
+	investigation, err = repo.Get(ctx, "rue-morgue", userID)
+	require.NoError(t, err)
+	require.Nil(t, investigation.Completions[0].Examined)
+	require.Equal(t, floor, *investigation.Completions[len(investigation.Completions)-1].Examined)
+
+	investigation, err = repo.Get(ctx, "le-bon", userID)
+	require.NoError(t, err)
+	require.Empty(t, investigation.PointsOfInterest, "persons have no points of interest")
+}
+
+func TestInvestigationRepository_Passages(t *testing.T) {
+	t.Parallel()
+	logger := testhelpers.NewLogger(io.Discard)
+	dbs := newTestDB(t, logger)
+	repo := repositories.NewInvestigationRepository(dbs, logger)
+	ctx := context.TODO()
+	userID := []byte{2}
+
+	investigation, err := repo.Get(ctx, "gazette-des-tribunaux", userID)
+	require.NoError(t, err)
+	require.Equal(t, models.InvestigationTargetTypeDocument, investigation.Target.Type)
+	require.Len(t, investigation.Passages, 4)
+	require.True(t, strings.HasPrefix(investigation.Passages[0].Text, "EXTRAORDINARY MURDERS."))
+	require.False(t, investigation.Passages[0].Redacted)
+	require.Equal(t, models.Passage{Text: "", Redacted: true}, investigation.Passages[3],
+		"the redacted text isn't read")
+
+	require.NoError(t, repo.DiscoverClues(ctx, 4, userID, []string{"rue-morgue-window-spring"}))
+	investigation, err = repo.Get(ctx, "gazette-des-tribunaux", userID)
+	require.NoError(t, err)
+	require.False(t, investigation.Passages[3].Redacted, "the discovered clue unredacts the passage")
+	require.Contains(t, investigation.Passages[3].Text, "firmly fastened from within")
+
+	investigation, err = repo.Get(ctx, "gazette-des-tribunaux", []byte{1})
+	require.NoError(t, err)
+	require.True(t, investigation.Passages[3].Redacted, "other players' discoveries don't unredact passages")
+
+	investigation, err = repo.Get(ctx, "le-bon", userID)
+	require.NoError(t, err)
+	require.Empty(t, investigation.Passages, "only documents have passages")
+}
+
+func TestInvestigationRepository_GetCompletion(t *testing.T) {
+	t.Parallel()
+	logger := testhelpers.NewLogger(io.Discard)
+	dbs := newTestDB(t, logger)
+	repo := repositories.NewInvestigationRepository(dbs, logger)
+	ctx := context.TODO()
+
+	completion, err := repo.GetCompletion(ctx, 5, []byte{2})
+	require.NoError(t, err)
+	require.Equal(t, models.Completion{
+		ID:           5,
+		ParentID:     ptr.Ref(int64(4)),
+		Order:        1,
+		Status:       models.CompletionStatusError,
+		Question:     "Who died?",
+		Answer:       "",
+		Summary:      "",
+		Alternatives: nil,
+		Evidence:     nil,
+		Examined:     nil,
+	}, *completion)
+
+	_, err = repo.GetCompletion(ctx, 5, []byte{1})
+	require.ErrorIs(t, err, sql.ErrNoRows, "other users' completions are not found")
+}
+
 func Benchmark_InvestigationRepository(b *testing.B) {
 	logger := testhelpers.NewLogger(os.Stdout)
 	dbs := newBenchmarkDB(b, logger)
@@ -218,3 +499,152 @@ func Benchmark_InvestigationRepository(b *testing.B) {
 		}
 	})
 }
+
+func TestInvestigationRepository_DiscoverClues(t *testing.T) {
+	t.Parallel()
+	ctx := context.Background()
+	logger := testhelpers.NewLogger(io.Discard)
+	dbs := newTestDB(t, logger)
+	repo := repositories.NewInvestigationRepository(dbs, logger)
+	userID := []byte{1}
+
+	require.NoError(t, repo.DiscoverClues(ctx, 2, userID, []string{"le-bon-victim-belongings"}))
+	// Rediscovering keeps the original discovery and clues of other users or targets are ignored.
+	require.NoError(t, repo.DiscoverClues(ctx, 3, userID, []string{"le-bon-victim-belongings"}))
+	require.NoError(t, repo.DiscoverClues(ctx, 4, userID, []string{"le-bon-last-meeting-with-the-victim"}))
+	require.NoError(t, repo.DiscoverClues(ctx, 4, []byte{2}, []string{"le-bon-last-meeting-with-the-victim"}))
+
+	investigation, err := repo.Get(ctx, "rue-morgue", userID)
+	require.NoError(t, err)
+	require.Len(t, investigation.DiscoveredClues, 1, "discovered clues are listed for the whole case")
+	discoveredClue := investigation.DiscoveredClues[0]
+	require.Equal(t, "le-bon-victim-belongings", discoveredClue.Clue.ID)
+	require.Equal(t, []string{"gold", "watch", "scissors"}, discoveredClue.Clue.Keywords)
+	require.Equal(t, "le-bon", discoveredClue.InvestigationTargetID)
+	require.Equal(t, int64(2), discoveredClue.CompletionID)
+	require.False(t, discoveredClue.Discovered.IsZero())
+
+	clues, err := repo.ListCompletionClues(ctx, 2, userID)
+	require.NoError(t, err)
+	require.Len(t, clues, 1)
+	require.Equal(t, "le-bon-victim-belongings", clues[0].ID)
+	clues, err = repo.ListCompletionClues(ctx, 2, []byte{2})
+	require.NoError(t, err)
+	require.Empty(t, clues)
+}
+
+func TestInvestigationRepository_SaveSummary(t *testing.T) {
+	t.Parallel()
+	ctx := context.Background()
+	logger := testhelpers.NewLogger(io.Discard)
+	dbs := newTestDB(t, logger)
+	repo := repositories.NewInvestigationRepository(dbs, logger)
+	userID := []byte{1}
+
+	require.NoError(t, repo.SaveSummary(ctx, 2, userID, "Adolphe is a bank clerk."))
+	require.ErrorIs(t, repo.SaveSummary(ctx, 2, []byte{2}, "Not my completion."), sql.ErrNoRows)
+	require.ErrorIs(t, repo.SaveSummary(ctx, 5, []byte{2}, "Failed completion."), sql.ErrNoRows)
+
+	investigation, err := repo.Get(ctx, "le-bon", userID)
+	require.NoError(t, err)
+	require.Len(t, investigation.Completions, 3)
+	require.Empty(t, investigation.Completions[0].Summary)
+	require.Equal(t, "Adolphe is a bank clerk.", investigation.Completions[1].Summary)
+}
+
+func TestInvestigationRepository_Branches(t *testing.T) {
+	t.Parallel()
+	ctx := context.Background()
+	logger := testhelpers.NewLogger(io.Discard)
+	dbs := newTestDB(t, logger)
+	repo := repositories.NewInvestigationRepository(dbs, logger)
+	userID := []byte{1}
+	investigationTargetID := "le-bon"
+
+	finish := func(completionID int64, answer string) {
+		require.NoError(t, repo.StartStreaming(ctx, completionID, userID))
+		require.NoError(t, repo.FinishCompletion(ctx, completionID, userID, answer))
+	}
+	activeBranch := func() ([]int64, [][]int64) {
+		investigation, err := repo.Get(ctx, investigationTargetID, userID)
+		require.NoError(t, err)
+		var ids []int64
+		var alternatives [][]int64
+		for _, completion := range investigation.Completions {
+			ids = append(ids, completion.ID)
+			alternatives = append(alternatives, completion.Alternatives)
+		}
+		return ids, alternatives
+	}
+
+	// Editing the second question branches the history.
+	editedID, err := repo.CreateAlternative(ctx, 2, userID, newInquiry("What is your job?"), "person@test")
+	require.NoError(t, err)
+	ids, _ := activeBranch()
+	require.Equal(t, []int64{1, editedID}, ids)
+	finish(editedID, "I'm a clerk.")
+	ids, alternatives := activeBranch()
+	require.Equal(t, []int64{1, editedID}, ids)
+	require.Equal(t, [][]int64{{1}, {2, editedID}}, alternatives)
+
+	_, err = repo.CreateCompletion(ctx, investigationTargetID, userID, 1, newInquiry("question"), "person@test")
+	require.ErrorIs(t, err, repositories.ErrInvalidParent, "has to continue from the end of the active branch")
+	_, err = repo.CreateCompletion(ctx, investigationTargetID, userID, 3, newInquiry("question"), "person@test")
+	require.ErrorIs(t, err, repositories.ErrInvalidParent, "inactive completions can't be continued")
+	_, err = repo.CreateAlternative(ctx, 3, userID, newInquiry("question"), "person@test")
+	require.ErrorIs(t, err, sql.ErrNoRows, "inactive completions can't be replaced")
+	_, err = repo.CreateAlternative(ctx, 2, []byte{2}, newInquiry("question"), "person@test")
+	require.ErrorIs(t, err, sql.ErrNoRows, "other users' completions can't be replaced")
+
+	// Regenerating the answers of the edited branch.
+	firstID, err := repo.CreateCompletion(ctx, investigationTargetID, userID, editedID, newInquiry("Where?"),
+		"person@test")
+	require.NoError(t, err)
+	finish(firstID, "At the bank.")
+	secondID, err := repo.CreateAlternative(ctx, firstID, userID, newInquiry("Where?"), "person@test")
+	require.NoError(t, err)
+	finish(secondID, "In the Rue Morgue.")
+	ids, alternatives = activeBranch()
+	require.Equal(t, []int64{1, editedID, secondID}, ids)
+	require.Equal(t, []int64{firstID, secondID}, alternatives[2])
+
+	// Clue discovery follows the active branch.
+	require.NoError(t, repo.DiscoverClues(ctx, 3, userID, []string{"le-bon-victim-belongings"}))
+	require.NoError(t, repo.DiscoverClues(ctx, secondID, userID, []string{"le-bon-last-meeting-with-the-victim"}))
+	discoveredClueIDs := func() []string {
+		investigation, getErr := repo.Get(ctx, investigationTargetID, userID)
+		require.NoError(t, getErr)
+		var clueIDs []string
+		for _, discoveredClue := range investigation.DiscoveredClues {
+			clueIDs = append(clueIDs, discoveredClue.Clue.ID)
+		}
+		return clueIDs
+	}
+	require.Equal(t, []string{"le-bon-last-meeting-with-the-victim"}, discoveredClueIDs())
+
+	// Switching back to the original question continues with its original answers.
+	require.NoError(t, repo.SelectCompletion(ctx, 2, userID))
+	ids, alternatives = activeBranch()
+	require.Equal(t, []int64{1, 2, 3}, ids)
+	require.Equal(t, []int64{2, editedID}, alternatives[1])
+	require.Equal(t, []string{"le-bon-victim-belongings"}, discoveredClueIDs())
+
+	// Switching continues with the most recently active answers. The activation timestamps have millisecond precision.
+	time.Sleep(2 * time.Millisecond)
+	require.NoError(t, repo.SelectCompletion(ctx, editedID, userID))
+	ids, _ = activeBranch()
+	require.Equal(t, []int64{1, editedID, secondID}, ids)
+	time.Sleep(2 * time.Millisecond)
+	require.NoError(t, repo.SelectCompletion(ctx, firstID, userID))
+	time.Sleep(2 * time.Millisecond)
+	require.NoError(t, repo.SelectCompletion(ctx, 2, userID))
+	time.Sleep(2 * time.Millisecond)
+	require.NoError(t, repo.SelectCompletion(ctx, editedID, userID))
+	ids, _ = activeBranch()
+	require.Equal(t, []int64{1, editedID, firstID}, ids)
+
+	require.ErrorIs(t, repo.SelectCompletion(ctx, 3, userID), repositories.ErrInvalidParent,
+		"the parent has to be on the active branch")
+	require.ErrorIs(t, repo.SelectCompletion(ctx, 1, []byte{2}), sql.ErrNoRows)
+	require.ErrorIs(t, repo.SelectCompletion(ctx, 5, []byte{2}), sql.ErrNoRows, "failed completions can't be selected")
+}
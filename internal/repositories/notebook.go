@@ -0,0 +1,452 @@
+package repositories
+
+import (
+	"context"
+	"database/sql"
+	"github.com/myrjola/sheerluck/internal/errors"
+	"github.com/myrjola/sheerluck/internal/models"
+	"github.com/myrjola/sheerluck/internal/sqlite"
+	"log/slog"
+	"time"
+)
+
+// ErrUnknownTarget is returned when a note is tagged with an investigation target outside the note's case.
+var ErrUnknownTarget = errors.NewSentinel("unknown investigation target")
+
+type NotebookRepository struct {
+	database *sqlite.Database
+	logger   *slog.Logger
+}
+
+func NewNotebookRepository(dbs *sqlite.Database, logger *slog.Logger) *NotebookRepository {
+	return &NotebookRepository{
+		database: dbs,
+		logger:   logger.With("source", "NotebookRepository"),
+	}
+}
+
+// Get reads the user's notebook for the case. The notebook is empty if the user hasn't written anything in it.
+func (r *NotebookRepository) Get(ctx context.Context, caseID string, userID []byte) (*models.Notebook, error) {
+	var (
+		notebook models.Notebook
+		err      error
+	)
+	if notebook.Notes, err = r.listNotes(ctx, caseID, userID); err != nil {
+		return nil, errors.Wrap(err, "list notes")
+	}
+	if notebook.PinnedCompletions, err = r.listPinnedCompletions(ctx, caseID, userID); err != nil {
+		return nil, errors.Wrap(err, "list pinned completions")
+	}
+	if notebook.PinnedClues, err = r.listPinnedClues(ctx, caseID, userID); err != nil {
+		return nil, errors.Wrap(err, "list pinned clues")
+	}
+	return &notebook, nil
+}
+
+func (r *NotebookRepository) listNotes(ctx context.Context, caseID string, userID []byte) ([]models.Note, error) {
+	var (
+		notes []models.Note
+		err   error
+		rows  *sql.Rows
+	)
+	stmt := `SELECT id, text, created, updated
+FROM notes
+WHERE case_id = ?
+  AND user_id = ?
+ORDER BY created DESC, id DESC`
+	if rows, err = r.database.ReadOnly.QueryContext(ctx, stmt, caseID, userID); err != nil {
+		return nil, errors.Wrap(err, "query notes")
+	}
+	defer func() {
+		if err = rows.Close(); err != nil {
+			err = errors.Wrap(err, "close rows")
+			r.logger.Error("could not close rows", errors.SlogError(err))
+		}
+	}()
+	noteIndex := make(map[int64]int)
+	for rows.Next() {
+		var (
+			note             models.Note
+			created, updated string
+		)
+		if err = rows.Scan(&note.ID, &note.Text, &created, &updated); err != nil {
+			return nil, errors.Wrap(err, "scan note")
+		}
+		if note.Created, err = time.Parse(time.RFC3339Nano, created); err != nil {
+			return nil, errors.Wrap(err, "parse note created time", slog.String("created", created))
+		}
+		if note.Updated, err = time.Parse(time.RFC3339Nano, updated); err != nil {
+			return nil, errors.Wrap(err, "parse note updated time", slog.String("updated", updated))
+		}
+		noteIndex[note.ID] = len(notes)
+		notes = append(notes, note)
+	}
+	if err = rows.Err(); err != nil {
+		return nil, errors.Wrap(err, "rows error")
+	}
+
+	stmt = `SELECT nt.note_id, t.id, t.name, t.short_name, t.type, t.image_path
+FROM note_tags nt
+         JOIN notes n ON n.id = nt.note_id
+         JOIN investigation_targets t ON t.id = nt.investigation_target_id
+WHERE n.case_id = ?
+  AND n.user_id = ?
+ORDER BY t.name`
+	var tagRows *sql.Rows
+	if tagRows, err = r.database.ReadOnly.QueryContext(ctx, stmt, caseID, userID); err != nil {
+		return nil, errors.Wrap(err, "query note tags")
+	}
+	defer func() {
+		if err = tagRows.Close(); err != nil {
+			err = errors.Wrap(err, "close rows")
+			r.logger.Error("could not close rows", errors.SlogError(err))
+		}
+	}()
+	for tagRows.Next() {
+		var (
+			noteID int64
+			target models.InvestigationTarget
+		)
+		if err = tagRows.Scan(
+			&noteID, &target.ID, &target.Name, &target.ShortName, &target.Type, &target.ImagePath,
+		); err != nil {
+			return nil, errors.Wrap(err, "scan note tag")
+		}
+		i := noteIndex[noteID]
+		notes[i].Tags = append(notes[i].Tags, target)
+	}
+	if err = tagRows.Err(); err != nil {
+		return nil, errors.Wrap(err, "rows error")
+	}
+	return notes, nil
+}
+
+func (r *NotebookRepository) listPinnedCompletions(
+	ctx context.Context,
+	caseID string,
+	userID []byte,
+) ([]models.PinnedCompletion, error) {
+	var (
+		pinnedCompletions []models.PinnedCompletion
+		err               error
+		rows              *sql.Rows
+	)
+	stmt := `SELECT c.id, c.question, c.answer, t.id, t.name, t.short_name, t.type, t.image_path, p.created
+FROM pinned_completions p
+         JOIN completions c ON c.id = p.completion_id
+         JOIN investigation_targets t ON t.id = c.investigation_target_id
+WHERE t.case_id = ?
+  AND p.user_id = ?
+ORDER BY p.created, c.id`
+	if rows, err = r.database.ReadOnly.QueryContext(ctx, stmt, caseID, userID); err != nil {
+		return nil, errors.Wrap(err, "query pinned completions")
+	}
+	defer func() {
+		if err = rows.Close(); err != nil {
+			err = errors.Wrap(err, "close rows")
+			r.logger.Error("could not close rows", errors.SlogError(err))
+		}
+	}()
+	for rows.Next() {
+		var (
+			pinned  models.PinnedCompletion
+			created string
+		)
+		if err = rows.Scan(
+			&pinned.Completion.ID,
+			&pinned.Completion.Question,
+			&pinned.Completion.Answer,
+			&pinned.Target.ID,
+			&pinned.Target.Name,
+			&pinned.Target.ShortName,
+			&pinned.Target.Type,
+			&pinned.Target.ImagePath,
+			&created,
+		); err != nil {
+			return nil, errors.Wrap(err, "scan pinned completion")
+		}
+		if pinned.Pinned, err = time.Parse(time.RFC3339Nano, created); err != nil {
+			return nil, errors.Wrap(err, "parse pin time", slog.String("created", created))
+		}
+		pinnedCompletions = append(pinnedCompletions, pinned)
+	}
+	if err = rows.Err(); err != nil {
+		return nil, errors.Wrap(err, "rows error")
+	}
+	return pinnedCompletions, nil
+}
+
+func (r *NotebookRepository) listPinnedClues(
+	ctx context.Context,
+	caseID string,
+	userID []byte,
+) ([]models.PinnedClue, error) {
+	var (
+		pinnedClues []models.PinnedClue
+		err         error
+		rows        *sql.Rows
+	)
+	stmt := `SELECT c.id, c.description, c.keywords, c.investigation_target_id, p.created
+FROM pinned_clues p
+         JOIN clues c ON c.id = p.clue_id
+         JOIN investigation_targets t ON t.id = c.investigation_target_id
+WHERE t.case_id = @case_id
+  AND p.user_id = @user_id
+  AND EXISTS (SELECT 1
+              FROM discovered_clues d
+                       JOIN completions comp ON comp.id = d.completion_id
+              WHERE d.user_id = @user_id
+                AND d.clue_id = p.clue_id
+                AND comp.active = 1)
+ORDER BY p.created, c.id`
+	if rows, err = r.database.ReadOnly.QueryContext(ctx, stmt,
+		sql.Named("case_id", caseID),
+		sql.Named("user_id", userID),
+	); err != nil {
+		return nil, errors.Wrap(err, "query pinned clues")
+	}
+	defer func() {
+		if err = rows.Close(); err != nil {
+			err = errors.Wrap(err, "close rows")
+			r.logger.Error("could not close rows", errors.SlogError(err))
+		}
+	}()
+	for rows.Next() {
+		var (
+			pinned   models.PinnedClue
+			keywords string
+			created  string
+		)
+		if err = rows.Scan(
+			&pinned.Clue.ID,
+			&pinned.Clue.Description,
+			&keywords,
+			&pinned.InvestigationTargetID,
+			&created,
+		); err != nil {
+			return nil, errors.Wrap(err, "scan pinned clue")
+		}
+		pinned.Clue.Keywords = splitKeywords(keywords)
+		if pinned.Pinned, err = time.Parse(time.RFC3339Nano, created); err != nil {
+			return nil, errors.Wrap(err, "parse pin time", slog.String("created", created))
+		}
+		pinnedClues = append(pinnedClues, pinned)
+	}
+	if err = rows.Err(); err != nil {
+		return nil, errors.Wrap(err, "rows error")
+	}
+	return pinnedClues, nil
+}
+
+// CreateNote writes a note tagged with the investigation targets to the user's notebook for the case and returns its
+// ID. sql.ErrNoRows is returned if the case doesn't exist and ErrUnknownTarget if a target doesn't belong to it.
+func (r *NotebookRepository) CreateNote(
+	ctx context.Context,
+	caseID string,
+	userID []byte,
+	text string,
+	targetIDs []string,
+) (int64, error) {
+	var (
+		tx     *sql.Tx
+		noteID int64
+		err    error
+	)
+	if tx, err = r.database.ReadWrite.BeginTx(ctx, nil); err != nil {
+		return 0, errors.Wrap(err, "begin transaction")
+	}
+	defer rollback(ctx, r.logger, tx)
+
+	stmt := `INSERT INTO notes (user_id, case_id, text)
+SELECT ?, id, ?
+FROM cases
+WHERE id = ?
+RETURNING id`
+	if err = tx.QueryRowContext(ctx, stmt, userID, text, caseID).Scan(&noteID); err != nil {
+		return 0, errors.Wrap(err, "insert note", slog.String("case_id", caseID))
+	}
+	if err = tagNote(ctx, tx, noteID, caseID, targetIDs); err != nil {
+		return 0, errors.Wrap(err, "tag note")
+	}
+
+	if err = tx.Commit(); err != nil {
+		return 0, errors.Wrap(err, "commit transaction")
+	}
+	return noteID, nil
+}
+
+// UpdateNote replaces the text and the tags of the user's note in the case. sql.ErrNoRows is returned if there's no
+// such note and ErrUnknownTarget if a target doesn't belong to the case.
+func (r *NotebookRepository) UpdateNote(
+	ctx context.Context,
+	noteID int64,
+	caseID string,
+	userID []byte,
+	text string,
+	targetIDs []string,
+) error {
+	var (
+		tx     *sql.Tx
+		result sql.Result
+		err    error
+	)
+	if tx, err = r.database.ReadWrite.BeginTx(ctx, nil); err != nil {
+		return errors.Wrap(err, "begin transaction")
+	}
+	defer rollback(ctx, r.logger, tx)
+
+	stmt := `UPDATE notes SET text = ? WHERE id = ? AND case_id = ? AND user_id = ?`
+	if result, err = tx.ExecContext(ctx, stmt, text, noteID, caseID, userID); err != nil {
+		return errors.Wrap(err, "update note", slog.Int64("note_id", noteID))
+	}
+	if err = requireAffected(result); err != nil {
+		return errors.Wrap(err, "update note", slog.Int64("note_id", noteID))
+	}
+	if _, err = tx.ExecContext(ctx, `DELETE FROM note_tags WHERE note_id = ?`, noteID); err != nil {
+		return errors.Wrap(err, "delete note tags")
+	}
+	if err = tagNote(ctx, tx, noteID, caseID, targetIDs); err != nil {
+		return errors.Wrap(err, "tag note")
+	}
+
+	if err = tx.Commit(); err != nil {
+		return errors.Wrap(err, "commit transaction")
+	}
+	return nil
+}
+
+// tagNote tags the note with the investigation targets. ErrUnknownTarget is returned if a target doesn't belong to
+// the case.
+func tagNote(ctx context.Context, tx *sql.Tx, noteID int64, caseID string, targetIDs []string) error {
+	stmt := `INSERT INTO note_tags (note_id, investigation_target_id)
+SELECT ?, id
+FROM investigation_targets
+WHERE id = ?
+  AND case_id = ?
+ON CONFLICT DO UPDATE SET note_id = note_id`
+	for _, targetID := range targetIDs {
+		var (
+			result sql.Result
+			err    error
+		)
+		if result, err = tx.ExecContext(ctx, stmt, noteID, targetID, caseID); err != nil {
+			return errors.Wrap(err, "insert note tag", slog.String("investigation_target_id", targetID))
+		}
+		if err = requireAffected(result); errors.Is(err, sql.ErrNoRows) {
+			return errors.Wrap(ErrUnknownTarget, "validate tag", slog.String("investigation_target_id", targetID))
+		} else if err != nil {
+			return errors.Wrap(err, "insert note tag")
+		}
+	}
+	return nil
+}
+
+// DeleteNote deletes the user's note in the case. sql.ErrNoRows is returned if there's no such note.
+func (r *NotebookRepository) DeleteNote(ctx context.Context, noteID int64, caseID string, userID []byte) error {
+	var (
+		result sql.Result
+		err    error
+	)
+	stmt := `DELETE FROM notes WHERE id = ? AND case_id = ? AND user_id = ?`
+	if result, err = r.database.ReadWrite.ExecContext(ctx, stmt, noteID, caseID, userID); err != nil {
+		return errors.Wrap(err, "delete note", slog.Int64("note_id", noteID))
+	}
+	if err = requireAffected(result); err != nil {
+		return errors.Wrap(err, "delete note", slog.Int64("note_id", noteID))
+	}
+	return nil
+}
+
+// PinCompletion pins the user's answered completion in the case to the notebook. Pinning a pinned completion does
+// nothing. sql.ErrNoRows is returned if there's no such completion.
+func (r *NotebookRepository) PinCompletion(
+	ctx context.Context,
+	completionID int64,
+	caseID string,
+	userID []byte,
+) error {
+	var (
+		result sql.Result
+		err    error
+	)
+	stmt := `INSERT INTO pinned_completions (user_id, completion_id)
+SELECT c.user_id, c.id
+FROM completions c
+         JOIN investigation_targets t ON t.id = c.investigation_target_id
+WHERE c.id = ?
+  AND c.user_id = ?
+  AND c.status = 'done'
+  AND t.case_id = ?
+ON CONFLICT DO UPDATE SET created = created`
+	if result, err = r.database.ReadWrite.ExecContext(ctx, stmt, completionID, userID, caseID); err != nil {
+		return errors.Wrap(err, "pin completion", slog.Int64("completion_id", completionID))
+	}
+	if err = requireAffected(result); err != nil {
+		return errors.Wrap(err, "pin completion", slog.Int64("completion_id", completionID))
+	}
+	return nil
+}
+
+// UnpinCompletion removes the completion from the user's notebook. Unpinning a completion that isn't pinned does
+// nothing.
+func (r *NotebookRepository) UnpinCompletion(ctx context.Context, completionID int64, userID []byte) error {
+	stmt := `DELETE FROM pinned_completions WHERE completion_id = ? AND user_id = ?`
+	if _, err := r.database.ReadWrite.ExecContext(ctx, stmt, completionID, userID); err != nil {
+		return errors.Wrap(err, "unpin completion", slog.Int64("completion_id", completionID))
+	}
+	return nil
+}
+
+// PinClue pins the clue the user has discovered in the case to the notebook. Pinning a pinned clue does nothing.
+// sql.ErrNoRows is returned if the user hasn't discovered the clue on the active branches.
+func (r *NotebookRepository) PinClue(ctx context.Context, clueID string, caseID string, userID []byte) error {
+	var (
+		result sql.Result
+		err    error
+	)
+	stmt := `INSERT INTO pinned_clues (user_id, clue_id)
+SELECT @user_id, c.id
+FROM clues c
+         JOIN investigation_targets t ON t.id = c.investigation_target_id
+WHERE c.id = @clue_id
+  AND t.case_id = @case_id
+  AND EXISTS (SELECT 1
+              FROM discovered_clues d
+                       JOIN completions comp ON comp.id = d.completion_id
+              WHERE d.user_id = @user_id
+                AND d.clue_id = c.id
+                AND comp.active = 1)
+ON CONFLICT DO UPDATE SET created = created`
+	if result, err = r.database.ReadWrite.ExecContext(ctx, stmt,
+		sql.Named("user_id", userID),
+		sql.Named("clue_id", clueID),
+		sql.Named("case_id", caseID),
+	); err != nil {
+		return errors.Wrap(err, "pin clue", slog.String("clue_id", clueID))
+	}
+	if err = requireAffected(result); err != nil {
+		return errors.Wrap(err, "pin clue", slog.String("clue_id", clueID))
+	}
+	return nil
+}
+
+// UnpinClue removes the clue from the user's notebook. Unpinning a clue that isn't pinned does nothing.
+func (r *NotebookRepository) UnpinClue(ctx context.Context, clueID string, userID []byte) error {
+	stmt := `DELETE FROM pinned_clues WHERE clue_id = ? AND user_id = ?`
+	if _, err := r.database.ReadWrite.ExecContext(ctx, stmt, clueID, userID); err != nil {
+		return errors.Wrap(err, "unpin clue", slog.String("clue_id", clueID))
+	}
+	return nil
+}
+
+// requireAffected returns sql.ErrNoRows if the statement didn't affect any rows.
+func requireAffected(result sql.Result) error {
+	affected, err := result.RowsAffected()
+	if err != nil {
+		return errors.Wrap(err, "rows affected")
+	}
+	if affected == 0 {
+		return sql.ErrNoRows
+	}
+	return nil
+}
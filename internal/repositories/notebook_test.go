@@ -0,0 +1,109 @@
+package repositories_test
+
+import (
+	"context"
+	"database/sql"
+	"github.com/myrjola/sheerluck/internal/repositories"
+	"github.com/myrjola/sheerluck/internal/testhelpers"
+	"github.com/stretchr/testify/require"
+	"io"
+	"testing"
+)
+
+func TestNotebookRepository_Notes(t *testing.T) {
+	t.Parallel()
+	ctx := context.Background()
+	logger := testhelpers.NewLogger(io.Discard)
+	dbs := newTestDB(t, logger)
+	repo := repositories.NewNotebookRepository(dbs, logger)
+	userID := []byte{1}
+
+	notebook, err := repo.Get(ctx, "rue-morgue", userID)
+	require.NoError(t, err)
+	require.Empty(t, notebook.Notes)
+
+	firstID, err := repo.CreateNote(ctx, "rue-morgue", userID, "Le Bon seems nervous.", []string{"le-bon"})
+	require.NoError(t, err)
+	secondID, err := repo.CreateNote(ctx, "rue-morgue", userID, "Check the window.", nil)
+	require.NoError(t, err)
+	_, err = repo.CreateNote(ctx, "rue-morgue", userID, "Unknown tag.", []string{"nonexistent"})
+	require.ErrorIs(t, err, repositories.ErrUnknownTarget)
+	_, err = repo.CreateNote(ctx, "nonexistent", userID, "Unknown case.", nil)
+	require.ErrorIs(t, err, sql.ErrNoRows)
+
+	notebook, err = repo.Get(ctx, "rue-morgue", userID)
+	require.NoError(t, err)
+	require.Len(t, notebook.Notes, 2, "the note with the unknown tag should be rolled back")
+	require.Equal(t, secondID, notebook.Notes[0].ID, "the newest note should be first")
+	require.Empty(t, notebook.Notes[0].Tags)
+	require.Equal(t, firstID, notebook.Notes[1].ID)
+	require.Equal(t, "Le Bon seems nervous.", notebook.Notes[1].Text)
+	require.Len(t, notebook.Notes[1].Tags, 1)
+	require.Equal(t, "Adolphe Le Bon", notebook.Notes[1].Tags[0].Name)
+	require.False(t, notebook.Notes[1].Created.IsZero())
+
+	err = repo.UpdateNote(ctx, secondID, "rue-morgue", userID, "Check the window nail.",
+		[]string{"rue-morgue", "le-bon", "rue-morgue"})
+	require.NoError(t, err)
+	err = repo.UpdateNote(ctx, secondID, "rue-morgue", []byte{2}, "Not my note.", nil)
+	require.ErrorIs(t, err, sql.ErrNoRows)
+	err = repo.UpdateNote(ctx, secondID, "rue-morgue", userID, "Unknown tag.", []string{"nonexistent"})
+	require.ErrorIs(t, err, repositories.ErrUnknownTarget)
+	notebook, err = repo.Get(ctx, "rue-morgue", userID)
+	require.NoError(t, err)
+	require.Equal(t, "Check the window nail.", notebook.Notes[0].Text)
+	require.Len(t, notebook.Notes[0].Tags, 2)
+	require.Equal(t, "Adolphe Le Bon", notebook.Notes[0].Tags[0].Name)
+	require.Equal(t, "Rue Morgue Murder Scene", notebook.Notes[0].Tags[1].Name)
+
+	notebook, err = repo.Get(ctx, "rue-morgue", []byte{2})
+	require.NoError(t, err)
+	require.Empty(t, notebook.Notes, "notes should be private")
+
+	require.ErrorIs(t, repo.DeleteNote(ctx, firstID, "rue-morgue", []byte{2}), sql.ErrNoRows)
+	require.NoError(t, repo.DeleteNote(ctx, firstID, "rue-morgue", userID))
+	require.ErrorIs(t, repo.DeleteNote(ctx, firstID, "rue-morgue", userID), sql.ErrNoRows)
+	notebook, err = repo.Get(ctx, "rue-morgue", userID)
+	require.NoError(t, err)
+	require.Len(t, notebook.Notes, 1)
+}
+
+func TestNotebookRepository_Pins(t *testing.T) {
+	t.Parallel()
+	ctx := context.Background()
+	logger := testhelpers.NewLogger(io.Discard)
+	dbs := newTestDB(t, logger)
+	repo := repositories.NewNotebookRepository(dbs, logger)
+	investigations := repositories.NewInvestigationRepository(dbs, logger)
+	userID := []byte{1}
+
+	require.NoError(t, repo.PinCompletion(ctx, 2, "rue-morgue", userID))
+	require.NoError(t, repo.PinCompletion(ctx, 2, "rue-morgue", userID), "pinning again should do nothing")
+	require.ErrorIs(t, repo.PinCompletion(ctx, 4, "rue-morgue", userID), sql.ErrNoRows, "another user's completion")
+	require.ErrorIs(t, repo.PinCompletion(ctx, 5, "rue-morgue", []byte{2}), sql.ErrNoRows, "failed completion")
+
+	require.ErrorIs(t, repo.PinClue(ctx, "le-bon-victim-belongings", "rue-morgue", userID), sql.ErrNoRows,
+		"undiscovered clue")
+	require.NoError(t, investigations.DiscoverClues(ctx, 2, userID, []string{"le-bon-victim-belongings"}))
+	require.NoError(t, repo.PinClue(ctx, "le-bon-victim-belongings", "rue-morgue", userID))
+	require.NoError(t, repo.PinClue(ctx, "le-bon-victim-belongings", "rue-morgue", userID))
+
+	notebook, err := repo.Get(ctx, "rue-morgue", userID)
+	require.NoError(t, err)
+	require.Len(t, notebook.PinnedCompletions, 1)
+	require.Equal(t, "What is your occupation?", notebook.PinnedCompletions[0].Completion.Question)
+	require.Equal(t, "Bank clerc", notebook.PinnedCompletions[0].Completion.Answer)
+	require.Equal(t, "Adolphe Le Bon", notebook.PinnedCompletions[0].Target.Name)
+	require.True(t, notebook.HasPinnedCompletion(2))
+	require.False(t, notebook.HasPinnedCompletion(3))
+	require.Len(t, notebook.PinnedClues, 1)
+	require.Equal(t, "le-bon", notebook.PinnedClues[0].InvestigationTargetID)
+	require.True(t, notebook.HasPinnedClue("le-bon-victim-belongings"))
+
+	require.NoError(t, repo.UnpinCompletion(ctx, 2, userID))
+	require.NoError(t, repo.UnpinClue(ctx, "le-bon-victim-belongings", userID))
+	notebook, err = repo.Get(ctx, "rue-morgue", userID)
+	require.NoError(t, err)
+	require.Empty(t, notebook.PinnedCompletions)
+	require.Empty(t, notebook.PinnedClues)
+}
@@ -0,0 +1,393 @@
+package repositories
+
+import (
+	"context"
+	"database/sql"
+	"github.com/myrjola/sheerluck/internal/errors"
+	"github.com/myrjola/sheerluck/internal/models"
+	"github.com/myrjola/sheerluck/internal/sqlite"
+	"log/slog"
+	"time"
+)
+
+// ErrPartyMember is returned when a player who is already a member of a party of the case creates or joins another.
+var ErrPartyMember = errors.NewSentinel("already a member of a party of the case")
+
+type PartyRepository struct {
+	database *sqlite.Database
+	logger   *slog.Logger
+}
+
+func NewPartyRepository(dbs *sqlite.Database, logger *slog.Logger) *PartyRepository {
+	return &PartyRepository{
+		database: dbs,
+		logger:   logger.With("source", "PartyRepository"),
+	}
+}
+
+// Create creates a party for the case owned by the user and returns its ID. The invite token is the secret of the
+// invite link. sql.ErrNoRows is returned if the case doesn't exist and ErrPartyMember if the user is already a member
+// of a party of the case.
+func (r *PartyRepository) Create(ctx context.Context, caseID string, userID []byte, inviteToken string) (int64, error) {
+	var (
+		tx      *sql.Tx
+		partyID int64
+		err     error
+	)
+	if tx, err = r.database.ReadWrite.BeginTx(ctx, nil); err != nil {
+		return 0, errors.Wrap(err, "begin transaction")
+	}
+	defer rollback(ctx, r.logger, tx)
+
+	if err = requireNoParty(ctx, tx, caseID, userID); err != nil {
+		return 0, errors.Wrap(err, "require no party")
+	}
+	stmt := `INSERT INTO parties (case_id, owner_id, invite_token)
+SELECT id, ?, ?
+FROM cases
+WHERE id = ?
+RETURNING id`
+	if err = tx.QueryRowContext(ctx, stmt, userID, inviteToken, caseID).Scan(&partyID); err != nil {
+		return 0, errors.Wrap(err, "insert party", slog.String("case_id", caseID))
+	}
+	stmt = `INSERT INTO party_members (party_id, user_id, case_id) VALUES (?, ?, ?)`
+	if _, err = tx.ExecContext(ctx, stmt, partyID, userID, caseID); err != nil {
+		return 0, errors.Wrap(err, "insert owner")
+	}
+
+	if err = tx.Commit(); err != nil {
+		return 0, errors.Wrap(err, "commit transaction")
+	}
+	return partyID, nil
+}
+
+// requireNoParty returns ErrPartyMember if the user is a member of a party of the case.
+func requireNoParty(ctx context.Context, tx *sql.Tx, caseID string, userID []byte) error {
+	var member bool
+	stmt := `SELECT EXISTS (SELECT 1 FROM party_members WHERE case_id = ? AND user_id = ?)`
+	if err := tx.QueryRowContext(ctx, stmt, caseID, userID).Scan(&member); err != nil {
+		return errors.Wrap(err, "query membership")
+	}
+	if member {
+		return ErrPartyMember
+	}
+	return nil
+}
+
+// Join adds the user to the party with the invite token and returns the ID of the party's case. Joining a party the
+// user is already a member of does nothing. sql.ErrNoRows is returned if there's no such party and ErrPartyMember if
+// the user is a member of another party of the case.
+func (r *PartyRepository) Join(ctx context.Context, inviteToken string, userID []byte) (string, error) {
+	var (
+		tx      *sql.Tx
+		partyID int64
+		caseID  string
+		member  bool
+		err     error
+	)
+	if tx, err = r.database.ReadWrite.BeginTx(ctx, nil); err != nil {
+		return "", errors.Wrap(err, "begin transaction")
+	}
+	defer rollback(ctx, r.logger, tx)
+
+	stmt := `SELECT p.id,
+       p.case_id,
+       EXISTS (SELECT 1 FROM party_members pm WHERE pm.party_id = p.id AND pm.user_id = ?)
+FROM parties p
+WHERE p.invite_token = ?`
+	if err = tx.QueryRowContext(ctx, stmt, userID, inviteToken).Scan(&partyID, &caseID, &member); err != nil {
+		return "", errors.Wrap(err, "read party")
+	}
+	if member {
+		return caseID, nil
+	}
+	if err = requireNoParty(ctx, tx, caseID, userID); err != nil {
+		return "", errors.Wrap(err, "require no party")
+	}
+	stmt = `INSERT INTO party_members (party_id, user_id, case_id) VALUES (?, ?, ?)`
+	if _, err = tx.ExecContext(ctx, stmt, partyID, userID, caseID); err != nil {
+		return "", errors.Wrap(err, "insert member")
+	}
+
+	if err = tx.Commit(); err != nil {
+		return "", errors.Wrap(err, "commit transaction")
+	}
+	return caseID, nil
+}
+
+// Leave removes the user from their party of the case and returns the party's ID. The party is disbanded if the user
+// owns it. The completions asked in the party stay visible to the remaining members. sql.ErrNoRows is returned if the
+// user isn't a member of a party of the case.
+func (r *PartyRepository) Leave(ctx context.Context, caseID string, userID []byte) (int64, error) {
+	var (
+		tx      *sql.Tx
+		partyID int64
+		owner   bool
+		err     error
+	)
+	if tx, err = r.database.ReadWrite.BeginTx(ctx, nil); err != nil {
+		return 0, errors.Wrap(err, "begin transaction")
+	}
+	defer rollback(ctx, r.logger, tx)
+
+	stmt := `SELECT p.id, p.owner_id = pm.user_id
+FROM party_members pm
+         JOIN parties p ON p.id = pm.party_id
+WHERE pm.case_id = ?
+  AND pm.user_id = ?`
+	if err = tx.QueryRowContext(ctx, stmt, caseID, userID).Scan(&partyID, &owner); err != nil {
+		return 0, errors.Wrap(err, "read membership")
+	}
+	if owner {
+		_, err = tx.ExecContext(ctx, `DELETE FROM parties WHERE id = ?`, partyID)
+	} else {
+		_, err = tx.ExecContext(ctx, `DELETE FROM party_members WHERE party_id = ? AND user_id = ?`, partyID, userID)
+	}
+	if err != nil {
+		return 0, errors.Wrap(err, "leave party", slog.Int64("party_id", partyID), slog.Bool("owner", owner))
+	}
+
+	if err = tx.Commit(); err != nil {
+		return 0, errors.Wrap(err, "commit transaction")
+	}
+	return partyID, nil
+}
+
+// Get reads the user's party for the case with its members, completions, and discovered clues. sql.ErrNoRows is
+// returned if the user isn't a member of a party of the case.
+func (r *PartyRepository) Get(ctx context.Context, caseID string, userID []byte) (*models.Party, error) {
+	stmt := `SELECT p.id, p.invite_token, p.created, c.id, c.name
+FROM party_members pm
+         JOIN parties p ON p.id = pm.party_id
+         JOIN cases c ON c.id = p.case_id
+WHERE pm.case_id = ?
+  AND pm.user_id = ?`
+	party, err := r.get(ctx, userID, stmt, caseID, userID)
+	if err != nil {
+		return nil, errors.Wrap(err, "get party")
+	}
+	if party.Completions, err = r.listCompletions(ctx, party.ID, userID); err != nil {
+		return nil, errors.Wrap(err, "list completions")
+	}
+	if party.DiscoveredClues, err = r.listDiscoveredClues(ctx, party.ID, userID); err != nil {
+		return nil, errors.Wrap(err, "list discovered clues")
+	}
+	return party, nil
+}
+
+// GetByInvite reads the party with the invite token and its members for the user considering to join it. The
+// completions and the clues are left out since the user might not be a member. sql.ErrNoRows is returned if there's
+// no such party.
+func (r *PartyRepository) GetByInvite(ctx context.Context, inviteToken string, userID []byte) (*models.Party, error) {
+	stmt := `SELECT p.id, p.invite_token, p.created, c.id, c.name
+FROM parties p
+         JOIN cases c ON c.id = p.case_id
+WHERE p.invite_token = ?`
+	party, err := r.get(ctx, userID, stmt, inviteToken)
+	if err != nil {
+		return nil, errors.Wrap(err, "get party")
+	}
+	return party, nil
+}
+
+// get reads the party selected by stmt and its members.
+func (r *PartyRepository) get(ctx context.Context, userID []byte, stmt string, args ...any) (*models.Party, error) {
+	var (
+		party   models.Party
+		created string
+		err     error
+	)
+	if err = r.database.ReadOnly.QueryRowContext(ctx, stmt, args...).Scan(
+		&party.ID, &party.InviteToken, &created, &party.Case.ID, &party.Case.Name,
+	); err != nil {
+		return nil, errors.Wrap(err, "read party")
+	}
+	if party.Created, err = time.Parse(time.RFC3339Nano, created); err != nil {
+		return nil, errors.Wrap(err, "parse party created time", slog.String("created", created))
+	}
+	if party.Members, err = r.listMembers(ctx, party.ID, userID); err != nil {
+		return nil, errors.Wrap(err, "list members")
+	}
+	return &party, nil
+}
+
+func (r *PartyRepository) listMembers(ctx context.Context, partyID int64, userID []byte) ([]models.PartyMember, error) {
+	var (
+		members []models.PartyMember
+		err     error
+		rows    *sql.Rows
+	)
+	stmt := `SELECT u.display_name, pm.user_id = p.owner_id, pm.user_id = ?, pm.joined
+FROM party_members pm
+         JOIN parties p ON p.id = pm.party_id
+         JOIN users u ON u.id = pm.user_id
+WHERE pm.party_id = ?
+ORDER BY pm.joined, u.display_name`
+	if rows, err = r.database.ReadOnly.QueryContext(ctx, stmt, userID, partyID); err != nil {
+		return nil, errors.Wrap(err, "query members")
+	}
+	defer func() {
+		if err = rows.Close(); err != nil {
+			err = errors.Wrap(err, "close rows")
+			r.logger.Error("could not close rows", errors.SlogError(err))
+		}
+	}()
+	for rows.Next() {
+		var (
+			member models.PartyMember
+			joined string
+		)
+		if err = rows.Scan(&member.DisplayName, &member.Owner, &member.Self, &joined); err != nil {
+			return nil, errors.Wrap(err, "scan member")
+		}
+		if member.Joined, err = time.Parse(time.RFC3339Nano, joined); err != nil {
+			return nil, errors.Wrap(err, "parse joined time", slog.String("joined", joined))
+		}
+		members = append(members, member)
+	}
+	if err = rows.Err(); err != nil {
+		return nil, errors.Wrap(err, "rows error")
+	}
+	return members, nil
+}
+
+// listCompletions lists the done completions asked in the party on the active branches. The askers might have left
+// the party, so only the display name, Owner, and Self of their PartyMember are populated.
+func (r *PartyRepository) listCompletions(
+	ctx context.Context,
+	partyID int64,
+	userID []byte,
+) ([]models.PartyCompletion, error) {
+	var (
+		completions []models.PartyCompletion
+		err         error
+		rows        *sql.Rows
+	)
+	stmt := `SELECT u.display_name,
+       co.user_id = p.owner_id,
+       co.user_id = ?,
+       t.id,
+       t.name,
+       t.short_name,
+       t.type,
+       t.image_path,
+       co.id,
+       co.question,
+       co.answer
+FROM completions co
+         JOIN parties p ON p.id = co.party_id
+         JOIN users u ON u.id = co.user_id
+         JOIN investigation_targets t ON t.id = co.investigation_target_id
+WHERE co.party_id = ?
+  AND co.active = 1
+  AND co.status = 'done'
+ORDER BY co.created, co.id`
+	if rows, err = r.database.ReadOnly.QueryContext(ctx, stmt, userID, partyID); err != nil {
+		return nil, errors.Wrap(err, "query completions")
+	}
+	defer func() {
+		if err = rows.Close(); err != nil {
+			err = errors.Wrap(err, "close rows")
+			r.logger.Error("could not close rows", errors.SlogError(err))
+		}
+	}()
+	for rows.Next() {
+		var completion models.PartyCompletion
+		if err = rows.Scan(
+			&completion.Member.DisplayName,
+			&completion.Member.Owner,
+			&completion.Member.Self,
+			&completion.Target.ID,
+			&completion.Target.Name,
+			&completion.Target.ShortName,
+			&completion.Target.Type,
+			&completion.Target.ImagePath,
+			&completion.Completion.ID,
+			&completion.Completion.Question,
+			&completion.Completion.Answer,
+		); err != nil {
+			return nil, errors.Wrap(err, "scan completion")
+		}
+		completions = append(completions, completion)
+	}
+	if err = rows.Err(); err != nil {
+		return nil, errors.Wrap(err, "rows error")
+	}
+	return completions, nil
+}
+
+// listDiscoveredClues lists the clues revealed by the completions asked in the party on the active branches. A clue
+// discovered by several members is listed once with its first discovery.
+func (r *PartyRepository) listDiscoveredClues(
+	ctx context.Context,
+	partyID int64,
+	userID []byte,
+) ([]models.PartyClue, error) {
+	var (
+		clues []models.PartyClue
+		err   error
+		rows  *sql.Rows
+	)
+	// SQLite takes the bare columns from the row with the first discovery.
+	stmt := `SELECT cl.id,
+       cl.description,
+       u.display_name,
+       co.user_id = p.owner_id,
+       co.user_id = ?,
+       cl.investigation_target_id,
+       MIN(dc.created)
+FROM discovered_clues dc
+         JOIN completions co ON co.id = dc.completion_id
+         JOIN parties p ON p.id = co.party_id
+         JOIN users u ON u.id = co.user_id
+         JOIN clues cl ON cl.id = dc.clue_id
+WHERE co.party_id = ?
+  AND co.active = 1
+GROUP BY cl.id
+ORDER BY MIN(dc.created), cl.id`
+	if rows, err = r.database.ReadOnly.QueryContext(ctx, stmt, userID, partyID); err != nil {
+		return nil, errors.Wrap(err, "query discovered clues")
+	}
+	defer func() {
+		if err = rows.Close(); err != nil {
+			err = errors.Wrap(err, "close rows")
+			r.logger.Error("could not close rows", errors.SlogError(err))
+		}
+	}()
+	for rows.Next() {
+		var (
+			clue       models.PartyClue
+			discovered string
+		)
+		if err = rows.Scan(
+			&clue.Clue.ID,
+			&clue.Clue.Description,
+			&clue.Member.DisplayName,
+			&clue.Member.Owner,
+			&clue.Member.Self,
+			&clue.InvestigationTargetID,
+			&discovered,
+		); err != nil {
+			return nil, errors.Wrap(err, "scan discovered clue")
+		}
+		if clue.Discovered, err = time.Parse(time.RFC3339Nano, discovered); err != nil {
+			return nil, errors.Wrap(err, "parse discovery time", slog.String("discovered", discovered))
+		}
+		clues = append(clues, clue)
+	}
+	if err = rows.Err(); err != nil {
+		return nil, errors.Wrap(err, "rows error")
+	}
+	return clues, nil
+}
+
+// GetCompletionPartyID returns the ID of the party the completion was asked in. sql.ErrNoRows is returned if it
+// wasn't asked in a party.
+func (r *PartyRepository) GetCompletionPartyID(ctx context.Context, completionID int64) (int64, error) {
+	var partyID int64
+	stmt := `SELECT party_id FROM completions WHERE id = ? AND party_id IS NOT NULL`
+	if err := r.database.ReadOnly.QueryRowContext(ctx, stmt, completionID).Scan(&partyID); err != nil {
+		return 0, errors.Wrap(err, "read completion party", slog.Int64("completion_id", completionID))
+	}
+	return partyID, nil
+}
@@ -0,0 +1,93 @@
+package repositories_test
+
+import (
+	"context"
+	"database/sql"
+	"github.com/myrjola/sheerluck/internal/models"
+	"github.com/myrjola/sheerluck/internal/repositories"
+	"github.com/myrjola/sheerluck/internal/testhelpers"
+	"github.com/stretchr/testify/require"
+	"io"
+	"testing"
+)
+
+func TestPartyRepository(t *testing.T) {
+	t.Parallel()
+	ctx := context.Background()
+	logger := testhelpers.NewLogger(io.Discard)
+	dbs := newTestDB(t, logger)
+	repo := repositories.NewPartyRepository(dbs, logger)
+	investigations := repositories.NewInvestigationRepository(dbs, logger)
+	owner := []byte{1}
+	member := []byte{2}
+
+	partyID, err := repo.Create(ctx, "rue-morgue", owner, "invite")
+	require.NoError(t, err)
+	_, err = repo.Create(ctx, "rue-morgue", owner, "another-invite")
+	require.ErrorIs(t, err, repositories.ErrPartyMember)
+	_, err = repo.Create(ctx, "unknown", owner, "unknown-invite")
+	require.ErrorIs(t, err, sql.ErrNoRows)
+
+	party, err := repo.GetByInvite(ctx, "invite", member)
+	require.NoError(t, err)
+	require.Equal(t, partyID, party.ID)
+	require.Equal(t, "The Murders in the Rue Morgue", party.Case.Name)
+	require.Len(t, party.Members, 1)
+	require.Equal(t, "Test user 1", party.Members[0].DisplayName)
+	require.True(t, party.Members[0].Owner)
+	require.False(t, party.Members[0].Self)
+	_, err = repo.GetByInvite(ctx, "unknown-invite", member)
+	require.ErrorIs(t, err, sql.ErrNoRows)
+
+	caseID, err := repo.Join(ctx, "invite", member)
+	require.NoError(t, err)
+	require.Equal(t, "rue-morgue", caseID)
+	_, err = repo.Join(ctx, "invite", member)
+	require.NoError(t, err, "joining again does nothing")
+
+	// The questions asked in the party are shared with the members but the earlier ones aren't.
+	inquiry := models.Inquiry{Question: "Where did you get the gold watch?", Evidence: nil, Examined: nil}
+	completionID, err := investigations.CreateCompletion(ctx, "le-bon", member, -1, inquiry, "")
+	require.NoError(t, err)
+	require.NoError(t, investigations.StartStreaming(ctx, completionID, member))
+	require.NoError(t, investigations.FinishCompletion(ctx, completionID, member, "From the ladies."))
+	require.NoError(t, investigations.DiscoverClues(ctx, completionID, member, []string{"le-bon-victim-belongings"}))
+	gotPartyID, err := repo.GetCompletionPartyID(ctx, completionID)
+	require.NoError(t, err)
+	require.Equal(t, partyID, gotPartyID)
+	_, err = repo.GetCompletionPartyID(ctx, 1)
+	require.ErrorIs(t, err, sql.ErrNoRows)
+
+	party, err = repo.Get(ctx, "rue-morgue", owner)
+	require.NoError(t, err)
+	require.Equal(t, "invite", party.InviteToken)
+	require.Len(t, party.Members, 2)
+	require.True(t, party.Members[0].Self)
+	require.Equal(t, "Test user 2", party.Members[1].DisplayName)
+	require.Len(t, party.Completions, 1)
+	require.Equal(t, "Test user 2", party.Completions[0].Member.DisplayName)
+	require.Equal(t, "Adolphe Le Bon", party.Completions[0].Target.Name)
+	require.Equal(t, "From the ladies.", party.Completions[0].Completion.Answer)
+	require.Len(t, party.DiscoveredClues, 1)
+	require.Equal(t, "le-bon-victim-belongings", party.DiscoveredClues[0].Clue.ID)
+	require.Equal(t, "le-bon", party.DiscoveredClues[0].InvestigationTargetID)
+
+	// The completions stay with the party when the member leaves.
+	gotPartyID, err = repo.Leave(ctx, "rue-morgue", member)
+	require.NoError(t, err)
+	require.Equal(t, partyID, gotPartyID)
+	_, err = repo.Get(ctx, "rue-morgue", member)
+	require.ErrorIs(t, err, sql.ErrNoRows)
+	party, err = repo.Get(ctx, "rue-morgue", owner)
+	require.NoError(t, err)
+	require.Len(t, party.Members, 1)
+	require.Len(t, party.Completions, 1)
+
+	// The party is disbanded when the owner leaves.
+	_, err = repo.Leave(ctx, "rue-morgue", owner)
+	require.NoError(t, err)
+	_, err = repo.GetByInvite(ctx, "invite", member)
+	require.ErrorIs(t, err, sql.ErrNoRows)
+	_, err = repo.Leave(ctx, "rue-morgue", owner)
+	require.ErrorIs(t, err, sql.ErrNoRows)
+}
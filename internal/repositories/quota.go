@@ -0,0 +1,179 @@
+package repositories
+
+import (
+	"context"
+	"database/sql"
+	"github.com/myrjola/sheerluck/internal/errors"
+	"github.com/myrjola/sheerluck/internal/models"
+	"github.com/myrjola/sheerluck/internal/sqlite"
+	"log/slog"
+)
+
+var (
+	// ErrQuestionRateExceeded is returned when too many questions have been asked within the last minute.
+	ErrQuestionRateExceeded = errors.NewSentinel("question rate exceeded")
+	// ErrTokenQuotaExceeded is returned when too many tokens have been used within the last day.
+	ErrTokenQuotaExceeded = errors.NewSentinel("token quota exceeded")
+)
+
+// QuotaLimits configures the AI quotas. Zero or negative limits are not enforced.
+type QuotaLimits struct {
+	UserQuestionsPerMinute   int
+	UserTokensPerDay         int
+	GlobalQuestionsPerMinute int
+	GlobalTokensPerDay       int
+}
+
+// QuotaRepository tracks the AI usage for enforcing the quotas.
+type QuotaRepository struct {
+	database *sqlite.Database
+	logger   *slog.Logger
+}
+
+func NewQuotaRepository(dbs *sqlite.Database, logger *slog.Logger) *QuotaRepository {
+	return &QuotaRepository{
+		database: dbs,
+		logger:   logger.With("source", "QuotaRepository"),
+	}
+}
+
+// queryRower is implemented by both *sql.DB and *sql.Tx.
+type queryRower interface {
+	QueryRowContext(ctx context.Context, query string, args ...any) *sql.Row
+}
+
+// queryUsage reads the usage of the user or the global usage if userID is nil.
+func queryUsage(ctx context.Context, db queryRower, userID []byte) (models.AIUsage, error) {
+	var usage models.AIUsage
+	stmt := `SELECT COALESCE(SUM(questions)
+                FILTER (WHERE created >= STRFTIME('%Y-%m-%dT%H:%M:%fZ', 'now', '-1 minute')), 0),
+       COALESCE(SUM(questions), 0),
+       COALESCE(SUM(prompt_tokens + completion_tokens), 0)
+FROM ai_usage
+WHERE created >= STRFTIME('%Y-%m-%dT%H:%M:%fZ', 'now', '-1 day')
+  AND (@user_id IS NULL OR user_id = @user_id)`
+	if err := db.QueryRowContext(ctx, stmt, sql.Named("user_id", userID)).Scan(
+		&usage.QuestionsLastMinute,
+		&usage.QuestionsLastDay,
+		&usage.TokensLastDay,
+	); err != nil {
+		return usage, errors.Wrap(err, "query usage")
+	}
+	return usage, nil
+}
+
+// checkLimits returns an error if the usage has reached the limits.
+func checkLimits(usage models.AIUsage, questionsPerMinute, tokensPerDay int) error {
+	if questionsPerMinute > 0 && usage.QuestionsLastMinute >= questionsPerMinute {
+		return errors.Wrap(ErrQuestionRateExceeded, "check questions per minute",
+			slog.Int("limit", questionsPerMinute), slog.Int("questions", usage.QuestionsLastMinute))
+	}
+	if tokensPerDay > 0 && usage.TokensLastDay >= tokensPerDay {
+		return errors.Wrap(ErrTokenQuotaExceeded, "check tokens per day",
+			slog.Int("limit", tokensPerDay), slog.Int("tokens", usage.TokensLastDay))
+	}
+	return nil
+}
+
+// RecordQuestion records a question of the user if the user and global usage stay within the limits. Otherwise,
+// ErrQuestionRateExceeded or ErrTokenQuotaExceeded is returned.
+func (r *QuotaRepository) RecordQuestion(ctx context.Context, userID []byte, limits QuotaLimits) error {
+	var (
+		tx          *sql.Tx
+		err         error
+		userUsage   models.AIUsage
+		globalUsage models.AIUsage
+	)
+	if tx, err = r.database.ReadWrite.BeginTx(ctx, nil); err != nil {
+		return errors.Wrap(err, "begin transaction")
+	}
+	defer rollback(ctx, r.logger, tx)
+
+	// Only the last day matters for the quotas.
+	stmt := `DELETE FROM ai_usage WHERE created < STRFTIME('%Y-%m-%dT%H:%M:%fZ', 'now', '-2 days')`
+	if _, err = tx.ExecContext(ctx, stmt); err != nil {
+		return errors.Wrap(err, "delete expired usage")
+	}
+
+	if userUsage, err = queryUsage(ctx, tx, userID); err != nil {
+		return errors.Wrap(err, "query user usage")
+	}
+	if err = checkLimits(userUsage, limits.UserQuestionsPerMinute, limits.UserTokensPerDay); err != nil {
+		return errors.Wrap(err, "check user limits")
+	}
+	if globalUsage, err = queryUsage(ctx, tx, nil); err != nil {
+		return errors.Wrap(err, "query global usage")
+	}
+	if err = checkLimits(globalUsage, limits.GlobalQuestionsPerMinute, limits.GlobalTokensPerDay); err != nil {
+		return errors.Wrap(err, "check global limits")
+	}
+
+	stmt = `INSERT INTO ai_usage (user_id, questions) VALUES (?, 1)`
+	if _, err = tx.ExecContext(ctx, stmt, userID); err != nil {
+		return errors.Wrap(err, "insert question")
+	}
+
+	if err = tx.Commit(); err != nil {
+		return errors.Wrap(err, "commit transaction")
+	}
+	return nil
+}
+
+// RecordTokens records the tokens the AI provider reports for a request of the user.
+func (r *QuotaRepository) RecordTokens(ctx context.Context, userID []byte, promptTokens, completionTokens int) error {
+	stmt := `INSERT INTO ai_usage (user_id, prompt_tokens, completion_tokens) VALUES (?, ?, ?)`
+	if _, err := r.database.ReadWrite.ExecContext(ctx, stmt, userID, promptTokens, completionTokens); err != nil {
+		return errors.Wrap(err, "insert tokens")
+	}
+	return nil
+}
+
+// Report summarises the global usage and the usage of the users active within the last day.
+func (r *QuotaRepository) Report(ctx context.Context) (*models.AIUsageReport, error) {
+	var (
+		report models.AIUsageReport
+		err    error
+		rows   *sql.Rows
+	)
+	if report.Global, err = queryUsage(ctx, r.database.ReadOnly, nil); err != nil {
+		return nil, errors.Wrap(err, "query global usage")
+	}
+
+	stmt := `SELECT u.id,
+       u.display_name,
+       COALESCE(SUM(a.questions) FILTER (WHERE a.created >= STRFTIME('%Y-%m-%dT%H:%M:%fZ', 'now', '-1 minute')), 0),
+       SUM(a.questions),
+       SUM(a.prompt_tokens + a.completion_tokens) AS tokens
+FROM ai_usage a
+         JOIN users u ON u.id = a.user_id
+WHERE a.created >= STRFTIME('%Y-%m-%dT%H:%M:%fZ', 'now', '-1 day')
+GROUP BY u.id
+ORDER BY tokens DESC, u.id
+LIMIT 100`
+	if rows, err = r.database.ReadOnly.QueryContext(ctx, stmt); err != nil {
+		return nil, errors.Wrap(err, "query user usage")
+	}
+	defer func() {
+		if err = rows.Close(); err != nil {
+			err = errors.Wrap(err, "close rows")
+			r.logger.Error("could not close rows", errors.SlogError(err))
+		}
+	}()
+	for rows.Next() {
+		var userUsage models.UserAIUsage
+		if err = rows.Scan(
+			&userUsage.UserID,
+			&userUsage.DisplayName,
+			&userUsage.Usage.QuestionsLastMinute,
+			&userUsage.Usage.QuestionsLastDay,
+			&userUsage.Usage.TokensLastDay,
+		); err != nil {
+			return nil, errors.Wrap(err, "scan user usage")
+		}
+		report.Users = append(report.Users, userUsage)
+	}
+	if err = rows.Err(); err != nil {
+		return nil, errors.Wrap(err, "rows error")
+	}
+	return &report, nil
+}
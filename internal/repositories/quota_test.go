@@ -0,0 +1,73 @@
+package repositories_test
+
+import (
+	"context"
+	"github.com/myrjola/sheerluck/internal/repositories"
+	"github.com/myrjola/sheerluck/internal/testhelpers"
+	"github.com/stretchr/testify/require"
+	"io"
+	"testing"
+)
+
+func TestQuotaRepository_RecordQuestion(t *testing.T) {
+	t.Parallel()
+	ctx := context.Background()
+	logger := testhelpers.NewLogger(io.Discard)
+	dbs := newTestDB(t, logger)
+	repo := repositories.NewQuotaRepository(dbs, logger)
+	limits := repositories.QuotaLimits{
+		UserQuestionsPerMinute:   2,
+		UserTokensPerDay:         100,
+		GlobalQuestionsPerMinute: 3,
+		GlobalTokensPerDay:       0,
+	}
+	user1, user2 := []byte{1}, []byte{2}
+
+	require.NoError(t, repo.RecordQuestion(ctx, user1, limits))
+	require.NoError(t, repo.RecordQuestion(ctx, user1, limits))
+	err := repo.RecordQuestion(ctx, user1, limits)
+	require.ErrorIs(t, err, repositories.ErrQuestionRateExceeded, "user limit")
+
+	require.NoError(t, repo.RecordQuestion(ctx, user2, limits))
+	err = repo.RecordQuestion(ctx, user2, limits)
+	require.ErrorIs(t, err, repositories.ErrQuestionRateExceeded, "global limit")
+
+	require.NoError(t, repo.RecordTokens(ctx, user2, 60, 40))
+	limits.GlobalQuestionsPerMinute = 0
+	limits.UserQuestionsPerMinute = 0
+	err = repo.RecordQuestion(ctx, user2, limits)
+	require.ErrorIs(t, err, repositories.ErrTokenQuotaExceeded)
+	require.NoError(t, repo.RecordQuestion(ctx, user1, limits), "other users are not affected")
+
+	report, err := repo.Report(ctx)
+	require.NoError(t, err)
+	require.Equal(t, 4, report.Global.QuestionsLastMinute)
+	require.Equal(t, 4, report.Global.QuestionsLastDay)
+	require.Equal(t, 100, report.Global.TokensLastDay)
+	require.Len(t, report.Users, 2)
+	require.Equal(t, user2, report.Users[0].UserID, "ordered by tokens")
+	require.Equal(t, "Test user 2", report.Users[0].DisplayName)
+	require.Equal(t, 1, report.Users[0].Usage.QuestionsLastDay)
+	require.Equal(t, 100, report.Users[0].Usage.TokensLastDay)
+	require.Equal(t, 3, report.Users[1].Usage.QuestionsLastMinute)
+}
+
+func TestUserRepository_IsAdmin(t *testing.T) {
+	t.Parallel()
+	ctx := context.Background()
+	logger := testhelpers.NewLogger(io.Discard)
+	dbs := newTestDB(t, logger)
+	repo := repositories.NewUserRepository(dbs, logger)
+	_, err := dbs.ReadWrite.ExecContext(ctx, "UPDATE users SET admin = 1 WHERE id = X'02'")
+	require.NoError(t, err)
+
+	isAdmin, err := repo.IsAdmin(ctx, []byte{1})
+	require.NoError(t, err)
+	require.False(t, isAdmin)
+	isAdmin, err = repo.IsAdmin(ctx, []byte{2})
+	require.NoError(t, err)
+	require.True(t, isAdmin)
+	isAdmin, err = repo.IsAdmin(ctx, []byte{3})
+	require.NoError(t, err)
+	require.False(t, isAdmin)
+}
@@ -0,0 +1,123 @@
+package repositories
+
+import (
+	"context"
+	"database/sql"
+	"github.com/myrjola/sheerluck/internal/errors"
+	"github.com/myrjola/sheerluck/internal/models"
+	"github.com/myrjola/sheerluck/internal/sqlite"
+	"log/slog"
+	"time"
+)
+
+type ShareRepository struct {
+	database *sqlite.Database
+	logger   *slog.Logger
+}
+
+func NewShareRepository(dbs *sqlite.Database, logger *slog.Logger) *ShareRepository {
+	return &ShareRepository{
+		database: dbs,
+		logger:   logger.With("source", "ShareRepository"),
+	}
+}
+
+// Create shares the user's solved case and returns the ID of the share. sql.ErrNoRows is returned if the user hasn't
+// made an accusation in the case.
+func (r *ShareRepository) Create(ctx context.Context, caseID string, userID []byte, blurSpoilers bool) (int64, error) {
+	var (
+		shareID int64
+		err     error
+	)
+	stmt := `INSERT INTO shares (user_id, case_id, blur_spoilers)
+SELECT user_id, case_id, ?
+FROM accusations
+WHERE case_id = ?
+  AND user_id = ?
+RETURNING id`
+	if err = r.database.ReadWrite.QueryRowContext(ctx, stmt, blurSpoilers, caseID, userID).Scan(&shareID); err != nil {
+		return 0, errors.Wrap(err, "insert share", slog.String("case_id", caseID))
+	}
+	return shareID, nil
+}
+
+// Get returns the share with the ID and the nonce. sql.ErrNoRows is returned if there's no such share.
+func (r *ShareRepository) Get(ctx context.Context, shareID int64, nonce string) (*models.Share, error) {
+	var (
+		share   models.Share
+		created string
+		err     error
+	)
+	stmt := `SELECT id, nonce, case_id, user_id, blur_spoilers, created FROM shares WHERE id = ? AND nonce = ?`
+	if err = r.database.ReadOnly.QueryRowContext(ctx, stmt, shareID, nonce).Scan(
+		&share.ID,
+		&share.Nonce,
+		&share.CaseID,
+		&share.UserID,
+		&share.BlurSpoilers,
+		&created,
+	); err != nil {
+		return nil, errors.Wrap(err, "query share", slog.Int64("share_id", shareID))
+	}
+	if share.Created, err = time.Parse(time.RFC3339Nano, created); err != nil {
+		return nil, errors.Wrap(err, "parse share time", slog.String("created", created))
+	}
+	return &share, nil
+}
+
+// List lists the user's shares of the case from the oldest to the newest.
+func (r *ShareRepository) List(ctx context.Context, caseID string, userID []byte) ([]models.Share, error) {
+	var (
+		shares []models.Share
+		err    error
+		rows   *sql.Rows
+	)
+	stmt := `SELECT id, nonce, case_id, user_id, blur_spoilers, created
+FROM shares
+WHERE case_id = ?
+  AND user_id = ?
+ORDER BY id`
+	if rows, err = r.database.ReadOnly.QueryContext(ctx, stmt, caseID, userID); err != nil {
+		return nil, errors.Wrap(err, "query shares")
+	}
+	defer func() {
+		if err = rows.Close(); err != nil {
+			err = errors.Wrap(err, "close rows")
+			r.logger.Error("could not close rows", errors.SlogError(err))
+		}
+	}()
+	for rows.Next() {
+		var (
+			share   models.Share
+			created string
+		)
+		if err = rows.Scan(&share.ID, &share.Nonce, &share.CaseID, &share.UserID, &share.BlurSpoilers, &created); err != nil {
+			return nil, errors.Wrap(err, "scan share")
+		}
+		if share.Created, err = time.Parse(time.RFC3339Nano, created); err != nil {
+			return nil, errors.Wrap(err, "parse share time", slog.String("created", created))
+		}
+		shares = append(shares, share)
+	}
+	if err = rows.Err(); err != nil {
+		return nil, errors.Wrap(err, "rows error")
+	}
+	return shares, nil
+}
+
+// Revoke deletes the user's share of the case so that its link stops working. sql.ErrNoRows is returned if there's
+// no such share.
+func (r *ShareRepository) Revoke(ctx context.Context, shareID int64, caseID string, userID []byte) error {
+	var (
+		result sql.Result
+		err    error
+	)
+	stmt := `DELETE FROM shares WHERE id = ? AND case_id = ? AND user_id = ?`
+	if result, err = r.database.ReadWrite.ExecContext(ctx, stmt, shareID, caseID, userID); err != nil {
+		return errors.Wrap(err, "delete share", slog.Int64("share_id", shareID))
+	}
+	if err = requireAffected(result); err != nil {
+		return errors.Wrap(err, "delete share", slog.Int64("share_id", shareID))
+	}
+	return nil
+}
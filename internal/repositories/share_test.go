@@ -0,0 +1,78 @@
+package repositories_test
+
+import (
+	"context"
+	"database/sql"
+	"github.com/myrjola/sheerluck/internal/models"
+	"github.com/myrjola/sheerluck/internal/repositories"
+	"github.com/myrjola/sheerluck/internal/testhelpers"
+	"github.com/stretchr/testify/require"
+	"io"
+	"testing"
+	"time"
+)
+
+func TestShareRepository(t *testing.T) {
+	t.Parallel()
+	ctx := context.Background()
+	logger := testhelpers.NewLogger(io.Discard)
+	dbs := newTestDB(t, logger)
+	repo := repositories.NewShareRepository(dbs, logger)
+	accusations := repositories.NewAccusationRepository(dbs, logger)
+	userID := []byte{1}
+
+	_, err := repo.Create(ctx, "rue-morgue", userID, false)
+	require.ErrorIs(t, err, sql.ErrNoRows, "only solved cases can be shared")
+
+	suspects, err := accusations.ListSuspects(ctx, "rue-morgue")
+	require.NoError(t, err)
+	_, err = accusations.Create(ctx, userID, &models.Accusation{
+		ID:             0,
+		CaseID:         "rue-morgue",
+		Culprit:        suspects[0],
+		Motive:         "Greed.",
+		Clues:          nil,
+		Questions:      0,
+		Hints:          0,
+		CulpritCorrect: false,
+		MotiveCorrect:  false,
+		Score:          0,
+		Created:        time.Time{},
+	})
+	require.NoError(t, err)
+
+	_, err = repo.Create(ctx, "rue-morgue", userID, false)
+	require.NoError(t, err)
+	shareID, err := repo.Create(ctx, "rue-morgue", userID, true)
+	require.NoError(t, err)
+	shares, err := repo.List(ctx, "rue-morgue", userID)
+	require.NoError(t, err)
+	require.Len(t, shares, 2)
+	nonce := shares[1].Nonce
+	require.Len(t, nonce, 32)
+	require.NotEqual(t, shares[0].Nonce, nonce)
+	share, err := repo.Get(ctx, shareID, nonce)
+	require.NoError(t, err)
+	require.Equal(t, shares[1], *share)
+	require.Equal(t, "rue-morgue", share.CaseID)
+	require.Equal(t, userID, share.UserID)
+	require.True(t, share.BlurSpoilers)
+	require.WithinDuration(t, time.Now(), share.Created, time.Minute)
+	_, err = repo.Get(ctx, shareID, shares[0].Nonce)
+	require.ErrorIs(t, err, sql.ErrNoRows, "the nonce must match")
+
+	err = repo.Revoke(ctx, shareID, "rue-morgue", []byte{2})
+	require.ErrorIs(t, err, sql.ErrNoRows, "only the owner can revoke the share")
+	require.NoError(t, repo.Revoke(ctx, shareID, "rue-morgue", userID))
+	_, err = repo.Get(ctx, shareID, nonce)
+	require.ErrorIs(t, err, sql.ErrNoRows)
+	err = repo.Revoke(ctx, shareID, "rue-morgue", userID)
+	require.ErrorIs(t, err, sql.ErrNoRows)
+
+	// SQLite reuses the ID of the revoked share because it was the largest, but its old link must not work.
+	newShareID, err := repo.Create(ctx, "rue-morgue", userID, false)
+	require.NoError(t, err)
+	require.Equal(t, shareID, newShareID)
+	_, err = repo.Get(ctx, newShareID, nonce)
+	require.ErrorIs(t, err, sql.ErrNoRows, "the revoked link stays revoked")
+}
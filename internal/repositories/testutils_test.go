@@ -4,6 +4,7 @@ import (
 	"context"
 	_ "embed"
 	"fmt"
+	"github.com/myrjola/sheerluck/internal/casebundle"
 	"github.com/myrjola/sheerluck/internal/sqlite"
 	"log/slog"
 	"os"
@@ -13,6 +14,21 @@ import (
 //go:embed testdata/fixtures.sql
 var testFixtures string
 
+// importCases imports the case bundles of the game.
+func importCases(tb testing.TB, dbs *sqlite.Database, logger *slog.Logger) {
+	tb.Helper()
+	bundles, err := casebundle.LoadAll(os.DirFS("../../ui/cases"))
+	if err != nil {
+		tb.Fatal(err)
+	}
+	importer := casebundle.NewImporter(dbs, logger)
+	for _, bundle := range bundles {
+		if _, err = importer.Import(context.Background(), bundle, casebundle.ImportOptions{Prune: false, DryRun: false}); err != nil {
+			tb.Fatal(err)
+		}
+	}
+}
+
 // newTestDB creates a new in-memory database for testing purposes.
 func newTestDB(t *testing.T, logger *slog.Logger) *sqlite.Database {
 	var (
@@ -25,6 +41,8 @@ func newTestDB(t *testing.T, logger *slog.Logger) *sqlite.Database {
 		t.Fatal(err)
 	}
 
+	importCases(t, database, logger)
+
 	// Add test data
 	if _, err = database.ReadWrite.Exec(testFixtures); err != nil {
 		t.Fatal(err)
@@ -55,6 +73,7 @@ func newBenchmarkDB(b *testing.B, logger *slog.Logger) *sqlite.Database {
 	if dbs, err = sqlite.NewDatabase(context.Background(), benchmarkDBPath, logger); err != nil {
 		b.Fatal(err)
 	}
+	importCases(b, dbs, logger)
 
 	b.Cleanup(func() {
 		if err = dbs.ReadWrite.Close(); err != nil {
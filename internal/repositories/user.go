@@ -0,0 +1,31 @@
+package repositories
+
+import (
+	"context"
+	"github.com/myrjola/sheerluck/internal/errors"
+	"github.com/myrjola/sheerluck/internal/sqlite"
+	"log/slog"
+)
+
+// UserRepository reads the users' application-specific data. The WebAuthn handler manages their credentials.
+type UserRepository struct {
+	database *sqlite.Database
+	logger   *slog.Logger
+}
+
+func NewUserRepository(dbs *sqlite.Database, logger *slog.Logger) *UserRepository {
+	return &UserRepository{
+		database: dbs,
+		logger:   logger.With("source", "UserRepository"),
+	}
+}
+
+// IsAdmin reports whether the user is an admin. Unknown users are not.
+func (r *UserRepository) IsAdmin(ctx context.Context, userID []byte) (bool, error) {
+	var isAdmin bool
+	stmt := `SELECT EXISTS(SELECT 1 FROM users WHERE id = ? AND admin = 1)`
+	if err := r.database.ReadOnly.QueryRowContext(ctx, stmt, userID).Scan(&isAdmin); err != nil {
+		return false, errors.Wrap(err, "query admin")
+	}
+	return isAdmin, nil
+}
@@ -0,0 +1,89 @@
+// Package scoring grades the players' accusations against the case solutions.
+package scoring
+
+import (
+	"github.com/myrjola/sheerluck/internal/discovery"
+	"github.com/myrjola/sheerluck/internal/models"
+	"regexp"
+	"strings"
+)
+
+const (
+	// CulpritPoints are awarded for naming the culprit.
+	CulpritPoints = 50
+	// MotivePoints are awarded for explaining the motive.
+	MotivePoints = 20
+	// CluePoints are shared between the clues supporting the solution.
+	CluePoints = 30
+	// WrongCluePenalty is deducted for each presented clue not supporting the solution.
+	WrongCluePenalty = 5
+	// FreeQuestions is the number of questions that can be asked without a penalty.
+	FreeQuestions = 20
+	// MaxQuestionPenalty caps the penalty of one point per question exceeding FreeQuestions.
+	MaxQuestionPenalty = 20
+	// HintPenalty is deducted for each hint taken from Dupin.
+	HintPenalty = 5
+)
+
+// Grade grades the accusation against the solution by setting the correctness of the accusation and its score.
+//
+// The score rewards a correct culprit, motive and supporting clues, and penalises presenting irrelevant clues, asking
+// many questions, and taking hints. It's never negative.
+//
+// The motive is correct if it mentions the solution's motive and the wrong motives don't outnumber it, so that
+// listing every plausible motive doesn't pass.
+func Grade(solution *models.CaseSolution, accusation *models.Accusation) {
+	accusation.CulpritCorrect = accusation.Culprit.ID == solution.Culprit.ID
+	right, wrong := countMotives(accusation.Motive, solution)
+	accusation.MotiveCorrect = right > 0 && wrong <= right
+
+	supporting := make(map[string]bool, len(solution.Clues))
+	for _, clue := range solution.Clues {
+		supporting[clue.ID] = true
+	}
+	var correctClues, wrongClues int
+	for i := range accusation.Clues {
+		accusation.Clues[i].Correct = supporting[accusation.Clues[i].Clue.ID]
+		if accusation.Clues[i].Correct {
+			correctClues++
+		} else {
+			wrongClues++
+		}
+	}
+
+	score := 0
+	if accusation.CulpritCorrect {
+		score += CulpritPoints
+	}
+	if accusation.MotiveCorrect {
+		score += MotivePoints
+	}
+	if len(solution.Clues) > 0 {
+		score += CluePoints * correctClues / len(solution.Clues)
+	}
+	score -= WrongCluePenalty * wrongClues
+	score -= min(max(accusation.Questions-FreeQuestions, 0), MaxQuestionPenalty)
+	score -= HintPenalty * accusation.Hints
+	accusation.Score = max(score, 0)
+}
+
+// clauseSeparator splits the motive into clauses.
+var clauseSeparator = regexp.MustCompile(`[.,;:!?\n]`) //nolint:gochecknoglobals // compiled once
+
+// negation matches the words that rule out the wrong motives mentioned in the same clause, e.g., "it wasn't a robbery".
+var negation = regexp.MustCompile( //nolint:gochecknoglobals // compiled once
+	`\b(no|not|never|nor|neither|without|\w+n['’]t)\b`,
+)
+
+// countMotives counts the keywords of the solution's motive and of the wrong motives in the accused motive. The wrong
+// motives in negated clauses don't count, because ruling them out is how the detective reasons.
+func countMotives(motive string, solution *models.CaseSolution) (int, int) {
+	var right, wrong int
+	for _, clause := range clauseSeparator.Split(strings.ToLower(motive), -1) {
+		right += discovery.CountKeywords(clause, solution.MotiveKeywords)
+		if !negation.MatchString(clause) {
+			wrong += discovery.CountKeywords(clause, solution.WrongMotiveKeywords)
+		}
+	}
+	return right, wrong
+}
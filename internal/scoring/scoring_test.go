@@ -0,0 +1,203 @@
+package scoring_test
+
+import (
+	"github.com/myrjola/sheerluck/internal/models"
+	"github.com/myrjola/sheerluck/internal/scoring"
+	"github.com/stretchr/testify/require"
+	"testing"
+	"time"
+)
+
+func newSuspect(id string) models.Suspect {
+	return models.Suspect{ID: id, Name: id, Description: ""}
+}
+
+func newClue(id string) models.Clue {
+	return models.Clue{ID: id, Description: id, Keywords: nil, RequiresEvidence: nil, PointOfInterest: nil}
+}
+
+func newAccusedClues(ids ...string) []models.AccusedClue {
+	clues := make([]models.AccusedClue, 0, len(ids))
+	for _, id := range ids {
+		clues = append(clues, models.AccusedClue{Clue: newClue(id), Correct: false})
+	}
+	return clues
+}
+
+func TestGrade(t *testing.T) {
+	t.Parallel()
+	solution := &models.CaseSolution{
+		CaseID:              "rue-morgue",
+		Culprit:             newSuspect("ourang-outang"),
+		Motive:              "None.",
+		MotiveKeywords:      []string{"no-motive", "panic", "frenzy", "frighten"},
+		WrongMotiveKeywords: []string{"greed", "revenge", "rob", "money", "gold"},
+		Clues:               []models.Clue{newClue("hair"), newClue("window")},
+		Explanation:         "",
+	}
+	tests := []struct {
+		name           string
+		culpritID      string
+		motive         string
+		clueIDs        []string
+		questions      int
+		hints          int
+		wantCulprit    bool
+		wantMotive     bool
+		wantCorrectIDs []string
+		wantScore      int
+	}{
+		{
+			name:           "perfect",
+			culpritID:      "ourang-outang",
+			motive:         "It panicked when the ladies screamed.",
+			clueIDs:        []string{"hair", "window"},
+			questions:      scoring.FreeQuestions,
+			hints:          0,
+			wantCulprit:    true,
+			wantMotive:     true,
+			wantCorrectIDs: []string{"hair", "window"},
+			wantScore:      scoring.CulpritPoints + scoring.MotivePoints + scoring.CluePoints,
+		},
+		{
+			name:           "partial clues and too many questions",
+			culpritID:      "ourang-outang",
+			motive:         "There was no motive!",
+			clueIDs:        []string{"hair", "gold"},
+			questions:      scoring.FreeQuestions + 3,
+			hints:          0,
+			wantCulprit:    true,
+			wantMotive:     true,
+			wantCorrectIDs: []string{"hair"},
+			wantScore: scoring.CulpritPoints + scoring.MotivePoints + scoring.CluePoints/2 -
+				scoring.WrongCluePenalty - 3,
+		},
+		{
+			name:           "hints",
+			culpritID:      "ourang-outang",
+			motive:         "It panicked.",
+			clueIDs:        []string{"hair", "window"},
+			questions:      0,
+			hints:          2,
+			wantCulprit:    true,
+			wantMotive:     true,
+			wantCorrectIDs: []string{"hair", "window"},
+			wantScore: scoring.CulpritPoints + scoring.MotivePoints + scoring.CluePoints -
+				2*scoring.HintPenalty,
+		},
+		{
+			name:           "every plausible motive",
+			culpritID:      "ourang-outang",
+			motive:         "Greed, revenge, or panic.",
+			clueIDs:        nil,
+			questions:      0,
+			hints:          0,
+			wantCulprit:    true,
+			wantMotive:     false,
+			wantCorrectIDs: nil,
+			wantScore:      scoring.CulpritPoints,
+		},
+		{
+			name:           "wrong motive",
+			culpritID:      "ourang-outang",
+			motive:         "It was greed and revenge, not panic.",
+			clueIDs:        nil,
+			questions:      0,
+			hints:          0,
+			wantCulprit:    true,
+			wantMotive:     false,
+			wantCorrectIDs: nil,
+			wantScore:      scoring.CulpritPoints,
+		},
+		{
+			name:           "negated wrong motive",
+			culpritID:      "ourang-outang",
+			motive:         "It wasn't a robbery. The frightened beast attacked in a frenzy.",
+			clueIDs:        nil,
+			questions:      0,
+			hints:          0,
+			wantCulprit:    true,
+			wantMotive:     true,
+			wantCorrectIDs: nil,
+			wantScore:      scoring.CulpritPoints + scoring.MotivePoints,
+		},
+		{
+			name:           "wrong motives ruled out",
+			culpritID:      "ourang-outang",
+			motive:         "Not money: the gold was left untouched. The ape panicked.",
+			clueIDs:        nil,
+			questions:      0,
+			hints:          0,
+			wantCulprit:    true,
+			wantMotive:     true,
+			wantCorrectIDs: nil,
+			wantScore:      scoring.CulpritPoints + scoring.MotivePoints,
+		},
+		{
+			name:      "the explanation of the solution",
+			culpritID: "ourang-outang",
+			motive: "When Madame L'Espanaye screamed, it flew into a frenzy. The gold was left untouched because no " +
+				"robber was ever there.",
+			clueIDs:        nil,
+			questions:      0,
+			hints:          0,
+			wantCulprit:    true,
+			wantMotive:     true,
+			wantCorrectIDs: nil,
+			wantScore:      scoring.CulpritPoints + scoring.MotivePoints,
+		},
+		{
+			name:           "wrong culprit",
+			culpritID:      "le-bon",
+			motive:         "Greed.",
+			clueIDs:        nil,
+			questions:      0,
+			hints:          0,
+			wantCulprit:    false,
+			wantMotive:     false,
+			wantCorrectIDs: nil,
+			wantScore:      0,
+		},
+		{
+			name:           "score is never negative",
+			culpritID:      "le-bon",
+			motive:         "Greed.",
+			clueIDs:        []string{"gold", "watch"},
+			questions:      1000,
+			hints:          0,
+			wantCulprit:    false,
+			wantMotive:     false,
+			wantCorrectIDs: nil,
+			wantScore:      0,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			t.Parallel()
+			accusation := &models.Accusation{
+				ID:             0,
+				CaseID:         "rue-morgue",
+				Culprit:        newSuspect(tt.culpritID),
+				Motive:         tt.motive,
+				Clues:          newAccusedClues(tt.clueIDs...),
+				Questions:      tt.questions,
+				Hints:          tt.hints,
+				CulpritCorrect: false,
+				MotiveCorrect:  false,
+				Score:          0,
+				Created:        time.Time{},
+			}
+			scoring.Grade(solution, accusation)
+			require.Equal(t, tt.wantCulprit, accusation.CulpritCorrect)
+			require.Equal(t, tt.wantMotive, accusation.MotiveCorrect)
+			var correctIDs []string
+			for _, clue := range accusation.Clues {
+				if clue.Correct {
+					correctIDs = append(correctIDs, clue.Clue.ID)
+				}
+			}
+			require.Equal(t, tt.wantCorrectIDs, correctIDs)
+			require.Equal(t, tt.wantScore, accusation.Score)
+		})
+	}
+}
@@ -0,0 +1,66 @@
+// Package sharetoken signs the tokens of the links sharing an investigation with people without an account.
+//
+// A token is the ID and the nonce of the share and their HMAC-SHA256 signature, e.g., "42.9f86d0...Xq3...". The
+// signature keeps the links from being guessed although the IDs are sequential. The share itself is stored in the
+// database so that it can be revoked. The nonce keeps a revoked link from working again when the database reuses the
+// ID for another share.
+package sharetoken
+
+import (
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/base64"
+	"github.com/myrjola/sheerluck/internal/errors"
+	"strconv"
+	"strings"
+)
+
+// signatureLength is the number of bytes of the HMAC kept in the token. 128 bits are plenty against guessing.
+const signatureLength = 16
+
+// ErrInvalidToken is returned when a token is malformed or its signature doesn't match.
+var ErrInvalidToken = errors.NewSentinel("invalid share token")
+
+// Signer signs and verifies share tokens with a secret key.
+type Signer struct {
+	key []byte
+}
+
+// NewSigner creates a Signer with the secret key. The tokens signed with another key are invalid.
+func NewSigner(key []byte) *Signer {
+	return &Signer{key: key}
+}
+
+func (s *Signer) signature(payload string) string {
+	mac := hmac.New(sha256.New, s.key)
+	_, _ = mac.Write([]byte(payload)) // Writing to a hash never fails.
+	return base64.RawURLEncoding.EncodeToString(mac.Sum(nil)[:signatureLength])
+}
+
+// Sign returns the token of the share with the nonce.
+func (s *Signer) Sign(shareID int64, nonce string) string {
+	payload := strconv.FormatInt(shareID, 10) + "." + nonce
+	return payload + "." + s.signature(payload)
+}
+
+// Verify returns the ID and the nonce of the share the token was signed for. ErrInvalidToken is returned if the token
+// wasn't signed with the Signer's key.
+func (s *Signer) Verify(token string) (int64, string, error) {
+	i := strings.LastIndexByte(token, '.')
+	if i < 0 {
+		return 0, "", ErrInvalidToken
+	}
+	payload, signature := token[:i], token[i+1:]
+	if !hmac.Equal([]byte(signature), []byte(s.signature(payload))) {
+		return 0, "", ErrInvalidToken
+	}
+	id, nonce, ok := strings.Cut(payload, ".")
+	if !ok {
+		return 0, "", ErrInvalidToken
+	}
+	shareID, err := strconv.ParseInt(id, 10, 64)
+	if err != nil {
+		return 0, "", errors.Wrap(ErrInvalidToken, "parse share ID")
+	}
+	return shareID, nonce, nil
+}
@@ -0,0 +1,46 @@
+package sharetoken_test
+
+import (
+	"github.com/myrjola/sheerluck/internal/sharetoken"
+	"github.com/stretchr/testify/require"
+	"testing"
+)
+
+func TestSigner(t *testing.T) {
+	t.Parallel()
+	signer := sharetoken.NewSigner([]byte("secret"))
+	token := signer.Sign(42, "abc")
+	require.Regexp(t, `^42\.abc\.[A-Za-z0-9_-]{22}$`, token)
+	shareID, nonce, err := signer.Verify(token)
+	require.NoError(t, err)
+	require.Equal(t, int64(42), shareID)
+	require.Equal(t, "abc", nonce)
+
+	tests := []struct {
+		name  string
+		token string
+	}{
+		{name: "empty", token: ""},
+		{name: "no signature", token: "42.abc"},
+		{name: "no nonce", token: "42." + token[len("42.abc."):]},
+		{name: "another ID", token: "43" + token[2:]},
+		{name: "another nonce", token: "42.abd" + token[len("42.abc"):]},
+		{name: "tampered signature", token: token[:7] + tampered(token[7]) + token[8:]},
+		{name: "another key", token: sharetoken.NewSigner([]byte("another secret")).Sign(42, "abc")},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			t.Parallel()
+			_, _, err := signer.Verify(tt.token)
+			require.ErrorIs(t, err, sharetoken.ErrInvalidToken)
+		})
+	}
+}
+
+// tampered returns another base64url character.
+func tampered(c byte) string {
+	if c == 'A' {
+		return "B"
+	}
+	return "A"
+}
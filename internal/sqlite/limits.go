@@ -0,0 +1,32 @@
+package sqlite
+
+import (
+	"regexp"
+	"strconv"
+)
+
+var (
+	tableDefinitionRegexp = regexp.MustCompile(`(?s)CREATE TABLE (\w+)\s*\((.*?)\)\s*(?:WITHOUT ROWID, )?STRICT;`)
+	lengthCheckRegexp     = regexp.MustCompile(`(?m)^\s*"?(\w+)"?\s.*CHECK \(length\((\w+)\) < (\d+)\)`)
+)
+
+// LengthLimits returns the maximum lengths of the columns constrained with a `CHECK (length(column) < N)` in the
+// schema keyed by table and column. SQLite counts the length of text in characters.
+//
+// Validating content against the limits catches values that SQLite would reject before they reach the database.
+func LengthLimits() map[string]map[string]int {
+	limits := make(map[string]map[string]int)
+	for _, table := range tableDefinitionRegexp.FindAllStringSubmatch(schemaDefinition, -1) {
+		columns := make(map[string]int)
+		for _, check := range lengthCheckRegexp.FindAllStringSubmatch(table[2], -1) {
+			if check[1] != check[2] {
+				continue
+			}
+			// The regexp guarantees a number.
+			limit, _ := strconv.Atoi(check[3]) //nolint:errcheck // see above
+			columns[check[1]] = limit - 1
+		}
+		limits[table[1]] = columns
+	}
+	return limits
+}
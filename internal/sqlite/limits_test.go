@@ -0,0 +1,18 @@
+package sqlite_test
+
+import (
+	"github.com/myrjola/sheerluck/internal/sqlite"
+	"github.com/stretchr/testify/require"
+	"testing"
+)
+
+func TestLengthLimits(t *testing.T) {
+	t.Parallel()
+	limits := sqlite.LengthLimits()
+	require.Equal(t, 255, limits["cases"]["name"])
+	require.Equal(t, 2047, limits["cases"]["setting"])
+	require.Equal(t, 1023, limits["clues"]["description"])
+	require.Equal(t, 255, limits["clues"]["keywords"])
+	require.Equal(t, 1023, limits["completions"]["question"])
+	require.NotContains(t, limits["cases"], "play_time_minutes")
+}
@@ -22,8 +22,11 @@ import (
 // 3. Migrates changed tables using 12-step schema migration https://www.sqlite.org/lang_altertable.html#otheralter,
 // 4. Synchronises triggers and indexes.
 //
+// The backfills are keyed by table and column, e.g., "completions.parent_id". A backfill runs after its column is added
+// to an existing table, so it runs only once.
+//
 // Inspired by https://david.rothlis.net/declarative-schema-migration-for-sqlite/
-func (db *Database) migrateTo(ctx context.Context, schemaDefinition string) error {
+func (db *Database) migrateTo(ctx context.Context, schemaDefinition string, backfills map[string]string) error {
 	var err error
 	// 12-step schema migration starts here. See https://www.sqlite.org/lang_altertable.html#otheralter.
 	start := time.Now()
@@ -58,7 +61,7 @@ func (db *Database) migrateTo(ctx context.Context, schemaDefinition string) erro
 	defer db.rollback(ctx, tx)()
 
 	// Step 3-7 migrate tables.
-	if err = db.migrateTables(ctx, tx); err != nil {
+	if err = db.migrateTables(ctx, tx, backfills); err != nil {
 		return errors.Wrap(err, "migrate tables")
 	}
 
@@ -136,8 +139,8 @@ func (db *Database) rollback(ctx context.Context, tx *sql.Tx) func() {
 	}
 }
 
-// migrateTables ensures table schema is synchronized between databases.
-func (db *Database) migrateTables(ctx context.Context, tx *sql.Tx) error {
+// migrateTables ensures table schema is synchronized between databases and backfills the added columns.
+func (db *Database) migrateTables(ctx context.Context, tx *sql.Tx, backfills map[string]string) error {
 	// Step 3: Remember schema (also includes trivial creation and deletion of tables).
 	var err error
 
@@ -197,6 +200,10 @@ WHERE live.type = 'table'
 		}
 
 		// Step 5: Copy common columns between tables.
+		var addedColumns []string
+		if addedColumns, err = db.queryAddedColumns(ctx, tx, table.name); err != nil {
+			return errors.Wrap(err, "query added columns")
+		}
 		var commonColumns []string
 		if commonColumns, err = db.queryCommonColumns(ctx, tx, table.name); err != nil {
 			return errors.Wrap(err, "query common columns")
@@ -222,6 +229,18 @@ WHERE live.type = 'table'
 		if _, err = tx.ExecContext(ctx, renameSQL); err != nil {
 			return errors.Wrap(err, "rename new table")
 		}
+
+		// Fill the added columns of the existing rows.
+		for _, column := range addedColumns {
+			backfillSQL, ok := backfills[table.name+"."+column]
+			if !ok {
+				continue
+			}
+			db.logger.LogAttrs(ctx, slog.LevelInfo, "backfilling column", slog.String("query", backfillSQL))
+			if _, err = tx.ExecContext(ctx, backfillSQL); err != nil {
+				return errors.Wrap(err, "backfill column", slog.String("table", table.name), slog.String("column", column))
+			}
+		}
 	}
 	return nil
 }
@@ -278,6 +297,21 @@ JOIN PRAGMA_TABLE_INFO(:table_name, 'schemaTarget') AS target ON target.name = l
 	return commonColumns, nil
 }
 
+// queryAddedColumns returns the columns of the table that are present in the target schema but not in the live schema.
+func (db *Database) queryAddedColumns(ctx context.Context, tx *sql.Tx, table string) ([]string, error) {
+	var (
+		addedColumns []string
+		err          error
+	)
+	if addedColumns, err = db.queryStringSlice(ctx, tx, `SELECT target.name
+FROM PRAGMA_TABLE_INFO(:table_name, 'schemaTarget') AS target
+WHERE target.name NOT IN (SELECT live.name FROM PRAGMA_TABLE_INFO(:table_name) AS live)`,
+		sql.Named("table_name", table)); err != nil {
+		return nil, errors.Wrap(err, "query string slice")
+	}
+	return addedColumns, nil
+}
+
 // queryStringSlice returns a slice of strings from a query and its args.
 //
 // It is used to query a single column from a table.
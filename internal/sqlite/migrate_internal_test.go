@@ -126,7 +126,7 @@ func TestDatabase_migrate(t *testing.T) {
 			require.NoError(t, err)
 			for _, schemaDefinition := range tt.schemaDefinitions {
 				logger.LogAttrs(ctx, slog.LevelInfo, "migrating", slog.String("schema", schemaDefinition))
-				err = db.migrateTo(ctx, schemaDefinition)
+				err = db.migrateTo(ctx, schemaDefinition, nil)
 				require.NoError(t, err)
 			}
 			for _, query := range tt.testQueries {
@@ -141,3 +141,32 @@ func TestDatabase_migrate(t *testing.T) {
 		})
 	}
 }
+
+func TestDatabase_migrateBackfill(t *testing.T) {
+	t.Parallel()
+	ctx := context.Background()
+	logger := testhelpers.NewLogger(io.Discard)
+	db, err := connect(":memory:", logger)
+	require.NoError(t, err)
+	backfills := map[string]string{"test.name": "UPDATE test SET name = 'backfilled'"}
+	queryNames := func() string {
+		var names string
+		row := db.ReadWrite.QueryRowContext(ctx, "SELECT group_concat(name, ',') FROM (SELECT name FROM test ORDER BY id)")
+		require.NoError(t, row.Scan(&names))
+		return names
+	}
+
+	require.NoError(t, db.migrateTo(ctx, "CREATE TABLE test (id INTEGER PRIMARY KEY)", backfills))
+	_, err = db.ReadWrite.ExecContext(ctx, "INSERT INTO test (id) VALUES (1)")
+	require.NoError(t, err)
+
+	// The backfill fills the column when it's added.
+	require.NoError(t, db.migrateTo(ctx, "CREATE TABLE test (id INTEGER PRIMARY KEY, name TEXT)", backfills))
+	_, err = db.ReadWrite.ExecContext(ctx, "INSERT INTO test (id, name) VALUES (2, 'inserted')")
+	require.NoError(t, err)
+	require.Equal(t, "backfilled,inserted", queryNames())
+
+	// Later migrations of the table don't run the backfill again.
+	require.NoError(t, db.migrateTo(ctx, "CREATE TABLE test (id INTEGER PRIMARY KEY, name TEXT, age INTEGER)", backfills))
+	require.Equal(t, "backfilled,inserted", queryNames())
+}
@@ -11,6 +11,8 @@ CREATE TABLE users
 (
     id           BLOB PRIMARY KEY CHECK (length(id) < 256),
     display_name TEXT NOT NULL CHECK (length(display_name) < 64),
+    -- Admins can view operational data such as the AI usage. Granted manually in the database.
+    admin        INTEGER NOT NULL DEFAULT 0 CHECK (admin IN (0, 1)),
 
     created      TEXT NOT NULL DEFAULT (STRFTIME('%Y-%m-%dT%H:%M:%fZ')) CHECK (length(created) < 256),
     updated      TEXT NOT NULL DEFAULT (STRFTIME('%Y-%m-%dT%H:%M:%fZ')) CHECK (length(updated) < 256)
@@ -53,21 +55,60 @@ END;
 
 CREATE TABLE cases
 (
-    id         TEXT PRIMARY KEY CHECK (length(id) < 256),
-    name       TEXT NOT NULL UNIQUE CHECK (length(name) < 256),
-    author     TEXT NOT NULL CHECK (length(author) < 256),
-    image_path TEXT NOT NULL CHECK (length(image_path) < 256)
+    id                TEXT PRIMARY KEY CHECK (length(id) < 256),
+    name              TEXT    NOT NULL UNIQUE CHECK (length(name) < 256),
+    author            TEXT    NOT NULL CHECK (length(author) < 256),
+    image_path        TEXT    NOT NULL CHECK (length(image_path) < 256),
+    -- Background shared with every investigation target of the case when prompting the AI.
+    setting           TEXT    NOT NULL DEFAULT '' CHECK (length(setting) < 2048),
+    -- Catalogue metadata shown on the home page.
+    difficulty        TEXT    NOT NULL DEFAULT 'medium' CHECK (difficulty IN ('easy', 'medium', 'hard')),
+    play_time_minutes INTEGER NOT NULL DEFAULT 30 CHECK (play_time_minutes > 0),
+    blurb             TEXT    NOT NULL DEFAULT '' CHECK (length(blurb) < 512)
+) WITHOUT ROWID, STRICT;
+
+-- The acts of a case. The investigation targets of a chapter are locked until the player discovers the clues
+-- unlocking it.
+CREATE TABLE chapters
+(
+    id       TEXT PRIMARY KEY CHECK (length(id) < 256),
+    name     TEXT    NOT NULL CHECK (length(name) < 256),
+    -- Orders the chapters of a case as declared in the case bundle.
+    position INTEGER NOT NULL DEFAULT 0,
+
+    case_id  TEXT    NOT NULL REFERENCES cases (id) ON DELETE CASCADE
 ) WITHOUT ROWID, STRICT;
 
 CREATE TABLE investigation_targets
 (
-    id         TEXT PRIMARY KEY CHECK (length(id) < 256),
-    name       TEXT                                       NOT NULL UNIQUE CHECK (length(name) < 256),
-    short_name TEXT                                       NOT NULL CHECK (length(short_name) < 256),
-    type       TEXT CHECK ( type IN ('person', 'scene') ) NOT NULL CHECK (length(type) < 256),
-    image_path TEXT                                       NOT NULL CHECK (length(image_path) < 256),
+    id          TEXT PRIMARY KEY CHECK (length(id) < 256),
+    name        TEXT NOT NULL UNIQUE CHECK (length(name) < 256),
+    short_name  TEXT NOT NULL CHECK (length(short_name) < 256),
+    type        TEXT NOT NULL CHECK ( type IN ('person', 'scene', 'item', 'document') ) CHECK (length(type) < 256),
+    image_path  TEXT NOT NULL CHECK (length(image_path) < 256),
+    -- Who the person is or what the scene, the item, or the document looks like.
+    description TEXT NOT NULL DEFAULT '' CHECK (length(description) < 2048),
+    -- What the person is hiding or what is not obvious at first glance.
+    secret            TEXT    NOT NULL DEFAULT '' CHECK (length(secret) < 2048),
+    -- In the clock game mode, the in-game minutes from the start of the investigation after which the target can no
+    -- longer be investigated. Zero keeps the target available.
+    available_minutes INTEGER NOT NULL DEFAULT 0 CHECK (available_minutes >= 0),
+
+    case_id           TEXT    NOT NULL REFERENCES cases (id) ON DELETE CASCADE,
+    -- The chapter the target is introduced in or NULL if the target is available from the start.
+    chapter_id        TEXT REFERENCES chapters (id) ON DELETE SET NULL
+) WITHOUT ROWID, STRICT;
+
+CREATE TABLE points_of_interest
+(
+    id                      TEXT PRIMARY KEY CHECK (length(id) < 256),
+    -- Completes "Examine ...", e.g., "the hearth".
+    name                    TEXT    NOT NULL CHECK (length(name) < 256),
+    description             TEXT    NOT NULL DEFAULT '' CHECK (length(description) < 1024),
+    -- Orders the points of interest of a scene as declared in the case bundle.
+    position                INTEGER NOT NULL DEFAULT 0,
 
-    case_id    TEXT                                       NOT NULL REFERENCES cases (id) ON DELETE CASCADE
+    investigation_target_id TEXT    NOT NULL REFERENCES investigation_targets (id) ON DELETE CASCADE
 ) WITHOUT ROWID, STRICT;
 
 CREATE TABLE clues
@@ -76,7 +117,70 @@ CREATE TABLE clues
     description             TEXT NOT NULL CHECK (length(description) < 1024),
     keywords                TEXT NOT NULL CHECK (length(keywords) < 256),
 
-    investigation_target_id TEXT NOT NULL REFERENCES investigation_targets (id) ON DELETE CASCADE
+    investigation_target_id TEXT NOT NULL REFERENCES investigation_targets (id) ON DELETE CASCADE,
+    -- The clue the player has to present to the investigation target before it reveals this clue. Deferred so that
+    -- the clues of a case can be imported in any order.
+    requires_evidence_id    TEXT REFERENCES clues (id) ON DELETE SET NULL DEFERRABLE INITIALLY DEFERRED,
+    -- The point of interest of a scene the player has to examine before the scene reveals this clue.
+    point_of_interest_id    TEXT REFERENCES points_of_interest (id) ON DELETE SET NULL
+) WITHOUT ROWID, STRICT;
+
+-- Graduated hints authored for a clue. Dupin writes the levels without an authored hint himself.
+CREATE TABLE clue_hints
+(
+    clue_id TEXT    NOT NULL REFERENCES clues (id) ON DELETE CASCADE,
+    -- 1 is a vague nudge, 2 names the investigation target to question, and 3 the topic to raise.
+    level   INTEGER NOT NULL CHECK (level BETWEEN 1 AND 3),
+    text    TEXT    NOT NULL CHECK (length(text) < 1024),
+
+    PRIMARY KEY (clue_id, level)
+) WITHOUT ROWID, STRICT;
+
+CREATE TABLE passages
+(
+    investigation_target_id TEXT    NOT NULL REFERENCES investigation_targets (id) ON DELETE CASCADE,
+    -- Orders the passages of a document as declared in the case bundle.
+    position                INTEGER NOT NULL,
+    text                    TEXT    NOT NULL CHECK (length(text) < 4096),
+    -- The clue unredacting the passage when the player discovers it or NULL if the passage isn't redacted.
+    redacted_until_clue_id  TEXT REFERENCES clues (id) ON DELETE SET NULL,
+
+    PRIMARY KEY (investigation_target_id, position)
+) WITHOUT ROWID, STRICT;
+
+-- The clues the player has to discover to unlock a chapter. A chapter without prerequisites is unlocked from the
+-- start.
+CREATE TABLE chapter_prerequisites
+(
+    chapter_id TEXT NOT NULL REFERENCES chapters (id) ON DELETE CASCADE,
+    clue_id    TEXT NOT NULL REFERENCES clues (id) ON DELETE CASCADE,
+    PRIMARY KEY (chapter_id, clue_id)
+) WITHOUT ROWID, STRICT;
+
+-- Players investigating a case together. The members see each other's interrogations and discoveries while keeping
+-- their own history and score.
+CREATE TABLE parties
+(
+    id           INTEGER PRIMARY KEY,
+    case_id      TEXT NOT NULL REFERENCES cases (id) ON DELETE CASCADE,
+    -- The player who created the party. The party is disbanded when the owner leaves.
+    owner_id     BLOB NOT NULL REFERENCES users (id) ON DELETE CASCADE,
+    -- The secret of the invite link.
+    invite_token TEXT NOT NULL UNIQUE CHECK (length(invite_token) < 256),
+
+    created      TEXT NOT NULL DEFAULT (STRFTIME('%Y-%m-%dT%H:%M:%fZ')) CHECK (length(created) < 256)
+) STRICT;
+
+CREATE TABLE party_members
+(
+    party_id INTEGER NOT NULL REFERENCES parties (id) ON DELETE CASCADE,
+    user_id  BLOB    NOT NULL REFERENCES users (id) ON DELETE CASCADE,
+    -- The case of the party so that a player can be a member of only one party per case.
+    case_id  TEXT    NOT NULL REFERENCES cases (id) ON DELETE CASCADE,
+
+    joined   TEXT    NOT NULL DEFAULT (STRFTIME('%Y-%m-%dT%H:%M:%fZ')) CHECK (length(joined) < 256),
+    PRIMARY KEY (party_id, user_id),
+    UNIQUE (user_id, case_id)
 ) WITHOUT ROWID, STRICT;
 
 CREATE TABLE completions
@@ -84,9 +188,251 @@ CREATE TABLE completions
     id                      INTEGER PRIMARY KEY,
     "order"                 INTEGER NOT NULL,
     question                TEXT    NOT NULL CHECK (length(question) < 1024),
-    answer                  TEXT    NOT NULL CHECK (length(answer) < 2056),
+    answer                  TEXT    NOT NULL DEFAULT '' CHECK (length(answer) < 2056),
+    -- Lifecycle modelled in spec/completion.tla. Completions predating the lifecycle are done.
+    status                  TEXT    NOT NULL DEFAULT 'done' CHECK (status IN ('created', 'streaming', 'done', 'error')),
+    -- Identifies the prompt templates used for the answer so that playtests can be compared across prompt changes.
+    prompt_version          TEXT    NOT NULL DEFAULT '' CHECK (length(prompt_version) < 256),
+    -- Rolling summary of the history up to and including this completion for fitting long interrogations into the
+    -- context window of the AI. Empty if the history hasn't been summarised at this completion.
+    summary                 TEXT    NOT NULL DEFAULT '' CHECK (length(summary) < 4096),
+    -- Regenerated answers and edited questions branch the history into a tree through parent_id. The active
+    -- completions form the branch the player sees and continues.
+    active                  INTEGER NOT NULL DEFAULT 1 CHECK (active IN (0, 1)),
+    -- When the completion last joined the active branch. Switching branches continues with the most recently active
+    -- child at every level.
+    activated               TEXT    NOT NULL DEFAULT (STRFTIME('%Y-%m-%dT%H:%M:%fZ')) CHECK (length(activated) < 256),
 
+    created                 TEXT    NOT NULL DEFAULT (STRFTIME('%Y-%m-%dT%H:%M:%fZ')) CHECK (length(created) < 256),
+    updated                 TEXT    NOT NULL DEFAULT (STRFTIME('%Y-%m-%dT%H:%M:%fZ')) CHECK (length(updated) < 256),
+
+    parent_id               INTEGER REFERENCES completions (id) ON DELETE CASCADE,
     user_id                 BLOB    NOT NULL REFERENCES users (id) ON DELETE CASCADE,
     investigation_target_id TEXT    NOT NULL REFERENCES investigation_targets (id) ON DELETE CASCADE,
-    UNIQUE (user_id, investigation_target_id, "order")
+    -- The evidence the player presented with the question: a discovered clue or another target's statement.
+    evidence_clue_id        TEXT REFERENCES clues (id) ON DELETE SET NULL,
+    evidence_completion_id  INTEGER REFERENCES completions (id) ON DELETE SET NULL,
+    -- The point of interest of a scene the player examined with the question.
+    point_of_interest_id    TEXT REFERENCES points_of_interest (id) ON DELETE SET NULL,
+    -- The party the player was a member of when asking. The party's members see the completion.
+    party_id                INTEGER REFERENCES parties (id) ON DELETE SET NULL,
+
+    CHECK (evidence_clue_id IS NULL OR evidence_completion_id IS NULL)
+) STRICT;
+
+CREATE INDEX completions_parent_id_idx ON completions (parent_id);
+CREATE INDEX completions_party_id_idx ON completions (party_id);
+-- Keeps the active branch contiguous.
+CREATE UNIQUE INDEX completions_active_order_idx ON completions (user_id, investigation_target_id, "order")
+    WHERE active = 1;
+
+CREATE TRIGGER completions_updated_timestamp
+    AFTER UPDATE
+    ON completions
+BEGIN
+    UPDATE completions SET updated = STRFTIME('%Y-%m-%dT%H:%M:%fZ') WHERE id = old.id;
+END;
+
+CREATE TABLE discovered_clues
+(
+    user_id       BLOB    NOT NULL REFERENCES users (id) ON DELETE CASCADE,
+    clue_id       TEXT    NOT NULL REFERENCES clues (id) ON DELETE CASCADE,
+    -- The completion whose answer revealed the clue. The clue counts as discovered while the completion is on the
+    -- active branch and the discovery is forgotten with the completion.
+    completion_id INTEGER NOT NULL REFERENCES completions (id) ON DELETE CASCADE,
+
+    created       TEXT    NOT NULL DEFAULT (STRFTIME('%Y-%m-%dT%H:%M:%fZ')) CHECK (length(created) < 256),
+    PRIMARY KEY (completion_id, clue_id)
+) WITHOUT ROWID, STRICT;
+
+CREATE INDEX discovered_clues_user_id_clue_id_idx ON discovered_clues (user_id, clue_id);
+
+-- The chapters the player has unlocked. A chapter stays unlocked even if the player switches to a branch of the
+-- history where the prerequisites haven't been discovered.
+CREATE TABLE unlocked_chapters
+(
+    user_id       BLOB    NOT NULL REFERENCES users (id) ON DELETE CASCADE,
+    chapter_id    TEXT    NOT NULL REFERENCES chapters (id) ON DELETE CASCADE,
+    -- The completion after which the chapter was unlocked.
+    completion_id INTEGER REFERENCES completions (id) ON DELETE SET NULL,
+
+    created       TEXT    NOT NULL DEFAULT (STRFTIME('%Y-%m-%dT%H:%M:%fZ')) CHECK (length(created) < 256),
+    PRIMARY KEY (user_id, chapter_id)
+) WITHOUT ROWID, STRICT;
+
+-- The hints the player has asked Dupin for. Every hint counts against the score of the case.
+CREATE TABLE hints
+(
+    id      INTEGER PRIMARY KEY,
+    level   INTEGER NOT NULL CHECK (level BETWEEN 1 AND 3),
+    text    TEXT    NOT NULL CHECK (length(text) < 1024),
+
+    created TEXT    NOT NULL DEFAULT (STRFTIME('%Y-%m-%dT%H:%M:%fZ')) CHECK (length(created) < 256),
+
+    user_id BLOB    NOT NULL REFERENCES users (id) ON DELETE CASCADE,
+    case_id TEXT    NOT NULL REFERENCES cases (id) ON DELETE CASCADE,
+    -- The clue the hint points to. The hint keeps counting if the clue is removed from the case.
+    clue_id TEXT REFERENCES clues (id) ON DELETE SET NULL
+) STRICT;
+
+CREATE INDEX hints_user_id_case_id_idx ON hints (user_id, case_id);
+
+-- The people who could have committed the crime. An accusation names one of them as the culprit.
+CREATE TABLE suspects
+(
+    id          TEXT PRIMARY KEY CHECK (length(id) < 256),
+    name        TEXT NOT NULL CHECK (length(name) < 256),
+    description TEXT NOT NULL DEFAULT '' CHECK (length(description) < 1024),
+
+    case_id     TEXT NOT NULL REFERENCES cases (id) ON DELETE CASCADE
+) WITHOUT ROWID, STRICT;
+
+-- The solution the accusations are graded against. It's never shown to the AI so that no prompt can leak it.
+CREATE TABLE case_solutions
+(
+    case_id               TEXT PRIMARY KEY REFERENCES cases (id) ON DELETE CASCADE,
+    culprit_id            TEXT NOT NULL REFERENCES suspects (id) ON DELETE CASCADE,
+    motive                TEXT NOT NULL CHECK (length(motive) < 1024),
+    -- Comma-separated keywords of which the accused motive must mention at least one.
+    motive_keywords       TEXT NOT NULL CHECK (length(motive_keywords) < 256),
+    -- Comma-separated keywords of the plausible but wrong motives, none of which the accused motive may mention.
+    wrong_motive_keywords TEXT NOT NULL DEFAULT '' CHECK (length(wrong_motive_keywords) < 256),
+    -- Shown on the result page after the accusation.
+    explanation           TEXT NOT NULL CHECK (length(explanation) < 2048)
+) WITHOUT ROWID, STRICT;
+
+-- The clues supporting the solution.
+CREATE TABLE case_solution_clues
+(
+    case_id TEXT NOT NULL REFERENCES case_solutions (case_id) ON DELETE CASCADE,
+    clue_id TEXT NOT NULL REFERENCES clues (id) ON DELETE CASCADE,
+    PRIMARY KEY (case_id, clue_id)
+) WITHOUT ROWID, STRICT;
+
+-- The graded accusations. A player can accuse once per case, after which the case is solved.
+CREATE TABLE accusations
+(
+    id              INTEGER PRIMARY KEY,
+    motive          TEXT    NOT NULL CHECK (length(motive) < 1024),
+    culprit_correct INTEGER NOT NULL CHECK (culprit_correct IN (0, 1)),
+    motive_correct  INTEGER NOT NULL CHECK (motive_correct IN (0, 1)),
+    -- The number of answered questions on the active branches when accusing.
+    questions       INTEGER NOT NULL CHECK (questions >= 0),
+    -- The number of hints taken when accusing.
+    hints           INTEGER NOT NULL DEFAULT 0 CHECK (hints >= 0),
+    score           INTEGER NOT NULL CHECK (score >= 0),
+
+    created         TEXT    NOT NULL DEFAULT (STRFTIME('%Y-%m-%dT%H:%M:%fZ')) CHECK (length(created) < 256),
+
+    user_id         BLOB    NOT NULL REFERENCES users (id) ON DELETE CASCADE,
+    case_id         TEXT    NOT NULL REFERENCES cases (id) ON DELETE CASCADE,
+    culprit_id      TEXT    NOT NULL REFERENCES suspects (id) ON DELETE CASCADE
+) STRICT;
+
+CREATE UNIQUE INDEX accusations_user_id_case_id_idx ON accusations (user_id, case_id);
+
+-- The discovered clues the player presented to support the accusation.
+CREATE TABLE accusation_clues
+(
+    accusation_id INTEGER NOT NULL REFERENCES accusations (id) ON DELETE CASCADE,
+    clue_id       TEXT    NOT NULL REFERENCES clues (id) ON DELETE CASCADE,
+    -- Whether the clue supports the solution when accusing.
+    correct       INTEGER NOT NULL CHECK (correct IN (0, 1)),
+    PRIMARY KEY (accusation_id, clue_id)
+) WITHOUT ROWID, STRICT;
+
+-- AI usage events for enforcing the quotas. Questions are recorded when they're asked and tokens when the AI provider
+-- reports their usage.
+CREATE TABLE ai_usage
+(
+    id                INTEGER PRIMARY KEY,
+    questions         INTEGER NOT NULL DEFAULT 0 CHECK (questions >= 0),
+    prompt_tokens     INTEGER NOT NULL DEFAULT 0 CHECK (prompt_tokens >= 0),
+    completion_tokens INTEGER NOT NULL DEFAULT 0 CHECK (completion_tokens >= 0),
+
+    created           TEXT    NOT NULL DEFAULT (STRFTIME('%Y-%m-%dT%H:%M:%fZ')) CHECK (length(created) < 256),
+
+    user_id           BLOB    NOT NULL REFERENCES users (id) ON DELETE CASCADE
+) STRICT;
+
+CREATE INDEX ai_usage_created_idx ON ai_usage (created);
+CREATE INDEX ai_usage_user_id_created_idx ON ai_usage (user_id, created);
+
+-- Free-text notes in the player's notebook of a case.
+CREATE TABLE notes
+(
+    id      INTEGER PRIMARY KEY,
+    text    TEXT NOT NULL CHECK (length(text) < 2048),
+
+    created TEXT NOT NULL DEFAULT (STRFTIME('%Y-%m-%dT%H:%M:%fZ')) CHECK (length(created) < 256),
+    updated TEXT NOT NULL DEFAULT (STRFTIME('%Y-%m-%dT%H:%M:%fZ')) CHECK (length(updated) < 256),
+
+    user_id BLOB NOT NULL REFERENCES users (id) ON DELETE CASCADE,
+    case_id TEXT NOT NULL REFERENCES cases (id) ON DELETE CASCADE
+) STRICT;
+
+CREATE INDEX notes_user_id_case_id_idx ON notes (user_id, case_id);
+
+CREATE TRIGGER notes_updated_timestamp
+    AFTER UPDATE
+    ON notes
+BEGIN
+    UPDATE notes SET updated = STRFTIME('%Y-%m-%dT%H:%M:%fZ') WHERE id = old.id;
+END;
+
+-- The investigation targets a note is about.
+CREATE TABLE note_tags
+(
+    note_id                 INTEGER NOT NULL REFERENCES notes (id) ON DELETE CASCADE,
+    investigation_target_id TEXT    NOT NULL REFERENCES investigation_targets (id) ON DELETE CASCADE,
+    PRIMARY KEY (note_id, investigation_target_id)
+) WITHOUT ROWID, STRICT;
+
+-- Questions and answers the player has pinned to the notebook. The pins stay when switching branches.
+CREATE TABLE pinned_completions
+(
+    user_id       BLOB    NOT NULL REFERENCES users (id) ON DELETE CASCADE,
+    completion_id INTEGER NOT NULL REFERENCES completions (id) ON DELETE CASCADE,
+
+    created       TEXT    NOT NULL DEFAULT (STRFTIME('%Y-%m-%dT%H:%M:%fZ')) CHECK (length(created) < 256),
+    PRIMARY KEY (user_id, completion_id)
+) WITHOUT ROWID, STRICT;
+
+-- Discovered clues the player has pinned to the notebook. The pins are shown while the clues remain discovered.
+CREATE TABLE pinned_clues
+(
+    user_id BLOB NOT NULL REFERENCES users (id) ON DELETE CASCADE,
+    clue_id TEXT NOT NULL REFERENCES clues (id) ON DELETE CASCADE,
+
+    created TEXT NOT NULL DEFAULT (STRFTIME('%Y-%m-%dT%H:%M:%fZ')) CHECK (length(created) < 256),
+    PRIMARY KEY (user_id, clue_id)
+) WITHOUT ROWID, STRICT;
+
+-- The game mode the player chose when starting a case. Players who haven't chosen one play the relaxed mode.
+CREATE TABLE game_modes
+(
+    user_id BLOB NOT NULL REFERENCES users (id) ON DELETE CASCADE,
+    case_id TEXT NOT NULL REFERENCES cases (id) ON DELETE CASCADE,
+    mode    TEXT NOT NULL CHECK (mode IN ('relaxed', 'case-budget', 'target-budget', 'clock')),
+
+    created TEXT NOT NULL DEFAULT (STRFTIME('%Y-%m-%dT%H:%M:%fZ')) CHECK (length(created) < 256),
+    PRIMARY KEY (user_id, case_id)
+) WITHOUT ROWID, STRICT;
+
+-- The links sharing a read-only replay of the player's solved case. The links are signed with the share's ID and
+-- nonce and deleting the share revokes its link.
+CREATE TABLE shares
+(
+    id            INTEGER PRIMARY KEY,
+    -- SQLite reuses the ID of a deleted row so the random nonce keeps a revoked link from working for a new share.
+    nonce         TEXT    NOT NULL DEFAULT (lower(hex(randomblob(16)))) CHECK (length(nonce) < 256),
+    -- Whether the clues and the solution are blurred until the viewer reveals them.
+    blur_spoilers INTEGER NOT NULL CHECK (blur_spoilers IN (0, 1)),
+
+    created       TEXT    NOT NULL DEFAULT (STRFTIME('%Y-%m-%dT%H:%M:%fZ')) CHECK (length(created) < 256),
+
+    user_id       BLOB    NOT NULL REFERENCES users (id) ON DELETE CASCADE,
+    case_id       TEXT    NOT NULL REFERENCES cases (id) ON DELETE CASCADE
 ) STRICT;
+
+CREATE INDEX shares_user_id_case_id_idx ON shares (user_id, case_id);
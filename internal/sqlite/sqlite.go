@@ -17,8 +17,19 @@ import (
 //go:embed schema.sql
 var schemaDefinition string
 
-//go:embed fixtures.sql
-var fixtures string
+// backfills fill the columns added to the existing tables with data. The key is the table and the column, e.g.,
+// "completions.parent_id". A backfill runs once in the migration adding the column.
+var backfills = map[string]string{ //nolint:gochecknoglobals // constant
+	// Completions created before the parent pointers existed continue the completion with the previous order.
+	"completions.parent_id": `UPDATE completions
+SET parent_id = (SELECT parent.id
+                 FROM completions AS parent
+                 WHERE parent.user_id = completions.user_id
+                   AND parent.investigation_target_id = completions.investigation_target_id
+                   AND parent."order" = completions."order" - 1)
+WHERE parent_id IS NULL
+  AND "order" > 0`,
+}
 
 type Database struct {
 	ReadWrite *sql.DB
@@ -26,7 +37,7 @@ type Database struct {
 	logger    *slog.Logger
 }
 
-// NewDatabase connects to database, migrates the schema, and applies fixtures.
+// NewDatabase connects to database and migrates the schema.
 //
 // It establishes two database connections, one for read/write operations and one for read-only operations.
 // This is a best practice mentioned in https://github.com/mattn/go-sqlite3/issues/1179#issuecomment-1638083995
@@ -42,15 +53,10 @@ func NewDatabase(ctx context.Context, url string, logger *slog.Logger) (*Databas
 		return nil, errors.Wrap(err, "connect")
 	}
 
-	if err = db.migrateTo(ctx, schemaDefinition); err != nil {
+	if err = db.migrateTo(ctx, schemaDefinition, backfills); err != nil {
 		return nil, errors.Wrap(err, "migrateTo")
 	}
 
-	// Apply fixtures.
-	if _, err = db.ReadWrite.ExecContext(ctx, fixtures); err != nil {
-		return nil, errors.Wrap(err, "apply fixtures")
-	}
-
 	go db.startDatabaseOptimizer(ctx)
 
 	return db, nil
@@ -0,0 +1,292 @@
+// Package transcript exports the player's investigation of a case for reading and analysis.
+//
+// A Transcript is built from the models.Investigation of every investigation target the player can see. Its JSON
+// encoding is a stable, versioned schema: fields are only ever added, and changing or removing one bumps Version. The
+// transcript never contains the secrets of the targets nor the clues the player hasn't discovered.
+package transcript
+
+import (
+	_ "embed"
+	"github.com/myrjola/sheerluck/internal/errors"
+	"github.com/myrjola/sheerluck/internal/models"
+	"io"
+	"strings"
+	"text/template"
+	"time"
+)
+
+// Version is the version of the JSON schema of a Transcript.
+const Version = 1
+
+//go:embed transcript.md.gotmpl
+var markdownTemplate string
+
+var markdown = template.Must(template.New("transcript").Funcs(template.FuncMap{
+	"quote": quote,
+}).Parse(markdownTemplate))
+
+// Transcript is the player's investigation of a case.
+type Transcript struct {
+	Version  int       `json:"version"`
+	Exported time.Time `json:"exported"`
+	Case     Case      `json:"case"`
+	// Targets are the investigation targets in the order of the case overview. Locked targets are left out.
+	Targets []Target `json:"targets"`
+	// Clues are the discovered clues in the order of discovery.
+	Clues []Clue `json:"clues"`
+	// Notes are the notes of the notebook from the oldest to the newest.
+	Notes []Note `json:"notes"`
+	// Hints are the hints the player has taken in the order they were given.
+	Hints []Hint `json:"hints"`
+	// Accusation is nil while the case is still open.
+	Accusation *Accusation `json:"accusation"`
+}
+
+type Case struct {
+	ID     string `json:"id"`
+	Name   string `json:"name"`
+	Author string `json:"author"`
+}
+
+type Target struct {
+	ID          string `json:"id"`
+	Name        string `json:"name"`
+	Type        string `json:"type"`
+	Description string `json:"description"`
+	// Completions are the answered questions on the active branch of the history.
+	Completions []Completion `json:"completions"`
+}
+
+type Completion struct {
+	ID       int64  `json:"id"`
+	Question string `json:"question"`
+	Answer   string `json:"answer"`
+	// Evidence is the description of the clue or the statement the player presented, or empty.
+	Evidence string `json:"evidence"`
+	// Examined is the name of the point of interest the player examined, or empty.
+	Examined string `json:"examined"`
+	// Clues are the IDs of the clues the answer revealed.
+	Clues  []string `json:"clues"`
+	Pinned bool     `json:"pinned"`
+}
+
+type Clue struct {
+	ID          string `json:"id"`
+	Description string `json:"description"`
+	// TargetID is the investigation target that revealed the clue.
+	TargetID     string    `json:"target_id"`
+	CompletionID int64     `json:"completion_id"`
+	Discovered   time.Time `json:"discovered"`
+	Pinned       bool      `json:"pinned"`
+}
+
+type Note struct {
+	Text string `json:"text"`
+	// Tags are the IDs of the investigation targets the note is about.
+	Tags    []string  `json:"tags"`
+	Created time.Time `json:"created"`
+	Updated time.Time `json:"updated"`
+}
+
+type Hint struct {
+	// ClueID is the clue the hint is about or empty if it has since been removed from the case.
+	ClueID  string    `json:"clue_id"`
+	Level   int       `json:"level"`
+	Text    string    `json:"text"`
+	Created time.Time `json:"created"`
+}
+
+type Accusation struct {
+	CulpritID      string        `json:"culprit_id"`
+	Culprit        string        `json:"culprit"`
+	Motive         string        `json:"motive"`
+	Clues          []AccusedClue `json:"clues"`
+	Questions      int           `json:"questions"`
+	Hints          int           `json:"hints"`
+	CulpritCorrect bool          `json:"culprit_correct"`
+	MotiveCorrect  bool          `json:"motive_correct"`
+	Score          int           `json:"score"`
+	Created        time.Time     `json:"created"`
+}
+
+type AccusedClue struct {
+	ID          string `json:"id"`
+	Description string `json:"description"`
+	Correct     bool   `json:"correct"`
+}
+
+// New builds the transcript of the player's investigations of the case in the overview. The investigations of locked
+// targets must be left out. The accusation is nil if the player hasn't made one.
+func New(
+	overview models.CaseOverview,
+	investigations []models.Investigation,
+	notebook models.Notebook,
+	accusation *models.Accusation,
+	exported time.Time,
+) Transcript {
+	return Transcript{
+		Version:  Version,
+		Exported: exported.UTC(),
+		Case: Case{
+			ID:     overview.Case.ID,
+			Name:   overview.Case.Name,
+			Author: overview.Case.Author,
+		},
+		Targets:    newTargets(investigations, overview.DiscoveredClues, notebook),
+		Clues:      newClues(overview.DiscoveredClues, notebook),
+		Notes:      newNotes(notebook.Notes),
+		Hints:      newHints(overview.Hints),
+		Accusation: newAccusation(accusation),
+	}
+}
+
+func newTargets(
+	investigations []models.Investigation,
+	discovered []models.DiscoveredClue,
+	notebook models.Notebook,
+) []Target {
+	revealed := make(map[int64][]string)
+	for _, clue := range discovered {
+		revealed[clue.CompletionID] = append(revealed[clue.CompletionID], clue.Clue.ID)
+	}
+	targets := make([]Target, 0, len(investigations))
+	for _, investigation := range investigations {
+		completions := make([]Completion, 0, len(investigation.Completions))
+		for _, completion := range investigation.Completions {
+			if completion.Status != models.CompletionStatusDone {
+				continue
+			}
+			clues := revealed[completion.ID]
+			if clues == nil {
+				clues = []string{}
+			}
+			completions = append(completions, Completion{
+				ID:       completion.ID,
+				Question: completion.Question,
+				Answer:   completion.Answer,
+				Evidence: evidence(completion.Evidence),
+				Examined: examined(completion.Examined),
+				Clues:    clues,
+				Pinned:   notebook.HasPinnedCompletion(completion.ID),
+			})
+		}
+		targets = append(targets, Target{
+			ID:          investigation.Target.ID,
+			Name:        investigation.Target.Name,
+			Type:        string(investigation.Target.Type),
+			Description: investigation.Target.Description,
+			Completions: completions,
+		})
+	}
+	return targets
+}
+
+func evidence(e *models.Evidence) string {
+	switch {
+	case e == nil:
+		return ""
+	case e.Clue != nil:
+		return e.Clue.Description
+	case e.Statement != nil:
+		return e.Statement.Target.Name + " answered “" + e.Statement.Question + "”: " + e.Statement.Answer
+	default:
+		return ""
+	}
+}
+
+func examined(poi *models.PointOfInterest) string {
+	if poi == nil {
+		return ""
+	}
+	return poi.Name
+}
+
+func newClues(discovered []models.DiscoveredClue, notebook models.Notebook) []Clue {
+	clues := make([]Clue, 0, len(discovered))
+	for _, clue := range discovered {
+		clues = append(clues, Clue{
+			ID:           clue.Clue.ID,
+			Description:  clue.Clue.Description,
+			TargetID:     clue.InvestigationTargetID,
+			CompletionID: clue.CompletionID,
+			Discovered:   clue.Discovered.UTC(),
+			Pinned:       notebook.HasPinnedClue(clue.Clue.ID),
+		})
+	}
+	return clues
+}
+
+func newNotes(notes []models.Note) []Note {
+	transcribed := make([]Note, 0, len(notes))
+	// The notebook lists the newest note first but a transcript reads from the start.
+	for i := len(notes) - 1; i >= 0; i-- {
+		tags := make([]string, 0, len(notes[i].Tags))
+		for _, tag := range notes[i].Tags {
+			tags = append(tags, tag.ID)
+		}
+		transcribed = append(transcribed, Note{
+			Text:    notes[i].Text,
+			Tags:    tags,
+			Created: notes[i].Created.UTC(),
+			Updated: notes[i].Updated.UTC(),
+		})
+	}
+	return transcribed
+}
+
+func newHints(hints []models.Hint) []Hint {
+	transcribed := make([]Hint, 0, len(hints))
+	for _, hint := range hints {
+		transcribed = append(transcribed, Hint{
+			ClueID:  hint.ClueID,
+			Level:   int(hint.Level),
+			Text:    hint.Text,
+			Created: hint.Created.UTC(),
+		})
+	}
+	return transcribed
+}
+
+func newAccusation(accusation *models.Accusation) *Accusation {
+	if accusation == nil {
+		return nil
+	}
+	clues := make([]AccusedClue, 0, len(accusation.Clues))
+	for _, clue := range accusation.Clues {
+		clues = append(clues, AccusedClue{
+			ID:          clue.Clue.ID,
+			Description: clue.Clue.Description,
+			Correct:     clue.Correct,
+		})
+	}
+	return &Accusation{
+		CulpritID:      accusation.Culprit.ID,
+		Culprit:        accusation.Culprit.Name,
+		Motive:         accusation.Motive,
+		Clues:          clues,
+		Questions:      accusation.Questions,
+		Hints:          accusation.Hints,
+		CulpritCorrect: accusation.CulpritCorrect,
+		MotiveCorrect:  accusation.MotiveCorrect,
+		Score:          accusation.Score,
+		Created:        accusation.Created.UTC(),
+	}
+}
+
+// WriteMarkdown writes the transcript to w as a Markdown document.
+func (t Transcript) WriteMarkdown(w io.Writer) error {
+	if err := markdown.Execute(w, t); err != nil {
+		return errors.Wrap(err, "execute markdown template")
+	}
+	return nil
+}
+
+// quote formats text as a Markdown block quote so that the player's and the AI's text can't break the document
+// structure.
+func quote(text string) string {
+	lines := strings.Split(strings.TrimSpace(text), "\n")
+	for i, line := range lines {
+		lines[i] = strings.TrimRight("> "+line, " ")
+	}
+	return strings.Join(lines, "\n")
+}
@@ -0,0 +1,162 @@
+package transcript_test
+
+import (
+	"bytes"
+	"encoding/json"
+	"github.com/myrjola/sheerluck/internal/models"
+	"github.com/myrjola/sheerluck/internal/transcript"
+	"github.com/stretchr/testify/require"
+	"testing"
+	"time"
+)
+
+func newTranscript(accusation *models.Accusation) transcript.Transcript {
+	rueMorgue := models.Case{
+		ID:              "rue-morgue",
+		Name:            "The Murders in the Rue Morgue",
+		Author:          "Edgar Allan Poe",
+		ImagePath:       "",
+		Setting:         "",
+		Difficulty:      models.CaseDifficultyEasy,
+		PlayTimeMinutes: 0,
+		Blurb:           "",
+	}
+	leBon := models.InvestigationTarget{
+		ID:          "le-bon",
+		Name:        "Adolphe Le Bon",
+		ShortName:   "Adolphe",
+		Type:        models.InvestigationTargetTypePerson,
+		ImagePath:   "",
+		Description: "A bank clerk.",
+		Secret:      "He is hiding the delivery.",
+	}
+	goldClue := models.Clue{
+		ID:               "untouched-gold",
+		Description:      "The gold was left untouched.",
+		Keywords:         nil,
+		RequiresEvidence: nil,
+		PointOfInterest:  nil,
+	}
+	discovered := time.Date(2024, 10, 1, 12, 0, 0, 0, time.UTC)
+	completion := models.Completion{
+		ID:           2,
+		ParentID:     nil,
+		Order:        1,
+		Status:       models.CompletionStatusDone,
+		Question:     "Was anything stolen?",
+		Answer:       "No.\nThe gold was left untouched.",
+		Summary:      "",
+		Alternatives: nil,
+		Evidence:     &models.Evidence{Clue: &goldClue, Statement: nil},
+		Examined:     nil,
+	}
+	failed := completion
+	failed.ID = 3
+	failed.Status = models.CompletionStatusError
+	failed.Question = "Who did it?"
+	return transcript.New(
+		models.CaseOverview{
+			Case:    rueMorgue,
+			Targets: nil,
+			DiscoveredClues: []models.DiscoveredClue{
+				{Clue: goldClue, InvestigationTargetID: "le-bon", CompletionID: 2, Discovered: discovered},
+			},
+			Hints: nil,
+		},
+		[]models.Investigation{{
+			Case:        rueMorgue,
+			Target:      leBon,
+			Completions: []models.Completion{completion, failed},
+			Clues: []models.Clue{{
+				ID:               "secret",
+				Description:      "Undiscovered.",
+				Keywords:         nil,
+				RequiresEvidence: nil,
+				PointOfInterest:  nil,
+			}},
+			DiscoveredClues:  nil,
+			PointsOfInterest: nil,
+			Passages:         nil,
+			Locked:           false,
+		}},
+		models.Notebook{
+			Notes: []models.Note{
+				{ID: 2, Text: "Newer note.", Tags: []models.InvestigationTarget{leBon}, Created: discovered, Updated: discovered},
+				{ID: 1, Text: "Older note.", Tags: nil, Created: discovered, Updated: discovered},
+			},
+			PinnedCompletions: nil,
+			PinnedClues:       []models.PinnedClue{{Clue: goldClue, InvestigationTargetID: "le-bon", Pinned: discovered}},
+		},
+		accusation,
+		discovered,
+	)
+}
+
+func TestTranscript_JSON(t *testing.T) {
+	t.Parallel()
+	body, err := json.Marshal(newTranscript(nil))
+	require.NoError(t, err)
+	require.JSONEq(t, `{
+  "version": 1,
+  "exported": "2024-10-01T12:00:00Z",
+  "case": {"id": "rue-morgue", "name": "The Murders in the Rue Morgue", "author": "Edgar Allan Poe"},
+  "targets": [{
+    "id": "le-bon",
+    "name": "Adolphe Le Bon",
+    "type": "person",
+    "description": "A bank clerk.",
+    "completions": [{
+      "id": 2,
+      "question": "Was anything stolen?",
+      "answer": "No.\nThe gold was left untouched.",
+      "evidence": "The gold was left untouched.",
+      "examined": "",
+      "clues": ["untouched-gold"],
+      "pinned": false
+    }]
+  }],
+  "clues": [{
+    "id": "untouched-gold",
+    "description": "The gold was left untouched.",
+    "target_id": "le-bon",
+    "completion_id": 2,
+    "discovered": "2024-10-01T12:00:00Z",
+    "pinned": true
+  }],
+  "notes": [
+    {"text": "Older note.", "tags": [], "created": "2024-10-01T12:00:00Z", "updated": "2024-10-01T12:00:00Z"},
+    {"text": "Newer note.", "tags": ["le-bon"], "created": "2024-10-01T12:00:00Z", "updated": "2024-10-01T12:00:00Z"}
+  ],
+  "hints": [],
+  "accusation": null
+}`, string(body), "the schema is stable and leaves out the secrets, the undiscovered clues and the failed answers")
+}
+
+func TestTranscript_WriteMarkdown(t *testing.T) {
+	t.Parallel()
+	accusation := &models.Accusation{
+		ID:             1,
+		CaseID:         "rue-morgue",
+		Culprit:        models.Suspect{ID: "le-bon", Name: "Adolphe Le Bon", Description: ""},
+		Motive:         "Greed.",
+		Clues:          nil,
+		Questions:      1,
+		Hints:          0,
+		CulpritCorrect: false,
+		MotiveCorrect:  false,
+		Score:          0,
+		Created:        time.Time{},
+	}
+	var buf bytes.Buffer
+	require.NoError(t, newTranscript(accusation).WriteMarkdown(&buf))
+	markdown := buf.String()
+	require.Contains(t, markdown, "# The Murders in the Rue Morgue\n")
+	require.Contains(t, markdown, "## Adolphe Le Bon\n")
+	require.Contains(t, markdown, "**Presented:** The gold was left untouched.\n\n**Q:** Was anything stolen?\n\n"+
+		"> No.\n> The gold was left untouched.\n")
+	require.Contains(t, markdown, "- The gold was left untouched.")
+	require.Contains(t, markdown, "> Older note.\n\n> Newer note.")
+	require.Contains(t, markdown, "- Culprit: Adolphe Le Bon\n- Motive: Greed.\n\nScore 0 after 1 questions and 0 hints.")
+	require.NotContains(t, markdown, "Who did it?")
+	require.NotContains(t, markdown, "## Hints")
+}